@@ -27,22 +27,68 @@ type ConnectorInspectResponse struct {
 type SiteConfig struct {
 	Spec      SiteConfigSpec
 	Reference SiteConfigReference
+	// SiteId identifies the site to the router network; it is the UID
+	// of the skupper-site config map, unless an identity has been
+	// restored from a backup
+	SiteId string
 }
 
 type SiteConfigSpec struct {
-	SkupperName         string
-	SkupperNamespace    string
-	IsEdge              bool
-	EnableController    bool
-	EnableServiceSync   bool
-	EnableRouterConsole bool
-	EnableConsole       bool
-	AuthMode            string
-	User                string
-	Password            string
-	ClusterLocal        bool
-	Replicas            int32
-	SiteControlled      bool
+	SkupperName         string `json:"name,omitempty"`
+	SkupperNamespace    string `json:"-"`
+	IsEdge              bool   `json:"edge,omitempty"`
+	EnableController    bool   `json:"serviceController"`
+	EnableServiceSync   bool   `json:"serviceSync"`
+	EnableRouterConsole bool   `json:"routerConsole,omitempty"`
+	EnableConsole       bool   `json:"console,omitempty"`
+	AuthMode            string `json:"consoleAuthentication,omitempty"`
+	User                string `json:"consoleUser,omitempty"`
+	Password            string `json:"consolePassword,omitempty"`
+	ClusterLocal        bool   `json:"clusterLocal,omitempty"`
+	Replicas            int32  `json:"routers,omitempty"`
+	SiteControlled      bool   `json:"-"`
+	// Ingress determines how the site accepts links from other
+	// sites; if empty a route is used where supported, otherwise a
+	// load balancer (or none if ClusterLocal is set)
+	Ingress string `json:"ingress,omitempty"`
+	// IngressHost, IngressInterRouterPort and IngressEdgePort, if set,
+	// override the host and ports advertised in connection tokens
+	IngressHost            string      `json:"ingressHost,omitempty"`
+	IngressInterRouterPort int         `json:"ingressInterRouterPort,omitempty"`
+	IngressEdgePort        int         `json:"ingressEdgePort,omitempty"`
+	Router                 PodSettings `json:"router,omitempty"`
+	Controller             PodSettings `json:"controller,omitempty"`
+	ImagePullSecrets       []string    `json:"imagePullSecrets,omitempty"`
+	// KeepCertificateAuthorities retains the CA secrets when the site
+	// is torn down, so that a reinstalled site keeps its identity
+	KeepCertificateAuthorities bool `json:"keepCertificateAuthorities,omitempty"`
+}
+
+// PodSettings holds the scheduling, resource and security settings
+// for the pods of the router or controller
+type PodSettings struct {
+	Resources                corev1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector             map[string]string           `json:"nodeSelector,omitempty"`
+	Tolerations              []corev1.Toleration         `json:"tolerations,omitempty"`
+	Affinity                 *corev1.Affinity            `json:"affinity,omitempty"`
+	PriorityClassName        string                      `json:"priorityClassName,omitempty"`
+	PodSecurityContext       *corev1.PodSecurityContext  `json:"podSecurityContext,omitempty"`
+	ContainerSecurityContext *corev1.SecurityContext     `json:"securityContext,omitempty"`
+	SidecarResources         corev1.ResourceRequirements `json:"sidecarResources,omitempty"`
+}
+
+// GetIngress returns the ingress strategy in effect for the site
+func (s *SiteConfigSpec) GetIngress(routeSupported bool) string {
+	if s.Ingress != "" {
+		return s.Ingress
+	}
+	if s.ClusterLocal {
+		return IngressNoneString
+	}
+	if routeSupported {
+		return IngressRouteString
+	}
+	return IngressLoadBalancerString
 }
 
 type SiteConfigReference struct {
@@ -52,6 +98,57 @@ type SiteConfigReference struct {
 	Kind       string
 }
 
+// Identifies the version of the site manifest format
+const (
+	SiteManifestAPIVersion string = "skupper.io/v1alpha1"
+	SiteManifestKind       string = "SiteManifest"
+)
+
+// SiteManifest describes a site declaratively: its configuration, the
+// links it makes to other sites and the services it defines. It is
+// produced from a namespace by SiteManifestExport, and a namespace is
+// brought into line with it by SiteManifestApply.
+type SiteManifest struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Site       SiteConfigSpec     `json:"site"`
+	Links      []SiteManifestLink `json:"links,omitempty"`
+	Services   []ServiceInterface `json:"services,omitempty"`
+}
+
+// SiteManifestLink refers to the token from which a link is made. The
+// token itself is not held in the manifest, as it is a credential.
+type SiteManifestLink struct {
+	Name string `json:"name"`
+	// Token is the path of the token file, needed only when the
+	// link is created
+	Token string `json:"token,omitempty"`
+	Cost  int32  `json:"cost,omitempty"`
+}
+
+// Actions taken to bring a namespace into line with a site manifest
+const (
+	ManifestCreate string = "create"
+	ManifestUpdate string = "update"
+	ManifestDelete string = "delete"
+)
+
+// Kinds of object described by a site manifest
+const (
+	ManifestSite    string = "site"
+	ManifestLink    string = "link"
+	ManifestService string = "service"
+)
+
+// SiteManifestChange describes one of the changes needed to bring a
+// namespace into line with a site manifest
+type SiteManifestChange struct {
+	Action  string
+	Kind    string
+	Name    string
+	Details []string
+}
+
 type ServiceInterfaceCreateOptions struct {
 	Protocol   string
 	Address    string
@@ -68,6 +165,22 @@ type RouterInspectResponse struct {
 	ConsoleUrl        string
 }
 
+// Outcomes of the checks made by SkupperCheck
+const (
+	CheckPass string = "pass"
+	CheckWarn string = "warn"
+	CheckFail string = "fail"
+)
+
+// CheckResult is the outcome of one of the checks made of a site, with
+// a hint as to how to put right any problem found
+type CheckResult struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
 type VanClientInterface interface {
 	RouterCreate(ctx context.Context, options SiteConfig) error
 	RouterInspect(ctx context.Context) (*RouterInspectResponse, error)
@@ -85,12 +198,19 @@ type VanClientInterface interface {
 	ServiceInterfaceList(ctx context.Context) ([]*ServiceInterface, error)
 	ServiceInterfaceRemove(ctx context.Context, address string) error
 	ServiceInterfaceUpdate(ctx context.Context, service *ServiceInterface) error
+	ServiceInterfaceExternalUrl(ctx context.Context, service *ServiceInterface) (string, error)
 	ServiceInterfaceBind(ctx context.Context, service *ServiceInterface, targetType string, targetName string, protocol string, targetPort int) error
 	GetHeadlessServiceConfiguration(targetName string, protocol string, address string, port int) (*ServiceInterface, error)
 	ServiceInterfaceUnbind(ctx context.Context, targetType string, targetName string, address string, deleteIfNoTargets bool) error
 	SiteConfigCreate(ctx context.Context, spec SiteConfigSpec) (*SiteConfig, error)
 	SiteConfigInspect(ctx context.Context, input *corev1.ConfigMap) (*SiteConfig, error)
 	SiteConfigRemove(ctx context.Context) error
-	SkupperDump(ctx context.Context, tarName string, version string, kubeConfigPath string, kubeConfigContext string) error
+	SkupperDump(ctx context.Context, tarName string, version string, redact bool) error
+	SiteManifestExport(ctx context.Context) (*SiteManifest, error)
+	SiteManifestDiff(ctx context.Context, manifest *SiteManifest, prune bool) ([]SiteManifestChange, error)
+	SiteManifestApply(ctx context.Context, manifest *SiteManifest, prune bool) ([]SiteManifestChange, error)
+	SiteBackup(ctx context.Context, file string, passphrase string) error
+	SiteRestore(ctx context.Context, file string, passphrase string) error
+	SkupperCheck(ctx context.Context) ([]CheckResult, error)
 	GetNamespace() string
 }
@@ -15,8 +15,12 @@ limitations under the License.
 package types
 
 import (
+	"fmt"
+
 	routev1 "github.com/openshift/api/route/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
@@ -74,6 +78,7 @@ const (
 	ControllerServiceAccountName string = "skupper-proxy-controller"
 	ControllerConfigPath         string = "/etc/messaging/"
 	ControllerEditRoleName       string = "skupper-edit"
+	ControllerHealthPort         int32  = 8081
 )
 
 var ControllerEditPolicyRule = []rbacv1.PolicyRule{
@@ -88,10 +93,25 @@ var ControllerEditPolicyRule = []rbacv1.PolicyRule{
 		Resources: []string{"deployments", "statefulsets"},
 	},
 	{
-		Verbs:     []string{"get", "list", "watch"},
+		Verbs:     []string{"get", "create", "update", "delete"},
+		APIGroups: []string{""},
+		Resources: []string{"secrets"},
+	},
+	{
+		Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
 		APIGroups: []string{"route.openshift.io"},
 		Resources: []string{"routes"},
 	},
+	{
+		Verbs:     []string{"get", "list", "watch", "create", "update", "delete"},
+		APIGroups: []string{"networking.k8s.io"},
+		Resources: []string{"ingresses"},
+	},
+	{
+		Verbs:     []string{"create", "patch"},
+		APIGroups: []string{""},
+		Resources: []string{"events"},
+	},
 }
 
 // Skupper qualifiers
@@ -100,6 +120,7 @@ const (
 	InternalQualifier           string = "internal." + BaseQualifier
 	AddressQualifier            string = BaseQualifier + "/address"
 	PortQualifier               string = BaseQualifier + "/port"
+	DistributionQualifier       string = BaseQualifier + "/distribution"
 	ProxyQualifier              string = BaseQualifier + "/proxy"
 	TargetServiceQualifier      string = BaseQualifier + "/target"
 	ControlledQualifier         string = InternalQualifier + "/controlled"
@@ -108,6 +129,9 @@ const (
 	OriginalSelectorQualifier   string = InternalQualifier + "/originalSelector"
 	OriginalTargetPortQualifier string = InternalQualifier + "/originalTargetPort"
 	OriginalAssignedQualifier   string = InternalQualifier + "/originalAssignedPort"
+	AppliedLabelsQualifier      string = InternalQualifier + "/appliedLabels"
+	AppliedAnnotationsQualifier string = InternalQualifier + "/appliedAnnotations"
+	ExternalForQualifier        string = InternalQualifier + "/external-for"
 	InternalTypeQualifier       string = InternalQualifier + "/type"
 	SkupperTypeQualifier        string = BaseQualifier + "/type"
 	TypeProxyQualifier          string = InternalTypeQualifier + "=proxy"
@@ -116,11 +140,62 @@ const (
 	TypeTokenRequestQualifier   string = BaseQualifier + "/type=connection-token-request"
 	TokenGeneratedBy            string = BaseQualifier + "/generated-by"
 	TokenCost                   string = BaseQualifier + "/cost"
+	TokenRouterReplicas         string = BaseQualifier + "/router-replicas"
+	TokenSiteName               string = BaseQualifier + "/site-name"
+	// SiteIdQualifier, set on the skupper-site config map, gives an
+	// identity restored from a backup which the site adopts in place
+	// of the UID of the config map
+	SiteIdQualifier string = BaseQualifier + "/site-id"
+)
+
+// Annotations through which the site controller reports on the tokens,
+// token requests and skupper-site config maps it has processed
+const (
+	StatusQualifier        string = BaseQualifier + "/status"
+	StatusMessageQualifier string = BaseQualifier + "/status-message"
+	StatusTimeQualifier    string = BaseQualifier + "/status-time"
+	RemoteSiteQualifier    string = BaseQualifier + "/remote-site"
+)
+
+// Values of the status annotation
+const (
+	StatusAccepted   string = "accepted"
+	StatusConnecting string = "connecting"
+	StatusConnected  string = "connected"
+	StatusGenerated  string = "generated"
+	StatusReady      string = "ready"
+	StatusFailed     string = "failed"
 )
 
 // Service Interface constants
 const (
 	ServiceInterfaceConfigMap string = "skupper-services"
+	// ServiceDefinitionShardsQualifier, set on skupper-services once
+	// the definitions have been moved out of it, gives the number of
+	// config maps, each labelled with TypeServiceDefinitionsQualifier,
+	// amongst which they are spread
+	ServiceDefinitionShardsQualifier string = InternalQualifier + "/service-definition-shards"
+	TypeServiceDefinitions           string = "service-definitions"
+	TypeServiceDefinitionsQualifier  string = InternalTypeQualifier + "=" + TypeServiceDefinitions
+)
+
+// Weight and priority of service targets. Weights are relative
+// shares of traffic (0 is treated as 1); those of targets are relative
+// to the other targets of the service in the same site. Lower
+// priorities are preferred; targets at a higher priority only receive
+// traffic when no target at a lower one is available. At the site
+// level, only sites with a non-zero priority take part in failover,
+// and a site only claims its priority while it has a target available.
+const (
+	MaxServiceWeight int = 100
+)
+
+// Service distribution modes
+const (
+	// DistributionBalanced spreads load across all targets in the network
+	DistributionBalanced string = "balanced"
+	// DistributionClosest prefers the targets with the lowest cost from the client
+	DistributionClosest string = "closest"
 )
 
 // OpenShift constants
@@ -161,6 +236,93 @@ const (
 	InterRouterProfile      string = "skupper-internal"
 )
 
+// Site ingress constants
+const (
+	IngressRouteString           string = "route"
+	IngressLoadBalancerString    string = "loadbalancer"
+	IngressNodePortString        string = "nodeport"
+	IngressNginxIngressString    string = "nginx-ingress"
+	IngressNoneString            string = "none"
+	IngressPassthroughAnnotation string = "nginx.ingress.kubernetes.io/ssl-passthrough"
+)
+
+func ValidIngressOptions() []string {
+	return []string{IngressRouteString, IngressLoadBalancerString, IngressNodePortString, IngressNginxIngressString, IngressNoneString}
+}
+
+func IsValidIngress(ingress string) bool {
+	for _, option := range ValidIngressOptions() {
+		if ingress == option {
+			return true
+		}
+	}
+	return false
+}
+
+// GetIngressHostForRole returns the host name under which the
+// inter-router or edge listener is exposed through an nginx ingress
+func GetIngressHostForRole(role string, namespace string, ingressHost string) string {
+	return role + "-" + namespace + "." + ingressHost
+}
+
+// Router replica constants. When the router has more than one replica,
+// the service controller labels each router pod with its index. Each
+// replica can then be reached through its own services, and the
+// replicas of interior sites link to each other.
+const (
+	RouterReplicaQualifier string = BaseQualifier + "/router-replica"
+	ReplicaConnectorPrefix string = "skupper-replica-"
+	// InternalCertHostsQualifier, set on the router's pod template,
+	// records the hosts of the skupper-internal certificate, so that
+	// the router restarts when they change
+	InternalCertHostsQualifier string = InternalQualifier + "/internal-cert-hosts"
+)
+
+// GetRouterReplicaServiceName returns the name of the headless service
+// for the router replica with the given index, through which the other
+// replicas link to it
+func GetRouterReplicaServiceName(index int) string {
+	return fmt.Sprintf("%s-%d", TransportDeploymentName, index)
+}
+
+// GetInterRouterReplicaServiceName returns the name of the service
+// exposing the router replica with the given index through a load
+// balancer or node ports
+func GetInterRouterReplicaServiceName(index int) string {
+	return fmt.Sprintf("%s-%d", InterRouterProfile, index)
+}
+
+// GetReplicaRouteName returns the name of the route, or nginx ingress,
+// for the router replica with the given index
+func GetReplicaRouteName(name string, index int) string {
+	return fmt.Sprintf("%s-%d", name, index)
+}
+
+// GetReplicaConnectorName returns the name of the connector through
+// which a router replica links to the one with the given index
+func GetReplicaConnectorName(index int) string {
+	return fmt.Sprintf("%s%d", ReplicaConnectorPrefix, index)
+}
+
+// Service TLS constants
+const (
+	ServiceCaSecret           string = "skupper-service-ca"
+	ServiceTlsProfilePrefix   string = "skupper-tls-"
+	ServiceTrustProfilePrefix string = "skupper-trust-"
+)
+
+// GetServiceTlsProfile returns the name of the ssl profile, and of the
+// secret holding its certificate, used to terminate TLS for an address
+func GetServiceTlsProfile(address string) string {
+	return ServiceTlsProfilePrefix + address
+}
+
+// GetServiceTrustProfile returns the name of the ssl profile used to
+// verify targets against the CA in the named secret
+func GetServiceTrustProfile(caSecret string) string {
+	return ServiceTrustProfilePrefix + caSecret
+}
+
 // Service Sync constants
 const (
 	ServiceSyncAddress = "mc/$skupper-service-sync"
@@ -181,21 +343,32 @@ type RouterSpec struct {
 
 // DeploymentSpec for the VAN router or controller components to run within a cluster
 type DeploymentSpec struct {
-	Image           string                   `json:"image,omitempty"`
-	Replicas        int32                    `json:"replicas,omitempty"`
-	LivenessPort    int32                    `json:"livenessPort,omitempty"`
-	Labels          map[string]string        `json:"labels,omitempty"`
-	Annotations     map[string]string        `json:"annotations,omitempty"`
-	EnvVar          []corev1.EnvVar          `json:"envVar,omitempty"`
-	Ports           []corev1.ContainerPort   `json:"ports,omitempty"`
-	Volumes         []corev1.Volume          `json:"volumes,omitempty"`
-	VolumeMounts    [][]corev1.VolumeMount   `json:"volumeMounts,omitempty"`
-	Roles           []*rbacv1.Role           `json:"roles,omitempty"`
-	RoleBindings    []*rbacv1.RoleBinding    `json:"roleBinding,omitempty"`
-	Routes          []*routev1.Route         `json:"routes,omitempty"`
-	ServiceAccounts []*corev1.ServiceAccount `json:"serviceAccounts,omitempty"`
-	Services        []*corev1.Service        `json:"services,omitempty"`
-	Sidecars        []*corev1.Container      `json:"sidecars,omitempty"`
+	Image                    string                             `json:"image,omitempty"`
+	Replicas                 int32                              `json:"replicas,omitempty"`
+	LivenessPort             int32                              `json:"livenessPort,omitempty"`
+	Labels                   map[string]string                  `json:"labels,omitempty"`
+	Annotations              map[string]string                  `json:"annotations,omitempty"`
+	EnvVar                   []corev1.EnvVar                    `json:"envVar,omitempty"`
+	Ports                    []corev1.ContainerPort             `json:"ports,omitempty"`
+	Volumes                  []corev1.Volume                    `json:"volumes,omitempty"`
+	VolumeMounts             [][]corev1.VolumeMount             `json:"volumeMounts,omitempty"`
+	Roles                    []*rbacv1.Role                     `json:"roles,omitempty"`
+	RoleBindings             []*rbacv1.RoleBinding              `json:"roleBinding,omitempty"`
+	Routes                   []*routev1.Route                   `json:"routes,omitempty"`
+	Ingresses                []*networkingv1beta1.Ingress       `json:"ingresses,omitempty"`
+	PodDisruptionBudget      *policyv1beta1.PodDisruptionBudget `json:"podDisruptionBudget,omitempty"`
+	ServiceAccounts          []*corev1.ServiceAccount           `json:"serviceAccounts,omitempty"`
+	Services                 []*corev1.Service                  `json:"services,omitempty"`
+	Sidecars                 []*corev1.Container                `json:"sidecars,omitempty"`
+	Resources                corev1.ResourceRequirements        `json:"resources,omitempty"`
+	NodeSelector             map[string]string                  `json:"nodeSelector,omitempty"`
+	Tolerations              []corev1.Toleration                `json:"tolerations,omitempty"`
+	Affinity                 *corev1.Affinity                   `json:"affinity,omitempty"`
+	PriorityClassName        string                             `json:"priorityClassName,omitempty"`
+	PodSecurityContext       *corev1.PodSecurityContext         `json:"podSecurityContext,omitempty"`
+	ContainerSecurityContext *corev1.SecurityContext            `json:"containerSecurityContext,omitempty"`
+	ImagePullSecrets         []corev1.LocalObjectReference      `json:"imagePullSecrets,omitempty"`
+	SidecarResources         corev1.ResourceRequirements        `json:"sidecarResources,omitempty"`
 }
 
 // AssemblySpec for the links and connectors that form the VAN topology
@@ -214,6 +387,7 @@ type AssemblySpec struct {
 type RouterStatusSpec struct {
 	SiteName               string                  `json:"siteName,omitempty"`
 	Mode                   string                  `json:"mode,omitempty"`
+	TransportReplicas      int32                   `json:"transportReplicas,omitempty"`
 	TransportReadyReplicas int32                   `json:"transportReadyReplicas,omitempty"`
 	ConnectedSites         TransportConnectedSites `json:"connectedSites,omitempty"`
 	BindingsCount          int                     `json:"bindingsCount,omitempty"`
@@ -288,18 +462,113 @@ type ServiceInterface struct {
 	Address      string                   `json:"address"`
 	Protocol     string                   `json:"protocol"`
 	Port         int                      `json:"port"`
+	Ports        []ServicePort            `json:"ports,omitempty"`
 	EventChannel bool                     `json:"eventchannel,omitempty"`
 	Aggregate    string                   `json:"aggregate,omitempty"`
+	Distribution string                   `json:"distribution,omitempty"`
+	Weight       int                      `json:"weight,omitempty"`
+	Priority     int                      `json:"priority,omitempty"`
 	Headless     *Headless                `json:"headless,omitempty"`
+	Tls          *ServiceTls              `json:"tls,omitempty"`
+	Labels       map[string]string        `json:"labels,omitempty"`
+	External     *ServiceExternal         `json:"external,omitempty"`
+	Annotations  map[string]string        `json:"annotations,omitempty"`
 	Targets      []ServiceInterfaceTarget `json:"targets"`
 	Origin       string                   `json:"origin,omitempty"`
 }
 
 type ServiceInterfaceTarget struct {
-	Name       string `json:"name,omitempty"`
-	Selector   string `json:"selector,omitempty"`
-	TargetPort int    `json:"targetPort,omitempty"`
-	Service    string `json:"service,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Selector    string         `json:"selector,omitempty"`
+	TargetPort  int            `json:"targetPort,omitempty"`
+	TargetPorts map[string]int `json:"targetPorts,omitempty"`
+	Service     string         `json:"service,omitempty"`
+	Host        string         `json:"host,omitempty"`
+	Weight      int            `json:"weight,omitempty"`
+	Priority    int            `json:"priority,omitempty"`
+	// ResolveInterval, if set for a host target, is the period (e.g.
+	// "30s") at which the host name is re-resolved; connectors are
+	// then configured for each resolved address. If not set the
+	// host name is passed through to the router as is.
+	ResolveInterval string `json:"resolveInterval,omitempty"`
+}
+
+// ServiceTls configures TLS at the router bridges for a service, so
+// that neither clients nor targets need to handle certificates for the
+// skupper address themselves
+type ServiceTls struct {
+	// Terminate has the listeners for the service accept TLS, using
+	// a certificate for the service address issued by the site's
+	// service CA
+	Terminate bool `json:"terminate,omitempty"`
+	// Originate has the connectors to the targets of the service
+	// use TLS
+	Originate bool `json:"originate,omitempty"`
+	// CaSecret names a secret whose ca.crt is used to verify the
+	// targets when originating TLS; the site's service CA is used if
+	// it is not set
+	CaSecret string `json:"caSecret,omitempty"`
+	// VerifyHostname has the connectors check that the certificate
+	// of the target matches its host name
+	VerifyHostname bool `json:"verifyHostname,omitempty"`
+}
+
+// ServiceExternal publishes a service outside the cluster. It applies
+// only to the site in which it is configured and is not propagated to
+// other sites.
+type ServiceExternal struct {
+	// Type is one of loadbalancer, nodeport, ingress or route
+	Type string `json:"type"`
+	// Host, if set, is the host name for an ingress or route
+	Host string `json:"host,omitempty"`
+}
+
+const (
+	ExternalTypeLoadBalancer string = "loadbalancer"
+	ExternalTypeNodePort     string = "nodeport"
+	ExternalTypeIngress      string = "ingress"
+	ExternalTypeRoute        string = "route"
+)
+
+// ServicePort is a named port of a service interface. Services with
+// a single port need not name it and use Port instead of Ports.
+type ServicePort struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+// GetPorts returns all the ports of the service, with a single
+// unnamed port for services that do not define named ports
+func (s *ServiceInterface) GetPorts() []ServicePort {
+	if len(s.Ports) > 0 {
+		return s.Ports
+	}
+	return []ServicePort{
+		ServicePort{
+			Port: s.Port,
+		},
+	}
+}
+
+// GetTargetPort returns the port on the target for the named port of
+// the service
+func (s *ServiceInterface) GetTargetPort(target *ServiceInterfaceTarget, port ServicePort) int {
+	if targetPort, ok := target.TargetPorts[port.Name]; ok && targetPort != 0 {
+		return targetPort
+	}
+	if port.Name == "" && target.TargetPort != 0 {
+		return target.TargetPort
+	}
+	return port.Port
+}
+
+// GetAddressForPort returns the router address used for the named
+// port of the service with the given address
+func GetAddressForPort(address string, name string) string {
+	if name == "" {
+		return address
+	}
+	return address + "." + name
 }
 
 type Headless struct {
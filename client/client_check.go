@@ -0,0 +1,432 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	routev1 "github.com/openshift/api/route/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+// certificates due to expire within this period are reported
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+func checkPassed(name string, format string, args ...interface{}) types.CheckResult {
+	return types.CheckResult{Name: name, Status: types.CheckPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func checkWarned(name string, remediation string, format string, args ...interface{}) types.CheckResult {
+	return types.CheckResult{Name: name, Status: types.CheckWarn, Message: fmt.Sprintf(format, args...), Remediation: remediation}
+}
+
+func checkFailed(name string, remediation string, format string, args ...interface{}) types.CheckResult {
+	return types.CheckResult{Name: name, Status: types.CheckFail, Message: fmt.Sprintf(format, args...), Remediation: remediation}
+}
+
+type requiredPermission struct {
+	group    string
+	resource string
+	verbs    []string
+}
+
+// the permissions the CLI needs in the namespace of the site
+var cliPermissions = []requiredPermission{
+	{"", "configmaps", []string{"get", "create", "update", "delete"}},
+	{"", "secrets", []string{"get", "list", "create", "update", "delete"}},
+	{"", "services", []string{"get", "list", "create", "update", "delete"}},
+	{"", "serviceaccounts", []string{"create", "delete"}},
+	{"", "pods", []string{"list"}},
+	{"", "pods/exec", []string{"create"}},
+	{"", "pods/log", []string{"get"}},
+	{"apps", "deployments", []string{"get", "create", "update", "delete"}},
+	{"rbac.authorization.k8s.io", "roles", []string{"create", "delete"}},
+	{"rbac.authorization.k8s.io", "rolebindings", []string{"create", "delete"}},
+}
+
+func (cli *VanClient) checkPermissions() types.CheckResult {
+	permissions := cliPermissions
+	if cli.RouteClient != nil {
+		permissions = append(permissions, requiredPermission{"route.openshift.io", "routes", []string{"get", "create", "delete"}})
+	}
+	denied := []string{}
+	for _, permission := range permissions {
+		for _, verb := range permission.verbs {
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: cli.Namespace,
+						Verb:      verb,
+						Group:     permission.group,
+						Resource:  permission.resource,
+					},
+				},
+			}
+			result, err := cli.KubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+			if err != nil {
+				return checkWarned("permissions", "", "Could not check permissions: %s", err)
+			}
+			if !result.Status.Allowed {
+				denied = append(denied, verb+" "+permission.resource)
+			}
+		}
+	}
+	if len(denied) > 0 {
+		return checkFailed("permissions", fmt.Sprintf("Ask a cluster administrator to grant these permissions in namespace %s", cli.Namespace),
+			"Not permitted to %s", strings.Join(denied, ", "))
+	}
+	return checkPassed("permissions", "All permissions needed to manage the site are granted")
+}
+
+func (cli *VanClient) checkDeployment(name string) types.CheckResult {
+	subject := "deployment/" + name
+	deployment, err := kube.GetDeployment(name, cli.Namespace, cli.KubeClient)
+	if errors.IsNotFound(err) {
+		return checkFailed(subject, "Recreate the site with 'skupper delete' and 'skupper init'", "Not found")
+	} else if err != nil {
+		return checkWarned(subject, "", "Could not be retrieved: %s", err)
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ReadyReplicas < desired {
+		return checkFailed(subject, fmt.Sprintf("Look for the cause in the events and logs of its pods, e.g. with 'kubectl describe pods -l skupper.io/component -n %s'", cli.Namespace),
+			"%d of %d replicas ready", deployment.Status.ReadyReplicas, desired)
+	}
+	return checkPassed(subject, "%d of %d replicas ready", deployment.Status.ReadyReplicas, desired)
+}
+
+// checkIngress determines how other sites reach this one, as when
+// generating a token, and checks that tokens this site has generated
+// still advertise that
+func (cli *VanClient) checkIngress(ctx context.Context, siteConfig *types.SiteConfig) []types.CheckResult {
+	if siteConfig.Spec.IsEdge {
+		return []types.CheckResult{checkPassed("ingress", "Edge sites accept no links")}
+	}
+	ingress := siteConfig.Spec.GetIngress(cli.RouteClient != nil)
+	hostPorts := RouterHostPorts{}
+	if err := configureHostPorts(&hostPorts, cli, cli.Namespace, &siteConfig.Spec); err != nil {
+		return []types.CheckResult{checkFailed("ingress", "Other sites cannot link to this one until it is available; check the events for the service or route",
+			"Ingress (%s) not available: %s", ingress, err)}
+	}
+	results := []types.CheckResult{}
+	if hostPorts.LocalOnly {
+		results = append(results, checkPassed("ingress", "Reachable only from within the cluster, at %s", hostPorts.InterRouter.Host))
+	} else {
+		results = append(results, checkPassed("ingress", "Reachable through %s at %s:%s", ingress, hostPorts.InterRouter.Host, hostPorts.InterRouter.Port))
+	}
+	results = append(results, cli.checkIngressResources(ingress)...)
+
+	secrets, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).List(metav1.ListOptions{
+		LabelSelector: types.SkupperTypeQualifier + "=" + types.TypeToken,
+	})
+	if err != nil {
+		return append(results, checkWarned("tokens", "", "Could not be retrieved: %s", err))
+	}
+	for _, secret := range secrets.Items {
+		annotations := secret.ObjectMeta.Annotations
+		if siteConfig.SiteId == "" || annotations[types.TokenGeneratedBy] != siteConfig.SiteId {
+			continue
+		}
+		advertised := annotations["inter-router-host"] + ":" + annotations["inter-router-port"]
+		actual := hostPorts.InterRouter.Host + ":" + hostPorts.InterRouter.Port
+		if advertised != actual {
+			results = append(results, checkWarned("token/"+secret.ObjectMeta.Name, "Generate a new token and use it in place of this one",
+				"Advertises %s, but the site is now reachable at %s", advertised, actual))
+		}
+	}
+	return results
+}
+
+// checkIngressResources checks that the route, service or ingress
+// through which other sites reach this one has been admitted or
+// allocated an address, which the advertised host may not reflect
+func (cli *VanClient) checkIngressResources(ingress string) []types.CheckResult {
+	results := []types.CheckResult{}
+	switch ingress {
+	case types.IngressRouteString:
+		if cli.RouteClient == nil {
+			return results
+		}
+		for _, name := range []string{"skupper-inter-router", "skupper-edge"} {
+			subject := "route/" + name
+			route, err := cli.RouteClient.Routes(cli.Namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				results = append(results, checkFailed(subject, "Recreate the site, or set another ingress type", "Could not be retrieved: %s", err))
+			} else if !isRouteAdmitted(route) {
+				results = append(results, checkWarned(subject, "Check the route's status and the cluster's router", "Not admitted by any router"))
+			} else {
+				results = append(results, checkPassed(subject, "Admitted for %s", route.Spec.Host))
+			}
+		}
+	case types.IngressLoadBalancerString, types.IngressNodePortString:
+		subject := "service/" + types.InterRouterProfile
+		service, err := cli.KubeClient.CoreV1().Services(cli.Namespace).Get(types.InterRouterProfile, metav1.GetOptions{})
+		if err != nil {
+			return append(results, checkFailed(subject, "Recreate the site", "Could not be retrieved: %s", err))
+		}
+		if ingress == types.IngressLoadBalancerString {
+			if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+				results = append(results, checkFailed(subject, "Recreate the site", "Is of type %s, not LoadBalancer", service.Spec.Type))
+			} else if host := kube.GetLoadBalancerHostOrIP(service); host == "" {
+				results = append(results, checkWarned(subject, "Check the events for the service; the cluster may not support LoadBalancer services", "No load balancer address allocated"))
+			} else {
+				results = append(results, checkPassed(subject, "Load balancer address %s allocated", host))
+			}
+			break
+		}
+		missing := []string{}
+		for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+			allocated := false
+			for _, port := range service.Spec.Ports {
+				if port.Name == role && port.NodePort != 0 {
+					allocated = true
+				}
+			}
+			if !allocated {
+				missing = append(missing, role)
+			}
+		}
+		if service.Spec.Type != corev1.ServiceTypeNodePort {
+			results = append(results, checkFailed(subject, "Recreate the site", "Is of type %s, not NodePort", service.Spec.Type))
+		} else if len(missing) > 0 {
+			results = append(results, checkWarned(subject, "Check the events for the service", "No node port allocated for %s", strings.Join(missing, ", ")))
+		} else {
+			results = append(results, checkPassed(subject, "Node ports allocated"))
+		}
+	case types.IngressNginxIngressString:
+		for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+			name := "skupper-" + role
+			subject := "ingress/" + name
+			resource, err := kube.GetIngress(name, cli.Namespace, cli.KubeClient)
+			if err != nil {
+				results = append(results, checkFailed(subject, "Recreate the site", "Could not be retrieved: %s", err))
+			} else if len(resource.Status.LoadBalancer.Ingress) == 0 {
+				results = append(results, checkWarned(subject, "Check that an nginx ingress controller with ssl passthrough enabled is running", "No address assigned by an ingress controller"))
+			} else {
+				results = append(results, checkPassed(subject, "Address assigned by the ingress controller"))
+			}
+		}
+	}
+	return results
+}
+
+func isRouteAdmitted(route *routev1.Route) bool {
+	for _, ingress := range route.Status.Ingress {
+		for _, condition := range ingress.Conditions {
+			if condition.Type == routev1.RouteAdmitted && condition.Status == corev1.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func certificatesIn(secret *corev1.Secret) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for _, key := range []string{"tls.crt", "ca.crt"} {
+		data := secret.Data[key]
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+func (cli *VanClient) checkCertificates(now time.Time) []types.CheckResult {
+	secrets, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return []types.CheckResult{checkWarned("certificates", "", "Could not be retrieved: %s", err)}
+	}
+	results := []types.CheckResult{}
+	for i, secret := range secrets.Items {
+		if !isSkupperResource(secret.ObjectMeta.Name) && secret.ObjectMeta.Labels[types.SkupperTypeQualifier] != types.TypeToken {
+			continue
+		}
+		subject := "certificate/" + secret.ObjectMeta.Name
+		remediation := "Delete the secret so that it is regenerated, or replace the token"
+		certs, err := certificatesIn(&secrets.Items[i])
+		if err != nil {
+			results = append(results, checkFailed(subject, remediation, "Invalid certificate in %s", err))
+			continue
+		}
+		if len(certs) == 0 {
+			continue
+		}
+		var earliest *x509.Certificate
+		for _, cert := range certs {
+			if now.Before(cert.NotBefore) {
+				results = append(results, checkWarned(subject, "Check that the clocks of the cluster are synchronised", "%s is not valid until %s", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339)))
+			}
+			if earliest == nil || cert.NotAfter.Before(earliest.NotAfter) {
+				earliest = cert
+			}
+		}
+		if now.After(earliest.NotAfter) {
+			results = append(results, checkFailed(subject, remediation, "%s expired %s", earliest.Subject.CommonName, earliest.NotAfter.Format(time.RFC3339)))
+		} else if now.Add(certificateExpiryWarning).After(earliest.NotAfter) {
+			results = append(results, checkWarned(subject, remediation, "%s expires %s", earliest.Subject.CommonName, earliest.NotAfter.Format(time.RFC3339)))
+		} else {
+			results = append(results, checkPassed(subject, "Valid until %s", earliest.NotAfter.Format(time.RFC3339)))
+		}
+	}
+	return results
+}
+
+func isControlledService(service *corev1.Service) bool {
+	return service.ObjectMeta.Annotations[types.ControlledQualifier] == "true"
+}
+
+func hasRouterSelector(service *corev1.Service) bool {
+	for key, value := range kube.GetLabelsForRouter() {
+		if service.Spec.Selector[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// checkServiceSelectors finds services that were exposed by
+// redirecting their selector to the router, but that can no longer be
+// put back as they were
+func (cli *VanClient) checkServiceSelectors(ctx context.Context) []types.CheckResult {
+	services, err := cli.KubeClient.CoreV1().Services(cli.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return []types.CheckResult{checkWarned("services", "", "Could not be retrieved: %s", err)}
+	}
+	defined := map[string]bool{}
+	if definitions, err := cli.ServiceInterfaceList(ctx); err == nil {
+		for _, definition := range definitions {
+			defined[definition.Address] = true
+		}
+	}
+	results := []types.CheckResult{}
+	for i, service := range services.Items {
+		if isSkupperResource(service.ObjectMeta.Name) || isControlledService(&services.Items[i]) || !hasRouterSelector(&services.Items[i]) {
+			continue
+		}
+		subject := "service/" + service.ObjectMeta.Name
+		if _, ok := service.ObjectMeta.Annotations[types.OriginalSelectorQualifier]; !ok {
+			results = append(results, checkFailed(subject, "Restore the selector for the pods that implement the service by hand, e.g. with 'kubectl edit service'",
+				"Selects the router, but its original selector has been lost"))
+		} else if !defined[service.ObjectMeta.Name] {
+			results = append(results, checkWarned(subject, fmt.Sprintf("Run 'skupper unexpose service %s' to restore the original selector", service.ObjectMeta.Name),
+				"Selects the router, but is no longer exposed"))
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, checkPassed("services", "No services left selecting the router"))
+	}
+	return results
+}
+
+func differentBridges(kind string, configured map[string]string, live map[string]string) []string {
+	problems := []string{}
+	for name, endpoint := range configured {
+		if actual, ok := live[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s %s missing", kind, name))
+		} else if actual != endpoint {
+			problems = append(problems, fmt.Sprintf("%s %s is %s, not %s", kind, name, actual, endpoint))
+		}
+	}
+	for name := range live {
+		if _, ok := configured[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s %s not configured", kind, name))
+		}
+	}
+	return problems
+}
+
+func tcpEndpoints(endpoints qdr.TcpEndpointMap) map[string]string {
+	result := map[string]string{}
+	for name, e := range endpoints {
+		result[name] = e.Address + "@" + e.Host + ":" + e.Port
+	}
+	return result
+}
+
+func httpEndpoints(endpoints qdr.HttpEndpointMap) map[string]string {
+	result := map[string]string{}
+	for name, e := range endpoints {
+		result[name] = e.Address + "@" + e.Host + ":" + e.Port
+	}
+	return result
+}
+
+// checkBridges compares the bridges configured in skupper-internal with
+// those the router actually has
+func (cli *VanClient) checkBridges() types.CheckResult {
+	configmap, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient)
+	if err != nil {
+		return checkWarned("bridges", "", "Could not retrieve configuration: %s", err)
+	}
+	configured, err := qdr.GetBridgeConfigFromConfigMap(configmap)
+	if err != nil {
+		return checkWarned("bridges", "", "Could not read configuration: %s", err)
+	}
+	if cli.RestConfig == nil {
+		return checkWarned("bridges", "", "Not checked; the router cannot be queried without a connection to the cluster")
+	}
+	live, err := qdr.GetBridgeConfig(cli.Namespace, cli.KubeClient, cli.RestConfig)
+	if err != nil {
+		return checkWarned("bridges", "Check that the router is running", "Could not query the router: %s", err)
+	}
+	problems := []string{}
+	problems = append(problems, differentBridges("tcpListener", tcpEndpoints(configured.TcpListeners), tcpEndpoints(live.TcpListeners))...)
+	problems = append(problems, differentBridges("tcpConnector", tcpEndpoints(configured.TcpConnectors), tcpEndpoints(live.TcpConnectors))...)
+	problems = append(problems, differentBridges("httpListener", httpEndpoints(configured.HttpListeners), httpEndpoints(live.HttpListeners))...)
+	problems = append(problems, differentBridges("httpConnector", httpEndpoints(configured.HttpConnectors), httpEndpoints(live.HttpConnectors))...)
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return checkFailed("bridges", "The service controller should correct this; if it persists, restart the router pods",
+			"Router differs from its configuration: %s", strings.Join(problems, "; "))
+	}
+	return checkPassed("bridges", "Router has the %d bridges configured", len(configured.TcpListeners)+len(configured.TcpConnectors)+len(configured.HttpListeners)+len(configured.HttpConnectors))
+}
+
+// SkupperCheck examines the site for common problems, returning the
+// outcome of each check made along with how to remedy any failure
+func (cli *VanClient) SkupperCheck(ctx context.Context) ([]types.CheckResult, error) {
+	results := []types.CheckResult{cli.checkPermissions()}
+	siteConfig, err := cli.SiteConfigInspect(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if siteConfig == nil {
+		return append(results, checkFailed("site", "Run 'skupper init'", "Skupper is not initialised in namespace %s", cli.Namespace)), nil
+	}
+	results = append(results, checkPassed("site", "Site %s (%s)", siteConfig.Spec.SkupperName, siteConfig.SiteId))
+	results = append(results, cli.checkDeployment(types.TransportDeploymentName))
+	if siteConfig.Spec.EnableController {
+		results = append(results, cli.checkDeployment(types.ControllerDeploymentName))
+	}
+	results = append(results, cli.checkIngress(ctx, siteConfig)...)
+	results = append(results, cli.checkCertificates(time.Now())...)
+	results = append(results, cli.checkServiceSelectors(ctx)...)
+	results = append(results, cli.checkBridges())
+	return results, nil
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+func expiredCertificate(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Assert(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "old"},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Assert(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSkupperCheck(t *testing.T) {
+	if *clusterRun {
+		t.Skip("Only runs against the mock client")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "skupper-check")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "east.yaml")
+	assert.Assert(t, ioutil.WriteFile(tokenFile, []byte(testToken), 0644))
+
+	cli, err := newMockClient("check-west", "", "")
+	assert.Assert(t, err)
+	// everything is permitted but deleting roles
+	cli.KubeClient.(*fake.Clientset).PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = !(attributes.Verb == "delete" && attributes.Resource == "roles")
+		return true, review, nil
+	})
+
+	results, err := cli.SkupperCheck(ctx)
+	assert.Assert(t, err)
+	assert.Equal(t, results[len(results)-1].Name, "site")
+	assert.Equal(t, results[len(results)-1].Status, types.CheckFail)
+
+	manifest, err := ReadSiteManifest([]byte(testManifest))
+	assert.Assert(t, err)
+	manifest.Links[0].Token = tokenFile
+	_, err = cli.SiteManifestApply(ctx, manifest, false)
+	assert.Assert(t, err)
+	site, err := kube.GetConfigMap(types.DefaultSiteName, cli.Namespace, cli.KubeClient)
+	assert.Assert(t, err)
+	site.ObjectMeta.Annotations = map[string]string{types.SiteIdQualifier: "west-id"}
+	_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(site)
+	assert.Assert(t, err)
+
+	secrets := []*corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "issued",
+				Labels: map[string]string{types.SkupperTypeQualifier: types.TypeToken},
+				Annotations: map[string]string{
+					types.TokenGeneratedBy: "west-id",
+					"inter-router-host":    "old.example.com",
+					"inter-router-port":    "55671",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "skupper-old"},
+			Data:       map[string][]byte{"tls.crt": expiredCertificate(t)},
+		},
+	}
+	for _, secret := range secrets {
+		_, err = cli.KubeClient.CoreV1().Secrets(cli.Namespace).Create(secret)
+		assert.Assert(t, err)
+	}
+	services := []*corev1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "lost"},
+			Spec:       corev1.ServiceSpec{Selector: kube.GetLabelsForRouter()},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "stale",
+				Annotations: map[string]string{types.OriginalSelectorQualifier: "app=stale"},
+			},
+			Spec: corev1.ServiceSpec{Selector: kube.GetLabelsForRouter()},
+		},
+	}
+	for _, service := range services {
+		_, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Create(service)
+		assert.Assert(t, err)
+	}
+
+	results, err = cli.SkupperCheck(ctx)
+	assert.Assert(t, err)
+	found := map[string]types.CheckResult{}
+	for _, result := range results {
+		found[result.Name] = result
+	}
+	assert.Equal(t, found["permissions"].Status, types.CheckFail)
+	assert.Equal(t, found["permissions"].Message, "Not permitted to delete roles")
+	assert.Equal(t, found["site"].Status, types.CheckPass)
+	// nothing runs against the mock client
+	assert.Equal(t, found["deployment/skupper-router"].Status, types.CheckFail)
+	assert.Equal(t, found["ingress"].Status, types.CheckPass)
+	assert.Equal(t, found["token/issued"].Status, types.CheckWarn)
+	assert.Equal(t, found["token/issued"].Message, "Advertises old.example.com:55671, but the site is now reachable at skupper-internal.check-west:55671")
+	assert.Equal(t, found["certificate/skupper-internal-ca"].Status, types.CheckPass)
+	assert.Equal(t, found["certificate/skupper-old"].Status, types.CheckFail)
+	assert.Equal(t, found["service/lost"].Status, types.CheckFail)
+	assert.Equal(t, found["service/stale"].Status, types.CheckWarn)
+	assert.Equal(t, found["bridges"].Status, types.CheckWarn)
+	for _, result := range results {
+		if result.Status != types.CheckPass {
+			assert.Assert(t, result.Remediation != "" || result.Name == "bridges", "%s has no remediation", result.Name)
+		}
+	}
+}
+
+func TestCheckIngressResources(t *testing.T) {
+	cli, err := newMockClient("check-ingress", "", "")
+	assert.Assert(t, err)
+	statusOf := func(ingress string) map[string]string {
+		found := map[string]string{}
+		for _, result := range cli.checkIngressResources(ingress) {
+			found[result.Name] = result.Status
+		}
+		return found
+	}
+	assert.Equal(t, len(statusOf(types.IngressNoneString)), 0)
+	assert.Equal(t, statusOf(types.IngressLoadBalancerString)["service/skupper-internal"], types.CheckFail)
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: types.InterRouterProfile},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{
+				{Name: types.InterRouterRole, Port: types.InterRouterListenerPort},
+				{Name: types.EdgeRole, Port: types.EdgeListenerPort},
+			},
+		},
+	}
+	service, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Create(service)
+	assert.Assert(t, err)
+	// the advertised host may be configured while no address has
+	// been allocated
+	assert.Equal(t, statusOf(types.IngressLoadBalancerString)["service/skupper-internal"], types.CheckWarn)
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.1.2.3"}}
+	service, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Update(service)
+	assert.Assert(t, err)
+	assert.Equal(t, statusOf(types.IngressLoadBalancerString)["service/skupper-internal"], types.CheckPass)
+	assert.Equal(t, statusOf(types.IngressNodePortString)["service/skupper-internal"], types.CheckFail)
+
+	service.Spec.Type = corev1.ServiceTypeNodePort
+	service.Spec.Ports[0].NodePort = 30001
+	service, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Update(service)
+	assert.Assert(t, err)
+	assert.Equal(t, statusOf(types.IngressNodePortString)["service/skupper-internal"], types.CheckWarn)
+	service.Spec.Ports[1].NodePort = 30002
+	_, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Update(service)
+	assert.Assert(t, err)
+	assert.Equal(t, statusOf(types.IngressNodePortString)["service/skupper-internal"], types.CheckPass)
+
+	_, err = cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).Create(&networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "skupper-inter-router"},
+		Status: networkingv1beta1.IngressStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "10.1.2.4"}}},
+		},
+	})
+	assert.Assert(t, err)
+	_, err = cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).Create(&networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "skupper-edge"},
+	})
+	assert.Assert(t, err)
+	found := statusOf(types.IngressNginxIngressString)
+	assert.Equal(t, found["ingress/skupper-inter-router"], types.CheckPass)
+	assert.Equal(t, found["ingress/skupper-edge"], types.CheckWarn)
+}
@@ -2,32 +2,167 @@ package client
 
 import (
 	"archive/tar"
-	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
+	jsonencoding "encoding/json"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/runtime/serializer/json"
-	"k8s.io/client-go/kubernetes/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 
+	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
 )
 
-func runCommand(name string, args ...string) ([]byte, error) {
-	cmd := exec.Command(name, args...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err := cmd.Run()
-	if err != nil {
-		return nil, err
+// The index written to every dump, describing what it holds and what
+// could not be collected
+const DumpManifestFile string = "manifest.json"
+
+type DumpManifest struct {
+	Namespace string            `json:"namespace"`
+	Time      time.Time         `json:"time"`
+	Redacted  bool              `json:"redacted"`
+	Versions  map[string]string `json:"versions"`
+	Entries   []DumpEntry       `json:"entries"`
+}
+
+// DumpEntry is either a file in the dump, or something that could not
+// be collected and the reason why
+type DumpEntry struct {
+	Path        string `json:"path,omitempty"`
+	Description string `json:"description"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DumpServiceSync records, for each service definition in
+// skupper-services, where it came from and whether the service for it
+// exists at this site
+type DumpServiceSync struct {
+	Address        string `json:"address"`
+	Protocol       string `json:"protocol"`
+	Origin         string `json:"origin"`
+	ServicePresent bool   `json:"servicePresent"`
+}
+
+var ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// ipv6Pattern matches candidate IPv6 addresses (hex groups separated by
+// at least two colons); only those that parse as IPv6 are redacted
+var ipv6Pattern = regexp.MustCompile(`(?i)[0-9a-f]*:[0-9a-f:]*:[0-9a-f]*`)
+
+// redactor replaces hostnames, IP addresses and credentials with
+// placeholders, the same placeholder being used for each occurrence
+// of a value so that the dump can still be followed
+type redactor struct {
+	enabled      bool
+	placeholders map[string]string
+	counts       map[string]int
+	replacer     *strings.Replacer
+}
+
+func newRedactor(enabled bool) *redactor {
+	return &redactor{
+		enabled:      enabled,
+		placeholders: map[string]string{},
+		counts:       map[string]int{},
+	}
+}
+
+func (r *redactor) placeholder(kind string, value string) string {
+	if p, ok := r.placeholders[value]; ok {
+		return p
+	}
+	r.counts[kind]++
+	p := fmt.Sprintf("redacted-%s-%d", kind, r.counts[kind])
+	r.placeholders[value] = p
+	r.replacer = nil
+	return p
+}
+
+func (r *redactor) addHost(value string) {
+	if value == "" || value == "localhost" || value == corev1.ClusterIPNone {
+		return
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		r.addIP(ip)
+		return
 	}
-	return out.Bytes(), nil
+	r.placeholder("host", value)
+}
+
+func (r *redactor) addIP(ip net.IP) {
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return
+	}
+	r.placeholder("ip", ip.String())
+}
+
+func (r *redactor) addCredential(value string) {
+	if value == "" {
+		return
+	}
+	r.placeholder("credential", value)
+}
+
+func (r *redactor) redact(data []byte) []byte {
+	if !r.enabled {
+		return data
+	}
+	if r.replacer == nil {
+		values := []string{}
+		for value := range r.placeholders {
+			values = append(values, value)
+		}
+		// longest first, so that a value containing another is
+		// replaced whole
+		sort.Slice(values, func(i, j int) bool {
+			if len(values[i]) != len(values[j]) {
+				return len(values[i]) > len(values[j])
+			}
+			return values[i] < values[j]
+		})
+		pairs := []string{}
+		for _, value := range values {
+			pairs = append(pairs, value, r.placeholders[value])
+		}
+		r.replacer = strings.NewReplacer(pairs...)
+	}
+	text := r.replacer.Replace(string(data))
+	text = ipv6Pattern.ReplaceAllStringFunc(text, func(match string) string {
+		ip := net.ParseIP(match)
+		if ip == nil || ip.To4() != nil || ip.IsLoopback() || ip.IsUnspecified() {
+			return match
+		}
+		return r.placeholder("ip", ip.String())
+	})
+	text = ipv4Pattern.ReplaceAllStringFunc(text, func(match string) string {
+		ip := net.ParseIP(match)
+		if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+			return match
+		}
+		return r.placeholder("ip", ip.String())
+	})
+	return []byte(text)
+}
+
+type dumpWriter struct {
+	tw       *tar.Writer
+	ts       time.Time
+	redactor *redactor
+	manifest DumpManifest
+	// the first error writing the archive, after which nothing more
+	// is written
+	err error
 }
 
 func writeTar(name string, data []byte, ts time.Time, tw *tar.Writer) error {
@@ -37,54 +172,372 @@ func writeTar(name string, data []byte, ts time.Time, tw *tar.Writer) error {
 		Size:    int64(len(data)),
 		ModTime: ts,
 	}
-	tw.WriteHeader(hdr)
-	tw.Write(data)
-	return nil
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
 }
 
-func (cli *VanClient) writeDeployment(name string, tw *tar.Writer) error {
-	var b bytes.Buffer
-	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+func (w *dumpWriter) write(path string, description string, data []byte) {
+	if w.err != nil {
+		return
+	}
+	w.manifest.Entries = append(w.manifest.Entries, DumpEntry{Path: path, Description: description})
+	w.err = writeTar(path, w.redactor.redact(data), w.ts, w.tw)
+}
 
-	deployment, err := kube.GetDeployment(name, cli.Namespace, cli.KubeClient)
-	if errors.IsNotFound(err) {
-		return nil
-	} else if err != nil {
-		return err
+func (w *dumpWriter) writeObject(path string, description string, obj interface{}) {
+	encoded, err := yaml.Marshal(obj)
+	if err != nil {
+		w.failed(description, err)
+		return
 	}
-	if err = s.Encode(deployment, &b); err != nil {
-		return err
+	w.write(path, description, encoded)
+}
+
+// failed records something that could not be collected; the dump
+// carries on regardless
+func (w *dumpWriter) failed(description string, err error) {
+	w.manifest.Entries = append(w.manifest.Entries, DumpEntry{Description: description, Error: string(w.redactor.redact([]byte(err.Error())))})
+}
+
+func isSkupperResource(name string) bool {
+	return strings.HasPrefix(name, "skupper")
+}
+
+// collectSensitiveValues registers the hostnames, addresses and
+// credentials known to the site, so that they can be redacted wherever
+// they appear, including in logs and events
+func (cli *VanClient) collectSensitiveValues(r *redactor) {
+	if cm, err := kube.GetConfigMap(types.DefaultSiteName, cli.Namespace, cli.KubeClient); err == nil {
+		r.addHost(cm.Data["ingress-host"])
+		r.addCredential(cm.Data["console-password"])
+	}
+	if cm, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient); err == nil {
+		if config, err := qdr.GetRouterConfigFromConfigMap(cm); err == nil {
+			for _, connector := range config.Connectors {
+				r.addHost(connector.Host)
+			}
+		}
+	}
+	if secrets, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		for _, secret := range secrets.Items {
+			for _, key := range []string{"inter-router-host", "edge-host"} {
+				r.addHost(secret.ObjectMeta.Annotations[key])
+			}
+			if secret.ObjectMeta.Name == "skupper-console-users" {
+				for _, password := range secret.Data {
+					r.addCredential(string(password))
+				}
+			}
+		}
+	}
+	if services, err := cli.KubeClient.CoreV1().Services(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		for _, service := range services.Items {
+			r.addHost(service.Spec.ClusterIP)
+			r.addHost(service.Spec.ExternalName)
+			for _, ip := range service.Spec.ExternalIPs {
+				r.addHost(ip)
+			}
+			for _, ingress := range service.Status.LoadBalancer.Ingress {
+				r.addHost(ingress.IP)
+				r.addHost(ingress.Hostname)
+			}
+		}
+	}
+	if definitions, err := kube.GetServiceDefinitions(cli.Namespace, cli.KubeClient); err == nil {
+		for _, encoded := range definitions {
+			definition := types.ServiceInterface{}
+			if err := jsonencoding.Unmarshal([]byte(encoded), &definition); err != nil {
+				continue
+			}
+			if definition.External != nil {
+				r.addHost(definition.External.Host)
+			}
+			for _, target := range definition.Targets {
+				r.addHost(target.Host)
+			}
+		}
+	}
+	if ingresses, err := cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		for _, ingress := range ingresses.Items {
+			for _, rule := range ingress.Spec.Rules {
+				r.addHost(rule.Host)
+			}
+			for _, tls := range ingress.Spec.TLS {
+				for _, host := range tls.Hosts {
+					r.addHost(host)
+				}
+			}
+			for _, lb := range ingress.Status.LoadBalancer.Ingress {
+				r.addHost(lb.IP)
+				r.addHost(lb.Hostname)
+			}
+		}
+	}
+	if pods, err := cli.KubeClient.CoreV1().Pods(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		for _, pod := range pods.Items {
+			r.addHost(pod.Spec.NodeName)
+			r.addHost(pod.Status.HostIP)
+			r.addHost(pod.Status.PodIP)
+		}
+	}
+	if cli.RouteClient != nil {
+		if routes, err := cli.RouteClient.Routes(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+			for _, route := range routes.Items {
+				r.addHost(route.Spec.Host)
+			}
+		}
+	}
+}
+
+func (cli *VanClient) dumpVersions(w *dumpWriter, version string) {
+	w.manifest.Versions["client"] = version
+	if kv, err := cli.KubeClient.Discovery().ServerVersion(); err == nil {
+		w.manifest.Versions["kubernetes"] = kv.GitVersion
+	} else {
+		w.failed("kubernetes version", err)
+	}
+	if cli.RouteClient != nil {
+		w.manifest.Versions["openshift"] = "routes available"
+	}
+	if vir, err := cli.RouterInspect(context.Background()); err == nil {
+		w.manifest.Versions["transport"] = vir.TransportVersion
+		w.manifest.Versions["controller"] = vir.ControllerVersion
+	} else {
+		w.failed("skupper versions", err)
+	}
+	names := []string{}
+	for name := range w.manifest.Versions {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%-30s %s", name+" version", w.manifest.Versions[name]))
+	}
+	w.write("versions.txt", "client, skupper and cluster versions", []byte(strings.Join(lines, "\n")+"\n"))
+}
 
-	return writeTar(name+"-deployment.yaml", b.Bytes(), time.Now(), tw)
+// secretMetadata keeps what can be known of a secret without its
+// contents: the keys of its data are kept, but not their values
+func secretMetadata(secret *corev1.Secret) corev1.Secret {
+	result := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              secret.ObjectMeta.Name,
+			Labels:            secret.ObjectMeta.Labels,
+			Annotations:       secret.ObjectMeta.Annotations,
+			CreationTimestamp: secret.ObjectMeta.CreationTimestamp,
+			OwnerReferences:   secret.ObjectMeta.OwnerReferences,
+		},
+		Type: secret.Type,
+		Data: map[string][]byte{},
+	}
+	for key := range secret.Data {
+		result.Data[key] = []byte{}
+	}
+	return result
 }
 
-func (cli *VanClient) writeConfigMap(name string, tw *tar.Writer) error {
-	var b bytes.Buffer
-	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+func (cli *VanClient) dumpResources(w *dumpWriter) {
+	if secrets, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		list := &corev1.SecretList{}
+		for i, secret := range secrets.Items {
+			if isSkupperResource(secret.ObjectMeta.Name) || secret.ObjectMeta.Labels[types.SkupperTypeQualifier] != "" {
+				list.Items = append(list.Items, secretMetadata(&secrets.Items[i]))
+			}
+		}
+		w.writeObject("secrets.yaml", "metadata of skupper secrets and tokens, without their contents", list)
+	} else {
+		w.failed("secrets", err)
+	}
+	if services, err := cli.KubeClient.CoreV1().Services(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		w.writeObject("services.yaml", "services in the namespace", services)
+	} else {
+		w.failed("services", err)
+	}
+	if pods, err := cli.KubeClient.CoreV1().Pods(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		w.writeObject("pods.yaml", "pods in the namespace", pods)
+	} else {
+		w.failed("pods", err)
+	}
+	if events, err := cli.KubeClient.CoreV1().Events(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		w.writeObject("events.yaml", "events in the namespace", events)
+	} else {
+		w.failed("events", err)
+	}
+	if accounts, err := cli.KubeClient.CoreV1().ServiceAccounts(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		list := &corev1.ServiceAccountList{}
+		for _, account := range accounts.Items {
+			if isSkupperResource(account.ObjectMeta.Name) {
+				list.Items = append(list.Items, account)
+			}
+		}
+		w.writeObject("rbac/serviceaccounts.yaml", "skupper service accounts", list)
+	} else {
+		w.failed("service accounts", err)
+	}
+	if roles, err := cli.KubeClient.RbacV1().Roles(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		all := roles.Items
+		roles.Items = nil
+		for _, role := range all {
+			if isSkupperResource(role.ObjectMeta.Name) {
+				roles.Items = append(roles.Items, role)
+			}
+		}
+		w.writeObject("rbac/roles.yaml", "skupper roles", roles)
+	} else {
+		w.failed("roles", err)
+	}
+	if bindings, err := cli.KubeClient.RbacV1().RoleBindings(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		all := bindings.Items
+		bindings.Items = nil
+		for _, binding := range all {
+			if isSkupperResource(binding.ObjectMeta.Name) {
+				bindings.Items = append(bindings.Items, binding)
+			}
+		}
+		w.writeObject("rbac/rolebindings.yaml", "skupper role bindings", bindings)
+	} else {
+		w.failed("role bindings", err)
+	}
+}
 
-	cm, err := kube.GetConfigMap(name, cli.Namespace, cli.KubeClient)
+func (cli *VanClient) dumpServiceSync(w *dumpWriter) {
+	definitions, err := kube.GetServiceDefinitions(cli.Namespace, cli.KubeClient)
 	if errors.IsNotFound(err) {
-		return nil
+		return
 	} else if err != nil {
-		return err
+		w.failed("service sync state", err)
+		return
 	}
-	if err = s.Encode(cm, &b); err != nil {
-		return err
+	services := map[string]bool{}
+	if list, err := cli.KubeClient.CoreV1().Services(cli.Namespace).List(metav1.ListOptions{}); err == nil {
+		for _, service := range list.Items {
+			services[service.ObjectMeta.Name] = true
+		}
+	}
+	state := []DumpServiceSync{}
+	for _, encoded := range definitions {
+		definition := types.ServiceInterface{}
+		if err := jsonencoding.Unmarshal([]byte(encoded), &definition); err != nil {
+			w.failed("service sync state", err)
+			continue
+		}
+		origin := definition.Origin
+		if origin == "" {
+			origin = "local"
+		}
+		state = append(state, DumpServiceSync{
+			Address:        definition.Address,
+			Protocol:       definition.Protocol,
+			Origin:         origin,
+			ServicePresent: services[definition.Address],
+		})
 	}
+	sort.Slice(state, func(i, j int) bool { return state[i].Address < state[j].Address })
+	encoded, err := jsonencoding.MarshalIndent(state, "", "  ")
+	if err != nil {
+		w.failed("service sync state", err)
+		return
+	}
+	w.write("service-sync.json", "origin of each service definition and whether its service exists", encoded)
+}
 
-	return writeTar(name+"-configmap.yaml", b.Bytes(), time.Now(), tw)
+// dumpControllerData retrieves the service controller's view of the
+// network through the API server's proxy to its pod, authenticating as
+// the console would
+func (cli *VanClient) dumpControllerData(w *dumpWriter, pod *corev1.Pod, container *corev1.Container) {
+	if cli.RestConfig == nil {
+		return
+	}
+	if env := kube.FindEnvVar(container.Env, "METRICS_HOST"); env != nil && env.Value != "" {
+		w.failed("service controller data", fmt.Errorf("console data is only served to %s in %s", env.Value, pod.Name))
+		return
+	}
+	port := "8080"
+	if env := kube.FindEnvVar(container.Env, "METRICS_PORT"); env != nil && env.Value != "" {
+		port = env.Value
+	}
+	request := cli.KubeClient.CoreV1().RESTClient().Get().Namespace(cli.Namespace).Resource("pods").Name(pod.Name + ":" + port).SubResource("proxy").Suffix("DATA")
+	if env := kube.FindEnvVar(container.Env, "METRICS_USERS"); env != nil && env.Value != "" {
+		users, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get("skupper-console-users", metav1.GetOptions{})
+		if err != nil {
+			w.failed("service controller data", err)
+			return
+		}
+		for user, password := range users.Data {
+			request = request.SetHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+string(password))))
+			break
+		}
+	}
+	data, err := request.DoRaw()
+	if err != nil {
+		w.failed("service controller data", err)
+		return
+	}
+	w.write("service-controller/"+pod.Name+"-DATA.json", "the service controller's view of the network, as shown by the console", data)
 }
 
-func (cli *VanClient) SkupperDump(ctx context.Context, tarName string, version string, kubeConfigPath string, kubeConfigContext string) error {
-	configMaps := []string{"skupper-site", "skupper-services", "skupper-internal", "skupper-sasl-config"}
-	deployments := []string{"skupper-site-controller", "skupper-router", "skupper-service-controller"}
+func (cli *VanClient) dumpDeployment(w *dumpWriter, name string) {
 	qdstatFlags := []string{"-g", "-c", "-l", "-n", "-e", "-a", "-m", "-p"}
 
+	deployment, err := kube.GetDeployment(name, cli.Namespace, cli.KubeClient)
+	if errors.IsNotFound(err) {
+		return
+	} else if err != nil {
+		w.failed(name+" deployment", err)
+		return
+	}
+	w.writeObject("deployments/"+name+".yaml", name+" deployment", deployment)
+
+	component := kube.GetDeploymentLabel(name, "skupper.io/component", cli.Namespace, cli.KubeClient)
+	podList, err := kube.GetDeploymentPods(name, "skupper.io/component="+component, cli.Namespace, cli.KubeClient)
+	if err != nil {
+		w.failed(name+" pods", err)
+		return
+	}
+	for i := range podList {
+		pod := &podList[i]
+		for j := range pod.Spec.Containers {
+			container := &pod.Spec.Containers[j]
+			if container.Name == "router" && cli.RestConfig != nil {
+				// while we are here collect qdstats, logs will show these operations
+				for _, flag := range qdstatFlags {
+					qdr, err := kube.ExecCommandInContainer([]string{"qdstat", flag}, pod.Name, "router", cli.Namespace, cli.KubeClient, cli.RestConfig)
+					if err == nil {
+						w.write("qdstat/"+pod.Name+flag+".txt", "qdstat "+flag+" in "+pod.Name, qdr.Bytes())
+					} else {
+						w.failed("qdstat "+flag+" in "+pod.Name, err)
+					}
+				}
+			}
+			if name == types.ControllerDeploymentName && container.Name == types.ControllerContainerName {
+				cli.dumpControllerData(w, pod, container)
+			}
+			log, err := kube.GetPodContainerLogs(pod.Name, container.Name, cli.Namespace, cli.KubeClient)
+			if err == nil {
+				w.write("logs/"+pod.Name+"-"+container.Name+".txt", "logs of "+container.Name+" in "+pod.Name, []byte(log))
+			} else {
+				w.failed("logs of "+container.Name+" in "+pod.Name, err)
+			}
+		}
+	}
+}
+
+// SkupperDump writes a compressed archive of the state of the site for
+// diagnosis, indexed by manifest.json. If redact is set, hostnames, IP
+// addresses and credentials are replaced by placeholders throughout.
+func (cli *VanClient) SkupperDump(ctx context.Context, tarName string, version string, redact bool) error {
+	configMaps := []string{types.DefaultSiteName, types.ServiceInterfaceConfigMap, "skupper-internal", "skupper-sasl-config"}
+	deployments := []string{"skupper-site-controller", types.TransportDeploymentName, types.ControllerDeploymentName}
+
 	tarFile, err := os.Create(tarName)
 	if err != nil {
 		return err
 	}
+	defer tarFile.Close()
 
 	// compress tar
 	gz := gzip.NewWriter(tarFile)
@@ -92,69 +545,57 @@ func (cli *VanClient) SkupperDump(ctx context.Context, tarName string, version s
 	tw := tar.NewWriter(gz)
 	defer tw.Close()
 
-	kv, err := runCommand("kubectl", "version", "--short", "--kubeconfig="+kubeConfigPath, "--context="+kubeConfigContext)
-	if err == nil {
-		writeTar("k8s-versions.txt", kv, time.Now(), tw)
+	w := &dumpWriter{
+		tw:       tw,
+		ts:       time.Now(),
+		redactor: newRedactor(redact),
+		manifest: DumpManifest{
+			Namespace: cli.Namespace,
+			Redacted:  redact,
+			Versions:  map[string]string{},
+		},
 	}
-
-	if cli.RouteClient != nil {
-		ocv, err := runCommand("oc", "version", "--kubeconfig="+kubeConfigPath, "--context="+kubeConfigContext)
-		if err == nil {
-			writeTar("oc-versions.txt", ocv, time.Now(), tw)
-		}
+	w.manifest.Time = w.ts
+	if redact {
+		cli.collectSensitiveValues(w.redactor)
 	}
 
-	var cversions []string
-	vir, err := cli.RouterInspect(context.Background())
-	if err == nil {
-		cversions = append(cversions, fmt.Sprintf("%-30s %s", "client version", version))
-		cversions = append(cversions, fmt.Sprintf("%-30s %s", "transport version", vir.TransportVersion))
-		cversions = append(cversions, fmt.Sprintf("%-30s %s\n", "controller version", vir.ControllerVersion))
-		writeTar("skupper-versions.txt", []byte(strings.Join(cversions, "\n")), time.Now(), tw)
+	cli.dumpVersions(w, version)
+	for _, name := range deployments {
+		cli.dumpDeployment(w, name)
 	}
-
-	for i := range deployments {
-		err := cli.writeDeployment(deployments[i], tw)
-		if err != nil {
-			return err
-		}
-
-		component := kube.GetDeploymentLabel(deployments[i], "skupper.io/component", cli.Namespace, cli.KubeClient)
-
-		podList, err := kube.GetDeploymentPods(deployments[i], "skupper.io/component="+component, cli.Namespace, cli.KubeClient)
+	for _, name := range configMaps {
+		cm, err := kube.GetConfigMap(name, cli.Namespace, cli.KubeClient)
 		if errors.IsNotFound(err) {
 			continue
 		} else if err != nil {
-			return err
-		}
-		for _, pod := range podList {
-			for container := range pod.Spec.Containers {
-				if pod.Spec.Containers[container].Name == "router" {
-					// while we are here collect qdstats, logs will show these operations
-					for x := range qdstatFlags {
-						qdr, err := kube.ExecCommandInContainer([]string{"qdstat", qdstatFlags[x]}, pod.Name, "router", cli.Namespace, cli.KubeClient, cli.RestConfig)
-						if err == nil {
-							writeTar(pod.Name+"-qdstat"+qdstatFlags[x]+".txt", qdr.Bytes(), time.Now(), tw)
-						} else {
-							continue
-
-						}
-					}
-				}
-
-				log, err := kube.GetPodContainerLogs(pod.Name, pod.Spec.Containers[container].Name, cli.Namespace, cli.KubeClient)
-				if err == nil {
-					writeTar(pod.Name+"-"+pod.Spec.Containers[container].Name+"-logs.txt", []byte(log), time.Now(), tw)
-				}
+			w.failed(name+" config map", err)
+			continue
+		}
+		w.writeObject("configmaps/"+name+".yaml", name+" config map", cm)
+	}
+	// the shards into which service definitions move once
+	// skupper-services grows too large
+	if shards, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).List(metav1.ListOptions{LabelSelector: types.TypeServiceDefinitionsQualifier}); err == nil {
+		for i, shard := range shards.Items {
+			name := shard.ObjectMeta.Name
+			if name == types.ServiceInterfaceConfigMap || shard.ObjectMeta.Labels[types.InternalTypeQualifier] != types.TypeServiceDefinitions {
+				continue
 			}
+			w.writeObject("configmaps/"+name+".yaml", name+" service definitions shard", &shards.Items[i])
 		}
+	} else {
+		w.failed("service definitions shards", err)
+	}
+	cli.dumpResources(w)
+	cli.dumpServiceSync(w)
+	if w.err != nil {
+		return w.err
 	}
 
-	for i := range configMaps {
-		err := cli.writeConfigMap(configMaps[i], tw)
-		if err != nil {
-			return err
-		}
+	encoded, err := jsonencoding.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
 	}
-	return nil
+	return writeTar(DumpManifestFile, encoded, w.ts, tw)
 }
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	jsonencoding "encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+// Severities of the problems found in a dump
+const (
+	FindingError   string = "error"
+	FindingWarning string = "warning"
+	FindingInfo    string = "info"
+)
+
+// DumpFinding is a problem found by analysing a dump, concerning the
+// object named by subject
+type DumpFinding struct {
+	Severity string
+	Subject  string
+	Message  string
+}
+
+func (f DumpFinding) String() string {
+	return fmt.Sprintf("%-8s %s: %s", f.Severity, f.Subject, f.Message)
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case FindingError:
+		return 0
+	case FindingWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func readDump(file string) (map[string][]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("Not a skupper dump: %w", err)
+	}
+	defer gz.Close()
+	contents := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Not a skupper dump: %w", err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		contents[hdr.Name] = data
+	}
+	return contents, nil
+}
+
+type dumpAnalysis struct {
+	contents map[string][]byte
+	findings []DumpFinding
+}
+
+func (a *dumpAnalysis) add(severity string, subject string, format string, args ...interface{}) {
+	a.findings = append(a.findings, DumpFinding{Severity: severity, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+// load decodes a file from the dump, returning false if it is not there
+func (a *dumpAnalysis) load(path string, obj interface{}) bool {
+	data, ok := a.contents[path]
+	if !ok {
+		return false
+	}
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		a.add(FindingWarning, path, "could not be read: %s", err)
+		return false
+	}
+	return true
+}
+
+func (a *dumpAnalysis) checkDeployments() {
+	for _, name := range []string{types.TransportDeploymentName, types.ControllerDeploymentName} {
+		deployment := appsv1.Deployment{}
+		if !a.load("deployments/"+name+".yaml", &deployment) {
+			if name == types.TransportDeploymentName {
+				a.add(FindingError, name, "deployment not found")
+			}
+			continue
+		}
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		if deployment.Status.ReadyReplicas < desired {
+			a.add(FindingError, name, "%d of %d replicas ready", deployment.Status.ReadyReplicas, desired)
+		}
+	}
+}
+
+func (a *dumpAnalysis) checkPods() {
+	pods := corev1.PodList{}
+	if !a.load("pods.yaml", &pods) {
+		return
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			subject := pod.ObjectMeta.Name + "/" + status.Name
+			if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" && waiting.Reason != "ContainerCreating" {
+				a.add(FindingError, subject, "waiting: %s %s", waiting.Reason, waiting.Message)
+			}
+			if status.RestartCount > 0 {
+				reason := ""
+				if terminated := status.LastTerminationState.Terminated; terminated != nil {
+					reason = fmt.Sprintf(" (last exit %d, %s)", terminated.ExitCode, terminated.Reason)
+				}
+				a.add(FindingWarning, subject, "restarted %d times%s", status.RestartCount, reason)
+			}
+		}
+	}
+}
+
+func (a *dumpAnalysis) checkEvents() {
+	events := corev1.EventList{}
+	if !a.load("events.yaml", &events) {
+		return
+	}
+	// the same warning repeated is reported once
+	seen := map[string]bool{}
+	for _, event := range events.Items {
+		if event.Type != corev1.EventTypeWarning {
+			continue
+		}
+		subject := strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name
+		key := subject + "/" + event.Reason
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		a.add(FindingWarning, subject, "%s: %s", event.Reason, event.Message)
+	}
+}
+
+func (a *dumpAnalysis) checkSite() {
+	site := corev1.ConfigMap{}
+	if !a.load("configmaps/"+types.DefaultSiteName+".yaml", &site) {
+		a.add(FindingError, types.DefaultSiteName, "not found; skupper is not initialised in the namespace")
+		return
+	}
+	if site.ObjectMeta.Annotations[types.StatusQualifier] == types.StatusFailed {
+		a.add(FindingError, types.DefaultSiteName, "site failed: %s", site.ObjectMeta.Annotations[types.StatusMessageQualifier])
+	}
+	if err := ValidateSiteConfig(site.Data); err != nil {
+		a.add(FindingError, types.DefaultSiteName, "%s", err)
+	}
+}
+
+func (a *dumpAnalysis) checkTokens() {
+	secrets := corev1.SecretList{}
+	if !a.load("secrets.yaml", &secrets) {
+		return
+	}
+	for _, secret := range secrets.Items {
+		if secret.ObjectMeta.Labels[types.SkupperTypeQualifier] != types.TypeToken {
+			continue
+		}
+		annotations := secret.ObjectMeta.Annotations
+		switch annotations[types.StatusQualifier] {
+		case types.StatusFailed:
+			a.add(FindingError, "link/"+secret.ObjectMeta.Name, "failed: %s", annotations[types.StatusMessageQualifier])
+		case types.StatusConnecting:
+			a.add(FindingWarning, "link/"+secret.ObjectMeta.Name, "not yet connected: %s", annotations[types.StatusMessageQualifier])
+		}
+	}
+}
+
+func (a *dumpAnalysis) checkServiceSync() {
+	data, ok := a.contents["service-sync.json"]
+	if !ok {
+		return
+	}
+	state := []DumpServiceSync{}
+	if err := jsonencoding.Unmarshal(data, &state); err != nil {
+		a.add(FindingWarning, "service-sync.json", "could not be read: %s", err)
+		return
+	}
+	for _, service := range state {
+		if !service.ServicePresent {
+			a.add(FindingWarning, "service/"+service.Address, "defined (origin %s) but no service exists for it", service.Origin)
+		}
+	}
+}
+
+// AnalyzeDump reads a dump written by SkupperDump and reports common
+// problems with the site it describes, most severe first
+func AnalyzeDump(file string) (*DumpManifest, []DumpFinding, error) {
+	contents, err := readDump(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := DumpManifest{}
+	encoded, ok := contents[DumpManifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("Not a skupper dump, or written by an older version: no %s", DumpManifestFile)
+	}
+	if err := jsonencoding.Unmarshal(encoded, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("Invalid %s: %w", DumpManifestFile, err)
+	}
+
+	a := &dumpAnalysis{contents: contents}
+	a.checkSite()
+	a.checkDeployments()
+	a.checkPods()
+	a.checkTokens()
+	a.checkServiceSync()
+	a.checkEvents()
+	for _, entry := range manifest.Entries {
+		if entry.Error != "" {
+			a.add(FindingInfo, entry.Description, "not collected: %s", entry.Error)
+		}
+	}
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		return severityRank(a.findings[i].Severity) < severityRank(a.findings[j].Severity)
+	})
+	return &manifest, a.findings, nil
+}
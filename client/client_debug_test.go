@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	jsonencoding "encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor(true)
+	r.addHost("east.example.com")
+	r.addHost("example.com")
+	r.addHost("localhost")
+	r.addCredential("s3cret")
+	input := "connecting to east.example.com (10.1.2.3) from example.com as admin:s3cret, then 10.1.2.3 and 127.0.0.1 via localhost"
+	assert.Equal(t, string(r.redact([]byte(input))), "connecting to redacted-host-1 (redacted-ip-1) from redacted-host-2 as admin:redacted-credential-1, then redacted-ip-1 and 127.0.0.1 via localhost")
+
+	r = newRedactor(false)
+	r.addHost("east.example.com")
+	assert.Equal(t, string(r.redact([]byte(input))), input)
+
+	r = newRedactor(true)
+	r.addHost("fd00::10")
+	input = "pod fd00::10 and peer [2001:db8::1]:55671 from fd00:0:0:0:0:0:0:10, not ::1 or :: at 12:30:45"
+	assert.Equal(t, string(r.redact([]byte(input))), "pod redacted-ip-1 and peer [redacted-ip-2]:55671 from redacted-ip-1, not ::1 or :: at 12:30:45")
+}
+
+func TestCollectSensitiveValues(t *testing.T) {
+	cli, err := newMockClient("dump-hosts", "", "")
+	assert.Assert(t, err)
+	definition, err := jsonencoding.Marshal(types.ServiceInterface{
+		Address:  "db",
+		Protocol: "tcp",
+		Port:     5432,
+		External: &types.ServiceExternal{Type: types.ExternalTypeIngress, Host: "db.apps.example.com"},
+		Targets: []types.ServiceInterfaceTarget{
+			{Name: "db", Host: "db.internal.example.com"},
+		},
+	})
+	assert.Assert(t, err)
+	_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: types.ServiceInterfaceConfigMap},
+		Data:       map[string]string{"db": string(definition)},
+	})
+	assert.Assert(t, err)
+	_, err = cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).Create(&networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "skupper"},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{Host: "inter-router.apps.example.com"}},
+		},
+	})
+	assert.Assert(t, err)
+
+	r := newRedactor(true)
+	cli.collectSensitiveValues(r)
+	input := "db.internal.example.com db.apps.example.com inter-router.apps.example.com"
+	assert.Equal(t, string(r.redact([]byte(input))), "redacted-host-2 redacted-host-1 redacted-host-3")
+}
+
+func TestSkupperDumpAnalyze(t *testing.T) {
+	if *clusterRun {
+		t.Skip("Only runs against the mock client")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "skupper-dump")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "east.yaml")
+	assert.Assert(t, ioutil.WriteFile(tokenFile, []byte(testToken), 0644))
+	dumpFile := filepath.Join(dir, "dump.tar.gz")
+
+	cli, err := newMockClient("dump-west", "", "")
+	assert.Assert(t, err)
+	manifest, err := ReadSiteManifest([]byte(testManifest))
+	assert.Assert(t, err)
+	manifest.Links[0].Token = tokenFile
+	_, err = cli.SiteManifestApply(ctx, manifest, false)
+	assert.Assert(t, err)
+	_, err = cli.KubeClient.CoreV1().Events(cli.Namespace).Create(&corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "east.1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Secret", Name: "east"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "LinkFailed",
+		Message:        "Could not connect to east.example.com at 10.1.2.3",
+	})
+	assert.Assert(t, err)
+
+	_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Create(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   types.ServiceInterfaceConfigMap + "-3",
+			Labels: map[string]string{types.InternalTypeQualifier: types.TypeServiceDefinitions},
+		},
+		Data: map[string]string{"sharded": `{"address": "sharded", "protocol": "tcp", "port": 8080}`},
+	})
+	assert.Assert(t, err)
+
+	assert.Assert(t, cli.SkupperDump(ctx, dumpFile, "test", true))
+	contents, err := readDump(dumpFile)
+	assert.Assert(t, err)
+	for _, path := range []string{DumpManifestFile, "versions.txt", "configmaps/skupper-site.yaml", "deployments/skupper-router.yaml", "secrets.yaml", "events.yaml", "service-sync.json", "configmaps/skupper-services-3.yaml"} {
+		_, ok := contents[path]
+		assert.Assert(t, ok, "%s not in dump", path)
+	}
+	for path, data := range contents {
+		assert.Assert(t, !bytes.Contains(data, []byte("east.example.com")), "hostname not redacted in %s", path)
+		assert.Assert(t, !bytes.Contains(data, []byte("10.1.2.3")), "address not redacted in %s", path)
+	}
+	// secrets are described but their contents are not included
+	assert.Assert(t, strings.Contains(string(contents["secrets.yaml"]), "name: east"))
+
+	dump, findings, err := AnalyzeDump(dumpFile)
+	assert.Assert(t, err)
+	assert.Equal(t, dump.Namespace, "dump-west")
+	assert.Assert(t, dump.Redacted)
+	assert.Equal(t, dump.Versions["client"], "test")
+	found := map[string]DumpFinding{}
+	for _, finding := range findings {
+		found[finding.Subject] = finding
+	}
+	// nothing runs against the mock client, so the router is not ready
+	assert.Equal(t, found[types.TransportDeploymentName].Severity, FindingError)
+	assert.Equal(t, found["secret/east"].Message, "LinkFailed: Could not connect to redacted-host-1 at redacted-ip-1")
+	assert.Equal(t, found["service/backend"].Severity, FindingWarning)
+	assert.Equal(t, findings[0].Severity, FindingError)
+}
@@ -67,7 +67,7 @@ func (cli *VanClient) isOwnToken(ctx context.Context, secretFile string) (bool,
 	if siteConfig == nil {
 		return false, fmt.Errorf("No site config")
 	}
-	return siteConfig.Reference.UID == string(generatedBy), nil
+	return siteConfig.SiteId == string(generatedBy), nil
 }
 
 func (cli *VanClient) ConnectorCreateFromFile(ctx context.Context, secretFile string, options types.ConnectorCreateOptions) (*corev1.Secret, error) {
@@ -146,6 +146,12 @@ func (cli *VanClient) ConnectorCreateSecretFromFile(ctx context.Context, secretF
 			secret.ObjectMeta.Labels = map[string]string{
 				"skupper.io/type": "connection-token",
 			}
+			if options.Cost != 0 {
+				if secret.ObjectMeta.Annotations == nil {
+					secret.ObjectMeta.Annotations = map[string]string{}
+				}
+				secret.ObjectMeta.Annotations[types.TokenCost] = strconv.Itoa(int(options.Cost))
+			}
 			secret.ObjectMeta.SetOwnerReferences([]metav1.OwnerReference{
 				kube.GetDeploymentOwnerReference(current),
 			})
@@ -163,6 +169,32 @@ func (cli *VanClient) ConnectorCreateSecretFromFile(ctx context.Context, secretF
 	}
 }
 
+// getTokenRouterReplicas returns the number of router replicas at the
+// site that generated the token
+func getTokenRouterReplicas(secret *corev1.Secret) int {
+	if value, ok := secret.ObjectMeta.Annotations[types.TokenRouterReplicas]; ok {
+		if replicas, err := strconv.Atoi(value); err == nil && replicas > 1 {
+			return replicas
+		}
+	}
+	return 1
+}
+
+// setReplicaHostPort points the connector at the router replica with
+// the given index, if the token gives a host and port for it
+func setReplicaHostPort(connector *qdr.Connector, secret *corev1.Secret, index int) {
+	role := "inter-router"
+	if connector.Role == qdr.RoleEdge {
+		role = "edge"
+	}
+	host, ok1 := secret.ObjectMeta.Annotations[getReplicaAnnotation(role+"-host", index)]
+	port, ok2 := secret.ObjectMeta.Annotations[getReplicaAnnotation(role+"-port", index)]
+	if ok1 && ok2 {
+		connector.Host = host
+		connector.Port = port
+	}
+}
+
 func (cli *VanClient) ConnectorCreate(ctx context.Context, secret *corev1.Secret, options types.ConnectorCreateOptions) error {
 
 	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -193,7 +225,18 @@ func (cli *VanClient) ConnectorCreate(ctx context.Context, secret *corev1.Secret
 			connector.Port = secret.ObjectMeta.Annotations["inter-router-port"]
 			connector.Role = qdr.RoleInterRouter
 		}
-		current.AddConnector(connector)
+		// make a connector per router replica of the remote site, so
+		// that the link survives the loss of any. Where the token
+		// gives the host and port of each replica, each connector
+		// targets its own; otherwise they share the host of the site,
+		// whose ingress decides which replica each reaches.
+		current.RemoveConnectorReplicas(options.Name)
+		for i := 0; i < getTokenRouterReplicas(secret); i++ {
+			replica := connector
+			replica.Name = qdr.GetConnectorReplicaName(options.Name, i)
+			setReplicaHostPort(&replica, secret, i)
+			current.AddConnector(replica)
+		}
 		current.UpdateConfigMap(configmap)
 		_, err = cli.KubeClient.CoreV1().ConfigMaps(options.SkupperNamespace).Update(configmap)
 		if err != nil {
@@ -9,7 +9,10 @@ import (
 
 	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
 	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var lightRed string = "\033[1;31m"
@@ -169,6 +172,78 @@ func setupTwoNamespaces(t *testing.T, ctx context.Context, tokenCreatorNamespace
 	return tokenCreatorClient, tokenUserClient, nil
 }
 
+func TestConnectorCreateReplicas(t *testing.T) {
+	if *clusterRun {
+		t.Skip("Only runs against the mock client")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ns := "namespace-for-testconnectorcreatereplicas"
+	cli, err := newMockClient(ns, "", "")
+	assert.Assert(t, err)
+	configureSiteAndCreateRouter(t, ctx, cli, "public")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "conn1",
+			Annotations: map[string]string{
+				"inter-router-host":       "remote.example.com",
+				"inter-router-port":       "55671",
+				types.TokenRouterReplicas: "3",
+			},
+		},
+	}
+	err = cli.ConnectorCreate(ctx, secret, types.ConnectorCreateOptions{
+		Name:             "conn1",
+		SkupperNamespace: ns,
+		Cost:             1,
+	})
+	assert.Assert(t, err)
+	getConnectors := func() map[string]qdr.Connector {
+		configmap, err := kube.GetConfigMap("skupper-internal", ns, cli.KubeClient)
+		assert.Assert(t, err)
+		config, err := qdr.GetRouterConfigFromConfigMap(configmap)
+		assert.Assert(t, err)
+		return config.Connectors
+	}
+	connectors := getConnectors()
+	assert.Equal(t, len(connectors), 3)
+	for _, name := range []string{"conn1", "conn1#1", "conn1#2"} {
+		connector, ok := connectors[name]
+		assert.Assert(t, ok, name)
+		assert.Equal(t, connector.Host, "remote.example.com")
+		assert.Equal(t, connector.SslProfile, "conn1-profile")
+	}
+
+	// each connector targets its own replica where the token says
+	// where each is
+	secret.ObjectMeta.Annotations["inter-router-host-0"] = "remote-0.example.com"
+	secret.ObjectMeta.Annotations["inter-router-port-0"] = "443"
+	secret.ObjectMeta.Annotations["inter-router-host-2"] = "remote-2.example.com"
+	secret.ObjectMeta.Annotations["inter-router-port-2"] = "443"
+	err = cli.ConnectorCreate(ctx, secret, types.ConnectorCreateOptions{
+		Name:             "conn1",
+		SkupperNamespace: ns,
+		Cost:             1,
+	})
+	assert.Assert(t, err)
+	connectors = getConnectors()
+	assert.Equal(t, len(connectors), 3)
+	assert.Equal(t, connectors["conn1"].Host, "remote-0.example.com")
+	assert.Equal(t, connectors["conn1"].Port, "443")
+	assert.Equal(t, connectors["conn1#1"].Host, "remote.example.com")
+	assert.Equal(t, connectors["conn1#1"].Port, "55671")
+	assert.Equal(t, connectors["conn1#2"].Host, "remote-2.example.com")
+
+	err = cli.ConnectorRemove(ctx, types.ConnectorRemoveOptions{
+		Name:             "conn1",
+		SkupperNamespace: ns,
+	})
+	assert.Assert(t, err)
+	assert.Equal(t, len(getConnectors()), 0)
+}
+
 func configureSiteAndCreateRouter(t *testing.T, ctx context.Context, cli *VanClient, name string) {
 	routerCreateOpts := types.SiteConfigSpec{
 		SkupperName:       "skupper",
@@ -27,6 +27,9 @@ func (cli *VanClient) ConnectorRemove(ctx context.Context, options types.Connect
 		}
 
 		found, connector := current.RemoveConnector(options.Name)
+		if current.RemoveConnectorReplicas(options.Name) > 0 {
+			found = true
+		}
 		if found || options.ForceCurrent {
 			if connector.SslProfile != "" {
 				current.RemoveSslProfile(connector.SslProfile)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -38,6 +39,11 @@ func annotateConnectionToken(secret *corev1.Secret, role string, host string, po
 	secret.ObjectMeta.Annotations[role+"-port"] = port
 }
 
+func annotateReplicaConnectionToken(secret *corev1.Secret, role string, index int, host string, port string) {
+	secret.ObjectMeta.Annotations[getReplicaAnnotation(role+"-host", index)] = host
+	secret.ObjectMeta.Annotations[getReplicaAnnotation(role+"-port", index)] = port
+}
+
 func configureHostPortsFromRoutes(result *RouterHostPorts, cli *VanClient, namespace string) (bool, error) {
 	if namespace == "" {
 		namespace = cli.Namespace
@@ -64,43 +70,154 @@ func configureHostPortsFromRoutes(result *RouterHostPorts, cli *VanClient, names
 	}
 }
 
-func configureHostPorts(result *RouterHostPorts, cli *VanClient, namespace string) bool {
+func setHostPorts(result *RouterHostPorts, host string, interRouterPort string, edgePort string) {
+	result.Hosts = host
+	result.InterRouter.Host = host
+	result.InterRouter.Port = interRouterPort
+	result.Edge.Host = host
+	result.Edge.Port = edgePort
+}
+
+func configureLocalHostPorts(result *RouterHostPorts, namespace string) {
+	result.LocalOnly = true
+	setHostPorts(result, fmt.Sprintf("skupper-internal.%s", namespace), strconv.Itoa(int(types.InterRouterListenerPort)), strconv.Itoa(int(types.EdgeListenerPort)))
+}
+
+func configureHostPortsFromNodePorts(result *RouterHostPorts, cli *VanClient, namespace string, spec *types.SiteConfigSpec) error {
+	service, err := cli.KubeClient.CoreV1().Services(namespace).Get(types.InterRouterProfile, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	host := spec.IngressHost
+	if host == "" {
+		// listing nodes needs cluster wide permissions, which a
+		// site controller watching only its own namespace lacks
+		host, err = kube.GetNodeAddress(cli.KubeClient)
+		if err != nil {
+			return err
+		}
+	}
+	setHostPorts(result, host, "", "")
+	for _, port := range service.Spec.Ports {
+		if port.NodePort == 0 {
+			continue
+		}
+		if port.Name == types.InterRouterRole {
+			result.InterRouter.Port = strconv.Itoa(int(port.NodePort))
+		} else if port.Name == types.EdgeRole {
+			result.Edge.Port = strconv.Itoa(int(port.NodePort))
+		}
+	}
+	if result.InterRouter.Port == "" || result.Edge.Port == "" {
+		return fmt.Errorf("Node ports not yet allocated for service %s", service.ObjectMeta.Name)
+	}
+	return nil
+}
+
+func configureHostPortsFromIngress(result *RouterHostPorts, spec *types.SiteConfigSpec, namespace string) error {
+	if spec.IngressHost == "" {
+		return fmt.Errorf("An ingress host is required for %s", types.IngressNginxIngressString)
+	}
+	result.InterRouter.Host = types.GetIngressHostForRole(types.InterRouterRole, namespace, spec.IngressHost)
+	result.InterRouter.Port = "443"
+	result.Edge.Host = types.GetIngressHostForRole(types.EdgeRole, namespace, spec.IngressHost)
+	result.Edge.Port = "443"
+	result.Hosts = result.Edge.Host + "," + result.InterRouter.Host
+	return nil
+}
+
+// configureHostPortsFromIngressType determines the host and ports for
+// the ingress strategy explicitly configured for the site
+func configureHostPortsFromIngressType(result *RouterHostPorts, cli *VanClient, namespace string, spec *types.SiteConfigSpec) error {
+	switch spec.Ingress {
+	case types.IngressRouteString:
+		ok, err := configureHostPortsFromRoutes(result, cli, namespace)
+		if err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("Routes for site not found")
+		}
+	case types.IngressLoadBalancerString:
+		if spec.IngressHost != "" {
+			// the host is overridden below, so there is no need to
+			// wait for one to be allocated
+			configureLocalHostPorts(result, namespace)
+			break
+		}
+		service, err := cli.KubeClient.CoreV1().Services(namespace).Get(types.InterRouterProfile, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		host := kube.GetLoadBalancerHostOrIP(service)
+		if host == "" {
+			return fmt.Errorf("LoadBalancer Host/IP not yet allocated for service %s", service.ObjectMeta.Name)
+		}
+		setHostPorts(result, host, strconv.Itoa(int(types.InterRouterListenerPort)), strconv.Itoa(int(types.EdgeListenerPort)))
+	case types.IngressNodePortString:
+		return configureHostPortsFromNodePorts(result, cli, namespace, spec)
+	case types.IngressNginxIngressString:
+		return configureHostPortsFromIngress(result, spec, namespace)
+	case types.IngressNoneString:
+		configureLocalHostPorts(result, namespace)
+	default:
+		return fmt.Errorf("Unrecognised ingress type %s", spec.Ingress)
+	}
+	return nil
+}
+
+// overrideHostPorts applies any host or ports the site is configured to
+// advertise in place of those determined by the ingress strategy
+func overrideHostPorts(result *RouterHostPorts, spec *types.SiteConfigSpec) {
+	if spec.IngressHost != "" && spec.Ingress != types.IngressNginxIngressString {
+		result.LocalOnly = false
+		result.Hosts = spec.IngressHost
+		result.InterRouter.Host = spec.IngressHost
+		result.Edge.Host = spec.IngressHost
+	}
+	if spec.IngressInterRouterPort != 0 {
+		result.InterRouter.Port = strconv.Itoa(spec.IngressInterRouterPort)
+	}
+	if spec.IngressEdgePort != 0 {
+		result.Edge.Port = strconv.Itoa(spec.IngressEdgePort)
+	}
+}
+
+func configureHostPorts(result *RouterHostPorts, cli *VanClient, namespace string, spec *types.SiteConfigSpec) error {
 	if namespace == "" {
 		namespace = cli.Namespace
 	}
+	if spec != nil && spec.Ingress != "" {
+		if err := configureHostPortsFromIngressType(result, cli, namespace, spec); err != nil {
+			return err
+		}
+		overrideHostPorts(result, spec)
+		return nil
+	}
 	ok, err := configureHostPortsFromRoutes(result, cli, namespace)
 	if err != nil {
-		return false
-	} else if ok {
-		return ok
-	} else {
+		return err
+	} else if !ok {
 		service, err := cli.KubeClient.CoreV1().Services(namespace).Get("skupper-internal", metav1.GetOptions{})
 		if err != nil {
-			return false
-		} else {
-			if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
-				host := kube.GetLoadBalancerHostOrIp(service)
-				if host != "" {
-					result.Hosts = host
-					result.InterRouter.Host = host
-					result.InterRouter.Port = "55671"
-					result.Edge.Host = host
-					result.Edge.Port = "45671"
-					return true
-				} else {
-					fmt.Printf("LoadBalancer Host/IP not yet allocated for service %s, ", service.ObjectMeta.Name)
-				}
+			return err
+		}
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			host := kube.GetLoadBalancerHostOrIp(service)
+			if host != "" {
+				setHostPorts(result, host, strconv.Itoa(int(types.InterRouterListenerPort)), strconv.Itoa(int(types.EdgeListenerPort)))
+				ok = true
+			} else {
+				fmt.Printf("LoadBalancer Host/IP not yet allocated for service %s, ", service.ObjectMeta.Name)
 			}
-			result.LocalOnly = true
-			host := fmt.Sprintf("skupper-internal.%s", namespace)
-			result.Hosts = host
-			result.InterRouter.Host = host
-			result.InterRouter.Port = "55671"
-			result.Edge.Host = host
-			result.Edge.Port = "45671"
-			return true
 		}
+		if !ok {
+			configureLocalHostPorts(result, namespace)
+		}
+	}
+	if spec != nil {
+		overrideHostPorts(result, spec)
 	}
+	return nil
 }
 
 func (cli *VanClient) ConnectorTokenCreate(ctx context.Context, subject string, namespace string) (*corev1.Secret, bool, error) {
@@ -124,11 +241,18 @@ func (cli *VanClient) ConnectorTokenCreate(ctx context.Context, subject string,
 	if err != nil {
 		return nil, false, err
 	}
+	siteConfig, err := cli.SiteConfigInspect(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	var spec *types.SiteConfigSpec
+	if siteConfig != nil {
+		spec = &siteConfig.Spec
+	}
 	//get the host and port for inter-router and edge
 	var hostPorts RouterHostPorts
-	if !configureHostPorts(&hostPorts, cli, namespace) {
-		//TODO: return the actual error
-		return nil, false, fmt.Errorf("Could not determine host/ports for token")
+	if err := configureHostPorts(&hostPorts, cli, namespace, spec); err != nil {
+		return nil, false, fmt.Errorf("Could not determine host/ports for token: %s", err)
 	}
 	secret := certs.GenerateSecret(subject, subject, hostPorts.Hosts, caSecret)
 	annotateConnectionToken(&secret, "inter-router", hostPorts.InterRouter.Host, hostPorts.InterRouter.Port)
@@ -138,12 +262,22 @@ func (cli *VanClient) ConnectorTokenCreate(ctx context.Context, subject string,
 	}
 	secret.ObjectMeta.Labels[types.SkupperTypeQualifier] = types.TypeToken
 	// Store our siteID in the token, to prevent later self-connection.
-	siteConfig, err := cli.SiteConfigInspect(ctx, nil)
-	if err != nil {
-		return nil, false, err
-	}
 	if siteConfig != nil {
-		secret.ObjectMeta.Annotations[types.TokenGeneratedBy] = siteConfig.Reference.UID
+		secret.ObjectMeta.Annotations[types.TokenGeneratedBy] = siteConfig.SiteId
+		secret.ObjectMeta.Annotations[types.TokenSiteName] = siteConfig.Spec.SkupperName
+	}
+	// Let the connecting site know to make a link to each replica and,
+	// where each is exposed separately, where to reach it
+	if spec != nil && getRouterReplicas(*spec) > 1 {
+		secret.ObjectMeta.Annotations[types.TokenRouterReplicas] = strconv.Itoa(int(getRouterReplicas(*spec)))
+		replicas, err := cli.getRouterReplicaHostPorts(*spec, spec.GetIngress(cli.RouteClient != nil), namespace, &hostPorts)
+		if err != nil {
+			return nil, false, fmt.Errorf("Could not determine host/ports of router replicas for token: %s", err)
+		}
+		for i, replica := range replicas {
+			annotateReplicaConnectionToken(&secret, "inter-router", i, replica.InterRouter.Host, replica.InterRouter.Port)
+			annotateReplicaConnectionToken(&secret, "edge", i, replica.Edge.Host, replica.Edge.Port)
+		}
 	}
 	return &secret, hostPorts.LocalOnly, nil
 }
@@ -2,11 +2,19 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
 	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/certs"
 	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestConnectorCreateTokenInterior(t *testing.T) {
@@ -61,3 +69,131 @@ func TestConnectorCreateTokenEdge(t *testing.T) {
 	assert.Error(t, err, "Edge configuration cannot accept connections", "Expect error when edge")
 
 }
+
+func TestConnectorCreateTokenReplicas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cli, err := newMockClient("skupper", "", "")
+	assert.Assert(t, err)
+	siteConfig, err := cli.SiteConfigCreate(ctx, types.SiteConfigSpec{
+		SkupperName:      "skupper",
+		EnableController: true,
+		ClusterLocal:     true,
+		Replicas:         2,
+	})
+	assert.Assert(t, err)
+	assert.Assert(t, cli.RouterCreate(ctx, *siteConfig))
+	internal, err := cli.KubeClient.CoreV1().Secrets("skupper").Get(types.InterRouterProfile, metav1.GetOptions{})
+	assert.Assert(t, err)
+	hosts, err := certs.GetSecretHosts(internal)
+	assert.Assert(t, err)
+	assert.Assert(t, hosts["skupper-router-1.skupper"])
+
+	token, _, err := cli.ConnectorTokenCreate(ctx, "conn1", "")
+	assert.Assert(t, err)
+	assert.Equal(t, token.ObjectMeta.Annotations[types.TokenRouterReplicas], "2")
+	for i := 0; i < 2; i++ {
+		host := fmt.Sprintf("skupper-router-%d.skupper", i)
+		assert.Equal(t, token.ObjectMeta.Annotations[fmt.Sprintf("inter-router-host-%d", i)], host)
+		assert.Equal(t, token.ObjectMeta.Annotations[fmt.Sprintf("inter-router-port-%d", i)], "55671")
+		assert.Equal(t, token.ObjectMeta.Annotations[fmt.Sprintf("edge-host-%d", i)], host)
+		assert.Equal(t, token.ObjectMeta.Annotations[fmt.Sprintf("edge-port-%d", i)], "45671")
+	}
+}
+
+func TestConfigureHostPortsForIngress(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: corev1.NodeExternalIP, Address: "192.0.2.1"},
+			},
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: types.InterRouterProfile, Namespace: "skupper"},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{
+				{Name: types.InterRouterRole, Port: types.InterRouterListenerPort, NodePort: 30001},
+				{Name: types.EdgeRole, Port: types.EdgeListenerPort, NodePort: 30002},
+			},
+		},
+	}
+	cli := &VanClient{
+		Namespace:  "skupper",
+		KubeClient: fake.NewSimpleClientset(node, service),
+	}
+
+	testcases := []struct {
+		doc                 string
+		spec                types.SiteConfigSpec
+		expectedHosts       string
+		expectedInterRouter HostPort
+		expectedEdge        HostPort
+		expectedLocalOnly   bool
+		expectedError       string
+	}{
+		{
+			doc:                 "node port",
+			spec:                types.SiteConfigSpec{Ingress: types.IngressNodePortString},
+			expectedHosts:       "192.0.2.1",
+			expectedInterRouter: HostPort{Host: "192.0.2.1", Port: "30001"},
+			expectedEdge:        HostPort{Host: "192.0.2.1", Port: "30002"},
+		},
+		{
+			doc:                 "node port behind external load balancer",
+			spec:                types.SiteConfigSpec{Ingress: types.IngressNodePortString, IngressHost: "lb.example.com", IngressInterRouterPort: 443, IngressEdgePort: 8443},
+			expectedHosts:       "lb.example.com",
+			expectedInterRouter: HostPort{Host: "lb.example.com", Port: "443"},
+			expectedEdge:        HostPort{Host: "lb.example.com", Port: "8443"},
+		},
+		{
+			doc:                 "nginx ingress",
+			spec:                types.SiteConfigSpec{Ingress: types.IngressNginxIngressString, IngressHost: "example.com"},
+			expectedHosts:       "edge-skupper.example.com,inter-router-skupper.example.com",
+			expectedInterRouter: HostPort{Host: "inter-router-skupper.example.com", Port: "443"},
+			expectedEdge:        HostPort{Host: "edge-skupper.example.com", Port: "443"},
+		},
+		{
+			doc:                 "none",
+			spec:                types.SiteConfigSpec{Ingress: types.IngressNoneString},
+			expectedHosts:       "skupper-internal.skupper",
+			expectedInterRouter: HostPort{Host: "skupper-internal.skupper", Port: "55671"},
+			expectedEdge:        HostPort{Host: "skupper-internal.skupper", Port: "45671"},
+			expectedLocalOnly:   true,
+		},
+		{
+			doc:           "route without route support",
+			spec:          types.SiteConfigSpec{Ingress: types.IngressRouteString},
+			expectedError: "Routes for site not found",
+		},
+	}
+	for _, c := range testcases {
+		var result RouterHostPorts
+		err := configureHostPorts(&result, cli, "", &c.spec)
+		if c.expectedError != "" {
+			assert.ErrorContains(t, err, c.expectedError, c.doc)
+			continue
+		}
+		assert.Assert(t, err, c.doc)
+		assert.Equal(t, result.Hosts, c.expectedHosts, c.doc)
+		assert.Equal(t, result.InterRouter, c.expectedInterRouter, c.doc)
+		assert.Equal(t, result.Edge, c.expectedEdge, c.doc)
+		assert.Equal(t, result.LocalOnly, c.expectedLocalOnly, c.doc)
+	}
+
+	// a site controller watching only its own namespace cannot list
+	// nodes, which is not needed when the host is overridden
+	cli.KubeClient.(*fake.Clientset).PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(corev1.Resource("nodes"), "", fmt.Errorf("cluster scoped"))
+	})
+	var result RouterHostPorts
+	assert.ErrorContains(t, configureHostPorts(&result, cli, "", &types.SiteConfigSpec{Ingress: types.IngressNodePortString}), "forbidden")
+	result = RouterHostPorts{}
+	assert.Assert(t, configureHostPorts(&result, cli, "", &types.SiteConfigSpec{Ingress: types.IngressNodePortString, IngressHost: "lb.example.com"}))
+	assert.Equal(t, result.InterRouter, HostPort{Host: "lb.example.com", Port: "30001"})
+	assert.Equal(t, result.Edge, HostPort{Host: "lb.example.com", Port: "30002"})
+}
@@ -0,0 +1,331 @@
+package client
+
+import (
+	jsonencoding "encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+// Prefixes for the skupper-site keys holding the pod settings of each
+// component, e.g. router-cpu or controller-node-selector
+const (
+	RouterPodSettingsPrefix     string = "router"
+	ControllerPodSettingsPrefix string = "controller"
+)
+
+// Suffixes of the skupper-site keys for pod settings
+const (
+	PodSettingsCpu                      string = "-cpu"
+	PodSettingsCpuLimit                 string = "-cpu-limit"
+	PodSettingsMemory                   string = "-memory"
+	PodSettingsMemoryLimit              string = "-memory-limit"
+	PodSettingsNodeSelector             string = "-node-selector"
+	PodSettingsTolerations              string = "-tolerations"
+	PodSettingsAffinity                 string = "-affinity"
+	PodSettingsPriorityClass            string = "-priority-class"
+	PodSettingsPodSecurityContext       string = "-pod-security-context"
+	PodSettingsContainerSecurityContext string = "-security-context"
+	PodSettingsSidecarCpu               string = "-sidecar-cpu"
+	PodSettingsSidecarCpuLimit          string = "-sidecar-cpu-limit"
+	PodSettingsSidecarMemory            string = "-sidecar-memory"
+	PodSettingsSidecarMemoryLimit       string = "-sidecar-memory-limit"
+)
+
+const ImagePullSecretsKey string = "image-pull-secrets"
+
+func PodSettingsKeys(prefix string) []string {
+	return []string{
+		prefix + PodSettingsCpu,
+		prefix + PodSettingsCpuLimit,
+		prefix + PodSettingsMemory,
+		prefix + PodSettingsMemoryLimit,
+		prefix + PodSettingsNodeSelector,
+		prefix + PodSettingsTolerations,
+		prefix + PodSettingsAffinity,
+		prefix + PodSettingsPriorityClass,
+		prefix + PodSettingsPodSecurityContext,
+		prefix + PodSettingsContainerSecurityContext,
+		prefix + PodSettingsSidecarCpu,
+		prefix + PodSettingsSidecarCpuLimit,
+		prefix + PodSettingsSidecarMemory,
+		prefix + PodSettingsSidecarMemoryLimit,
+	}
+}
+
+// defaultSidecarResources are given to sidecars (e.g. the oauth proxy
+// for the console) when the site does not specify any, so that pods
+// are not rejected by clusters requiring resource limits
+func defaultSidecarResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("32Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+	}
+}
+
+func parseQuantity(list *corev1.ResourceList, name corev1.ResourceName, key string, data map[string]string) error {
+	value := data[key]
+	if value == "" {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("Invalid value for %s: %s", key, err)
+	}
+	if *list == nil {
+		*list = corev1.ResourceList{}
+	}
+	(*list)[name] = quantity
+	return nil
+}
+
+// parseNodeSelector parses a comma separated list of key=value pairs
+func parseNodeSelector(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	selector := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid node selector %q; expected key=value", pair)
+		}
+		selector[parts[0]] = parts[1]
+	}
+	return selector, nil
+}
+
+func formatNodeSelector(selector map[string]string) string {
+	pairs := []string{}
+	for key, value := range selector {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// parseTolerations parses a comma separated list of tolerations, each
+// in the form key[=value][:effect] as used for taints by kubectl
+func parseTolerations(value string) ([]corev1.Toleration, error) {
+	if value == "" {
+		return nil, nil
+	}
+	tolerations := []corev1.Toleration{}
+	for _, item := range strings.Split(value, ",") {
+		toleration := corev1.Toleration{}
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			toleration.Effect = corev1.TaintEffect(item[i+1:])
+			item = item[:i]
+			switch toleration.Effect {
+			case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			default:
+				return nil, fmt.Errorf("Invalid toleration effect %q", toleration.Effect)
+			}
+		}
+		if parts := strings.SplitN(item, "=", 2); len(parts) == 2 {
+			toleration.Key = parts[0]
+			toleration.Value = parts[1]
+			toleration.Operator = corev1.TolerationOpEqual
+		} else {
+			toleration.Key = item
+			toleration.Operator = corev1.TolerationOpExists
+		}
+		if toleration.Key == "" {
+			return nil, fmt.Errorf("Invalid toleration %q; a key is required", item)
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	return tolerations, nil
+}
+
+func formatTolerations(tolerations []corev1.Toleration) string {
+	items := []string{}
+	for _, toleration := range tolerations {
+		item := toleration.Key
+		if toleration.Operator != corev1.TolerationOpExists {
+			item += "=" + toleration.Value
+		}
+		if toleration.Effect != "" {
+			item += ":" + string(toleration.Effect)
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ",")
+}
+
+func parseJsonSetting(key string, data map[string]string, target interface{}) error {
+	value := data[key]
+	if value == "" {
+		return nil
+	}
+	if err := jsonencoding.Unmarshal([]byte(value), target); err != nil {
+		return fmt.Errorf("Invalid value for %s: %s", key, err)
+	}
+	return nil
+}
+
+func formatJsonSetting(key string, data map[string]string, value interface{}) error {
+	encoded, err := jsonencoding.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Could not encode %s: %s", key, err)
+	}
+	data[key] = string(encoded)
+	return nil
+}
+
+// ParsePodSettings reads the pod settings for a component from the
+// keys of the skupper-site config map with the given prefix
+func ParsePodSettings(prefix string, data map[string]string) (types.PodSettings, error) {
+	settings := types.PodSettings{}
+	if err := parseQuantity(&settings.Resources.Requests, corev1.ResourceCPU, prefix+PodSettingsCpu, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.Resources.Limits, corev1.ResourceCPU, prefix+PodSettingsCpuLimit, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.Resources.Requests, corev1.ResourceMemory, prefix+PodSettingsMemory, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.Resources.Limits, corev1.ResourceMemory, prefix+PodSettingsMemoryLimit, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.SidecarResources.Requests, corev1.ResourceCPU, prefix+PodSettingsSidecarCpu, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.SidecarResources.Limits, corev1.ResourceCPU, prefix+PodSettingsSidecarCpuLimit, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.SidecarResources.Requests, corev1.ResourceMemory, prefix+PodSettingsSidecarMemory, data); err != nil {
+		return settings, err
+	}
+	if err := parseQuantity(&settings.SidecarResources.Limits, corev1.ResourceMemory, prefix+PodSettingsSidecarMemoryLimit, data); err != nil {
+		return settings, err
+	}
+	var err error
+	if settings.NodeSelector, err = parseNodeSelector(data[prefix+PodSettingsNodeSelector]); err != nil {
+		return settings, err
+	}
+	if settings.Tolerations, err = parseTolerations(data[prefix+PodSettingsTolerations]); err != nil {
+		return settings, err
+	}
+	if err := parseJsonSetting(prefix+PodSettingsAffinity, data, &settings.Affinity); err != nil {
+		return settings, err
+	}
+	settings.PriorityClassName = data[prefix+PodSettingsPriorityClass]
+	if err := parseJsonSetting(prefix+PodSettingsPodSecurityContext, data, &settings.PodSecurityContext); err != nil {
+		return settings, err
+	}
+	if err := parseJsonSetting(prefix+PodSettingsContainerSecurityContext, data, &settings.ContainerSecurityContext); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// FormatPodSettings writes the pod settings for a component into the
+// keys of the skupper-site config map with the given prefix
+func FormatPodSettings(prefix string, settings types.PodSettings, data map[string]string) error {
+	quantities := []struct {
+		list corev1.ResourceList
+		name corev1.ResourceName
+		key  string
+	}{
+		{settings.Resources.Requests, corev1.ResourceCPU, prefix + PodSettingsCpu},
+		{settings.Resources.Limits, corev1.ResourceCPU, prefix + PodSettingsCpuLimit},
+		{settings.Resources.Requests, corev1.ResourceMemory, prefix + PodSettingsMemory},
+		{settings.Resources.Limits, corev1.ResourceMemory, prefix + PodSettingsMemoryLimit},
+		{settings.SidecarResources.Requests, corev1.ResourceCPU, prefix + PodSettingsSidecarCpu},
+		{settings.SidecarResources.Limits, corev1.ResourceCPU, prefix + PodSettingsSidecarCpuLimit},
+		{settings.SidecarResources.Requests, corev1.ResourceMemory, prefix + PodSettingsSidecarMemory},
+		{settings.SidecarResources.Limits, corev1.ResourceMemory, prefix + PodSettingsSidecarMemoryLimit},
+	}
+	for _, q := range quantities {
+		if quantity, ok := q.list[q.name]; ok {
+			data[q.key] = quantity.String()
+		}
+	}
+	if len(settings.NodeSelector) > 0 {
+		data[prefix+PodSettingsNodeSelector] = formatNodeSelector(settings.NodeSelector)
+	}
+	if len(settings.Tolerations) > 0 {
+		data[prefix+PodSettingsTolerations] = formatTolerations(settings.Tolerations)
+	}
+	if settings.Affinity != nil {
+		if err := formatJsonSetting(prefix+PodSettingsAffinity, data, settings.Affinity); err != nil {
+			return err
+		}
+	}
+	if settings.PriorityClassName != "" {
+		data[prefix+PodSettingsPriorityClass] = settings.PriorityClassName
+	}
+	if settings.PodSecurityContext != nil {
+		if err := formatJsonSetting(prefix+PodSettingsPodSecurityContext, data, settings.PodSecurityContext); err != nil {
+			return err
+		}
+	}
+	if settings.ContainerSecurityContext != nil {
+		if err := formatJsonSetting(prefix+PodSettingsContainerSecurityContext, data, settings.ContainerSecurityContext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseImagePullSecrets(value string) []string {
+	var secrets []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			secrets = append(secrets, name)
+		}
+	}
+	return secrets
+}
+
+func setPodSettings(ds *types.DeploymentSpec, settings types.PodSettings, imagePullSecrets []string) {
+	ds.Resources = settings.Resources
+	ds.NodeSelector = settings.NodeSelector
+	ds.Tolerations = settings.Tolerations
+	ds.Affinity = settings.Affinity
+	ds.PriorityClassName = settings.PriorityClassName
+	ds.PodSecurityContext = settings.PodSecurityContext
+	ds.ContainerSecurityContext = settings.ContainerSecurityContext
+	ds.SidecarResources = settings.SidecarResources
+	if len(ds.SidecarResources.Requests) == 0 && len(ds.SidecarResources.Limits) == 0 {
+		ds.SidecarResources = defaultSidecarResources()
+	}
+	ds.ImagePullSecrets = nil
+	for _, name := range imagePullSecrets {
+		ds.ImagePullSecrets = append(ds.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+}
+
+// getRouterReplicas returns the number of router pods for the site
+func getRouterReplicas(spec types.SiteConfigSpec) int32 {
+	if spec.Replicas > 1 {
+		return spec.Replicas
+	}
+	return 1
+}
+
+// setRouterPodSettings applies the pod settings of the site to the
+// router; multiple replicas are spread across nodes unless the site
+// specifies an affinity of its own
+func setRouterPodSettings(ds *types.DeploymentSpec, spec types.SiteConfigSpec) {
+	setPodSettings(ds, spec.Router, spec.ImagePullSecrets)
+	if getRouterReplicas(spec) > 1 && ds.Affinity == nil {
+		ds.Affinity = kube.PreferredPodAntiAffinity(map[string]string{
+			"skupper.io/component": types.TransportComponentName,
+		})
+	}
+}
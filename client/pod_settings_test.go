@@ -0,0 +1,88 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/skupperproject/skupper/api/types"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParsePodSettings(t *testing.T) {
+	data := map[string]string{
+		"router-cpu":                  "100m",
+		"router-memory-limit":         "256Mi",
+		"router-node-selector":        "zone=east,disk=ssd",
+		"router-tolerations":          "dedicated=skupper:NoSchedule,spot",
+		"router-priority-class":       "high",
+		"router-security-context":     `{"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]}}`,
+		"router-pod-security-context": `{"runAsNonRoot":true}`,
+		"router-sidecar-memory-limit": "64Mi",
+		"controller-cpu":              "50m",
+	}
+	settings, err := ParsePodSettings(RouterPodSettingsPrefix, data)
+	assert.Assert(t, err)
+	cpu := settings.Resources.Requests[corev1.ResourceCPU]
+	assert.Equal(t, cpu.Cmp(resource.MustParse("100m")), 0)
+	memory := settings.Resources.Limits[corev1.ResourceMemory]
+	assert.Equal(t, memory.Cmp(resource.MustParse("256Mi")), 0)
+	assert.DeepEqual(t, settings.NodeSelector, map[string]string{"zone": "east", "disk": "ssd"})
+	assert.DeepEqual(t, settings.Tolerations, []corev1.Toleration{
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "skupper", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "spot", Operator: corev1.TolerationOpExists},
+	})
+	assert.Equal(t, settings.PriorityClassName, "high")
+	assert.Assert(t, *settings.PodSecurityContext.RunAsNonRoot)
+	assert.Assert(t, !*settings.ContainerSecurityContext.AllowPrivilegeEscalation)
+	assert.Assert(t, settings.Affinity == nil)
+	sidecarMemory := settings.SidecarResources.Limits[corev1.ResourceMemory]
+	assert.Equal(t, sidecarMemory.Cmp(resource.MustParse("64Mi")), 0)
+
+	// formatting and parsing again yields the same settings
+	formatted := map[string]string{}
+	assert.Assert(t, FormatPodSettings(RouterPodSettingsPrefix, settings, formatted))
+	assert.Equal(t, formatted["router-node-selector"], "disk=ssd,zone=east")
+	assert.Equal(t, formatted["router-tolerations"], "dedicated=skupper:NoSchedule,spot")
+	reparsed, err := ParsePodSettings(RouterPodSettingsPrefix, formatted)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, reparsed.Tolerations, settings.Tolerations)
+	assert.DeepEqual(t, reparsed.PodSecurityContext, settings.PodSecurityContext)
+	assert.Equal(t, formatted["router-sidecar-memory-limit"], "64Mi")
+
+	controller, err := ParsePodSettings(ControllerPodSettingsPrefix, data)
+	assert.Assert(t, err)
+	assert.Equal(t, len(controller.Resources.Requests), 1)
+	assert.Assert(t, controller.NodeSelector == nil)
+}
+
+func TestParsePodSettingsInvalid(t *testing.T) {
+	testcases := []struct {
+		key           string
+		value         string
+		expectedError string
+	}{
+		{"router-cpu", "lots", "Invalid value for router-cpu"},
+		{"router-node-selector", "zone", "Invalid node selector \"zone\""},
+		{"router-tolerations", "dedicated=skupper:Sometimes", "Invalid toleration effect \"Sometimes\""},
+		{"router-tolerations", "=skupper", "a key is required"},
+		{"router-affinity", "{", "Invalid value for router-affinity"},
+	}
+	for _, c := range testcases {
+		_, err := ParsePodSettings(RouterPodSettingsPrefix, map[string]string{c.key: c.value})
+		assert.ErrorContains(t, err, c.expectedError, c.key)
+	}
+}
+
+func TestSetPodSettingsSidecarResources(t *testing.T) {
+	ds := types.DeploymentSpec{}
+	setPodSettings(&ds, types.PodSettings{}, nil)
+	limit := ds.SidecarResources.Limits[corev1.ResourceMemory]
+	assert.Equal(t, limit.Cmp(resource.MustParse("128Mi")), 0)
+
+	settings, err := ParsePodSettings(RouterPodSettingsPrefix, map[string]string{"router-sidecar-cpu-limit": "500m"})
+	assert.Assert(t, err)
+	setPodSettings(&ds, settings, nil)
+	assert.Equal(t, len(ds.SidecarResources.Requests), 0)
+	assert.Equal(t, len(ds.SidecarResources.Limits), 1)
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	routev1 "github.com/openshift/api/route/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubetypes "k8s.io/apimachinery/pkg/types"
@@ -60,6 +62,8 @@ func (cli *VanClient) GetVanControllerSpec(options types.SiteConfigSpec, van *ty
 		van.Controller.Image = types.DefaultControllerImage
 	}
 	van.Controller.Replicas = 1
+	van.Controller.LivenessPort = types.ControllerHealthPort
+	setPodSettings(&van.Controller, options.Controller, options.ImagePullSecrets)
 	//TODO: change these to types constants
 	van.Controller.Labels = map[string]string{
 		"application":          "skupper",
@@ -183,8 +187,12 @@ func (cli *VanClient) GetVanControllerSpec(options types.SiteConfigSpec, van *ty
 			}
 			annotations = map[string]string{"service.alpha.openshift.io/serving-cert-secret-name": "skupper-controller-certs"}
 		}
-	} else if !options.ClusterLocal {
+	}
+	switch options.GetIngress(cli.RouteClient != nil) {
+	case types.IngressLoadBalancerString:
 		svctype = corev1.ServiceTypeLoadBalancer
+	case types.IngressNodePortString:
+		svctype = corev1.ServiceTypeNodePort
 	}
 	svcs = append(svcs, &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -204,7 +212,7 @@ func (cli *VanClient) GetVanControllerSpec(options types.SiteConfigSpec, van *ty
 	van.Controller.Services = svcs
 
 	routes := []*routev1.Route{}
-	if !options.ClusterLocal && cli.RouteClient != nil {
+	if options.GetIngress(cli.RouteClient != nil) == types.IngressRouteString {
 		routes = append(routes, &routev1.Route{
 			TypeMeta: metav1.TypeMeta{
 				APIVersion: "v1",
@@ -261,11 +269,17 @@ func (cli *VanClient) GetRouterSpecFromOpts(options types.SiteConfigSpec, siteId
 	} else {
 		van.Transport.Image = types.DefaultTransportImage
 	}
-	van.Transport.Replicas = 1
+	van.Transport.Replicas = getRouterReplicas(options)
+	setRouterPodSettings(&van.Transport, options)
 	van.Transport.Labels = map[string]string{
 		"application":          types.TransportDeploymentName,
 		"skupper.io/component": types.TransportComponentName,
 	}
+	if van.Transport.Replicas > 1 {
+		van.Transport.PodDisruptionBudget = kube.NewPodDisruptionBudget(types.TransportDeploymentName, map[string]string{
+			"skupper.io/component": types.TransportComponentName,
+		})
+	}
 	van.Transport.Annotations = types.TransportPrometheusAnnotations
 
 	routerConfig := qdr.InitialConfig(van.Name+"-${HOSTNAME}", siteId, options.IsEdge)
@@ -516,12 +530,16 @@ func (cli *VanClient) GetRouterSpecFromOpts(options types.SiteConfigSpec, siteId
 	})
 
 	if !options.IsEdge {
-		if options.ClusterLocal {
+		internalHosts := []string{"skupper-internal." + van.Namespace}
+		for i := 0; hasRouterReplicas(options) && i < int(van.Transport.Replicas); i++ {
+			internalHosts = append(internalHosts, types.GetRouterReplicaServiceName(i)+"."+van.Namespace)
+		}
+		if options.GetIngress(cli.RouteClient != nil) == types.IngressNoneString && options.IngressHost == "" {
 			credentials = append(credentials, types.Credential{
 				CA:          "skupper-internal-ca",
 				Name:        "skupper-internal",
 				Subject:     "skupper-internal",
-				Hosts:       []string{"skupper-internal." + van.Namespace},
+				Hosts:       internalHosts,
 				ConnectJson: false,
 				Post:        false,
 			})
@@ -530,7 +548,7 @@ func (cli *VanClient) GetRouterSpecFromOpts(options types.SiteConfigSpec, siteId
 				CA:          "skupper-internal-ca",
 				Name:        "skupper-internal",
 				Subject:     "skupper-internal",
-				Hosts:       []string{"skupper-internal." + van.Namespace},
+				Hosts:       internalHosts,
 				ConnectJson: false,
 				Post:        true,
 			})
@@ -627,8 +645,11 @@ func (cli *VanClient) GetRouterSpecFromOpts(options types.SiteConfigSpec, siteId
 	}
 	if !options.IsEdge {
 		svcType := corev1.ServiceTypeClusterIP
-		if !options.ClusterLocal && cli.RouteClient == nil {
+		switch options.GetIngress(cli.RouteClient != nil) {
+		case types.IngressLoadBalancerString:
 			svcType = corev1.ServiceTypeLoadBalancer
+		case types.IngressNodePortString:
+			svcType = corev1.ServiceTypeNodePort
 		}
 		svcs = append(svcs, &corev1.Service{
 			TypeMeta: metav1.TypeMeta{
@@ -659,10 +680,11 @@ func (cli *VanClient) GetRouterSpecFromOpts(options types.SiteConfigSpec, siteId
 			},
 		})
 	}
+	svcs = append(svcs, getRouterReplicaServices(options, options.GetIngress(cli.RouteClient != nil))...)
 	van.Transport.Services = svcs
 
 	routes := []*routev1.Route{}
-	if !options.ClusterLocal && cli.RouteClient != nil {
+	if options.GetIngress(cli.RouteClient != nil) == types.IngressRouteString {
 		routes = append(routes, &routev1.Route{
 			TypeMeta: metav1.TypeMeta{
 				APIVersion: "v1",
@@ -739,11 +761,68 @@ func (cli *VanClient) GetRouterSpecFromOpts(options types.SiteConfigSpec, siteId
 			},
 		})
 	}
+	routes = append(routes, getRouterReplicaRoutes(options, options.GetIngress(cli.RouteClient != nil))...)
 	van.Transport.Routes = routes
 
+	ingresses := []*networkingv1beta1.Ingress{}
+	if !options.IsEdge && options.GetIngress(cli.RouteClient != nil) == types.IngressNginxIngressString {
+		for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+			ingresses = append(ingresses, &networkingv1beta1.Ingress{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.k8s.io/v1beta1",
+					Kind:       "Ingress",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "skupper-" + role,
+					Annotations: map[string]string{
+						types.IngressPassthroughAnnotation: "true",
+					},
+				},
+				Spec: networkingv1beta1.IngressSpec{
+					Rules: []networkingv1beta1.IngressRule{
+						{
+							Host: types.GetIngressHostForRole(role, van.Namespace, options.IngressHost),
+							IngressRuleValue: networkingv1beta1.IngressRuleValue{
+								HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+									Paths: []networkingv1beta1.HTTPIngressPath{
+										{
+											Path: "/",
+											Backend: networkingv1beta1.IngressBackend{
+												ServiceName: types.InterRouterProfile,
+												ServicePort: intstr.FromString(role),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			})
+		}
+	}
+	ingresses = append(ingresses, getRouterReplicaIngresses(options, options.GetIngress(cli.RouteClient != nil), van.Namespace)...)
+	van.Transport.Ingresses = ingresses
+
 	return van
 }
 
+func (cli *VanClient) validateIngress(spec types.SiteConfigSpec) error {
+	if spec.Ingress == "" {
+		return nil
+	}
+	if !types.IsValidIngress(spec.Ingress) {
+		return fmt.Errorf("Invalid ingress type %q; must be one of %s", spec.Ingress, strings.Join(types.ValidIngressOptions(), ", "))
+	}
+	if spec.Ingress == types.IngressRouteString && cli.RouteClient == nil {
+		return fmt.Errorf("Routes are not supported in this cluster")
+	}
+	if spec.Ingress == types.IngressNginxIngressString && spec.IngressHost == "" {
+		return fmt.Errorf("An ingress host is required for %s", types.IngressNginxIngressString)
+	}
+	return nil
+}
+
 // RouterCreate instantiates a VAN (router and controller) deployment
 func (cli *VanClient) RouterCreate(ctx context.Context, options types.SiteConfig) error {
 	// todo return error
@@ -766,7 +845,20 @@ func (cli *VanClient) RouterCreate(ctx context.Context, options types.SiteConfig
 		}
 	}
 
-	siteId := options.Reference.UID
+	if err := cli.validateIngress(options.Spec); err != nil {
+		return err
+	}
+	if options.Spec.IngressInterRouterPort < 0 || 65535 < options.Spec.IngressInterRouterPort || options.Spec.IngressEdgePort < 0 || 65535 < options.Spec.IngressEdgePort {
+		return fmt.Errorf("Advertised ingress ports must be between 1 and 65535")
+	}
+	if err := validateRouterReplicas(options.Spec); err != nil {
+		return err
+	}
+
+	siteId := options.SiteId
+	if siteId == "" {
+		siteId = options.Reference.UID
+	}
 	if siteId == "" {
 		siteId = utils.RandomId(10)
 	}
@@ -821,15 +913,25 @@ sasldb_path: /tmp/qdrouterd.sasldb
 			kube.CreateRoute(rte, van.Namespace, cli.RouteClient)
 		}
 	}
+	for _, ingress := range van.Transport.Ingresses {
+		ingress.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*siteOwnerRef}
+		kube.CreateIngress(ingress, van.Namespace, cli.KubeClient)
+	}
+	if pdb := van.Transport.PodDisruptionBudget; pdb != nil {
+		pdb.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*siteOwnerRef}
+		kube.CreatePodDisruptionBudget(pdb, van.Namespace, cli.KubeClient)
+	}
 
-	kube.NewConfigMap("skupper-services", nil, siteOwnerRef, van.Namespace, cli.KubeClient)
+	kube.NewServiceDefinitionsConfigMap(nil, siteOwnerRef, van.Namespace, cli.KubeClient)
 	initialConfig := qdr.AsConfigMapData(van.RouterConfig)
 	kube.NewConfigMap("skupper-internal", &initialConfig, siteOwnerRef, van.Namespace, cli.KubeClient)
 
 	if !options.Spec.IsEdge {
+		ingress := options.Spec.GetIngress(cli.RouteClient != nil)
 		for _, cred := range van.Credentials {
 			if cred.Post {
-				if cli.RouteClient != nil {
+				switch ingress {
+				case types.IngressRouteString:
 					rte, err := kube.GetRoute(types.InterRouterRouteName, van.Namespace, cli.RouteClient)
 					if err == nil {
 						cred.Hosts = append(cred.Hosts, rte.Spec.Host)
@@ -842,8 +944,10 @@ sasldb_path: /tmp/qdrouterd.sasldb
 					} else {
 						fmt.Println("Failed to retrieve route: ", err.Error())
 					}
-
-				} else {
+				case types.IngressLoadBalancerString:
+					if options.Spec.IngressHost != "" {
+						break
+					}
 					service, err := kube.GetService(types.InterRouterProfile, van.Namespace, cli.KubeClient)
 					if err == nil {
 						host := kube.GetLoadBalancerHostOrIP(service)
@@ -864,6 +968,39 @@ sasldb_path: /tmp/qdrouterd.sasldb
 							}
 						}
 					}
+				case types.IngressNodePortString:
+					if options.Spec.IngressHost != "" {
+						break
+					}
+					host, err := kube.GetNodeAddress(cli.KubeClient)
+					if err == nil {
+						cred.Hosts = append(cred.Hosts, host)
+					} else {
+						fmt.Println("Failed to retrieve node address: ", err.Error())
+					}
+				case types.IngressNginxIngressString:
+					cred.Hosts = append(cred.Hosts, types.GetIngressHostForRole(types.InterRouterRole, van.Namespace, options.Spec.IngressHost))
+					cred.Hosts = append(cred.Hosts, types.GetIngressHostForRole(types.EdgeRole, van.Namespace, options.Spec.IngressHost))
+				}
+				if options.Spec.IngressHost != "" && ingress != types.IngressNginxIngressString {
+					cred.Hosts = append(cred.Hosts, options.Spec.IngressHost)
+					if len(options.Spec.IngressHost) < 64 {
+						cred.Subject = options.Spec.IngressHost
+					}
+				}
+				if cred.Name == types.InterRouterProfile {
+					hosts, err := cli.getRouterReplicaHosts(options.Spec, ingress, van.Namespace)
+					for i := 0; err != nil && ingress == types.IngressLoadBalancerString && i < 120; i++ {
+						if i == 0 {
+							fmt.Println("Waiting for LoadBalancer IP or hostname of router replicas...")
+						}
+						time.Sleep(time.Second)
+						hosts, err = cli.getRouterReplicaHosts(options.Spec, ingress, van.Namespace)
+					}
+					if err != nil {
+						return fmt.Errorf("Failed to get hosts for router replicas: %w", err)
+					}
+					cred.Hosts = appendMissingHosts(cred.Hosts, hosts)
 				}
 				kube.NewSecret(cred, siteOwnerRef, van.Namespace, cli.KubeClient)
 			}
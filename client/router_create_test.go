@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -326,3 +327,91 @@ func TestRouterCreateDefaults(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRouterSpecForIngress(t *testing.T) {
+	cli := &VanClient{Namespace: "skupper"}
+	serviceType := func(van *types.RouterSpec, name string) corev1.ServiceType {
+		for _, svc := range van.Transport.Services {
+			if svc.ObjectMeta.Name == name {
+				return svc.Spec.Type
+			}
+		}
+		return ""
+	}
+
+	van := cli.GetRouterSpecFromOpts(types.SiteConfigSpec{}, "site")
+	assert.Equal(t, serviceType(van, types.InterRouterProfile), corev1.ServiceTypeLoadBalancer)
+
+	van = cli.GetRouterSpecFromOpts(types.SiteConfigSpec{ClusterLocal: true}, "site")
+	assert.Equal(t, serviceType(van, types.InterRouterProfile), corev1.ServiceTypeClusterIP)
+
+	van = cli.GetRouterSpecFromOpts(types.SiteConfigSpec{Ingress: types.IngressNodePortString}, "site")
+	assert.Equal(t, serviceType(van, types.InterRouterProfile), corev1.ServiceTypeNodePort)
+	assert.Equal(t, len(van.Transport.Ingresses), 0)
+
+	van = cli.GetRouterSpecFromOpts(types.SiteConfigSpec{Ingress: types.IngressNginxIngressString, IngressHost: "example.com"}, "site")
+	assert.Equal(t, serviceType(van, types.InterRouterProfile), corev1.ServiceTypeClusterIP)
+	assert.Equal(t, len(van.Transport.Ingresses), 2)
+	ingress := van.Transport.Ingresses[0]
+	assert.Equal(t, ingress.ObjectMeta.Annotations[types.IngressPassthroughAnnotation], "true")
+	assert.Equal(t, ingress.Spec.Rules[0].Host, "inter-router-skupper.example.com")
+	assert.Equal(t, ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName, types.InterRouterProfile)
+
+	assert.ErrorContains(t, cli.validateIngress(types.SiteConfigSpec{Ingress: "gateway"}), "Invalid ingress type")
+	assert.ErrorContains(t, cli.validateIngress(types.SiteConfigSpec{Ingress: types.IngressRouteString}), "Routes are not supported")
+	assert.ErrorContains(t, cli.validateIngress(types.SiteConfigSpec{Ingress: types.IngressNginxIngressString}), "An ingress host is required")
+}
+
+func TestGetRouterSpecForReplicas(t *testing.T) {
+	cli := &VanClient{Namespace: "skupper"}
+
+	van := cli.GetRouterSpecFromOpts(types.SiteConfigSpec{}, "site")
+	assert.Equal(t, van.Transport.Replicas, int32(1))
+	assert.Assert(t, van.Transport.Affinity == nil)
+	assert.Assert(t, van.Transport.PodDisruptionBudget == nil)
+
+	van = cli.GetRouterSpecFromOpts(types.SiteConfigSpec{Replicas: 3}, "site")
+	assert.Equal(t, van.Transport.Replicas, int32(3))
+	assert.Assert(t, van.Transport.Affinity != nil && van.Transport.Affinity.PodAntiAffinity != nil)
+	term := van.Transport.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm
+	assert.Equal(t, term.TopologyKey, "kubernetes.io/hostname")
+	assert.Equal(t, term.LabelSelector.MatchLabels["skupper.io/component"], types.TransportComponentName)
+	pdb := van.Transport.PodDisruptionBudget
+	assert.Assert(t, pdb != nil)
+	assert.Equal(t, pdb.Spec.MaxUnavailable.IntValue(), 1)
+	assert.Equal(t, pdb.Spec.Selector.MatchLabels["skupper.io/component"], types.TransportComponentName)
+
+	// each replica is reached through services of its own, and the
+	// certificate of the router is valid for each
+	services := map[string]*corev1.Service{}
+	for _, svc := range van.Transport.Services {
+		services[svc.ObjectMeta.Name] = svc
+	}
+	for i := 0; i < 3; i++ {
+		headless, ok := services[types.GetRouterReplicaServiceName(i)]
+		assert.Assert(t, ok)
+		assert.Equal(t, headless.Spec.ClusterIP, corev1.ClusterIPNone)
+		assert.Equal(t, headless.Spec.Selector[types.RouterReplicaQualifier], strconv.Itoa(i))
+		exposed, ok := services[types.GetInterRouterReplicaServiceName(i)]
+		assert.Assert(t, ok)
+		assert.Equal(t, exposed.Spec.Type, corev1.ServiceTypeLoadBalancer)
+	}
+	for _, cred := range van.Credentials {
+		if cred.Name == types.InterRouterProfile {
+			assert.Equal(t, len(appendMissingHosts(cred.Hosts, []string{"skupper-router-2.skupper"})), len(cred.Hosts))
+		}
+	}
+
+	// an advertised host leaves only the links between replicas
+	van = cli.GetRouterSpecFromOpts(types.SiteConfigSpec{Replicas: 2, IngressHost: "lb.example.com"}, "site")
+	names := []string{}
+	for _, svc := range van.Transport.Services {
+		names = append(names, svc.ObjectMeta.Name)
+	}
+	assert.DeepEqual(t, names, []string{"skupper-messaging", "skupper-internal", "skupper-router-0", "skupper-router-1"})
+
+	// an explicit affinity takes precedence over the default spread
+	affinity := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}
+	van = cli.GetRouterSpecFromOpts(types.SiteConfigSpec{Replicas: 2, Router: types.PodSettings{Affinity: affinity}}, "site")
+	assert.Equal(t, van.Transport.Affinity, affinity)
+}
@@ -55,6 +55,9 @@ func (cli *VanClient) RouterInspect(ctx context.Context) (*types.RouterInspectRe
 		}
 		vir.Status.Mode = string(routerConfig.Metadata.Mode)
 		vir.Status.TransportReadyReplicas = current.Status.ReadyReplicas
+		if current.Spec.Replicas != nil {
+			vir.Status.TransportReplicas = *current.Spec.Replicas
+		}
 		connected, err := qdr.GetConnectedSites(vir.Status.Mode == types.TransportModeEdge, cli.Namespace, cli.KubeClient, cli.RestConfig)
 		for i := 0; i < 5 && err != nil; i++ {
 			time.Sleep(500 * time.Millisecond)
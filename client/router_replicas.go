@@ -0,0 +1,502 @@
+package client
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	routev1 "github.com/openshift/api/route/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/certs"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+// hasRouterReplicas returns true if the site is interior with more
+// than one router replica, in which case the replicas link to each
+// other through a headless service for each
+func hasRouterReplicas(spec types.SiteConfigSpec) bool {
+	return !spec.IsEdge && getRouterReplicas(spec) > 1
+}
+
+// hasReplicaEndpoints returns true if each router replica of the site
+// is exposed through endpoints of its own, so that sites linking to it
+// make a link to each replica. That is not possible if the site
+// advertises a host or ports of its own, e.g. those of a load balancer
+// that skupper knows nothing about.
+func hasReplicaEndpoints(spec types.SiteConfigSpec, ingress string) bool {
+	if !hasRouterReplicas(spec) || spec.IngressInterRouterPort != 0 || spec.IngressEdgePort != 0 {
+		return false
+	}
+	// node ports are exposed on the ingress host, if given, and an
+	// nginx ingress host is the domain under which each is exposed
+	return spec.IngressHost == "" || ingress == types.IngressNginxIngressString || ingress == types.IngressNodePortString
+}
+
+// validateRouterReplicas rejects multiple router replicas for an
+// interior site without a service controller, as it is the controller
+// that links the replicas to each other
+func validateRouterReplicas(spec types.SiteConfigSpec) error {
+	if hasRouterReplicas(spec) && !spec.EnableController {
+		return fmt.Errorf("The service controller is required to link multiple routers")
+	}
+	return nil
+}
+
+// getRouterReplicaSelector returns the labels selecting the router pod
+// serving as the replica with the given index
+func getRouterReplicaSelector(index int) map[string]string {
+	return map[string]string{
+		"skupper.io/component":       types.TransportComponentName,
+		types.RouterReplicaQualifier: strconv.Itoa(index),
+	}
+}
+
+func getRouterReplicaPorts() []corev1.ServicePort {
+	return []corev1.ServicePort{
+		{
+			Name:       types.InterRouterRole,
+			Protocol:   "TCP",
+			Port:       types.InterRouterListenerPort,
+			TargetPort: intstr.FromInt(int(types.InterRouterListenerPort)),
+		},
+		{
+			Name:       types.EdgeRole,
+			Protocol:   "TCP",
+			Port:       types.EdgeListenerPort,
+			TargetPort: intstr.FromInt(int(types.EdgeListenerPort)),
+		},
+	}
+}
+
+// getRouterReplicaServices returns the headless service for each router
+// replica and, where the site is exposed through a load balancer or
+// node ports, a service of that type for each
+func getRouterReplicaServices(spec types.SiteConfigSpec, ingress string) []*corev1.Service {
+	svcs := []*corev1.Service{}
+	if !hasRouterReplicas(spec) {
+		return svcs
+	}
+	svcType := corev1.ServiceType("")
+	if hasReplicaEndpoints(spec, ingress) {
+		switch ingress {
+		case types.IngressLoadBalancerString:
+			svcType = corev1.ServiceTypeLoadBalancer
+		case types.IngressNodePortString:
+			svcType = corev1.ServiceTypeNodePort
+		}
+	}
+	for i := 0; i < int(getRouterReplicas(spec)); i++ {
+		svcs = append(svcs, &corev1.Service{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Service",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   types.GetRouterReplicaServiceName(i),
+				Labels: map[string]string{types.RouterReplicaQualifier: strconv.Itoa(i)},
+			},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: corev1.ClusterIPNone,
+				Selector:  getRouterReplicaSelector(i),
+				Ports:     getRouterReplicaPorts(),
+				// the replicas link to each other before they are ready
+				PublishNotReadyAddresses: true,
+			},
+		})
+		if svcType != "" {
+			svcs = append(svcs, &corev1.Service{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Service",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   types.GetInterRouterReplicaServiceName(i),
+					Labels: map[string]string{types.RouterReplicaQualifier: strconv.Itoa(i)},
+				},
+				Spec: corev1.ServiceSpec{
+					Selector: getRouterReplicaSelector(i),
+					Ports:    getRouterReplicaPorts(),
+					Type:     svcType,
+				},
+			})
+		}
+	}
+	return svcs
+}
+
+// getRouterReplicaRoutes returns the inter-router and edge routes for
+// each router replica, where the site is exposed through routes
+func getRouterReplicaRoutes(spec types.SiteConfigSpec, ingress string) []*routev1.Route {
+	routes := []*routev1.Route{}
+	if ingress != types.IngressRouteString || !hasReplicaEndpoints(spec, ingress) {
+		return routes
+	}
+	for i := 0; i < int(getRouterReplicas(spec)); i++ {
+		for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+			routes = append(routes, &routev1.Route{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Route",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   types.GetReplicaRouteName("skupper-"+role, i),
+					Labels: map[string]string{types.RouterReplicaQualifier: strconv.Itoa(i)},
+				},
+				Spec: routev1.RouteSpec{
+					Port: &routev1.RoutePort{
+						TargetPort: intstr.FromString(role),
+					},
+					To: routev1.RouteTargetReference{
+						Kind: "Service",
+						Name: types.GetRouterReplicaServiceName(i),
+					},
+					TLS: &routev1.TLSConfig{
+						Termination:                   routev1.TLSTerminationPassthrough,
+						InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyNone,
+					},
+				},
+			})
+		}
+	}
+	return routes
+}
+
+// getReplicaIngressHost returns the host under which the inter-router
+// or edge listener of a router replica is exposed through an nginx
+// ingress
+func getReplicaIngressHost(role string, index int, namespace string, ingressHost string) string {
+	return types.GetIngressHostForRole(role+"-"+strconv.Itoa(index), namespace, ingressHost)
+}
+
+// getRouterReplicaIngresses returns the inter-router and edge nginx
+// ingresses for each router replica, where the site is exposed through
+// nginx ingresses
+func getRouterReplicaIngresses(spec types.SiteConfigSpec, ingress string, namespace string) []*networkingv1beta1.Ingress {
+	ingresses := []*networkingv1beta1.Ingress{}
+	if ingress != types.IngressNginxIngressString || !hasReplicaEndpoints(spec, ingress) {
+		return ingresses
+	}
+	for i := 0; i < int(getRouterReplicas(spec)); i++ {
+		for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+			ingresses = append(ingresses, &networkingv1beta1.Ingress{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "networking.k8s.io/v1beta1",
+					Kind:       "Ingress",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   types.GetReplicaRouteName("skupper-"+role, i),
+					Labels: map[string]string{types.RouterReplicaQualifier: strconv.Itoa(i)},
+					Annotations: map[string]string{
+						types.IngressPassthroughAnnotation: "true",
+					},
+				},
+				Spec: networkingv1beta1.IngressSpec{
+					Rules: []networkingv1beta1.IngressRule{
+						{
+							Host: getReplicaIngressHost(role, i, namespace, spec.IngressHost),
+							IngressRuleValue: networkingv1beta1.IngressRuleValue{
+								HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+									Paths: []networkingv1beta1.HTTPIngressPath{
+										{
+											Path: "/",
+											Backend: networkingv1beta1.IngressBackend{
+												ServiceName: types.GetRouterReplicaServiceName(i),
+												ServicePort: intstr.FromString(role),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			})
+		}
+	}
+	return ingresses
+}
+
+// getRouterReplicaHosts returns the hosts through which each router
+// replica is reached, which the skupper-internal certificate must
+// include. Hosts not yet allocated, e.g. of load balancers, are
+// reported as an error.
+func (cli *VanClient) getRouterReplicaHosts(spec types.SiteConfigSpec, ingress string, namespace string) ([]string, error) {
+	hosts := []string{}
+	if !hasRouterReplicas(spec) {
+		return hosts, nil
+	}
+	endpoints := hasReplicaEndpoints(spec, ingress)
+	for i := 0; i < int(getRouterReplicas(spec)); i++ {
+		hosts = append(hosts, types.GetRouterReplicaServiceName(i)+"."+namespace)
+		if !endpoints {
+			continue
+		}
+		switch ingress {
+		case types.IngressRouteString:
+			for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+				rte, err := kube.GetRoute(types.GetReplicaRouteName("skupper-"+role, i), namespace, cli.RouteClient)
+				if err != nil {
+					return nil, err
+				}
+				hosts = append(hosts, rte.Spec.Host)
+			}
+		case types.IngressLoadBalancerString:
+			name := types.GetInterRouterReplicaServiceName(i)
+			service, err := kube.GetService(name, namespace, cli.KubeClient)
+			if err != nil {
+				return nil, err
+			}
+			host := kube.GetLoadBalancerHostOrIP(service)
+			if host == "" {
+				return nil, fmt.Errorf("LoadBalancer Host/IP not yet allocated for service %s", name)
+			}
+			hosts = append(hosts, host)
+		case types.IngressNginxIngressString:
+			for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+				hosts = append(hosts, getReplicaIngressHost(role, i, namespace, spec.IngressHost))
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// getRouterReplicaHostPorts returns the host and ports through which
+// each router replica is reached by sites linking to this one, or nil
+// if the replicas are not exposed individually. Node ports are exposed
+// on the same host as those of the skupper-internal service.
+func (cli *VanClient) getRouterReplicaHostPorts(spec types.SiteConfigSpec, ingress string, namespace string, shared *RouterHostPorts) ([]RouterHostPorts, error) {
+	if !hasReplicaEndpoints(spec, ingress) {
+		return nil, nil
+	}
+	results := []RouterHostPorts{}
+	for i := 0; i < int(getRouterReplicas(spec)); i++ {
+		result := RouterHostPorts{}
+		switch ingress {
+		case types.IngressRouteString:
+			for _, role := range []string{types.InterRouterRole, types.EdgeRole} {
+				rte, err := kube.GetRoute(types.GetReplicaRouteName("skupper-"+role, i), namespace, cli.RouteClient)
+				if err != nil {
+					return nil, err
+				}
+				if role == types.InterRouterRole {
+					result.InterRouter = HostPort{Host: rte.Spec.Host, Port: "443"}
+				} else {
+					result.Edge = HostPort{Host: rte.Spec.Host, Port: "443"}
+				}
+			}
+		case types.IngressLoadBalancerString, types.IngressNodePortString:
+			name := types.GetInterRouterReplicaServiceName(i)
+			service, err := kube.GetService(name, namespace, cli.KubeClient)
+			if err != nil {
+				return nil, err
+			}
+			if ingress == types.IngressLoadBalancerString {
+				host := kube.GetLoadBalancerHostOrIP(service)
+				if host == "" {
+					return nil, fmt.Errorf("LoadBalancer Host/IP not yet allocated for service %s", name)
+				}
+				setHostPorts(&result, host, strconv.Itoa(int(types.InterRouterListenerPort)), strconv.Itoa(int(types.EdgeListenerPort)))
+			} else {
+				setHostPorts(&result, shared.InterRouter.Host, "", "")
+				for _, port := range service.Spec.Ports {
+					if port.Name == types.InterRouterRole && port.NodePort != 0 {
+						result.InterRouter.Port = strconv.Itoa(int(port.NodePort))
+					} else if port.Name == types.EdgeRole && port.NodePort != 0 {
+						result.Edge.Port = strconv.Itoa(int(port.NodePort))
+					}
+				}
+				if result.InterRouter.Port == "" || result.Edge.Port == "" {
+					return nil, fmt.Errorf("Node ports not yet allocated for service %s", name)
+				}
+			}
+		case types.IngressNginxIngressString:
+			result.InterRouter = HostPort{Host: getReplicaIngressHost(types.InterRouterRole, i, namespace, spec.IngressHost), Port: "443"}
+			result.Edge = HostPort{Host: getReplicaIngressHost(types.EdgeRole, i, namespace, spec.IngressHost), Port: "443"}
+		case types.IngressNoneString:
+			host := types.GetRouterReplicaServiceName(i) + "." + namespace
+			setHostPorts(&result, host, strconv.Itoa(int(types.InterRouterListenerPort)), strconv.Itoa(int(types.EdgeListenerPort)))
+		default:
+			return nil, nil
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// getReplicaAnnotation returns the token annotation giving the host or
+// port of the router replica with the given index, e.g.
+// inter-router-host-1
+func getReplicaAnnotation(key string, index int) string {
+	return key + "-" + strconv.Itoa(index)
+}
+
+// updateRouterReplicaResources creates the services, routes and
+// ingresses for each router replica of the site, and deletes those for
+// replicas it no longer has, then ensures the skupper-internal
+// certificate includes the hosts through which each replica is reached
+func (cli *VanClient) updateRouterReplicaResources(spec types.SiteConfigSpec, dep *appsv1.Deployment) error {
+	ingress := spec.GetIngress(cli.RouteClient != nil)
+	owners := dep.ObjectMeta.OwnerReferences
+	selector := metav1.ListOptions{LabelSelector: types.RouterReplicaQualifier}
+
+	desiredServices := map[string]*corev1.Service{}
+	for _, svc := range getRouterReplicaServices(spec, ingress) {
+		desiredServices[svc.ObjectMeta.Name] = svc
+	}
+	services, err := cli.KubeClient.CoreV1().Services(cli.Namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	for _, svc := range services.Items {
+		if _, ok := desiredServices[svc.ObjectMeta.Name]; ok {
+			delete(desiredServices, svc.ObjectMeta.Name)
+		} else if err := cli.KubeClient.CoreV1().Services(cli.Namespace).Delete(svc.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, svc := range desiredServices {
+		svc.ObjectMeta.OwnerReferences = owners
+		if _, err := cli.KubeClient.CoreV1().Services(cli.Namespace).Create(svc); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	if cli.RouteClient != nil {
+		desiredRoutes := map[string]*routev1.Route{}
+		for _, rte := range getRouterReplicaRoutes(spec, ingress) {
+			desiredRoutes[rte.ObjectMeta.Name] = rte
+		}
+		routes, err := cli.RouteClient.Routes(cli.Namespace).List(selector)
+		if err != nil {
+			return err
+		}
+		for _, rte := range routes.Items {
+			if _, ok := desiredRoutes[rte.ObjectMeta.Name]; ok {
+				delete(desiredRoutes, rte.ObjectMeta.Name)
+			} else if err := cli.RouteClient.Routes(cli.Namespace).Delete(rte.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		for _, rte := range desiredRoutes {
+			rte.ObjectMeta.OwnerReferences = owners
+			if _, err := cli.RouteClient.Routes(cli.Namespace).Create(rte); err != nil && !errors.IsAlreadyExists(err) {
+				return err
+			}
+		}
+	}
+
+	desiredIngresses := map[string]*networkingv1beta1.Ingress{}
+	for _, ing := range getRouterReplicaIngresses(spec, ingress, cli.Namespace) {
+		desiredIngresses[ing.ObjectMeta.Name] = ing
+	}
+	ingresses, err := cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).List(selector)
+	if err != nil {
+		return err
+	}
+	for _, ing := range ingresses.Items {
+		if _, ok := desiredIngresses[ing.ObjectMeta.Name]; ok {
+			delete(desiredIngresses, ing.ObjectMeta.Name)
+		} else if err := cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).Delete(ing.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, ing := range desiredIngresses {
+		ing.ObjectMeta.OwnerReferences = owners
+		if _, err := cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).Create(ing); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	if spec.IsEdge {
+		return nil
+	}
+	return cli.addInternalCertHosts(spec, ingress)
+}
+
+// addInternalCertHosts reissues the skupper-internal certificate, from
+// the same CA, if it lacks any of the hosts through which the router
+// replicas are reached, and restarts the router to pick it up
+func (cli *VanClient) addInternalCertHosts(spec types.SiteConfigSpec, ingress string) error {
+	secret, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get(types.InterRouterProfile, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	hosts, err := cli.getRouterReplicaHosts(spec, ingress, cli.Namespace)
+	if err != nil {
+		return err
+	}
+	current, err := certs.GetSecretHosts(secret)
+	if err != nil {
+		return err
+	}
+	missing := false
+	for _, host := range hosts {
+		if !current[host] {
+			current[host] = true
+			missing = true
+		}
+	}
+	if !missing {
+		return nil
+	}
+	ca, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get("skupper-internal-ca", metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve CA: %w", err)
+	}
+	all := []string{}
+	for host := range current {
+		all = append(all, host)
+	}
+	sort.Strings(all)
+	subject, err := certs.GetSecretSubject(secret)
+	if err != nil {
+		return err
+	}
+	regenerated := certs.GenerateSecret(secret.ObjectMeta.Name, subject, strings.Join(all, ","), ca)
+	secret.Data = regenerated.Data
+	if _, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Update(secret); err != nil {
+		return fmt.Errorf("Failed to update %s certificate: %w", secret.ObjectMeta.Name, err)
+	}
+	// the router only reads its certificate on starting
+	router, err := kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+	if err != nil {
+		return err
+	}
+	if router.Spec.Template.ObjectMeta.Annotations == nil {
+		router.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+	}
+	router.Spec.Template.ObjectMeta.Annotations[types.InternalCertHostsQualifier] = fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(all, ","))))[:16]
+	if _, err := cli.KubeClient.AppsV1().Deployments(cli.Namespace).Update(router); err != nil {
+		return fmt.Errorf("Failed to restart router with new certificate: %w", err)
+	}
+	return nil
+}
+
+// appendMissingHosts appends those of the hosts given not already in
+// the list
+func appendMissingHosts(hosts []string, additional []string) []string {
+	for _, host := range additional {
+		found := false
+		for _, existing := range hosts {
+			if existing == host {
+				found = true
+				break
+			}
+		}
+		if !found {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+// RouterUpdatePodSettings brings the resources, scheduling constraints
+// and security settings of the router and controller deployments, as
+// well as the number of router replicas and the resources through which
+// each is reached, into line with those of the site, returning true if
+// either deployment was updated
+func (cli *VanClient) RouterUpdatePodSettings(ctx context.Context, spec types.SiteConfigSpec) (bool, error) {
+	if err := validateRouterReplicas(spec); err != nil {
+		return false, err
+	}
+	updated := false
+	deployments := []struct {
+		name     string
+		desired  types.DeploymentSpec
+		replicas int32
+	}{
+		{name: types.TransportDeploymentName, replicas: getRouterReplicas(spec)},
+		{name: types.ControllerDeploymentName},
+	}
+	setRouterPodSettings(&deployments[0].desired, spec)
+	setPodSettings(&deployments[1].desired, spec.Controller, spec.ImagePullSecrets)
+	for _, d := range deployments {
+		dep, err := kube.GetDeployment(d.name, cli.Namespace, cli.KubeClient)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return updated, err
+		}
+		changed := kube.UpdatePodSettings(dep, &d.desired)
+		if d.replicas > 0 && (dep.Spec.Replicas == nil || *dep.Spec.Replicas != d.replicas) {
+			replicas := d.replicas
+			dep.Spec.Replicas = &replicas
+			changed = true
+		}
+		if changed {
+			_, err = cli.KubeClient.AppsV1().Deployments(cli.Namespace).Update(dep)
+			if err != nil {
+				return updated, fmt.Errorf("Failed to update pod settings for %s: %w", d.name, err)
+			}
+			updated = true
+		}
+		if d.name == types.TransportDeploymentName {
+			if err := cli.updateRouterDisruptionBudget(dep, d.replicas); err != nil {
+				return updated, err
+			}
+			if err := cli.updateRouterReplicaResources(spec, dep); err != nil {
+				return updated, err
+			}
+		}
+	}
+	return updated, nil
+}
+
+// updateRouterDisruptionBudget ensures there is a disruption budget for
+// the router if, and only if, it has more than one replica
+func (cli *VanClient) updateRouterDisruptionBudget(dep *appsv1.Deployment, replicas int32) error {
+	name := types.TransportDeploymentName
+	if replicas <= 1 {
+		return kube.DeletePodDisruptionBudget(name, cli.Namespace, cli.KubeClient)
+	}
+	_, err := cli.KubeClient.PolicyV1beta1().PodDisruptionBudgets(cli.Namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		pdb := kube.NewPodDisruptionBudget(name, map[string]string{
+			"skupper.io/component": types.TransportComponentName,
+		})
+		pdb.ObjectMeta.OwnerReferences = dep.ObjectMeta.OwnerReferences
+		_, err = kube.CreatePodDisruptionBudget(pdb, cli.Namespace, cli.KubeClient)
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/certs"
+)
+
+func TestRouterUpdatePodSettings(t *testing.T) {
+	router := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.TransportDeploymentName,
+			Namespace: "skupper",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: types.TransportContainerName}},
+				},
+			},
+		},
+	}
+	cli := &VanClient{
+		Namespace:  "skupper",
+		KubeClient: fake.NewSimpleClientset(router),
+	}
+	spec := types.SiteConfigSpec{
+		Router: types.PodSettings{
+			NodeSelector: map[string]string{"zone": "east"},
+		},
+		ImagePullSecrets: []string{"registry"},
+	}
+	updated, err := cli.RouterUpdatePodSettings(context.Background(), spec)
+	assert.Assert(t, err)
+	assert.Assert(t, updated)
+	actual, err := cli.KubeClient.AppsV1().Deployments("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, actual.Spec.Template.Spec.NodeSelector["zone"], "east")
+	assert.Equal(t, actual.Spec.Template.Spec.ImagePullSecrets[0].Name, "registry")
+
+	updated, err = cli.RouterUpdatePodSettings(context.Background(), spec)
+	assert.Assert(t, err)
+	assert.Assert(t, !updated)
+}
+
+func TestRouterUpdateReplicas(t *testing.T) {
+	replicas := int32(1)
+	router := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.TransportDeploymentName,
+			Namespace: "skupper",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: types.TransportContainerName}},
+				},
+			},
+		},
+	}
+	cli := &VanClient{
+		Namespace:  "skupper",
+		KubeClient: fake.NewSimpleClientset(router),
+	}
+	updated, err := cli.RouterUpdatePodSettings(context.Background(), types.SiteConfigSpec{Replicas: 3, EnableController: true})
+	assert.Assert(t, err)
+	assert.Assert(t, updated)
+	actual, err := cli.KubeClient.AppsV1().Deployments("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, *actual.Spec.Replicas, int32(3))
+	assert.Assert(t, actual.Spec.Template.Spec.Affinity.PodAntiAffinity != nil)
+	_, err = cli.KubeClient.PolicyV1beta1().PodDisruptionBudgets("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+
+	updated, err = cli.RouterUpdatePodSettings(context.Background(), types.SiteConfigSpec{})
+	assert.Assert(t, err)
+	assert.Assert(t, updated)
+	actual, err = cli.KubeClient.AppsV1().Deployments("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, *actual.Spec.Replicas, int32(1))
+	assert.Assert(t, actual.Spec.Template.Spec.Affinity == nil)
+	_, err = cli.KubeClient.PolicyV1beta1().PodDisruptionBudgets("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, errors.IsNotFound(err))
+}
+
+func TestRouterUpdateReplicaResources(t *testing.T) {
+	replicas := int32(1)
+	router := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.TransportDeploymentName,
+			Namespace: "skupper",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: types.TransportContainerName}},
+				},
+			},
+		},
+	}
+	ca := certs.GenerateCASecret("skupper-internal-ca", "skupper-internal-ca")
+	ca.ObjectMeta.Namespace = "skupper"
+	internal := certs.GenerateSecret(types.InterRouterProfile, "skupper-internal", "skupper-internal.skupper", &ca)
+	internal.ObjectMeta.Namespace = "skupper"
+	cli := &VanClient{
+		Namespace:  "skupper",
+		KubeClient: fake.NewSimpleClientset(router, &ca, &internal),
+	}
+	spec := types.SiteConfigSpec{Replicas: 3, EnableController: true, Ingress: types.IngressNoneString}
+	_, err := cli.RouterUpdatePodSettings(context.Background(), spec)
+	assert.Assert(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := cli.KubeClient.CoreV1().Services("skupper").Get(types.GetRouterReplicaServiceName(i), metav1.GetOptions{})
+		assert.Assert(t, err)
+	}
+	secret, err := cli.KubeClient.CoreV1().Secrets("skupper").Get(types.InterRouterProfile, metav1.GetOptions{})
+	assert.Assert(t, err)
+	hosts, err := certs.GetSecretHosts(secret)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, hosts, map[string]bool{
+		"skupper-internal.skupper": true,
+		"skupper-router-0.skupper": true,
+		"skupper-router-1.skupper": true,
+		"skupper-router-2.skupper": true,
+	})
+	actual, err := cli.KubeClient.AppsV1().Deployments("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	restart := actual.Spec.Template.ObjectMeta.Annotations[types.InternalCertHostsQualifier]
+	assert.Assert(t, restart != "")
+
+	// the certificate is kept, and the router not restarted, on
+	// scaling down
+	spec.Replicas = 2
+	_, err = cli.RouterUpdatePodSettings(context.Background(), spec)
+	assert.Assert(t, err)
+	_, err = cli.KubeClient.CoreV1().Services("skupper").Get(types.GetRouterReplicaServiceName(2), metav1.GetOptions{})
+	assert.Assert(t, errors.IsNotFound(err))
+	actual, err = cli.KubeClient.AppsV1().Deployments("skupper").Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, actual.Spec.Template.ObjectMeta.Annotations[types.InternalCertHostsQualifier], restart)
+
+	spec.EnableController = false
+	_, err = cli.RouterUpdatePodSettings(context.Background(), spec)
+	assert.ErrorContains(t, err, "The service controller is required to link multiple routers")
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+// ServiceInterfaceExternalUrl returns the url through which the service
+// can be reached from outside the cluster, or an empty string if the
+// service is not exposed externally or the cluster has not yet
+// assigned it an address
+func (cli *VanClient) ServiceInterfaceExternalUrl(ctx context.Context, service *types.ServiceInterface) (string, error) {
+	if service.External == nil {
+		return "", nil
+	}
+	host := ""
+	port := ""
+	switch service.External.Type {
+	case types.ExternalTypeLoadBalancer:
+		svc, err := kube.GetService(service.Address, cli.Namespace, cli.KubeClient)
+		if err != nil {
+			return "", err
+		}
+		host = kube.GetLoadBalancerHostOrIP(svc)
+		if len(svc.Spec.Ports) > 0 {
+			port = strconv.Itoa(int(svc.Spec.Ports[0].Port))
+		}
+	case types.ExternalTypeNodePort:
+		svc, err := kube.GetService(service.Address, cli.Namespace, cli.KubeClient)
+		if err != nil {
+			return "", err
+		}
+		if len(svc.Spec.Ports) > 0 && svc.Spec.Ports[0].NodePort != 0 {
+			host, err = kube.GetNodeAddress(cli.KubeClient)
+			if err != nil {
+				host = "<node>"
+			}
+			port = strconv.Itoa(int(svc.Spec.Ports[0].NodePort))
+		}
+	case types.ExternalTypeIngress:
+		ingress, err := cli.KubeClient.NetworkingV1beta1().Ingresses(cli.Namespace).Get(service.Address, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if len(ingress.Spec.Rules) > 0 && ingress.Spec.Rules[0].Host != "" {
+			host = ingress.Spec.Rules[0].Host
+		} else {
+			for _, i := range ingress.Status.LoadBalancer.Ingress {
+				if i.IP != "" {
+					host = i.IP
+				} else if i.Hostname != "" {
+					host = i.Hostname
+				}
+				if host != "" {
+					break
+				}
+			}
+		}
+	case types.ExternalTypeRoute:
+		if cli.RouteClient == nil {
+			return "", fmt.Errorf("Routes are not supported in this cluster")
+		}
+		route, err := cli.RouteClient.Routes(cli.Namespace).Get(service.Address, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return "https://" + route.Spec.Host, nil
+	default:
+		return "", fmt.Errorf("Unrecognised external access type %s", service.External.Type)
+	}
+	if host == "" {
+		return "", nil
+	}
+	if port != "" {
+		host = host + ":" + port
+	}
+	if service.Protocol == "http" || service.Protocol == "http2" {
+		if service.Tls != nil && service.Tls.Terminate {
+			return "https://" + host, nil
+		}
+		return "http://" + host, nil
+	}
+	return host, nil
+}
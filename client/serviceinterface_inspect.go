@@ -6,15 +6,14 @@ import (
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
 )
 
 func (cli *VanClient) ServiceInterfaceInspect(ctx context.Context, address string) (*types.ServiceInterface, error) {
-	current, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get("skupper-services", metav1.GetOptions{})
+	jsonDef, err := kube.GetServiceDefinition(address, cli.Namespace, cli.KubeClient)
 	if err == nil {
-		jsonDef := current.Data[address]
 		if jsonDef == "" {
 			return nil, nil
 		} else {
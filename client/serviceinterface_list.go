@@ -4,17 +4,16 @@ import (
 	"context"
 	jsonencoding "encoding/json"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
 	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
 )
 
 func (cli *VanClient) ServiceInterfaceList(ctx context.Context) ([]*types.ServiceInterface, error) {
 	var vsis []*types.ServiceInterface
 
-	current, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	definitions, err := kube.GetServiceDefinitions(cli.Namespace, cli.KubeClient)
 	if err == nil {
-		for _, v := range current.Data {
+		for _, v := range definitions {
 			if v != "" {
 				si := types.ServiceInterface{}
 				err = jsonencoding.Unmarshal([]byte(v), &si)
@@ -4,10 +4,15 @@ import (
 	"context"
 	jsonencoding "encoding/json"
 	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/pkg/kube"
@@ -30,6 +35,20 @@ func addTargetToServiceInterface(service *types.ServiceInterface, target *types.
 	for _, t := range service.Targets {
 		if t.Name == target.Name {
 			modified = true
+			// rebinding a target retains its preferences and
+			// named target ports unless new ones are given
+			if target.Weight == 0 {
+				target.Weight = t.Weight
+			}
+			if target.Priority == 0 {
+				target.Priority = t.Priority
+			}
+			if len(target.TargetPorts) == 0 {
+				target.TargetPorts = t.TargetPorts
+			}
+			if target.ResolveInterval == "" {
+				target.ResolveInterval = t.ResolveInterval
+			}
 			targets = append(targets, *target)
 		} else {
 			targets = append(targets, t)
@@ -41,6 +60,12 @@ func addTargetToServiceInterface(service *types.ServiceInterface, target *types.
 	service.Targets = targets
 }
 
+// GetServiceInterfaceTarget resolves the target of the given type and
+// name, as accepted by ServiceInterfaceBind
+func (cli *VanClient) GetServiceInterfaceTarget(targetType string, targetName string, deducePort bool) (*types.ServiceInterfaceTarget, error) {
+	return getServiceInterfaceTarget(targetType, targetName, deducePort, cli)
+}
+
 func getServiceInterfaceTarget(targetType string, targetName string, deducePort bool, cli *VanClient) (*types.ServiceInterfaceTarget, error) {
 	if targetType == "deployment" {
 		deployment, err := cli.KubeClient.AppsV1().Deployments(cli.Namespace).Get(targetName, metav1.GetOptions{})
@@ -93,62 +118,113 @@ func getServiceInterfaceTarget(targetType string, targetName string, deducePort
 			}
 		}
 		return &target, nil
+	} else if targetType == "host" {
+		if !isValidHost(targetName) {
+			return nil, fmt.Errorf("Invalid host %q; expected a DNS name or IP address", targetName)
+		}
+		// the port of an external host cannot be deduced
+		return &types.ServiceInterfaceTarget{
+			Name: targetName,
+			Host: targetName,
+		}, nil
 	} else {
 		return nil, fmt.Errorf("VAN service interface unsupported target type")
 	}
 }
 
+func isValidHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	return len(validation.IsDNS1123Subdomain(host)) == 0
+}
+
+// DefaultAddressForHost returns the address under which a host target
+// is exposed when none is specified, i.e. the first label of its DNS
+// name; an empty string is returned for IP addresses
+func DefaultAddressForHost(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	return strings.Split(host, ".")[0]
+}
+
 func updateServiceInterface(service *types.ServiceInterface, overwriteIfExists bool, owner *metav1.OwnerReference, cli *VanClient) error {
+	if len(service.Ports) > 0 {
+		// keep the first port in the unnamed field for readers
+		// that predate named ports
+		service.Port = service.Ports[0].Port
+	}
 	encoded, err := jsonencoding.Marshal(service)
 	if err != nil {
 		return fmt.Errorf("Failed to encode service interface as json: %s", err)
 	}
-	current, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get("skupper-services", metav1.GetOptions{})
-	if err == nil {
-		if overwriteIfExists || current.Data == nil || current.Data[service.Address] == "" {
-			if current.Data == nil {
-				current.Data = map[string]string{
-					service.Address: string(encoded),
+	define := func(definitions map[string]string) error {
+		if !overwriteIfExists && definitions[service.Address] != "" {
+			return fmt.Errorf("Service %s already defined", service.Address)
+		}
+		definitions[service.Address] = string(encoded)
+		return nil
+	}
+	err = kube.UpdateServiceDefinitions(cli.Namespace, cli.KubeClient, define)
+	if errors.IsNotFound(err) {
+		// another client may create skupper-services at the same
+		// time, so the definition is added only once it exists
+		if _, err = kube.NewServiceDefinitionsConfigMap(nil, owner, cli.Namespace, cli.KubeClient); err != nil {
+			return err
+		}
+		err = kube.UpdateServiceDefinitions(cli.Namespace, cli.KubeClient, define)
+	}
+	return err
+}
+
+var portNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func hasServicePort(service *types.ServiceInterface, name string) bool {
+	for _, port := range service.GetPorts() {
+		if port.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseServicePorts parses port specifications of the form
+// <name>:<port>, each of which may also be a comma separated list.
+// A single port may be given without a name.
+func ParseServicePorts(specs []string) ([]types.ServicePort, error) {
+	ports := []types.ServicePort{}
+	for _, spec := range specs {
+		for _, item := range strings.Split(spec, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			port := types.ServicePort{}
+			value := item
+			if i := strings.LastIndex(item, ":"); i >= 0 {
+				port.Name = item[:i]
+				value = item[i+1:]
+				if port.Name == "" {
+					return nil, fmt.Errorf("Invalid port %q; name must not be empty", item)
 				}
-			} else {
-				current.Data[service.Address] = string(encoded)
 			}
-			_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(current)
+			number, err := strconv.Atoi(value)
 			if err != nil {
-				return fmt.Errorf("Failed to update skupper-services config map: %s", err)
-			} else {
-				return nil
+				return nil, fmt.Errorf("Invalid port %q; expected <name>:<port>", item)
 			}
-		} else {
-			return fmt.Errorf("Service %s already defined", service.Address)
+			port.Port = number
+			ports = append(ports, port)
 		}
-	} else if errors.IsNotFound(err) {
-		configMap := corev1.ConfigMap{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: "v1",
-				Kind:       "ConfigMap",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "skupper-services",
-			},
-			Data: map[string]string{
-				service.Address: string(encoded),
-			},
-		}
-		if owner != nil {
-			configMap.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
-				*owner,
-			}
-		}
-		_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Create(&configMap)
-		if err != nil {
-			return fmt.Errorf("Failed to create skupper-services config map: %s", err)
-		} else {
-			return nil
+	}
+	if len(ports) > 1 {
+		for _, port := range ports {
+			if port.Name == "" {
+				return nil, fmt.Errorf("Each port must be named when more than one is specified (e.g. data:5432)")
+			}
 		}
-	} else {
-		return fmt.Errorf("Could not retrieve service interface definitions from configmap: %s", err)
 	}
+	return ports, nil
 }
 
 func validateServiceInterface(service *types.ServiceInterface) error {
@@ -162,6 +238,103 @@ func validateServiceInterface(service *types.ServiceInterface) error {
 		if target.TargetPort < 0 || 65535 < target.TargetPort {
 			return fmt.Errorf("Bad target port number. Target: %s  Port: %d", target.Name, target.TargetPort)
 		}
+		if target.Weight < 0 || types.MaxServiceWeight < target.Weight {
+			return fmt.Errorf("Bad target weight. Target: %s  Weight: %d (must be between 0 and %d)", target.Name, target.Weight, types.MaxServiceWeight)
+		}
+		if target.Priority < 0 {
+			return fmt.Errorf("Bad target priority. Target: %s  Priority: %d", target.Name, target.Priority)
+		}
+		if target.Host != "" && (target.Selector != "" || target.Service != "") {
+			return fmt.Errorf("Target %s cannot specify a host as well as a selector or service", target.Name)
+		}
+		if target.ResolveInterval != "" {
+			if target.Host == "" {
+				return fmt.Errorf("Target %s specifies a resolve interval but is not a host target", target.Name)
+			}
+			interval, err := time.ParseDuration(target.ResolveInterval)
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("Invalid resolve interval %q for target %s", target.ResolveInterval, target.Name)
+			}
+		}
+		for name, port := range target.TargetPorts {
+			if !hasServicePort(service, name) {
+				return fmt.Errorf("Target %s specifies a target port for %q which is not a port of the service", target.Name, name)
+			}
+			if port < 0 || 65535 < port {
+				return fmt.Errorf("Bad target port number. Target: %s  Port: %s:%d", target.Name, name, port)
+			}
+		}
+	}
+
+	for key, value := range service.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("Invalid label %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("Invalid value for label %q: %s", key, strings.Join(errs, "; "))
+		}
+		if kube.IsReservedMetadataKey(key) {
+			return fmt.Errorf("Label %q is reserved", key)
+		}
+	}
+	for key := range service.Annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("Invalid annotation %q: %s", key, strings.Join(errs, "; "))
+		}
+		if kube.IsReservedMetadataKey(key) {
+			return fmt.Errorf("Annotation %q is reserved", key)
+		}
+	}
+
+	if service.Tls != nil {
+		if service.Headless != nil {
+			return fmt.Errorf("TLS is not supported for headless services")
+		}
+		if !service.Tls.Originate && (service.Tls.CaSecret != "" || service.Tls.VerifyHostname) {
+			return fmt.Errorf("The CA secret and hostname verification options only apply when originating TLS")
+		}
+	}
+
+	if service.External != nil {
+		if service.Headless != nil {
+			return fmt.Errorf("External access is not supported for headless services")
+		}
+		isHttp := service.Protocol == "http" || service.Protocol == "http2"
+		switch service.External.Type {
+		case types.ExternalTypeLoadBalancer, types.ExternalTypeNodePort:
+			if service.External.Host != "" {
+				return fmt.Errorf("A host can only be specified for external access through an ingress or route")
+			}
+		case types.ExternalTypeIngress:
+			if !isHttp {
+				return fmt.Errorf("External access through an ingress is only valid for http or http2")
+			}
+		case types.ExternalTypeRoute:
+			if !isHttp && (service.Tls == nil || !service.Tls.Terminate) {
+				return fmt.Errorf("External access through a route is only valid for http or http2, or for services that terminate TLS")
+			}
+		default:
+			return fmt.Errorf("%s is not a valid external access type. Choose '%s', '%s', '%s' or '%s'.", service.External.Type, types.ExternalTypeLoadBalancer, types.ExternalTypeNodePort, types.ExternalTypeIngress, types.ExternalTypeRoute)
+		}
+	}
+
+	if len(service.Ports) > 0 {
+		if service.Headless != nil {
+			return fmt.Errorf("Named ports are not supported for headless services")
+		}
+		names := map[string]bool{}
+		for _, port := range service.Ports {
+			if !portNameRegex.MatchString(port.Name) || len(port.Name) > 15 {
+				return fmt.Errorf("Invalid port name %q; must be at most 15 lowercase alphanumeric characters or '-', starting and ending with an alphanumeric character", port.Name)
+			}
+			if names[port.Name] {
+				return fmt.Errorf("Port name %s is specified more than once", port.Name)
+			}
+			names[port.Name] = true
+			if port.Port < 1 || 65535 < port.Port {
+				return fmt.Errorf("Port %d is outside valid range.", port.Port)
+			}
+		}
 	}
 
 	//TODO: change service.Protocol to service.Mapping
@@ -177,6 +350,14 @@ func validateServiceInterface(service *types.ServiceInterface) error {
 		return fmt.Errorf("The aggregate option is currently only valid for http")
 	} else if service.EventChannel && service.Protocol != "http" {
 		return fmt.Errorf("The event-channel option is currently only valid for http")
+	} else if service.Distribution != "" && service.Distribution != types.DistributionBalanced && service.Distribution != types.DistributionClosest {
+		return fmt.Errorf("%s is not a valid distribution. Choose 'balanced' or 'closest'.", service.Distribution)
+	} else if service.Distribution != "" && service.Headless != nil {
+		return fmt.Errorf("The distribution option is not valid for headless services")
+	} else if service.Weight < 0 || types.MaxServiceWeight < service.Weight {
+		return fmt.Errorf("Weight %d is outside valid range (0-%d).", service.Weight, types.MaxServiceWeight)
+	} else if service.Priority < 0 {
+		return fmt.Errorf("Priority %d cannot be negative.", service.Priority)
 	} else {
 		return nil
 	}
@@ -290,57 +471,55 @@ func (cli *VanClient) GetHeadlessServiceConfiguration(targetName string, protoco
 }
 
 func removeServiceInterfaceTarget(serviceName string, targetName string, deleteIfNoTargets bool, cli *VanClient) error {
-	current, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get("skupper-services", metav1.GetOptions{})
-	if err == nil {
-		jsonDef := current.Data[serviceName]
+	err := kube.UpdateServiceDefinitions(cli.Namespace, cli.KubeClient, func(definitions map[string]string) error {
+		jsonDef := definitions[serviceName]
 		if jsonDef == "" {
 			return fmt.Errorf("Could not find entry for service interface %s", serviceName)
-		} else {
-			service := types.ServiceInterface{}
-			err = jsonencoding.Unmarshal([]byte(jsonDef), &service)
-			if err != nil {
-				return fmt.Errorf("Failed to read json for service interface %s: %s", serviceName, err)
+		}
+		service := types.ServiceInterface{}
+		err := jsonencoding.Unmarshal([]byte(jsonDef), &service)
+		if err != nil {
+			return fmt.Errorf("Failed to read json for service interface %s: %s", serviceName, err)
+		}
+		modified := false
+		targets := []types.ServiceInterfaceTarget{}
+		for _, t := range service.Targets {
+			if t.Name == targetName || (t.Name == "" && targetName == serviceName) {
+				modified = true
 			} else {
-				modified := false
-				targets := []types.ServiceInterfaceTarget{}
-				for _, t := range service.Targets {
-					if t.Name == targetName || (t.Name == "" && targetName == serviceName) {
-						modified = true
-					} else {
-						targets = append(targets, t)
-					}
-				}
-				if !modified {
-					return fmt.Errorf("Could not find target %s for service interface %s", targetName, serviceName)
-				}
-				if len(targets) == 0 && deleteIfNoTargets {
-					delete(current.Data, serviceName)
-				} else {
-					service.Targets = targets
-					encoded, err := jsonencoding.Marshal(service)
-					if err != nil {
-						return fmt.Errorf("Failed to create json for service interface: %s", err)
-					} else {
-						current.Data[serviceName] = string(encoded)
-					}
-				}
+				targets = append(targets, t)
 			}
 		}
-		_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(current)
-		if err != nil {
-			return fmt.Errorf("Failed to update skupper-services config map: %v", err.Error())
+		if !modified {
+			return fmt.Errorf("Could not find target %s for service interface %s", targetName, serviceName)
 		}
-	} else if errors.IsNotFound(err) {
+		if len(targets) == 0 && deleteIfNoTargets {
+			delete(definitions, serviceName)
+		} else {
+			service.Targets = targets
+			encoded, err := jsonencoding.Marshal(service)
+			if err != nil {
+				return fmt.Errorf("Failed to create json for service interface: %s", err)
+			}
+			definitions[serviceName] = string(encoded)
+		}
+		return nil
+	})
+	if errors.IsNotFound(err) {
 		return fmt.Errorf("No skupper service interfaces defined: %v", err.Error())
-	} else {
-		return fmt.Errorf("Could not retrieve service interfaces from configmap 'skupper-services': %v", err)
 	}
-	return nil
+	return err
 }
 
 func (cli *VanClient) ServiceInterfaceUnbind(ctx context.Context, targetType string, targetName string, address string, deleteIfNoTargets bool) error {
-	if targetType == "deployment" || targetType == "statefulset" || targetType == "service" {
-		if address == "" {
+	if targetType == "deployment" || targetType == "statefulset" || targetType == "service" || targetType == "host" {
+		if address == "" && targetType == "host" {
+			address = DefaultAddressForHost(targetName)
+			if address == "" {
+				return fmt.Errorf("The address must be specified for host %s", targetName)
+			}
+			return removeServiceInterfaceTarget(address, targetName, deleteIfNoTargets, cli)
+		} else if address == "" {
 			err := removeServiceInterfaceTarget(targetName, targetName, deleteIfNoTargets, cli)
 			return err
 		} else {
@@ -143,6 +143,7 @@ func TestVanServiceInteraceUpdate(t *testing.T) {
 		port            int
 		eventChannel    bool
 		aggregate       string
+		distribution    string
 		secretsExpected []string
 		opts            []cmp.Option
 	}{
@@ -212,6 +213,26 @@ func TestVanServiceInteraceUpdate(t *testing.T) {
 				trans,
 			},
 		},
+		{
+			doc:           "test six",
+			expectedError: "",
+			name:          "tcp-go-echo",
+			port:          0,
+			distribution:  "closest",
+			opts: []cmp.Option{
+				trans,
+			},
+		},
+		{
+			doc:           "test seven",
+			expectedError: "random is not a valid distribution. Choose 'balanced' or 'closest'.",
+			name:          "tcp-go-echo",
+			port:          0,
+			distribution:  "random",
+			opts: []cmp.Option{
+				trans,
+			},
+		},
 	}
 
 	var namespace string = "van-serviceinterface-update"
@@ -365,6 +386,9 @@ func TestVanServiceInteraceUpdate(t *testing.T) {
 		if c.aggregate != si.Aggregate {
 			si.Aggregate = c.aggregate
 		}
+		if c.distribution != si.Distribution {
+			si.Distribution = c.distribution
+		}
 		err = cli.ServiceInterfaceUpdate(ctx, si)
 		if c.expectedError == "" {
 			assert.Check(t, err, c.doc)
@@ -378,6 +402,7 @@ func TestVanServiceInteraceUpdate(t *testing.T) {
 	assert.Assert(t, err)
 	assert.Equal(t, si.Protocol, "tcp")
 	assert.Equal(t, si.Port, 9091)
+	assert.Equal(t, si.Distribution, "closest")
 
 	si, err = cli.ServiceInterfaceInspect(ctx, "nginx")
 	assert.Assert(t, err)
@@ -408,3 +433,212 @@ func TestVanServiceInteraceUpdate(t *testing.T) {
 	assert.Equal(t, len(items), 0)
 
 }
+
+func TestParseServicePorts(t *testing.T) {
+	testcases := []struct {
+		doc           string
+		specs         []string
+		expected      []types.ServicePort
+		expectedError string
+	}{
+		{
+			doc:      "single unnamed port",
+			specs:    []string{"8080"},
+			expected: []types.ServicePort{{Port: 8080}},
+		},
+		{
+			doc:      "named ports in one spec",
+			specs:    []string{"data:5432,admin:8080"},
+			expected: []types.ServicePort{{Name: "data", Port: 5432}, {Name: "admin", Port: 8080}},
+		},
+		{
+			doc:      "named ports across specs",
+			specs:    []string{"data:5432", "admin:8080"},
+			expected: []types.ServicePort{{Name: "data", Port: 5432}, {Name: "admin", Port: 8080}},
+		},
+		{
+			doc:           "unnamed port among several",
+			specs:         []string{"5432,admin:8080"},
+			expectedError: "Each port must be named when more than one is specified (e.g. data:5432)",
+		},
+		{
+			doc:           "bad port number",
+			specs:         []string{"data:abc"},
+			expectedError: "Invalid port \"data:abc\"; expected <name>:<port>",
+		},
+	}
+	for _, c := range testcases {
+		ports, err := ParseServicePorts(c.specs)
+		if c.expectedError != "" {
+			assert.Error(t, err, c.expectedError, c.doc)
+			continue
+		}
+		assert.Assert(t, err, c.doc)
+		assert.DeepEqual(t, ports, c.expected)
+	}
+}
+
+func TestValidateHostTarget(t *testing.T) {
+	testcases := []struct {
+		doc           string
+		target        types.ServiceInterfaceTarget
+		expectedError string
+	}{
+		{
+			doc:    "host without re-resolution",
+			target: types.ServiceInterfaceTarget{Name: "db.internal", Host: "db.internal"},
+		},
+		{
+			doc:    "host with re-resolution",
+			target: types.ServiceInterfaceTarget{Name: "db.internal", Host: "db.internal", ResolveInterval: "30s"},
+		},
+		{
+			doc:           "bad resolve interval",
+			target:        types.ServiceInterfaceTarget{Name: "db.internal", Host: "db.internal", ResolveInterval: "often"},
+			expectedError: "Invalid resolve interval \"often\" for target db.internal",
+		},
+		{
+			doc:           "resolve interval without host",
+			target:        types.ServiceInterfaceTarget{Name: "db", Service: "db", ResolveInterval: "30s"},
+			expectedError: "Target db specifies a resolve interval but is not a host target",
+		},
+		{
+			doc:           "host and service",
+			target:        types.ServiceInterfaceTarget{Name: "db", Service: "db", Host: "db.internal"},
+			expectedError: "Target db cannot specify a host as well as a selector or service",
+		},
+	}
+	for _, c := range testcases {
+		service := types.ServiceInterface{
+			Address:  "db",
+			Protocol: "tcp",
+			Port:     5432,
+			Targets:  []types.ServiceInterfaceTarget{c.target},
+		}
+		err := validateServiceInterface(&service)
+		if c.expectedError != "" {
+			assert.Error(t, err, c.expectedError, c.doc)
+		} else {
+			assert.Assert(t, err, c.doc)
+		}
+	}
+	assert.Equal(t, DefaultAddressForHost("db.internal"), "db")
+	assert.Equal(t, DefaultAddressForHost("10.0.0.1"), "")
+}
+
+func TestValidateServiceMetadata(t *testing.T) {
+	testcases := []struct {
+		doc           string
+		labels        map[string]string
+		annotations   map[string]string
+		expectedError string
+	}{
+		{
+			doc:         "valid labels and annotations",
+			labels:      map[string]string{"app": "db", "example.com/tier": "backend"},
+			annotations: map[string]string{"prometheus.io/scrape": "true"},
+		},
+		{
+			doc:           "invalid label value",
+			labels:        map[string]string{"app": "not valid"},
+			expectedError: "Invalid value for label \"app\"",
+		},
+		{
+			doc:           "reserved annotation",
+			annotations:   map[string]string{types.ControlledQualifier: "false"},
+			expectedError: "Annotation \"internal.skupper.io/controlled\" is reserved",
+		},
+		{
+			doc:           "reserved proxy annotation",
+			annotations:   map[string]string{types.ProxyQualifier: "tcp"},
+			expectedError: "Annotation \"skupper.io/proxy\" is reserved",
+		},
+		{
+			doc:           "reserved address label",
+			labels:        map[string]string{types.AddressQualifier: "other"},
+			expectedError: "Label \"skupper.io/address\" is reserved",
+		},
+	}
+	for _, c := range testcases {
+		service := types.ServiceInterface{
+			Address:     "db",
+			Protocol:    "tcp",
+			Port:        5432,
+			Labels:      c.labels,
+			Annotations: c.annotations,
+		}
+		err := validateServiceInterface(&service)
+		if c.expectedError != "" {
+			assert.ErrorContains(t, err, c.expectedError, c.doc)
+		} else {
+			assert.Assert(t, err, c.doc)
+		}
+	}
+}
+
+func TestValidateServiceExternal(t *testing.T) {
+	testcases := []struct {
+		doc           string
+		protocol      string
+		external      types.ServiceExternal
+		tls           *types.ServiceTls
+		expectedError string
+	}{
+		{
+			doc:      "load balancer",
+			protocol: "tcp",
+			external: types.ServiceExternal{Type: types.ExternalTypeLoadBalancer},
+		},
+		{
+			doc:           "host for node port",
+			protocol:      "tcp",
+			external:      types.ServiceExternal{Type: types.ExternalTypeNodePort, Host: "db.example.com"},
+			expectedError: "A host can only be specified",
+		},
+		{
+			doc:      "ingress for http",
+			protocol: "http",
+			external: types.ServiceExternal{Type: types.ExternalTypeIngress, Host: "web.example.com"},
+		},
+		{
+			doc:           "ingress for tcp",
+			protocol:      "tcp",
+			external:      types.ServiceExternal{Type: types.ExternalTypeIngress},
+			expectedError: "only valid for http or http2",
+		},
+		{
+			doc:      "passthrough route for tcp",
+			protocol: "tcp",
+			external: types.ServiceExternal{Type: types.ExternalTypeRoute},
+			tls:      &types.ServiceTls{Terminate: true},
+		},
+		{
+			doc:           "route for tcp",
+			protocol:      "tcp",
+			external:      types.ServiceExternal{Type: types.ExternalTypeRoute},
+			expectedError: "or for services that terminate TLS",
+		},
+		{
+			doc:           "unknown type",
+			protocol:      "tcp",
+			external:      types.ServiceExternal{Type: "gateway"},
+			expectedError: "gateway is not a valid external access type",
+		},
+	}
+	for _, c := range testcases {
+		external := c.external
+		service := types.ServiceInterface{
+			Address:  "db",
+			Protocol: c.protocol,
+			Port:     5432,
+			External: &external,
+			Tls:      c.tls,
+		}
+		err := validateServiceInterface(&service)
+		if c.expectedError != "" {
+			assert.ErrorContains(t, err, c.expectedError, c.doc)
+		} else {
+			assert.Assert(t, err, c.doc)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	jsonencoding "encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/utils"
+)
+
+const siteBackupVersion string = "1"
+
+// siteBackup holds what is needed to recreate a site with the same
+// identity, so that the tokens it has issued remain valid and its
+// links can be made again without new tokens
+type siteBackup struct {
+	Version                string                   `json:"version"`
+	Namespace              string                   `json:"namespace"`
+	SiteId                 string                   `json:"siteId"`
+	Site                   map[string]string        `json:"site"`
+	SiteLabels             map[string]string        `json:"siteLabels,omitempty"`
+	CertificateAuthorities []corev1.Secret          `json:"certificateAuthorities"`
+	Links                  []corev1.Secret          `json:"links,omitempty"`
+	Services               []types.ServiceInterface `json:"services,omitempty"`
+}
+
+// encryptedBackup is the form in which a backup is written; the
+// contents are sealed with AES-GCM under a key derived from the
+// passphrase with scrypt
+type encryptedBackup struct {
+	Version string `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("A passphrase is required to encrypt or decrypt a backup")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptBackup(plaintext []byte, passphrase string) ([]byte, error) {
+	result := encryptedBackup{
+		Version: siteBackupVersion,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := rand.Read(result.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := backupCipher(passphrase, result.Salt)
+	if err != nil {
+		return nil, err
+	}
+	result.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(result.Nonce); err != nil {
+		return nil, err
+	}
+	result.Data = aead.Seal(nil, result.Nonce, plaintext, []byte(result.Version))
+	return jsonencoding.MarshalIndent(result, "", "  ")
+}
+
+func decryptBackup(encoded []byte, passphrase string) ([]byte, error) {
+	input := encryptedBackup{}
+	if err := jsonencoding.Unmarshal(encoded, &input); err != nil {
+		return nil, fmt.Errorf("Not a skupper backup: %w", err)
+	}
+	if input.Version != siteBackupVersion {
+		return nil, fmt.Errorf("Unsupported backup version %q", input.Version)
+	}
+	aead, err := backupCipher(passphrase, input.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("Backup is corrupt")
+	}
+	plaintext, err := aead.Open(nil, input.Nonce, input.Data, []byte(input.Version))
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt backup; the passphrase may be wrong")
+	}
+	return plaintext, nil
+}
+
+// backupSecret keeps only the parts of a secret needed to recreate it
+func backupSecret(secret *corev1.Secret) corev1.Secret {
+	result := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   secret.ObjectMeta.Name,
+			Labels: secret.ObjectMeta.Labels,
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+	for key, value := range secret.ObjectMeta.Annotations {
+		switch key {
+		case types.StatusQualifier, types.StatusMessageQualifier, types.StatusTimeQualifier, types.RemoteSiteQualifier:
+			continue
+		}
+		if result.ObjectMeta.Annotations == nil {
+			result.ObjectMeta.Annotations = map[string]string{}
+		}
+		result.ObjectMeta.Annotations[key] = value
+	}
+	return result
+}
+
+// SiteBackup writes the identity of the site to a file, encrypted with
+// the passphrase: the site id and configuration, the certificate
+// authorities, the tokens from which its links are made and the
+// service definitions made at the site
+func (cli *VanClient) SiteBackup(ctx context.Context, file string, passphrase string) error {
+	configmap, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("Skupper not initialised in %s", cli.Namespace)
+	} else if err != nil {
+		return err
+	}
+	siteConfig, err := cli.SiteConfigInspect(ctx, configmap)
+	if err != nil {
+		return err
+	}
+	router, err := kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+	if err != nil {
+		return err
+	}
+	// the id the router was started with is authoritative, as it may
+	// have been generated rather than taken from the site config
+	siteId := siteConfig.SiteId
+	if containers := router.Spec.Template.Spec.Containers; len(containers) > 0 {
+		if env := kube.FindEnvVar(containers[0].Env, "SKUPPER_SITE_ID"); env != nil && env.Value != "" {
+			siteId = env.Value
+		}
+	}
+	backup := siteBackup{
+		Version:    siteBackupVersion,
+		Namespace:  cli.Namespace,
+		SiteId:     siteId,
+		Site:       configmap.Data,
+		SiteLabels: configmap.ObjectMeta.Labels,
+	}
+	for _, name := range []string{"skupper-ca", "skupper-internal-ca", types.ServiceCaSecret} {
+		secret, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		backup.CertificateAuthorities = append(backup.CertificateAuthorities, backupSecret(secret))
+	}
+	links, err := cli.getLinks()
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		secret, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get(link.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		saved := backupSecret(secret)
+		if link.Cost != 0 {
+			if saved.ObjectMeta.Annotations == nil {
+				saved.ObjectMeta.Annotations = map[string]string{}
+			}
+			saved.ObjectMeta.Annotations[types.TokenCost] = strconv.Itoa(int(link.Cost))
+		}
+		backup.Links = append(backup.Links, saved)
+	}
+	services, err := cli.getDefinedServices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		backup.Services = append(backup.Services, *service)
+	}
+
+	plaintext, err := jsonencoding.Marshal(backup)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryptBackup(plaintext, passphrase)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, encrypted, 0600)
+}
+
+// restoreRouterTimeout bounds how long a restore waits for the site
+// controller to create the router of a site it controls
+const restoreRouterTimeout = 2 * time.Minute
+
+func (cli *VanClient) waitForRouter(ctx context.Context) (*appsv1.Deployment, error) {
+	ctx, cancel := context.WithTimeout(ctx, restoreRouterTimeout)
+	defer cancel()
+	var router *appsv1.Deployment
+	err := utils.RetryWithContext(ctx, time.Second, func() (bool, error) {
+		var err error
+		router, err = kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return router, err
+}
+
+// SiteRestore recreates a site from a backup in a namespace in which
+// there is no site. The site adopts the identity of that backed up, so
+// that other sites need not be given new tokens or links.
+func (cli *VanClient) SiteRestore(ctx context.Context, file string, passphrase string) error {
+	encrypted, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decryptBackup(encrypted, passphrase)
+	if err != nil {
+		return err
+	}
+	backup := siteBackup{}
+	if err := jsonencoding.Unmarshal(plaintext, &backup); err != nil {
+		return fmt.Errorf("Backup is corrupt: %w", err)
+	}
+	if backup.SiteId == "" {
+		return fmt.Errorf("Backup does not identify a site")
+	}
+
+	if _, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{}); err == nil {
+		return fmt.Errorf("A site already exists in %s; it must be deleted before restoring", cli.Namespace)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	// the certificate authorities are created first, so that the
+	// router adopts them rather than generating new ones
+	for i := range backup.CertificateAuthorities {
+		ca := &backup.CertificateAuthorities[i]
+		if _, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Create(ca); errors.IsAlreadyExists(err) {
+			existing, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get(ca.ObjectMeta.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			existing.Data = ca.Data
+			if _, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Update(existing); err != nil {
+				return fmt.Errorf("Failed to restore %s: %w", ca.ObjectMeta.Name, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("Failed to restore %s: %w", ca.ObjectMeta.Name, err)
+		}
+	}
+
+	configmap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   types.DefaultSiteName,
+			Labels: backup.SiteLabels,
+			Annotations: map[string]string{
+				types.SiteIdQualifier: backup.SiteId,
+			},
+		},
+		Data: backup.Site,
+	}
+	actual, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Create(configmap)
+	if err != nil {
+		return err
+	}
+	if actual.TypeMeta.Kind == "" || actual.TypeMeta.APIVersion == "" {
+		actual.TypeMeta = configmap.TypeMeta
+	}
+	siteConfig, err := cli.SiteConfigInspect(ctx, actual)
+	if err != nil {
+		return err
+	}
+	siteConfig.Spec.SkupperNamespace = cli.Namespace
+	var router *appsv1.Deployment
+	if siteConfig.Spec.SiteControlled {
+		// the site controller creates the router from the config
+		// map, as it does for the links
+		router, err = cli.waitForRouter(ctx)
+		if err != nil {
+			return fmt.Errorf("The router was not created by the site controller: %w", err)
+		}
+	} else {
+		if err := cli.RouterCreate(ctx, *siteConfig); err != nil {
+			return err
+		}
+		router, err = kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range backup.Links {
+		secret := &backup.Links[i]
+		secret.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+			kube.GetDeploymentOwnerReference(router),
+		}
+		if _, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Create(secret); err != nil {
+			return fmt.Errorf("Failed to restore link %s: %w", secret.ObjectMeta.Name, err)
+		}
+		if siteConfig.Spec.SiteControlled {
+			// the site controller makes the link from the token
+			continue
+		}
+		options := types.ConnectorCreateOptions{
+			Name:             secret.ObjectMeta.Name,
+			SkupperNamespace: cli.Namespace,
+		}
+		if cost, err := strconv.Atoi(secret.ObjectMeta.Annotations[types.TokenCost]); err == nil {
+			options.Cost = int32(cost)
+		}
+		if err := cli.ConnectorCreate(ctx, secret, options); err != nil {
+			return fmt.Errorf("Failed to restore link %s: %w", secret.ObjectMeta.Name, err)
+		}
+	}
+	for i := range backup.Services {
+		if err := cli.ServiceInterfaceCreate(ctx, &backup.Services[i]); err != nil {
+			return fmt.Errorf("Failed to restore service %s: %w", backup.Services[i].Address, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+	"github.com/skupperproject/skupper/pkg/utils"
+)
+
+func getRouterSiteId(t *testing.T, cli *VanClient) string {
+	router, err := kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+	assert.Assert(t, err)
+	env := kube.FindEnvVar(router.Spec.Template.Spec.Containers[0].Env, "SKUPPER_SITE_ID")
+	assert.Assert(t, env != nil)
+	return env.Value
+}
+
+func TestSiteBackupRestore(t *testing.T) {
+	if *clusterRun {
+		t.Skip("Only runs against the mock client")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "site-backup")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "east.yaml")
+	assert.Assert(t, ioutil.WriteFile(tokenFile, []byte(testToken), 0644))
+	backupFile := filepath.Join(dir, "west.backup")
+
+	original, err := newMockClient("backup-west", "", "")
+	assert.Assert(t, err)
+	manifest, err := ReadSiteManifest([]byte(testManifest))
+	assert.Assert(t, err)
+	manifest.Links[0].Token = tokenFile
+	_, err = original.SiteManifestApply(ctx, manifest, false)
+	assert.Assert(t, err)
+	assert.Assert(t, original.SiteBackup(ctx, backupFile, "secret"))
+
+	// nothing of the site is readable in the file itself
+	encrypted, err := ioutil.ReadFile(backupFile)
+	assert.Assert(t, err)
+	assert.Assert(t, !bytes.Contains(encrypted, []byte("skupper-internal-ca")))
+
+	restored, err := newMockClient("backup-west", "", "")
+	assert.Assert(t, err)
+	err = restored.SiteRestore(ctx, backupFile, "wrong")
+	assert.ErrorContains(t, err, "passphrase may be wrong")
+	assert.Assert(t, restored.SiteRestore(ctx, backupFile, "secret"))
+
+	// the restored site has the same identity as the original
+	assert.Equal(t, getRouterSiteId(t, restored), getRouterSiteId(t, original))
+	configmap, err := kube.GetConfigMap(types.DefaultSiteName, restored.Namespace, restored.KubeClient)
+	assert.Assert(t, err)
+	siteConfig, err := restored.SiteConfigInspect(ctx, configmap)
+	assert.Assert(t, err)
+	assert.Equal(t, siteConfig.SiteId, getRouterSiteId(t, original))
+	assert.Equal(t, siteConfig.Spec.SkupperName, "west")
+	for _, name := range []string{"skupper-ca", "skupper-internal-ca"} {
+		before, err := original.KubeClient.CoreV1().Secrets(original.Namespace).Get(name, metav1.GetOptions{})
+		assert.Assert(t, err)
+		after, err := restored.KubeClient.CoreV1().Secrets(restored.Namespace).Get(name, metav1.GetOptions{})
+		assert.Assert(t, err)
+		assert.DeepEqual(t, after.Data, before.Data)
+	}
+	internal, err := kube.GetConfigMap("skupper-internal", restored.Namespace, restored.KubeClient)
+	assert.Assert(t, err)
+	config, err := qdr.GetRouterConfigFromConfigMap(internal)
+	assert.Assert(t, err)
+	assert.Equal(t, config.Connectors["east"].Host, "east.example.com")
+	assert.Equal(t, config.Connectors["east"].Cost, int32(2))
+	service, err := restored.ServiceInterfaceInspect(ctx, "backend")
+	assert.Assert(t, err)
+	assert.Equal(t, service.Port, 8080)
+
+	// a site is never overwritten
+	err = restored.SiteRestore(ctx, backupFile, "secret")
+	assert.ErrorContains(t, err, "A site already exists")
+}
+
+func TestSiteRestoreSiteControlled(t *testing.T) {
+	if *clusterRun {
+		t.Skip("Only runs against the mock client")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "site-backup")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "east.yaml")
+	assert.Assert(t, ioutil.WriteFile(tokenFile, []byte(testToken), 0644))
+	backupFile := filepath.Join(dir, "west.backup")
+
+	original, err := newMockClient("backup-controlled", "", "")
+	assert.Assert(t, err)
+	manifest, err := ReadSiteManifest([]byte(testManifest))
+	assert.Assert(t, err)
+	manifest.Links[0].Token = tokenFile
+	_, err = original.SiteManifestApply(ctx, manifest, false)
+	assert.Assert(t, err)
+	// hand the site over to the site controller
+	site, err := kube.GetConfigMap(types.DefaultSiteName, original.Namespace, original.KubeClient)
+	assert.Assert(t, err)
+	site.ObjectMeta.Labels = nil
+	_, err = original.KubeClient.CoreV1().ConfigMaps(original.Namespace).Update(site)
+	assert.Assert(t, err)
+	assert.Assert(t, original.SiteBackup(ctx, backupFile, "secret"))
+
+	restored, err := newMockClient("backup-controlled", "", "")
+	assert.Assert(t, err)
+	// stands in for the site controller, which creates the router once
+	// the site config map appears
+	controllerDone := make(chan error, 1)
+	go func() {
+		controllerDone <- utils.Retry(100*time.Millisecond, 100, func() (bool, error) {
+			configmap, err := kube.GetConfigMap(types.DefaultSiteName, restored.Namespace, restored.KubeClient)
+			if err != nil {
+				return false, nil
+			}
+			siteConfig, err := restored.SiteConfigInspect(ctx, configmap)
+			if err != nil {
+				return false, err
+			}
+			if !siteConfig.Spec.SiteControlled {
+				return false, fmt.Errorf("Restored site is not site controlled")
+			}
+			return true, restored.RouterCreate(ctx, *siteConfig)
+		})
+	}()
+	assert.Assert(t, restored.SiteRestore(ctx, backupFile, "secret"))
+	assert.Assert(t, <-controllerDone)
+
+	assert.Equal(t, getRouterSiteId(t, restored), getRouterSiteId(t, original))
+	// the link is left for the site controller to make from the token
+	_, err = restored.KubeClient.CoreV1().Secrets(restored.Namespace).Get("east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	internal, err := kube.GetConfigMap("skupper-internal", restored.Namespace, restored.KubeClient)
+	assert.Assert(t, err)
+	config, err := qdr.GetRouterConfigFromConfigMap(internal)
+	assert.Assert(t, err)
+	_, ok := config.Connectors["east"]
+	assert.Assert(t, !ok)
+	service, err := restored.ServiceInterfaceInspect(ctx, "backend")
+	assert.Assert(t, err)
+	assert.Equal(t, service.Port, 8080)
+}
@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -9,7 +11,8 @@ import (
 	"github.com/skupperproject/skupper/api/types"
 )
 
-func (cli *VanClient) SiteConfigCreate(ctx context.Context, spec types.SiteConfigSpec) (*types.SiteConfig, error) {
+// NewSiteConfigMap returns the skupper-site config map for the spec
+func (cli *VanClient) NewSiteConfigMap(spec types.SiteConfigSpec) (*corev1.ConfigMap, error) {
 	siteConfig := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -61,12 +64,46 @@ func (cli *VanClient) SiteConfigCreate(ctx context.Context, spec types.SiteConfi
 	if spec.ClusterLocal {
 		siteConfig.Data["cluster-local"] = "true"
 	}
-	// TODO: allow Replicas to be set through skupper-site configmap?
+	if spec.Ingress != "" {
+		siteConfig.Data["ingress"] = spec.Ingress
+	}
+	if spec.IngressHost != "" {
+		siteConfig.Data["ingress-host"] = spec.IngressHost
+	}
+	if spec.IngressInterRouterPort != 0 {
+		siteConfig.Data["ingress-inter-router-port"] = strconv.Itoa(spec.IngressInterRouterPort)
+	}
+	if spec.IngressEdgePort != 0 {
+		siteConfig.Data["ingress-edge-port"] = strconv.Itoa(spec.IngressEdgePort)
+	}
+	if err := FormatPodSettings(RouterPodSettingsPrefix, spec.Router, siteConfig.Data); err != nil {
+		return nil, err
+	}
+	if err := FormatPodSettings(ControllerPodSettingsPrefix, spec.Controller, siteConfig.Data); err != nil {
+		return nil, err
+	}
+	if len(spec.ImagePullSecrets) > 0 {
+		siteConfig.Data[ImagePullSecretsKey] = strings.Join(spec.ImagePullSecrets, ",")
+	}
+	if spec.Replicas > 1 {
+		siteConfig.Data["routers"] = strconv.Itoa(int(spec.Replicas))
+	}
+	if spec.KeepCertificateAuthorities {
+		siteConfig.Data["keep-ca-on-delete"] = "true"
+	}
 	if !spec.SiteControlled {
 		siteConfig.ObjectMeta.Labels = map[string]string{
 			"internal.skupper.io/site-controller-ignore": "true",
 		}
 	}
+	return siteConfig, nil
+}
+
+func (cli *VanClient) SiteConfigCreate(ctx context.Context, spec types.SiteConfigSpec) (*types.SiteConfig, error) {
+	siteConfig, err := cli.NewSiteConfigMap(spec)
+	if err != nil {
+		return nil, err
+	}
 	actual, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Create(siteConfig)
 	if err != nil {
 		return nil, err
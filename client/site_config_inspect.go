@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
@@ -78,7 +79,32 @@ func (cli *VanClient) SiteConfigInspect(ctx context.Context, input *corev1.Confi
 	} else {
 		result.Spec.ClusterLocal = false
 	}
-	// TODO: allow Replicas to be set through skupper-site configmap?
+	result.Spec.Ingress = siteConfig.Data["ingress"]
+	result.Spec.IngressHost = siteConfig.Data["ingress-host"]
+	if port, ok := siteConfig.Data["ingress-inter-router-port"]; ok {
+		result.Spec.IngressInterRouterPort, _ = strconv.Atoi(port)
+	}
+	if port, ok := siteConfig.Data["ingress-edge-port"]; ok {
+		result.Spec.IngressEdgePort, _ = strconv.Atoi(port)
+	}
+	var err error
+	if result.Spec.Router, err = ParsePodSettings(RouterPodSettingsPrefix, siteConfig.Data); err != nil {
+		return nil, err
+	}
+	if result.Spec.Controller, err = ParsePodSettings(ControllerPodSettingsPrefix, siteConfig.Data); err != nil {
+		return nil, err
+	}
+	result.Spec.ImagePullSecrets = parseImagePullSecrets(siteConfig.Data[ImagePullSecretsKey])
+	if routers, ok := siteConfig.Data["routers"]; ok {
+		replicas, err := strconv.Atoi(routers)
+		if err != nil || replicas < 1 {
+			return nil, fmt.Errorf("Invalid value for routers: %q", routers)
+		}
+		result.Spec.Replicas = int32(replicas)
+	}
+	if keepCAs, ok := siteConfig.Data["keep-ca-on-delete"]; ok {
+		result.Spec.KeepCertificateAuthorities, _ = strconv.ParseBool(keepCAs)
+	}
 	if siteConfig.ObjectMeta.Labels == nil {
 		result.Spec.SiteControlled = true
 	} else if ignore, ok := siteConfig.ObjectMeta.Labels["internal.skupper.io/site-controller-ignore"]; ok {
@@ -88,6 +114,11 @@ func (cli *VanClient) SiteConfigInspect(ctx context.Context, input *corev1.Confi
 		result.Spec.SiteControlled = true
 	}
 	result.Reference.UID = string(siteConfig.ObjectMeta.UID)
+	if siteId, ok := siteConfig.ObjectMeta.Annotations[types.SiteIdQualifier]; ok && siteId != "" {
+		result.SiteId = siteId
+	} else {
+		result.SiteId = result.Reference.UID
+	}
 	result.Reference.Name = siteConfig.ObjectMeta.Name
 	result.Reference.Kind = siteConfig.TypeMeta.Kind
 	result.Reference.APIVersion = siteConfig.TypeMeta.APIVersion
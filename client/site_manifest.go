@@ -0,0 +1,540 @@
+package client
+
+import (
+	"context"
+	jsonencoding "encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+// ReadSiteManifest parses a site manifest given in YAML or JSON
+func ReadSiteManifest(data []byte) (*types.SiteManifest, error) {
+	manifest := &types.SiteManifest{}
+	// these default to true if not specified, as for skupper init
+	manifest.Site.EnableController = true
+	manifest.Site.EnableServiceSync = true
+	if err := yaml.UnmarshalStrict(data, manifest); err != nil {
+		return nil, fmt.Errorf("Invalid site manifest: %w", err)
+	}
+	if manifest.APIVersion != types.SiteManifestAPIVersion || manifest.Kind != types.SiteManifestKind {
+		return nil, fmt.Errorf("Invalid site manifest: expected apiVersion %s and kind %s", types.SiteManifestAPIVersion, types.SiteManifestKind)
+	}
+	return manifest, nil
+}
+
+// WriteSiteManifest encodes a site manifest as YAML
+func WriteSiteManifest(manifest *types.SiteManifest) ([]byte, error) {
+	return yaml.Marshal(manifest)
+}
+
+// isUpdatableSiteKey returns true for the skupper-site keys whose
+// values can be changed without recreating the site
+func isUpdatableSiteKey(key string) bool {
+	if key == "routers" || key == ImagePullSecretsKey || key == "keep-ca-on-delete" {
+		return true
+	}
+	for _, prefix := range []string{RouterPodSettingsPrefix, ControllerPodSettingsPrefix} {
+		for _, podKey := range PodSettingsKeys(prefix) {
+			if key == podKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cli *VanClient) validateSiteManifest(manifest *types.SiteManifest) error {
+	configmap, err := cli.NewSiteConfigMap(manifest.Site)
+	if err != nil {
+		return err
+	}
+	if err := ValidateSiteConfig(configmap.Data); err != nil {
+		return err
+	}
+	links := map[string]bool{}
+	for _, link := range manifest.Links {
+		if link.Name == "" {
+			return fmt.Errorf("Each link must be named")
+		}
+		if links[link.Name] {
+			return fmt.Errorf("Link %s is specified more than once", link.Name)
+		}
+		links[link.Name] = true
+	}
+	addresses := map[string]bool{}
+	for i := range manifest.Services {
+		service := &manifest.Services[i]
+		if addresses[service.Address] {
+			return fmt.Errorf("Service %s is specified more than once", service.Address)
+		}
+		addresses[service.Address] = true
+		if err := ValidateServiceInterface(service); err != nil {
+			return fmt.Errorf("Invalid service %s: %w", service.Address, err)
+		}
+	}
+	return nil
+}
+
+// SiteManifestExport describes the site in the namespace. The console
+// password and the contents of tokens are omitted; each link refers
+// instead to a token file named after it.
+func (cli *VanClient) SiteManifestExport(ctx context.Context) (*types.SiteManifest, error) {
+	siteConfig, err := cli.SiteConfigInspect(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if siteConfig == nil {
+		return nil, fmt.Errorf("Skupper not initialised in %s", cli.Namespace)
+	}
+	manifest := &types.SiteManifest{
+		APIVersion: types.SiteManifestAPIVersion,
+		Kind:       types.SiteManifestKind,
+		Site:       siteConfig.Spec,
+	}
+	manifest.Site.Password = ""
+
+	links, err := cli.getLinks()
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		link.Token = link.Name + ".yaml"
+		manifest.Links = append(manifest.Links, link)
+	}
+
+	services, err := cli.getDefinedServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		manifest.Services = append(manifest.Services, *service)
+	}
+	return manifest, nil
+}
+
+// getLinks returns the links of the site, sorted by name
+func (cli *VanClient) getLinks() ([]types.SiteManifestLink, error) {
+	secrets, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).List(metav1.ListOptions{LabelSelector: "skupper.io/type=connection-token"})
+	if err != nil {
+		return nil, err
+	}
+	var connectors map[string]qdr.Connector
+	if configmap, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient); err == nil {
+		if current, err := qdr.GetRouterConfigFromConfigMap(configmap); err == nil {
+			connectors = current.Connectors
+		}
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	links := []types.SiteManifestLink{}
+	for _, secret := range secrets.Items {
+		link := types.SiteManifestLink{
+			Name: secret.ObjectMeta.Name,
+		}
+		if connector, ok := connectors[secret.ObjectMeta.Name]; ok {
+			link.Cost = connector.Cost
+		} else if value, ok := secret.ObjectMeta.Annotations[types.TokenCost]; ok {
+			if cost, err := strconv.Atoi(value); err == nil {
+				link.Cost = int32(cost)
+			}
+		}
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Name < links[j].Name
+	})
+	return links, nil
+}
+
+// getDefinedServices returns the service definitions made at the site
+// itself, rather than derived from annotations or received from other
+// sites, sorted by address
+func (cli *VanClient) getDefinedServices(ctx context.Context) ([]*types.ServiceInterface, error) {
+	list, err := cli.ServiceInterfaceList(ctx)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	services := []*types.ServiceInterface{}
+	for _, service := range list {
+		if service.Origin == "" {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Address < services[j].Address
+	})
+	return services, nil
+}
+
+// diffFields lists the top level fields of the JSON encodings of two
+// objects that differ
+func diffFields(current interface{}, desired interface{}) ([]string, error) {
+	toMap := func(obj interface{}) (map[string]interface{}, error) {
+		encoded, err := jsonencoding.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{}
+		err = jsonencoding.Unmarshal(encoded, &result)
+		return result, err
+	}
+	a, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toMap(desired)
+	if err != nil {
+		return nil, err
+	}
+	fields := []string{}
+	for key, value := range b {
+		if !reflect.DeepEqual(a[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+func normaliseServiceDefinition(service types.ServiceInterface) types.ServiceInterface {
+	service.Origin = ""
+	if service.Targets == nil {
+		service.Targets = []types.ServiceInterfaceTarget{}
+	}
+	return service
+}
+
+// siteChanges compares the skupper-site config map with that for the
+// desired spec, returning the keys whose values differ. A console
+// password that is not specified is left as it is.
+func (cli *VanClient) siteChanges(ctx context.Context, spec types.SiteConfigSpec) (*types.SiteManifestChange, error) {
+	desired, err := cli.NewSiteConfigMap(spec)
+	if err != nil {
+		return nil, err
+	}
+	current, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return &types.SiteManifestChange{
+			Action: types.ManifestCreate,
+			Kind:   types.ManifestSite,
+			Name:   desired.Data["name"],
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if spec.Password == "" {
+		desired.Data["console-password"] = current.Data["console-password"]
+	}
+	details := []string{}
+	for key, value := range desired.Data {
+		if old, ok := current.Data[key]; !ok || old != value {
+			if key == "console-password" {
+				details = append(details, key+": (changed)")
+			} else {
+				details = append(details, fmt.Sprintf("%s: %q -> %q", key, old, value))
+			}
+		}
+	}
+	for key, old := range current.Data {
+		if _, ok := desired.Data[key]; !ok {
+			details = append(details, fmt.Sprintf("%s: %q -> (unset)", key, old))
+		}
+	}
+	if len(details) == 0 {
+		return nil, nil
+	}
+	sort.Strings(details)
+	return &types.SiteManifestChange{
+		Action:  types.ManifestUpdate,
+		Kind:    types.ManifestSite,
+		Name:    desired.Data["name"],
+		Details: details,
+	}, nil
+}
+
+// SiteManifestDiff returns the changes needed to bring the namespace
+// into line with the manifest. Links and services not in the manifest
+// are only removed if prune is set; services defined through
+// annotations or received from other sites are never removed.
+func (cli *VanClient) SiteManifestDiff(ctx context.Context, manifest *types.SiteManifest, prune bool) ([]types.SiteManifestChange, error) {
+	if err := cli.validateSiteManifest(manifest); err != nil {
+		return nil, err
+	}
+	changes := []types.SiteManifestChange{}
+	siteChange, err := cli.siteChanges(ctx, manifest.Site)
+	if err != nil {
+		return nil, err
+	}
+	if siteChange != nil {
+		changes = append(changes, *siteChange)
+	}
+	siteExists := siteChange == nil || siteChange.Action != types.ManifestCreate
+
+	currentLinks := map[string]types.SiteManifestLink{}
+	if siteExists {
+		links, err := cli.getLinks()
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			currentLinks[link.Name] = link
+		}
+	}
+	desiredLinks := map[string]bool{}
+	for _, link := range manifest.Links {
+		desiredLinks[link.Name] = true
+		if current, ok := currentLinks[link.Name]; !ok {
+			changes = append(changes, types.SiteManifestChange{
+				Action: types.ManifestCreate,
+				Kind:   types.ManifestLink,
+				Name:   link.Name,
+			})
+		} else if link.Cost != 0 && current.Cost != link.Cost {
+			changes = append(changes, types.SiteManifestChange{
+				Action:  types.ManifestUpdate,
+				Kind:    types.ManifestLink,
+				Name:    link.Name,
+				Details: []string{fmt.Sprintf("cost: %d -> %d", current.Cost, link.Cost)},
+			})
+		}
+	}
+	if prune {
+		names := []string{}
+		for name := range currentLinks {
+			if !desiredLinks[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			changes = append(changes, types.SiteManifestChange{
+				Action: types.ManifestDelete,
+				Kind:   types.ManifestLink,
+				Name:   name,
+			})
+		}
+	}
+
+	currentServices := map[string]*types.ServiceInterface{}
+	annotated := map[string]bool{}
+	if siteExists {
+		list, err := cli.ServiceInterfaceList(ctx)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		for _, service := range list {
+			if service.Origin == "annotation" {
+				annotated[service.Address] = true
+			} else {
+				currentServices[service.Address] = service
+			}
+		}
+	}
+	desiredServices := map[string]bool{}
+	for _, service := range manifest.Services {
+		desiredServices[service.Address] = true
+		if annotated[service.Address] {
+			return nil, fmt.Errorf("Service %s is defined through annotations and cannot also be specified in the manifest", service.Address)
+		}
+		current, ok := currentServices[service.Address]
+		if !ok {
+			changes = append(changes, types.SiteManifestChange{
+				Action: types.ManifestCreate,
+				Kind:   types.ManifestService,
+				Name:   service.Address,
+			})
+			continue
+		}
+		fields, err := diffFields(normaliseServiceDefinition(*current), normaliseServiceDefinition(service))
+		if err != nil {
+			return nil, err
+		}
+		if current.Origin != "" {
+			// a definition received from another site is replaced
+			fields = append(fields, "origin")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, types.SiteManifestChange{
+				Action:  types.ManifestUpdate,
+				Kind:    types.ManifestService,
+				Name:    service.Address,
+				Details: fields,
+			})
+		}
+	}
+	if prune {
+		addresses := []string{}
+		for address := range currentServices {
+			if !desiredServices[address] && currentServices[address].Origin == "" {
+				addresses = append(addresses, address)
+			}
+		}
+		sort.Strings(addresses)
+		for _, address := range addresses {
+			changes = append(changes, types.SiteManifestChange{
+				Action: types.ManifestDelete,
+				Kind:   types.ManifestService,
+				Name:   address,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// SiteManifestApply brings the namespace into line with the manifest,
+// returning the changes made. Applying the same manifest again makes
+// no further changes. Settings of an existing site that can only be
+// chosen when it is created are not changed; an error is returned
+// before anything is applied if the manifest requires that.
+func (cli *VanClient) SiteManifestApply(ctx context.Context, manifest *types.SiteManifest, prune bool) ([]types.SiteManifestChange, error) {
+	changes, err := cli.SiteManifestDiff(ctx, manifest, prune)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		if change.Kind == types.ManifestSite && change.Action == types.ManifestUpdate {
+			fixed := []string{}
+			for _, detail := range change.Details {
+				key := strings.SplitN(detail, ":", 2)[0]
+				if !isUpdatableSiteKey(key) {
+					fixed = append(fixed, key)
+				}
+			}
+			if len(fixed) > 0 {
+				return nil, fmt.Errorf("Changing %s requires the site to be deleted and created again", strings.Join(fixed, ", "))
+			}
+		}
+		if change.Kind == types.ManifestLink && change.Action != types.ManifestDelete {
+			if link := getManifestLink(manifest, change.Name); link.Token == "" {
+				return nil, fmt.Errorf("A token is required to %s link %s", change.Action, change.Name)
+			}
+		}
+	}
+
+	spec := manifest.Site
+	spec.SkupperNamespace = cli.Namespace
+	for _, change := range changes {
+		switch change.Kind {
+		case types.ManifestSite:
+			if change.Action == types.ManifestCreate {
+				siteConfig, err := cli.SiteConfigCreate(ctx, spec)
+				if err != nil {
+					return nil, err
+				}
+				if err := cli.RouterCreate(ctx, *siteConfig); err != nil {
+					return nil, err
+				}
+			} else if err := cli.updateSiteConfig(ctx, spec); err != nil {
+				return nil, err
+			}
+		case types.ManifestLink:
+			if change.Action != types.ManifestCreate {
+				err := cli.ConnectorRemove(ctx, types.ConnectorRemoveOptions{
+					Name:             change.Name,
+					SkupperNamespace: cli.Namespace,
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+			if change.Action == types.ManifestDelete {
+				continue
+			}
+			link := getManifestLink(manifest, change.Name)
+			options := types.ConnectorCreateOptions{
+				Name:             link.Name,
+				Cost:             link.Cost,
+				SkupperNamespace: cli.Namespace,
+			}
+			siteConfig, err := cli.SiteConfigInspect(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+			if siteConfig != nil && siteConfig.Spec.SiteControlled {
+				// the site controller makes the link from the token
+				_, err = cli.ConnectorCreateSecretFromFile(ctx, link.Token, options)
+			} else {
+				_, err = cli.ConnectorCreateFromFile(ctx, link.Token, options)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Failed to create link %s: %w", link.Name, err)
+			}
+		case types.ManifestService:
+			switch change.Action {
+			case types.ManifestCreate:
+				err = cli.ServiceInterfaceCreate(ctx, getManifestService(manifest, change.Name))
+			case types.ManifestUpdate:
+				err = cli.ServiceInterfaceUpdate(ctx, getManifestService(manifest, change.Name))
+			case types.ManifestDelete:
+				err = cli.ServiceInterfaceRemove(ctx, change.Name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Failed to %s service %s: %w", change.Action, change.Name, err)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// updateSiteConfig rewrites the skupper-site config map for the spec
+// and applies the settings that can be changed on a running site
+func (cli *VanClient) updateSiteConfig(ctx context.Context, spec types.SiteConfigSpec) error {
+	desired, err := cli.NewSiteConfigMap(spec)
+	if err != nil {
+		return err
+	}
+	current, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if spec.Password == "" {
+		desired.Data["console-password"] = current.Data["console-password"]
+	}
+	current.Data = desired.Data
+	if _, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(current); err != nil {
+		return err
+	}
+	siteConfig, err := cli.SiteConfigInspect(ctx, current)
+	if err != nil {
+		return err
+	}
+	_, err = cli.RouterUpdatePodSettings(ctx, siteConfig.Spec)
+	return err
+}
+
+func getManifestLink(manifest *types.SiteManifest, name string) types.SiteManifestLink {
+	for _, link := range manifest.Links {
+		if link.Name == name {
+			return link
+		}
+	}
+	return types.SiteManifestLink{}
+}
+
+func getManifestService(manifest *types.SiteManifest, address string) *types.ServiceInterface {
+	for i := range manifest.Services {
+		if manifest.Services[i].Address == address {
+			service := manifest.Services[i]
+			return &service
+		}
+	}
+	return nil
+}
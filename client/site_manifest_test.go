@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+const testToken = `apiVersion: v1
+kind: Secret
+metadata:
+  name: east
+  annotations:
+    skupper.io/generated-by: east-site-id
+    inter-router-host: east.example.com
+    inter-router-port: "55671"
+    edge-host: east.example.com
+    edge-port: "45671"
+data:
+  ca.crt: ""
+  tls.crt: ""
+  tls.key: ""
+`
+
+const testManifest = `apiVersion: skupper.io/v1alpha1
+kind: SiteManifest
+site:
+  name: west
+  clusterLocal: true
+links:
+- name: east
+  token: TOKEN
+  cost: 2
+services:
+- address: backend
+  protocol: tcp
+  port: 8080
+  targets:
+  - name: backend
+    selector: app=backend
+`
+
+func TestReadSiteManifest(t *testing.T) {
+	manifest, err := ReadSiteManifest([]byte(testManifest))
+	assert.Assert(t, err)
+	assert.Equal(t, manifest.Site.SkupperName, "west")
+	assert.Assert(t, manifest.Site.ClusterLocal)
+	// defaults as for skupper init
+	assert.Assert(t, manifest.Site.EnableController)
+	assert.Assert(t, manifest.Site.EnableServiceSync)
+	assert.Equal(t, len(manifest.Links), 1)
+	assert.Equal(t, manifest.Links[0].Cost, int32(2))
+	assert.Equal(t, len(manifest.Services), 1)
+	assert.Equal(t, manifest.Services[0].Port, 8080)
+
+	_, err = ReadSiteManifest([]byte("apiVersion: skupper.io/v1alpha1\nkind: Site\n"))
+	assert.ErrorContains(t, err, "expected apiVersion skupper.io/v1alpha1 and kind SiteManifest")
+	_, err = ReadSiteManifest([]byte("apiVersion: skupper.io/v1alpha1\nkind: SiteManifest\nsite:\n  edgee: true\n"))
+	assert.ErrorContains(t, err, "Invalid site manifest")
+}
+
+func TestSiteManifestApply(t *testing.T) {
+	if *clusterRun {
+		t.Skip("Only runs against the mock client")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "site-manifest")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "east.yaml")
+	assert.Assert(t, ioutil.WriteFile(tokenFile, []byte(testToken), 0644))
+
+	cli, err := newMockClient("manifest-west", "", "")
+	assert.Assert(t, err)
+	manifest, err := ReadSiteManifest([]byte(testManifest))
+	assert.Assert(t, err)
+	manifest.Links[0].Token = tokenFile
+
+	changes, err := cli.SiteManifestApply(ctx, manifest, false)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, changes, []types.SiteManifestChange{
+		{Action: types.ManifestCreate, Kind: types.ManifestSite, Name: "west"},
+		{Action: types.ManifestCreate, Kind: types.ManifestLink, Name: "east"},
+		{Action: types.ManifestCreate, Kind: types.ManifestService, Name: "backend"},
+	})
+	configmap, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient)
+	assert.Assert(t, err)
+	config, err := qdr.GetRouterConfigFromConfigMap(configmap)
+	assert.Assert(t, err)
+	assert.Equal(t, config.Connectors["east"].Host, "east.example.com")
+	assert.Equal(t, config.Connectors["east"].Cost, int32(2))
+	service, err := cli.ServiceInterfaceInspect(ctx, "backend")
+	assert.Assert(t, err)
+	assert.Equal(t, service.Port, 8080)
+
+	// applying again changes nothing
+	changes, err = cli.SiteManifestDiff(ctx, manifest, true)
+	assert.Assert(t, err)
+	assert.Equal(t, len(changes), 0, "%v", changes)
+
+	// exporting gives back the manifest, without the token file
+	exported, err := cli.SiteManifestExport(ctx)
+	assert.Assert(t, err)
+	assert.Equal(t, exported.Site.SkupperName, "west")
+	assert.DeepEqual(t, exported.Links, []types.SiteManifestLink{{Name: "east", Token: "east.yaml", Cost: 2}})
+	assert.Equal(t, len(exported.Services), 1)
+	exported.Links[0].Token = tokenFile
+	changes, err = cli.SiteManifestDiff(ctx, exported, true)
+	assert.Assert(t, err)
+	assert.Equal(t, len(changes), 0, "%v", changes)
+
+	// settings of a running site can be changed if the router
+	// deployment can be updated in place
+	manifest.Site.Replicas = 2
+	manifest.Services[0].Port = 9090
+	changes, err = cli.SiteManifestApply(ctx, manifest, false)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, changes, []types.SiteManifestChange{
+		{Action: types.ManifestUpdate, Kind: types.ManifestSite, Name: "west", Details: []string{`routers: "" -> "2"`}},
+		{Action: types.ManifestUpdate, Kind: types.ManifestService, Name: "backend", Details: []string{"port"}},
+	})
+	router, err := kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, *router.Spec.Replicas, int32(2))
+
+	manifest.Site.IsEdge = true
+	_, err = cli.SiteManifestApply(ctx, manifest, false)
+	assert.ErrorContains(t, err, "Changing edge requires the site to be deleted and created again")
+	manifest.Site.IsEdge = false
+
+	// links and services left out are only removed when pruning
+	manifest.Links = nil
+	manifest.Services = nil
+	changes, err = cli.SiteManifestDiff(ctx, manifest, false)
+	assert.Assert(t, err)
+	assert.Equal(t, len(changes), 0)
+	changes, err = cli.SiteManifestApply(ctx, manifest, true)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, changes, []types.SiteManifestChange{
+		{Action: types.ManifestDelete, Kind: types.ManifestLink, Name: "east"},
+		{Action: types.ManifestDelete, Kind: types.ManifestService, Name: "backend"},
+	})
+	_, err = cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get("east", metav1.GetOptions{})
+	assert.Assert(t, err != nil)
+	services, err := cli.ServiceInterfaceList(ctx)
+	assert.Assert(t, err)
+	assert.Equal(t, len(services), 0)
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+// certificateAuthorities are the secrets retained on teardown if
+// requested, so that tokens issued by the site remain valid if it is
+// reinstalled
+var certificateAuthorities = map[string]bool{
+	"skupper-ca":          true,
+	"skupper-internal-ca": true,
+	types.ServiceCaSecret: true,
+}
+
+// siteOwners records the objects from which the resources generated
+// for a site hang
+type siteOwners map[k8stypes.UID]bool
+
+func (owners siteOwners) owns(obj metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if owners[ref.UID] {
+			return true
+		}
+	}
+	return false
+}
+
+func (owners siteOwners) disown(obj metav1.Object) {
+	refs := []metav1.OwnerReference{}
+	for _, ref := range obj.GetOwnerReferences() {
+		if !owners[ref.UID] {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+}
+
+func (cli *VanClient) getSiteOwners() siteOwners {
+	owners := siteOwners{}
+	if cm, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{}); err == nil && cm.ObjectMeta.UID != "" {
+		owners[cm.ObjectMeta.UID] = true
+	}
+	for _, name := range []string{types.TransportDeploymentName, types.ControllerDeploymentName} {
+		if dep, err := cli.KubeClient.AppsV1().Deployments(cli.Namespace).Get(name, metav1.GetOptions{}); err == nil && dep.ObjectMeta.UID != "" {
+			owners[dep.ObjectMeta.UID] = true
+		}
+	}
+	return owners
+}
+
+// SiteTeardown removes the site from its namespace in an orderly way.
+// The service controller is stopped first, so that it does not undo
+// what follows. Services that were exposed by rewriting their selector
+// or target ports are restored, the router's links are closed and the
+// resources generated for the site are deleted. The certificate
+// authorities are kept if requested.
+func (cli *VanClient) SiteTeardown(ctx context.Context, keepCertificateAuthorities bool) error {
+	owners := cli.getSiteOwners()
+	err := cli.KubeClient.AppsV1().Deployments(cli.Namespace).Delete(types.ControllerDeploymentName, &metav1.DeleteOptions{})
+	if err = ignoreNotFound(err); err != nil {
+		return fmt.Errorf("Failed to remove service controller: %w", err)
+	}
+	if err := cli.restoreAnnotatedServices(); err != nil {
+		return fmt.Errorf("Failed to restore services: %w", err)
+	}
+	if err := cli.removeAllConnectors(); err != nil {
+		return fmt.Errorf("Failed to remove links: %w", err)
+	}
+	return cli.deleteSiteResources(owners, keepCertificateAuthorities)
+}
+
+func (cli *VanClient) restoreAnnotatedServices() error {
+	services, err := cli.KubeClient.CoreV1().Services(cli.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, service := range services.Items {
+		if kube.RestoreServiceDefinition(&service) {
+			log.Printf("Restoring service %s", service.ObjectMeta.Name)
+			if _, err := cli.KubeClient.CoreV1().Services(cli.Namespace).Update(&service); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeAllConnectors drops the connectors from the router config, so
+// that a router still running does not reestablish its links
+func (cli *VanClient) removeAllConnectors() error {
+	configmap, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	current, err := qdr.GetRouterConfigFromConfigMap(configmap)
+	if err != nil {
+		return err
+	}
+	if len(current.Connectors) == 0 {
+		return nil
+	}
+	for name := range current.Connectors {
+		_, connector := current.RemoveConnector(name)
+		if connector.SslProfile != "" {
+			current.RemoveSslProfile(connector.SslProfile)
+		}
+	}
+	if _, err := current.UpdateConfigMap(configmap); err != nil {
+		return err
+	}
+	_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(configmap)
+	return err
+}
+
+func ignoreNotFound(err error) error {
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (cli *VanClient) deleteSiteResources(owners siteOwners, keepCertificateAuthorities bool) error {
+	var errs []error
+	record := func(kind string, name string, err error) {
+		if err = ignoreNotFound(err); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", kind, name, err))
+		}
+	}
+	ns := cli.Namespace
+	options := &metav1.DeleteOptions{}
+
+	if secrets, err := cli.KubeClient.CoreV1().Secrets(ns).List(metav1.ListOptions{}); err == nil {
+		for _, secret := range secrets.Items {
+			if !owners.owns(&secret) {
+				continue
+			}
+			if keepCertificateAuthorities && certificateAuthorities[secret.ObjectMeta.Name] {
+				owners.disown(&secret)
+				_, err = cli.KubeClient.CoreV1().Secrets(ns).Update(&secret)
+				record("secret", secret.ObjectMeta.Name, err)
+			} else {
+				record("secret", secret.ObjectMeta.Name, cli.KubeClient.CoreV1().Secrets(ns).Delete(secret.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("secrets", "", err)
+	}
+	if services, err := cli.KubeClient.CoreV1().Services(ns).List(metav1.ListOptions{}); err == nil {
+		for _, service := range services.Items {
+			if owners.owns(&service) {
+				record("service", service.ObjectMeta.Name, cli.KubeClient.CoreV1().Services(ns).Delete(service.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("services", "", err)
+	}
+	if configmaps, err := cli.KubeClient.CoreV1().ConfigMaps(ns).List(metav1.ListOptions{}); err == nil {
+		for _, configmap := range configmaps.Items {
+			if owners.owns(&configmap) {
+				record("configmap", configmap.ObjectMeta.Name, cli.KubeClient.CoreV1().ConfigMaps(ns).Delete(configmap.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("configmaps", "", err)
+	}
+	if accounts, err := cli.KubeClient.CoreV1().ServiceAccounts(ns).List(metav1.ListOptions{}); err == nil {
+		for _, account := range accounts.Items {
+			if owners.owns(&account) {
+				record("serviceaccount", account.ObjectMeta.Name, cli.KubeClient.CoreV1().ServiceAccounts(ns).Delete(account.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("serviceaccounts", "", err)
+	}
+	if roles, err := cli.KubeClient.RbacV1().Roles(ns).List(metav1.ListOptions{}); err == nil {
+		for _, role := range roles.Items {
+			if owners.owns(&role) {
+				record("role", role.ObjectMeta.Name, cli.KubeClient.RbacV1().Roles(ns).Delete(role.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("roles", "", err)
+	}
+	if bindings, err := cli.KubeClient.RbacV1().RoleBindings(ns).List(metav1.ListOptions{}); err == nil {
+		for _, binding := range bindings.Items {
+			if owners.owns(&binding) {
+				record("rolebinding", binding.ObjectMeta.Name, cli.KubeClient.RbacV1().RoleBindings(ns).Delete(binding.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("rolebindings", "", err)
+	}
+	if budgets, err := cli.KubeClient.PolicyV1beta1().PodDisruptionBudgets(ns).List(metav1.ListOptions{}); err == nil {
+		for _, budget := range budgets.Items {
+			if owners.owns(&budget) {
+				record("poddisruptionbudget", budget.ObjectMeta.Name, cli.KubeClient.PolicyV1beta1().PodDisruptionBudgets(ns).Delete(budget.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("poddisruptionbudgets", "", err)
+	}
+	if ingresses, err := cli.KubeClient.NetworkingV1beta1().Ingresses(ns).List(metav1.ListOptions{}); err == nil {
+		for _, ingress := range ingresses.Items {
+			if owners.owns(&ingress) {
+				record("ingress", ingress.ObjectMeta.Name, cli.KubeClient.NetworkingV1beta1().Ingresses(ns).Delete(ingress.ObjectMeta.Name, options))
+			}
+		}
+	} else {
+		record("ingresses", "", err)
+	}
+	if cli.RouteClient != nil {
+		if routes, err := cli.RouteClient.Routes(ns).List(metav1.ListOptions{}); err == nil {
+			for _, route := range routes.Items {
+				if owners.owns(&route) {
+					record("route", route.ObjectMeta.Name, cli.RouteClient.Routes(ns).Delete(route.ObjectMeta.Name, options))
+				}
+			}
+		} else {
+			record("routes", "", err)
+		}
+	}
+	// the router goes last, as the other resources may hang from it
+	record("deployment", types.TransportDeploymentName, cli.KubeClient.AppsV1().Deployments(ns).Delete(types.TransportDeploymentName, options))
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Failed to delete some resources for the site: %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+func createSiteForTeardown(t *testing.T, cli *VanClient) {
+	siteConfig, err := cli.SiteConfigCreate(context.Background(), types.SiteConfigSpec{
+		EnableController:  true,
+		EnableServiceSync: true,
+		ClusterLocal:      true,
+	})
+	assert.Assert(t, err)
+	// the fake clientset does not assign uids
+	cm, err := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	cm.ObjectMeta.UID = k8stypes.UID("site-uid")
+	_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(cm)
+	assert.Assert(t, err)
+	siteConfig.Reference.UID = "site-uid"
+	assert.Assert(t, cli.RouterCreate(context.Background(), *siteConfig))
+
+	configmap, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient)
+	assert.Assert(t, err)
+	current, err := qdr.GetRouterConfigFromConfigMap(configmap)
+	assert.Assert(t, err)
+	current.AddSslProfile(qdr.SslProfile{Name: "link1-profile"})
+	current.AddConnector(qdr.Connector{Name: "link1", Host: "east", Port: "55671", SslProfile: "link1-profile"})
+	_, err = current.UpdateConfigMap(configmap)
+	assert.Assert(t, err)
+	_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Update(configmap)
+	assert.Assert(t, err)
+
+	// a service exposed by annotation, whose selector and target port
+	// were rewritten to route through the router
+	_, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Create(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "backend",
+			Annotations: map[string]string{
+				types.ProxyQualifier:              "tcp",
+				types.OriginalSelectorQualifier:   "app=backend",
+				types.OriginalTargetPortQualifier: "8080",
+				types.OriginalAssignedQualifier:   "1024",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: kube.GetLabelsForRouter(),
+			Ports: []corev1.ServicePort{{
+				Port:       80,
+				TargetPort: intstr.FromInt(1024),
+			}},
+		},
+	})
+	assert.Assert(t, err)
+}
+
+func TestSiteTeardown(t *testing.T) {
+	tests := []struct {
+		name   string
+		keepCA bool
+	}{
+		{"delete-ca", false},
+		{"keep-ca", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cli, err := newMockClient("teardown-"+test.name, "", "")
+			assert.Assert(t, err)
+			createSiteForTeardown(t, cli)
+
+			fakeClient := cli.KubeClient.(*fake.Clientset)
+			fakeClient.ClearActions()
+			assert.Assert(t, cli.SiteTeardown(context.Background(), test.keepCA))
+			// the service controller is stopped before the services
+			// it manages are restored
+			firstWrite := ""
+			for _, action := range fakeClient.Actions() {
+				if action.GetVerb() == "update" || action.GetVerb() == "delete" {
+					firstWrite = action.GetVerb() + " " + action.GetResource().Resource
+					break
+				}
+			}
+			assert.Equal(t, firstWrite, "delete deployments")
+
+			service, err := cli.KubeClient.CoreV1().Services(cli.Namespace).Get("backend", metav1.GetOptions{})
+			assert.Assert(t, err)
+			assert.DeepEqual(t, service.Spec.Selector, map[string]string{"app": "backend"})
+			assert.Equal(t, service.Spec.Ports[0].TargetPort.IntValue(), 8080)
+			_, ok := service.ObjectMeta.Annotations[types.OriginalSelectorQualifier]
+			assert.Assert(t, !ok)
+			assert.Equal(t, service.ObjectMeta.Annotations[types.ProxyQualifier], "tcp")
+
+			for _, name := range []string{types.TransportDeploymentName, types.ControllerDeploymentName} {
+				_, err = cli.KubeClient.AppsV1().Deployments(cli.Namespace).Get(name, metav1.GetOptions{})
+				assert.Assert(t, errors.IsNotFound(err), name)
+			}
+			for _, name := range []string{"skupper-internal", "skupper-services"} {
+				_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(name, metav1.GetOptions{})
+				assert.Assert(t, errors.IsNotFound(err), name)
+			}
+			_, err = cli.KubeClient.CoreV1().Services(cli.Namespace).Get("skupper-messaging", metav1.GetOptions{})
+			assert.Assert(t, errors.IsNotFound(err))
+			_, err = cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get("skupper-amqps", metav1.GetOptions{})
+			assert.Assert(t, errors.IsNotFound(err))
+			// the site config itself is left to its owner
+			_, err = cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+			assert.Assert(t, err)
+
+			for _, name := range []string{"skupper-ca", "skupper-internal-ca"} {
+				ca, err := cli.KubeClient.CoreV1().Secrets(cli.Namespace).Get(name, metav1.GetOptions{})
+				if test.keepCA {
+					assert.Assert(t, err)
+					assert.Equal(t, len(ca.ObjectMeta.OwnerReferences), 0)
+				} else {
+					assert.Assert(t, errors.IsNotFound(err), name)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveAllConnectors(t *testing.T) {
+	cli, err := newMockClient("teardown-connectors", "", "")
+	assert.Assert(t, err)
+	createSiteForTeardown(t, cli)
+
+	assert.Assert(t, cli.removeAllConnectors())
+	configmap, err := kube.GetConfigMap("skupper-internal", cli.Namespace, cli.KubeClient)
+	assert.Assert(t, err)
+	current, err := qdr.GetRouterConfigFromConfigMap(configmap)
+	assert.Assert(t, err)
+	assert.Equal(t, len(current.Connectors), 0)
+	_, ok := current.SslProfiles["link1-profile"]
+	assert.Assert(t, !ok)
+}
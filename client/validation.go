@@ -0,0 +1,197 @@
+package client
+
+import (
+	jsonencoding "encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+// siteConfigBooleans are the skupper-site keys that take true or false
+var siteConfigBooleans = []string{
+	"edge",
+	"service-controller",
+	"service-sync",
+	"console",
+	"router-console",
+	"cluster-local",
+	"keep-ca-on-delete",
+}
+
+func siteConfigKeys() map[string]bool {
+	keys := map[string]bool{
+		"name":                      true,
+		"console-authentication":    true,
+		"console-user":              true,
+		"console-password":          true,
+		"ingress":                   true,
+		"ingress-host":              true,
+		"ingress-inter-router-port": true,
+		"ingress-edge-port":         true,
+		"routers":                   true,
+		ImagePullSecretsKey:         true,
+	}
+	for _, key := range siteConfigBooleans {
+		keys[key] = true
+	}
+	for _, prefix := range []string{RouterPodSettingsPrefix, ControllerPodSettingsPrefix} {
+		for _, key := range PodSettingsKeys(prefix) {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// ValidateSiteConfig checks the data of a skupper-site config map,
+// rejecting unrecognised keys and values that SiteConfigInspect would
+// otherwise ignore
+func ValidateSiteConfig(data map[string]string) error {
+	known := siteConfigKeys()
+	unknown := []string{}
+	for key := range data {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("Unrecognised keys in skupper-site: %s", strings.Join(unknown, ", "))
+	}
+	for _, key := range siteConfigBooleans {
+		if value, ok := data[key]; ok {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("Invalid value for %s: %q; expected true or false", key, value)
+			}
+		}
+	}
+	if mode, ok := data["console-authentication"]; ok {
+		switch types.ConsoleAuthMode(mode) {
+		case types.ConsoleAuthModeOpenshift, types.ConsoleAuthModeInternal, types.ConsoleAuthModeUnsecured:
+		default:
+			return fmt.Errorf("Invalid value for console-authentication: %q; choose '%s', '%s' or '%s'", mode, types.ConsoleAuthModeOpenshift, types.ConsoleAuthModeInternal, types.ConsoleAuthModeUnsecured)
+		}
+	}
+	if ingress, ok := data["ingress"]; ok && !isValidIngress(ingress) {
+		return fmt.Errorf("Invalid value for ingress: %q; choose one of %s", ingress, strings.Join(types.ValidIngressOptions(), ", "))
+	}
+	if host, ok := data["ingress-host"]; ok && host != "" && !isValidHost(host) {
+		return fmt.Errorf("Invalid value for ingress-host: %q", host)
+	}
+	for _, key := range []string{"ingress-inter-router-port", "ingress-edge-port"} {
+		if value, ok := data[key]; ok {
+			if port, err := strconv.Atoi(value); err != nil || port < 1 || 65535 < port {
+				return fmt.Errorf("Invalid value for %s: %q; expected a port number", key, value)
+			}
+		}
+	}
+	if routers, ok := data["routers"]; ok {
+		if replicas, err := strconv.Atoi(routers); err != nil || replicas < 1 {
+			return fmt.Errorf("Invalid value for routers: %q", routers)
+		} else if replicas > 1 && data["edge"] != "true" && data["service-controller"] == "false" {
+			return fmt.Errorf("The service-controller is required to link multiple routers")
+		}
+	}
+	for _, prefix := range []string{RouterPodSettingsPrefix, ControllerPodSettingsPrefix} {
+		if _, err := ParsePodSettings(prefix, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isValidIngress(ingress string) bool {
+	for _, option := range types.ValidIngressOptions() {
+		if ingress == option {
+			return true
+		}
+	}
+	return false
+}
+
+func validateAddress(address string) error {
+	if errs := validation.IsDNS1035Label(address); len(errs) > 0 {
+		return fmt.Errorf("Invalid address %q: %s", address, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateServiceInterface applies the checks made when a service is
+// created or updated through the client
+func ValidateServiceInterface(service *types.ServiceInterface) error {
+	if err := validateAddress(service.Address); err != nil {
+		return err
+	}
+	return validateServiceInterface(service)
+}
+
+// ValidateServiceAnnotations checks the annotations through which a
+// deployment, statefulset or service is exposed
+func ValidateServiceAnnotations(annotations map[string]string) error {
+	protocol, ok := annotations[types.ProxyQualifier]
+	if !ok {
+		return nil
+	}
+	service := types.ServiceInterface{
+		Protocol:     protocol,
+		Distribution: annotations[types.DistributionQualifier],
+	}
+	if protocol == "" {
+		return fmt.Errorf("Invalid %s annotation; a protocol must be specified", types.ProxyQualifier)
+	}
+	if address, ok := annotations[types.AddressQualifier]; ok {
+		if err := validateAddress(address); err != nil {
+			return fmt.Errorf("Invalid %s annotation: %s", types.AddressQualifier, err)
+		}
+		service.Address = address
+	}
+	if value, ok := annotations[types.PortQualifier]; ok {
+		ports, err := ParseServicePorts([]string{value})
+		if err != nil {
+			return fmt.Errorf("Invalid %s annotation: %s", types.PortQualifier, err)
+		}
+		if len(ports) == 0 {
+			return fmt.Errorf("Invalid %s annotation; no port specified", types.PortQualifier)
+		}
+		if len(ports) == 1 && ports[0].Name == "" {
+			service.Port = ports[0].Port
+		} else {
+			service.Ports = ports
+		}
+	}
+	return validateServiceInterface(&service)
+}
+
+// ValidateServiceDefinitions checks the entries of the skupper-services
+// config map, each of which must be a service definition keyed by its
+// address
+func ValidateServiceDefinitions(data map[string]string) error {
+	return ValidateServiceDefinitionChanges(nil, data)
+}
+
+// ValidateServiceDefinitionChanges checks only those entries of the
+// skupper-services config map, or one of its shards, that are added or
+// changed from the previous version, so that definitions accepted under
+// earlier rules do not block unrelated updates
+func ValidateServiceDefinitionChanges(old map[string]string, data map[string]string) error {
+	for key, value := range data {
+		if previous, ok := old[key]; ok && previous == value {
+			continue
+		}
+		service := types.ServiceInterface{}
+		if err := jsonencoding.Unmarshal([]byte(value), &service); err != nil {
+			return fmt.Errorf("Invalid service definition for %s: %s", key, err)
+		}
+		if service.Address != key {
+			return fmt.Errorf("Invalid service definition for %s: address is %q", key, service.Address)
+		}
+		if err := ValidateServiceInterface(&service); err != nil {
+			return fmt.Errorf("Invalid service definition for %s: %s", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+func TestValidateSiteConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  map[string]string
+		error string
+	}{
+		{
+			name: "valid",
+			data: map[string]string{
+				"name":                   "west",
+				"edge":                   "false",
+				"console":                "true",
+				"console-authentication": "internal",
+				"ingress":                "route",
+				"ingress-edge-port":      "8443",
+				"routers":                "2",
+				"router-cpu":             "500m",
+				"controller-memory":      "128Mi",
+				ImagePullSecretsKey:      "my-registry",
+			},
+		},
+		{
+			name:  "unknown-key",
+			data:  map[string]string{"servce-sync": "false", "edge": "true"},
+			error: "Unrecognised keys in skupper-site: servce-sync",
+		},
+		{
+			name:  "bad-boolean",
+			data:  map[string]string{"edge": "yes please"},
+			error: `Invalid value for edge: "yes please"; expected true or false`,
+		},
+		{
+			name:  "bad-auth-mode",
+			data:  map[string]string{"console-authentication": "ldap"},
+			error: `Invalid value for console-authentication: "ldap"; choose 'openshift', 'internal' or 'unsecured'`,
+		},
+		{
+			name:  "bad-ingress",
+			data:  map[string]string{"ingress": "ingress"},
+			error: `Invalid value for ingress: "ingress"; choose one of route, loadbalancer, nodeport, nginx-ingress, none`,
+		},
+		{
+			name:  "bad-port",
+			data:  map[string]string{"ingress-inter-router-port": "70000"},
+			error: `Invalid value for ingress-inter-router-port: "70000"; expected a port number`,
+		},
+		{
+			name:  "bad-routers",
+			data:  map[string]string{"routers": "0"},
+			error: `Invalid value for routers: "0"`,
+		},
+		{
+			name:  "routers-without-controller",
+			data:  map[string]string{"routers": "2", "service-controller": "false"},
+			error: "The service-controller is required to link multiple routers",
+		},
+		{
+			name: "edge-routers-without-controller",
+			data: map[string]string{"routers": "2", "service-controller": "false", "edge": "true"},
+		},
+		{
+			name:  "bad-pod-settings",
+			data:  map[string]string{"router-cpu": "lots"},
+			error: "router-cpu",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateSiteConfig(test.data)
+			if test.error == "" {
+				assert.Assert(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.error)
+			}
+		})
+	}
+}
+
+func TestValidateServiceAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		error       string
+	}{
+		{
+			name:        "not-exposed",
+			annotations: map[string]string{"app": "backend"},
+		},
+		{
+			name: "valid",
+			annotations: map[string]string{
+				types.ProxyQualifier:   "http",
+				types.AddressQualifier: "backend",
+				types.PortQualifier:    "8080",
+			},
+		},
+		{
+			name: "valid-named-ports",
+			annotations: map[string]string{
+				types.ProxyQualifier: "tcp",
+				types.PortQualifier:  "data:5432,admin:8080",
+			},
+		},
+		{
+			name:        "bad-protocol",
+			annotations: map[string]string{types.ProxyQualifier: "udp"},
+			error:       "udp is not a valid mapping",
+		},
+		{
+			name: "bad-address",
+			annotations: map[string]string{
+				types.ProxyQualifier:   "tcp",
+				types.AddressQualifier: "Backend.Service",
+			},
+			error: "Invalid skupper.io/address annotation",
+		},
+		{
+			name: "bad-port",
+			annotations: map[string]string{
+				types.ProxyQualifier: "tcp",
+				types.PortQualifier:  "eighty",
+			},
+			error: "Invalid skupper.io/port annotation",
+		},
+		{
+			name: "bad-distribution",
+			annotations: map[string]string{
+				types.ProxyQualifier:        "tcp",
+				types.DistributionQualifier: "random",
+			},
+			error: "random is not a valid distribution",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateServiceAnnotations(test.annotations)
+			if test.error == "" {
+				assert.Assert(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.error)
+			}
+		})
+	}
+}
+
+func TestValidateServiceDefinitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  map[string]string
+		error string
+	}{
+		{
+			name: "valid",
+			data: map[string]string{
+				"backend": `{"address": "backend", "protocol": "tcp", "port": 8080, "targets": [{"name": "backend", "selector": "app=backend"}]}`,
+			},
+		},
+		{
+			name:  "malformed",
+			data:  map[string]string{"backend": `{"address": "backend",`},
+			error: "Invalid service definition for backend",
+		},
+		{
+			name:  "wrong-key",
+			data:  map[string]string{"backend": `{"address": "frontend", "protocol": "tcp", "port": 8080}`},
+			error: `Invalid service definition for backend: address is "frontend"`,
+		},
+		{
+			name:  "invalid",
+			data:  map[string]string{"backend": `{"address": "backend", "protocol": "tcp", "port": 80000}`},
+			error: "Port 80000 is outside valid range.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateServiceDefinitions(test.data)
+			if test.error == "" {
+				assert.Assert(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.error)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
 	"github.com/skupperproject/skupper/pkg/qdr"
 )
 
@@ -26,33 +29,60 @@ func getBridgeName(address string, host string) string {
 }
 
 type EgressBindings struct {
-	name       string
-	selector   string
-	service    string
-	egressPort int
-	informer   cache.SharedIndexInformer
-	stopper    chan struct{}
+	name        string
+	selector    string
+	service     string
+	host        string
+	resolver    *HostResolver
+	egressPorts map[string]int
+	weight      int
+	priority    int
+	informer    cache.SharedIndexInformer
+	stopper     chan struct{}
+}
+
+// PortBindings describes a (possibly named) port of a service: the
+// port exposed on the kubernetes service and the port on which the
+// router listens for it
+type PortBindings struct {
+	name        string
+	publicPort  int
+	ingressPort int
 }
 
 type ServiceBindings struct {
 	origin       string
 	protocol     string
 	address      string
-	publicPort   int
-	ingressPort  int
+	ports        []PortBindings
 	aggregation  string
 	eventChannel bool
+	distribution string
+	weight       int
+	priority     int
 	headless     *types.Headless
+	tls          *types.ServiceTls
+	labels       map[string]string
+	external     *types.ServiceExternal
+	annotations  map[string]string
 	targets      map[string]*EgressBindings
 }
 
 func asServiceInterface(bindings *ServiceBindings) types.ServiceInterface {
+	var ports []types.ServicePort
+	if bindings.hasNamedPorts() {
+		ports = bindings.servicePorts()
+	}
 	return types.ServiceInterface{
 		Address:      bindings.address,
 		Protocol:     bindings.protocol,
-		Port:         bindings.publicPort,
+		Port:         bindings.publicPort(),
+		Ports:        ports,
 		Aggregate:    bindings.aggregation,
 		EventChannel: bindings.eventChannel,
+		Distribution: bindings.distribution,
+		Weight:       bindings.weight,
+		Priority:     bindings.priority,
 		Headless:     bindings.headless,
 		Origin:       bindings.origin,
 	}
@@ -70,12 +100,100 @@ func newServiceController() *ServiceController {
 	}
 }
 
-func getTargetPort(service types.ServiceInterface, target types.ServiceInterfaceTarget) int {
-	targetPort := target.TargetPort
-	if targetPort == 0 {
-		targetPort = service.Port
+func getTargetPorts(service types.ServiceInterface, target types.ServiceInterfaceTarget) map[string]int {
+	targetPorts := map[string]int{}
+	for _, port := range service.GetPorts() {
+		targetPorts[port.Name] = service.GetTargetPort(&target, port)
+	}
+	return targetPorts
+}
+
+func (sb *ServiceBindings) hasNamedPorts() bool {
+	return len(sb.ports) > 1 || (len(sb.ports) == 1 && sb.ports[0].name != "")
+}
+
+// publicPort returns the first (and for most services only) port
+func (sb *ServiceBindings) publicPort() int {
+	if len(sb.ports) > 0 {
+		return sb.ports[0].publicPort
+	}
+	return 0
+}
+
+func (sb *ServiceBindings) ingressPort() int {
+	if len(sb.ports) > 0 {
+		return sb.ports[0].ingressPort
+	}
+	return 0
+}
+
+func (sb *ServiceBindings) servicePorts() []types.ServicePort {
+	ports := []types.ServicePort{}
+	for _, p := range sb.ports {
+		ports = append(ports, types.ServicePort{Name: p.name, Port: p.publicPort})
+	}
+	return ports
+}
+
+func (sb *ServiceBindings) ingressPorts() map[string]int {
+	ports := map[string]int{}
+	for _, p := range sb.ports {
+		ports[p.name] = p.ingressPort
+	}
+	return ports
+}
+
+// updatePortBindings ensures there is an ingress port for each port
+// of the required service, retaining existing allocations and
+// releasing those no longer needed
+func (c *Controller) updatePortBindings(sb *ServiceBindings, required types.ServiceInterface, portAllocations map[string]int) error {
+	existing := map[string]PortBindings{}
+	for _, p := range sb.ports {
+		existing[p.name] = p
+	}
+	ports := []PortBindings{}
+	for _, sp := range required.GetPorts() {
+		pb := PortBindings{
+			name:       sp.Name,
+			publicPort: sp.Port,
+		}
+		if required.Headless != nil {
+			//headless services use distinct proxy pods, so don't need to allocate a port
+			pb.ingressPort = sp.Port
+		} else if current, ok := existing[sp.Name]; ok && sb.headless == nil {
+			pb.ingressPort = current.ingressPort
+			delete(existing, sp.Name)
+		} else {
+			if portAllocations != nil {
+				//existing bridge configuration is used on initiaising map to recover
+				//any previous port allocations
+				pb.ingressPort = portAllocations[types.GetAddressForPort(required.Address, sp.Name)]
+			}
+			if pb.ingressPort == 0 {
+				port, err := c.ports.nextFreePort()
+				if err != nil {
+					return err
+				}
+				pb.ingressPort = port
+			}
+		}
+		ports = append(ports, pb)
+	}
+	if sb.headless == nil {
+		for _, p := range existing {
+			c.ports.release(p.ingressPort)
+		}
+	}
+	sb.ports = ports
+	return nil
+}
+
+func (c *Controller) releasePorts(sb *ServiceBindings) {
+	if sb.headless == nil {
+		for _, p := range sb.ports {
+			c.ports.release(p.ingressPort)
+		}
 	}
-	return targetPort
 }
 
 func hasTargetForSelector(si types.ServiceInterface, selector string) bool {
@@ -96,34 +214,39 @@ func hasTargetForService(si types.ServiceInterface, service string) bool {
 	return false
 }
 
+func hasTargetForHost(si types.ServiceInterface, host string) bool {
+	for _, t := range si.Targets {
+		if t.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Controller) updateServiceBindings(required types.ServiceInterface, portAllocations map[string]int) error {
 	bindings := c.bindings[required.Address]
 	if bindings == nil {
 		//create it
-		var port int
-		//headless services use distinct proxy pods, so don't need to allocate a port
-		if required.Headless != nil {
-			port = required.Port
-		} else {
-			if portAllocations != nil {
-				//existing bridge configuration is used on initiaising map to recover
-				//any previous port allocations
-				port = portAllocations[required.Address]
-			}
-			if port == 0 {
-				var err error
-				port, err = c.ports.nextFreePort()
-				if err != nil {
-					return err
-				}
-			}
+		sb := newServiceBindings(required.Origin, required.Protocol, required.Address, nil, required.Headless, required.Aggregate, required.EventChannel, required.Distribution)
+		if err := c.updatePortBindings(sb, required, portAllocations); err != nil {
+			return err
 		}
-		sb := newServiceBindings(required.Origin, required.Protocol, required.Address, required.Port, required.Headless, port, required.Aggregate, required.EventChannel)
+		sb.weight = required.Weight
+		sb.priority = required.Priority
+		sb.tls = required.Tls
+		sb.labels = required.Labels
+		sb.external = required.External
+		sb.annotations = required.Annotations
 		for _, t := range required.Targets {
 			if t.Selector != "" {
-				sb.addSelectorTarget(t.Name, t.Selector, getTargetPort(required, t), c)
+				sb.addSelectorTarget(t.Name, t.Selector, getTargetPorts(required, t), c)
+				sb.targets[t.Selector].setPreference(t.Weight, t.Priority)
 			} else if t.Service != "" {
-				sb.addServiceTarget(t.Name, t.Service, getTargetPort(required, t), c)
+				sb.addServiceTarget(t.Name, t.Service, getTargetPorts(required, t), c)
+				sb.targets[t.Service].setPreference(t.Weight, t.Priority)
+			} else if t.Host != "" {
+				sb.addHostTarget(t.Name, t.Host, getResolveInterval(t), getTargetPorts(required, t), c)
+				sb.targets[t.Host].setPreference(t.Weight, t.Priority)
 			}
 		}
 		c.bindings[required.Address] = sb
@@ -132,8 +255,8 @@ func (c *Controller) updateServiceBindings(required types.ServiceInterface, port
 		if bindings.protocol != required.Protocol {
 			bindings.protocol = required.Protocol
 		}
-		if bindings.publicPort != required.Port {
-			bindings.publicPort = required.Port
+		if err := c.updatePortBindings(bindings, required, nil); err != nil {
+			return err
 		}
 		if bindings.aggregation != required.Aggregate {
 			bindings.aggregation = required.Aggregate
@@ -141,6 +264,27 @@ func (c *Controller) updateServiceBindings(required types.ServiceInterface, port
 		if bindings.eventChannel != required.EventChannel {
 			bindings.eventChannel = required.EventChannel
 		}
+		if bindings.distribution != required.Distribution {
+			bindings.distribution = required.Distribution
+		}
+		if bindings.weight != required.Weight {
+			bindings.weight = required.Weight
+		}
+		if bindings.priority != required.Priority {
+			bindings.priority = required.Priority
+		}
+		if !reflect.DeepEqual(bindings.tls, required.Tls) {
+			bindings.tls = required.Tls
+		}
+		if !reflect.DeepEqual(bindings.external, required.External) {
+			bindings.external = required.External
+		}
+		if !reflect.DeepEqual(bindings.labels, required.Labels) {
+			bindings.labels = required.Labels
+		}
+		if !reflect.DeepEqual(bindings.annotations, required.Annotations) {
+			bindings.annotations = required.Annotations
+		}
 		if required.Headless != nil {
 			if bindings.headless == nil {
 				bindings.headless = required.Headless
@@ -157,24 +301,42 @@ func (c *Controller) updateServiceBindings(required types.ServiceInterface, port
 
 		hasSkupperSelector := false
 		for _, t := range required.Targets {
-			targetPort := getTargetPort(required, t)
+			targetPorts := getTargetPorts(required, t)
 			if strings.Contains(t.Selector, "skupper.io/component=router") {
 				hasSkupperSelector = true
 			}
 			if t.Selector != "" {
 				target := bindings.targets[t.Selector]
 				if target == nil {
-					bindings.addSelectorTarget(t.Name, t.Selector, targetPort, c)
-				} else if target.egressPort != targetPort {
-					target.egressPort = targetPort
+					bindings.addSelectorTarget(t.Name, t.Selector, targetPorts, c)
+					target = bindings.targets[t.Selector]
+				} else if !reflect.DeepEqual(target.egressPorts, targetPorts) {
+					target.egressPorts = targetPorts
 				}
+				target.setPreference(t.Weight, t.Priority)
 			} else if t.Service != "" {
 				target := bindings.targets[t.Service]
 				if target == nil {
-					bindings.addServiceTarget(t.Name, t.Service, targetPort, c)
-				} else if target.egressPort != targetPort {
-					target.egressPort = targetPort
+					bindings.addServiceTarget(t.Name, t.Service, targetPorts, c)
+					target = bindings.targets[t.Service]
+				} else if !reflect.DeepEqual(target.egressPorts, targetPorts) {
+					target.egressPorts = targetPorts
+				}
+				target.setPreference(t.Weight, t.Priority)
+			} else if t.Host != "" {
+				interval := getResolveInterval(t)
+				target := bindings.targets[t.Host]
+				if target != nil && target.resolveInterval() != interval {
+					bindings.removeHostTarget(t.Host)
+					target = nil
+				}
+				if target == nil {
+					bindings.addHostTarget(t.Name, t.Host, interval, targetPorts, c)
+					target = bindings.targets[t.Host]
+				} else if !reflect.DeepEqual(target.egressPorts, targetPorts) {
+					target.egressPorts = targetPorts
 				}
+				target.setPreference(t.Weight, t.Priority)
 			}
 		}
 		for k, v := range bindings.targets {
@@ -186,31 +348,35 @@ func (c *Controller) updateServiceBindings(required types.ServiceInterface, port
 				if !hasTargetForService(required, k) {
 					bindings.removeServiceTarget(k)
 				}
+			} else if v.host != "" {
+				if !hasTargetForHost(required, k) {
+					bindings.removeHostTarget(k)
+				}
 			}
 		}
 	}
 	return nil
 }
 
-func newServiceBindings(origin string, protocol string, address string, publicPort int, headless *types.Headless, ingressPort int, aggregation string, eventChannel bool) *ServiceBindings {
+func newServiceBindings(origin string, protocol string, address string, ports []PortBindings, headless *types.Headless, aggregation string, eventChannel bool, distribution string) *ServiceBindings {
 	return &ServiceBindings{
 		origin:       origin,
 		protocol:     protocol,
 		address:      address,
-		publicPort:   publicPort,
-		ingressPort:  ingressPort,
+		ports:        ports,
 		aggregation:  aggregation,
 		eventChannel: eventChannel,
+		distribution: distribution,
 		headless:     headless,
 		targets:      map[string]*EgressBindings{},
 	}
 }
 
-func (sb *ServiceBindings) addSelectorTarget(name string, selector string, port int, controller *Controller) error {
+func (sb *ServiceBindings) addSelectorTarget(name string, selector string, ports map[string]int, controller *Controller) error {
 	sb.targets[selector] = &EgressBindings{
-		name:       name,
-		selector:   selector,
-		egressPort: port,
+		name:        name,
+		selector:    selector,
+		egressPorts: ports,
 		informer: corev1informer.NewFilteredPodInformer(
 			controller.vanClient.KubeClient,
 			controller.vanClient.Namespace,
@@ -230,12 +396,12 @@ func (sb *ServiceBindings) removeSelectorTarget(selector string) {
 	delete(sb.targets, selector)
 }
 
-func (sb *ServiceBindings) addServiceTarget(name string, service string, port int, controller *Controller) error {
+func (sb *ServiceBindings) addServiceTarget(name string, service string, ports map[string]int, controller *Controller) error {
 	sb.targets[service] = &EgressBindings{
-		name:       name,
-		service:    service,
-		egressPort: port,
-		stopper:    make(chan struct{}),
+		name:        name,
+		service:     service,
+		egressPorts: ports,
+		stopper:     make(chan struct{}),
 	}
 	return nil
 }
@@ -244,6 +410,45 @@ func (sb *ServiceBindings) removeServiceTarget(service string) {
 	delete(sb.targets, service)
 }
 
+// getResolveInterval returns the period at which a host target is
+// re-resolved, or zero if the name is left to the router to resolve
+func getResolveInterval(target types.ServiceInterfaceTarget) time.Duration {
+	if target.ResolveInterval == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(target.ResolveInterval)
+	if err != nil {
+		log.Printf("Ignoring invalid resolve interval %q for %s: %s", target.ResolveInterval, target.Name, err)
+		return 0
+	}
+	return interval
+}
+
+func (sb *ServiceBindings) addHostTarget(name string, host string, interval time.Duration, ports map[string]int, controller *Controller) error {
+	eb := &EgressBindings{
+		name:        name,
+		host:        host,
+		egressPorts: ports,
+		stopper:     make(chan struct{}),
+	}
+	if interval > 0 && net.ParseIP(host) == nil {
+		key := "targethost@" + sb.address
+		eb.resolver = newHostResolver(host, interval, func() {
+			if controller != nil {
+				controller.events.Add(key)
+			}
+		})
+		eb.resolver.start(eb.stopper)
+	}
+	sb.targets[host] = eb
+	return nil
+}
+
+func (sb *ServiceBindings) removeHostTarget(host string) {
+	sb.targets[host].stop()
+	delete(sb.targets, host)
+}
+
 func (sb *ServiceBindings) stop() {
 	for _, v := range sb.targets {
 		if v != nil {
@@ -252,15 +457,94 @@ func (sb *ServiceBindings) stop() {
 	}
 }
 
-func (sb *ServiceBindings) updateBridgeConfiguration(siteId string, bridges *qdr.BridgeConfig) {
+// activePriority returns the lowest priority amongst the targets that
+// currently have an available endpoint; false is returned if no target
+// is available, in which case all targets are configured
+func (sb *ServiceBindings) activePriority() (int, bool) {
+	priority := 0
+	found := false
+	for _, eb := range sb.targets {
+		if eb.available() && (!found || eb.priority < priority) {
+			priority = eb.priority
+			found = true
+		}
+	}
+	return priority, found
+}
+
+// targetsAvailable returns true if the service has no local targets
+// or at least one of them has an available endpoint
+func (sb *ServiceBindings) targetsAvailable() bool {
+	if len(sb.targets) == 0 {
+		return true
+	}
+	_, found := sb.activePriority()
+	return found
+}
+
+func (sb *ServiceBindings) updateBridgeConfiguration(siteId string, standby bool, bridges *qdr.BridgeConfig) {
 	if sb.headless == nil {
 		addIngressBridge(sb, siteId, bridges)
-		for _, eb := range sb.targets {
-			eb.updateBridgeConfiguration(sb.protocol, sb.address, siteId, bridges)
+		if standby {
+			// a site with a preferred priority is providing the
+			// service, so local targets are held in reserve
+			return
+		}
+		priority, found := sb.activePriority()
+		active := map[string]*EgressBindings{}
+		for key, eb := range sb.targets {
+			if !found || eb.priority == priority {
+				active[key] = eb
+			}
+		}
+		weights := targetWeights(sb.weight, active)
+		for key, eb := range active {
+			eb.updateBridgeConfiguration(sb.protocol, sb.address, siteId, weights[key], sb.egressTls(), bridges)
 		}
 	} // headless proxies are not specified through the main bridge configuration
 }
 
+func effectiveWeight(weight int) int {
+	if weight < 1 {
+		return 1
+	}
+	return weight
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// targetWeights returns the number of connectors to configure for each
+// endpoint of the given targets. Their weights only matter relative to
+// one another, so are reduced by their greatest common divisor before
+// being multiplied by the weight of the service. As weights are
+// realised by duplicating connectors, the results are then scaled so
+// that none exceeds MaxServiceWeight.
+func targetWeights(serviceWeight int, targets map[string]*EgressBindings) map[string]int {
+	divisor := 0
+	for _, eb := range targets {
+		divisor = gcd(effectiveWeight(eb.weight), divisor)
+	}
+	weights := map[string]int{}
+	highest := 0
+	for key, eb := range targets {
+		weights[key] = effectiveWeight(serviceWeight) * effectiveWeight(eb.weight) / divisor
+		if weights[key] > highest {
+			highest = weights[key]
+		}
+	}
+	if highest > types.MaxServiceWeight {
+		for key, weight := range weights {
+			weights[key] = effectiveWeight(weight * types.MaxServiceWeight / highest)
+		}
+	}
+	return weights
+}
+
 func (eb *EgressBindings) start() error {
 	go eb.informer.Run(eb.stopper)
 	if ok := cache.WaitForCacheSync(eb.stopper, eb.informer.HasSynced); !ok {
@@ -273,16 +557,78 @@ func (eb *EgressBindings) stop() {
 	close(eb.stopper)
 }
 
-func (eb *EgressBindings) updateBridgeConfiguration(protocol string, address string, siteId string, bridges *qdr.BridgeConfig) {
+func (eb *EgressBindings) setPreference(weight int, priority int) {
+	eb.weight = weight
+	eb.priority = priority
+}
+
+func (eb *EgressBindings) available() bool {
 	if eb.selector != "" {
 		pods := eb.informer.GetStore().List()
 		for _, p := range pods {
 			pod := p.(*corev1.Pod)
-			log.Printf("Adding pod for %s: %s", address, pod.ObjectMeta.Name)
-			addEgressBridge(protocol, pod.Status.PodIP, eb.egressPort, address, eb.name, siteId, "", bridges)
+			if pod.Status.PodIP != "" && kube.IsPodReady(pod) {
+				return true
+			}
+		}
+		return false
+	}
+	if eb.host != "" {
+		return len(eb.hostAddresses()) > 0
+	}
+	return eb.service != ""
+}
+
+func (eb *EgressBindings) resolveInterval() time.Duration {
+	if eb.resolver == nil {
+		return 0
+	}
+	return eb.resolver.interval
+}
+
+// hostAddresses returns the addresses to which connectors for a host
+// target are configured: either the most recently resolved addresses
+// or, where it is not re-resolved here, the host itself
+func (eb *EgressBindings) hostAddresses() []string {
+	if eb.resolver == nil {
+		return []string{eb.host}
+	}
+	return eb.resolver.getAddresses()
+}
+
+// getWeightedTargetName distinguishes the additional connectors
+// created for a target in proportion to its weight
+func getWeightedTargetName(target string, index int) string {
+	if index == 0 {
+		return target
+	}
+	return target + "#" + strconv.Itoa(index)
+}
+
+func (eb *EgressBindings) updateBridgeConfiguration(protocol string, address string, siteId string, weight int, tls *types.ServiceTls, bridges *qdr.BridgeConfig) {
+	for portName, egressPort := range eb.egressPorts {
+		portAddress := types.GetAddressForPort(address, portName)
+		targetName := types.GetAddressForPort(eb.name, portName)
+		if eb.selector != "" {
+			pods := eb.informer.GetStore().List()
+			for _, p := range pods {
+				pod := p.(*corev1.Pod)
+				log.Printf("Adding pod for %s: %s", portAddress, pod.ObjectMeta.Name)
+				for i := 0; i < weight; i++ {
+					addEgressBridge(protocol, pod.Status.PodIP, egressPort, portAddress, getWeightedTargetName(targetName, i), siteId, "", tls, bridges)
+				}
+			}
+		} else if eb.service != "" {
+			for i := 0; i < weight; i++ {
+				addEgressBridge(protocol, eb.service, egressPort, portAddress, getWeightedTargetName(targetName, i), siteId, eb.service, tls, bridges)
+			}
+		} else if eb.host != "" {
+			for _, host := range eb.hostAddresses() {
+				for i := 0; i < weight; i++ {
+					addEgressBridge(protocol, host, egressPort, portAddress, getWeightedTargetName(targetName, i), siteId, eb.host, tls, bridges)
+				}
+			}
 		}
-	} else if eb.service != "" {
-		addEgressBridge(protocol, eb.service, eb.egressPort, address, eb.name, siteId, eb.service, bridges)
 	}
 }
 
@@ -297,18 +643,45 @@ const (
 	ProtocolHTTP2 string = "http2"
 )
 
-func addEgressBridge(protocol string, host string, port int, address string, target string, siteId string, hostOverride string, bridges *qdr.BridgeConfig) (bool, error) {
+// egressTls returns the TLS configuration for connectors to the
+// targets of the service, or nil if they do not use TLS
+func (sb *ServiceBindings) egressTls() *types.ServiceTls {
+	if sb.originatesTls() {
+		return sb.tls
+	}
+	return nil
+}
+
+func getConnectorTls(tls *types.ServiceTls) (string, *bool) {
+	if tls == nil {
+		return "", nil
+	}
+	verifyHostname := tls.VerifyHostname
+	return getTrustProfile(tls).Name, &verifyHostname
+}
+
+func getListenerTls(sb *ServiceBindings) string {
+	if sb.terminatesTls() {
+		return types.GetServiceTlsProfile(sb.address)
+	}
+	return ""
+}
+
+func addEgressBridge(protocol string, host string, port int, address string, target string, siteId string, hostOverride string, tls *types.ServiceTls, bridges *qdr.BridgeConfig) (bool, error) {
 	if host == "" {
 		return false, fmt.Errorf("Cannot add connector without host (%s %s)", address, protocol)
 	}
+	sslProfile, verifyHostname := getConnectorTls(tls)
 	switch protocol {
 	case ProtocolHTTP:
 		b := qdr.HttpEndpoint{
-			Name:    getBridgeName(target, host),
-			Host:    host,
-			Port:    strconv.Itoa(port),
-			Address: address,
-			SiteId:  siteId,
+			Name:           getBridgeName(target, host),
+			Host:           host,
+			Port:           strconv.Itoa(port),
+			Address:        address,
+			SiteId:         siteId,
+			SslProfile:     sslProfile,
+			VerifyHostname: verifyHostname,
 		}
 		if hostOverride != "" {
 			b.HostOverride = hostOverride
@@ -322,14 +695,18 @@ func addEgressBridge(protocol string, host string, port int, address string, tar
 			Address:         address,
 			SiteId:          siteId,
 			ProtocolVersion: qdr.HttpVersion2,
+			SslProfile:      sslProfile,
+			VerifyHostname:  verifyHostname,
 		})
 	case ProtocolTCP:
 		bridges.AddTcpConnector(qdr.TcpEndpoint{
-			Name:    getBridgeName(target, host),
-			Host:    host,
-			Port:    strconv.Itoa(port),
-			Address: address,
-			SiteId:  siteId,
+			Name:           getBridgeName(target, host),
+			Host:           host,
+			Port:           strconv.Itoa(port),
+			Address:        address,
+			SiteId:         siteId,
+			SslProfile:     sslProfile,
+			VerifyHostname: verifyHostname,
 		})
 	default:
 		return false, fmt.Errorf("Unrecognised protocol for service %s: %s", address, protocol)
@@ -338,48 +715,105 @@ func addEgressBridge(protocol string, host string, port int, address string, tar
 }
 
 func addIngressBridge(sb *ServiceBindings, siteId string, bridges *qdr.BridgeConfig) (bool, error) {
-	switch sb.protocol {
-	case ProtocolHTTP:
-		bridges.AddHttpListener(qdr.HttpEndpoint{
-			Name:         getBridgeName(sb.address, ""),
-			Host:         "0.0.0.0",
-			Port:         strconv.Itoa(sb.ingressPort),
-			Address:      sb.address,
-			SiteId:       siteId,
-			Aggregation:  sb.aggregation,
-			EventChannel: sb.eventChannel,
-		})
-	case ProtocolHTTP2:
-		bridges.AddHttpListener(qdr.HttpEndpoint{
-			Name:            getBridgeName(sb.address, ""),
-			Host:            "0.0.0.0",
-			Port:            strconv.Itoa(sb.ingressPort),
-			Address:         sb.address,
-			SiteId:          siteId,
-			Aggregation:     sb.aggregation,
-			EventChannel:    sb.eventChannel,
-			ProtocolVersion: qdr.HttpVersion2,
-		})
-	case ProtocolTCP:
-		bridges.AddTcpListener(qdr.TcpEndpoint{
-			Name:    getBridgeName(sb.address, ""),
-			Host:    "0.0.0.0",
-			Port:    strconv.Itoa(sb.ingressPort),
-			Address: sb.address,
-			SiteId:  siteId,
-		})
-	default:
-		return false, fmt.Errorf("Unrecognised protocol for service %s: %s", sb.address, sb.protocol)
+	sslProfile := getListenerTls(sb)
+	for _, port := range sb.ports {
+		address := types.GetAddressForPort(sb.address, port.name)
+		switch sb.protocol {
+		case ProtocolHTTP:
+			bridges.AddHttpListener(qdr.HttpEndpoint{
+				Name:         getBridgeName(address, ""),
+				Host:         "0.0.0.0",
+				Port:         strconv.Itoa(port.ingressPort),
+				Address:      address,
+				SiteId:       siteId,
+				Aggregation:  sb.aggregation,
+				EventChannel: sb.eventChannel,
+				SslProfile:   sslProfile,
+			})
+		case ProtocolHTTP2:
+			bridges.AddHttpListener(qdr.HttpEndpoint{
+				Name:            getBridgeName(address, ""),
+				Host:            "0.0.0.0",
+				Port:            strconv.Itoa(port.ingressPort),
+				Address:         address,
+				SiteId:          siteId,
+				Aggregation:     sb.aggregation,
+				EventChannel:    sb.eventChannel,
+				ProtocolVersion: qdr.HttpVersion2,
+				SslProfile:      sslProfile,
+			})
+		case ProtocolTCP:
+			bridges.AddTcpListener(qdr.TcpEndpoint{
+				Name:       getBridgeName(address, ""),
+				Host:       "0.0.0.0",
+				Port:       strconv.Itoa(port.ingressPort),
+				Address:    address,
+				SiteId:     siteId,
+				SslProfile: sslProfile,
+			})
+		default:
+			return false, fmt.Errorf("Unrecognised protocol for service %s: %s", sb.address, sb.protocol)
+		}
 	}
 	return true, nil
 }
 
-func requiredBridges(services map[string]*ServiceBindings, siteId string) *qdr.BridgeConfig {
+func requiredBridges(services map[string]*ServiceBindings, siteId string, priorities *SitePriorities) *qdr.BridgeConfig {
 	//TODO: headless services not yet handled
 	//TODO: update for multicast when merged
 	bridges := newBridgeConfiguration()
 	for _, service := range services {
-		service.updateBridgeConfiguration(siteId, bridges)
+		service.updateBridgeConfiguration(siteId, priorities.isStandby(service.address, service.priority), bridges)
 	}
 	return bridges
 }
+
+// requiredBridgesFor returns the bridges required for a single
+// service, which are distinct from those of any other service
+func requiredBridgesFor(service *ServiceBindings, siteId string, priorities *SitePriorities) *qdr.BridgeConfig {
+	bridges := newBridgeConfiguration()
+	service.updateBridgeConfiguration(siteId, priorities.isStandby(service.address, service.priority), bridges)
+	return bridges
+}
+
+// updateBridgeFragment recomputes the bridges, address and ssl
+// profiles for a single address, returning true if they have changed
+func (c *Controller) updateBridgeFragment(address string) bool {
+	sb := c.bindings[address]
+	if sb == nil {
+		c.setTargetsAvailable(address, true)
+		return c.fragments.update(address, nil)
+	}
+	c.setTargetsAvailable(address, sb.targetsAvailable())
+	return c.fragments.update(address, requiredFragmentFor(sb, c.origin, c.sitePriorities))
+}
+
+// updateBridgeFragments recomputes the bridges for every address,
+// returning true if any have changed
+func (c *Controller) updateBridgeFragments() bool {
+	changed := false
+	for address := range c.fragments.byAddress {
+		if _, ok := c.bindings[address]; !ok && c.updateBridgeFragment(address) {
+			changed = true
+		}
+	}
+	for address := range c.bindings {
+		if c.updateBridgeFragment(address) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// requiredAddressFor returns the address the router must be configured
+// with for a single service, if any
+func requiredAddressFor(service *ServiceBindings) *qdr.Address {
+	if service.headless == nil && service.distribution != "" && !qdr.IsReservedPrefix(service.address) {
+		return &qdr.Address{
+			Name:         service.address,
+			Prefix:       service.address,
+			Distribution: service.distribution,
+		}
+	}
+	return nil
+}
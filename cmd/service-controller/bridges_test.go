@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+func TestRequiredBridgesWeightAndPriority(t *testing.T) {
+	sb := newServiceBindings("", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+	sb.addServiceTarget("primary", "db-primary", map[string]int{"": 5432}, nil)
+	sb.targets["db-primary"].setPreference(3, 0)
+	sb.addServiceTarget("secondary", "db-secondary", map[string]int{"": 5432}, nil)
+	sb.targets["db-secondary"].setPreference(1, 0)
+	sb.addServiceTarget("replica", "db-replica", map[string]int{"": 5432}, nil)
+	sb.targets["db-replica"].setPreference(5, 1)
+	services := map[string]*ServiceBindings{"db": sb}
+
+	bridges := requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.TcpListeners), 1)
+	assert.Equal(t, len(bridges.TcpConnectors), 4)
+	for _, name := range []string{"primary@db-primary", "primary#1@db-primary", "primary#2@db-primary", "secondary@db-secondary"} {
+		_, ok := bridges.TcpConnectors[name]
+		assert.Assert(t, ok, name)
+	}
+
+	sb.weight = 2
+	bridges = requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.TcpConnectors), 8)
+
+	// only the ratio of target weights matters
+	sb.weight = 0
+	sb.targets["db-primary"].setPreference(60, 0)
+	sb.targets["db-secondary"].setPreference(20, 0)
+	bridges = requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.TcpConnectors), 4)
+}
+
+func TestTargetWeights(t *testing.T) {
+	targets := map[string]*EgressBindings{
+		"a": &EgressBindings{weight: 100},
+		"b": &EgressBindings{weight: 99},
+		"c": &EgressBindings{weight: 0},
+	}
+	weights := targetWeights(100, targets)
+	assert.DeepEqual(t, weights, map[string]int{"a": 100, "b": 99, "c": 1})
+
+	targets["b"].weight = 50
+	weights = targetWeights(3, targets)
+	assert.DeepEqual(t, weights, map[string]int{"a": 100, "b": 50, "c": 1})
+
+	delete(targets, "c")
+	weights = targetWeights(3, targets)
+	assert.DeepEqual(t, weights, map[string]int{"a": 6, "b": 3})
+
+	weights = targetWeights(0, map[string]*EgressBindings{"a": &EgressBindings{weight: 40}})
+	assert.DeepEqual(t, weights, map[string]int{"a": 1})
+}
+
+func TestRequiredBridgesStandbySite(t *testing.T) {
+	sb := newServiceBindings("", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+	sb.priority = 2
+	sb.addServiceTarget("db", "db-local", map[string]int{"": 5432}, nil)
+	services := map[string]*ServiceBindings{"db": sb}
+	priorities := newSitePriorities()
+
+	bridges := requiredBridges(services, "site-b", priorities)
+	assert.Equal(t, len(bridges.TcpConnectors), 1)
+
+	assert.Assert(t, priorities.update("site-a", map[string]int{"db": 1}))
+	assert.Assert(t, !priorities.update("site-a", map[string]int{"db": 1}))
+	bridges = requiredBridges(services, "site-b", priorities)
+	assert.Equal(t, len(bridges.TcpListeners), 1)
+	assert.Equal(t, len(bridges.TcpConnectors), 0)
+
+	assert.Assert(t, priorities.remove("site-a"))
+	bridges = requiredBridges(services, "site-b", priorities)
+	assert.Equal(t, len(bridges.TcpConnectors), 1)
+}
+
+func TestSitePrioritiesIsStandby(t *testing.T) {
+	priorities := newSitePriorities()
+	priorities.update("site-a", map[string]int{"db": 2, "web": 1})
+	assert.Assert(t, !priorities.isStandby("db", 0))
+	assert.Assert(t, !priorities.isStandby("db", 1))
+	assert.Assert(t, !priorities.isStandby("db", 2))
+	assert.Assert(t, priorities.isStandby("db", 3))
+	assert.Assert(t, priorities.isStandby("web", 2))
+	assert.Assert(t, !priorities.isStandby("other", 5))
+}
+
+func TestPriorityWithdrawnWithoutTargets(t *testing.T) {
+	sb := newServiceBindings("", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+	sb.priority = 1
+	sb.addHostTarget("db.internal", "db.internal", 0, map[string]int{"": 5432}, nil)
+	resolved := []string{}
+	resolver := newHostResolver("db.internal", time.Minute, nil)
+	resolver.lookup = func(host string) ([]string, error) {
+		return resolved, nil
+	}
+	sb.targets["db.internal"].resolver = resolver
+	c := &Controller{
+		bindings:      map[string]*ServiceBindings{"db": sb},
+		fragments:     newRouterFragments(),
+		localServices: map[string]types.ServiceInterface{"db": {Address: "db", Priority: 1}},
+	}
+
+	// without an available target, the site does not claim priority,
+	// so that other sites stop holding theirs in reserve
+	c.updateBridgeFragment("db")
+	assert.Equal(t, c.localServiceDefinitions()[0].Priority, 0)
+
+	resolved = []string{"10.0.0.1"}
+	assert.Assert(t, resolver.resolve())
+	c.updateBridgeFragment("db")
+	assert.Equal(t, c.localServiceDefinitions()[0].Priority, 1)
+
+	delete(c.bindings, "db")
+	c.updateBridgeFragment("db")
+	assert.Equal(t, len(c.unavailable), 0)
+}
+
+func TestRequiredBridgesNamedPorts(t *testing.T) {
+	ports := []PortBindings{
+		{name: "data", publicPort: 5432, ingressPort: 1024},
+		{name: "admin", publicPort: 8080, ingressPort: 1025},
+	}
+	sb := newServiceBindings("", "tcp", "db", ports, nil, "", false, "")
+	sb.addServiceTarget("db", "db-backend", map[string]int{"data": 15432, "admin": 8080}, nil)
+	services := map[string]*ServiceBindings{"db": sb}
+
+	bridges := requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.TcpListeners), 2)
+	assert.Equal(t, bridges.TcpListeners["db.data"].Port, "1024")
+	assert.Equal(t, bridges.TcpListeners["db.data"].Address, "db.data")
+	assert.Equal(t, bridges.TcpListeners["db.admin"].Port, "1025")
+	assert.Equal(t, len(bridges.TcpConnectors), 2)
+	assert.Equal(t, bridges.TcpConnectors["db.data@db-backend"].Port, "15432")
+	assert.Equal(t, bridges.TcpConnectors["db.data@db-backend"].Address, "db.data")
+	assert.Equal(t, bridges.TcpConnectors["db.admin@db-backend"].Port, "8080")
+
+	si := asServiceInterface(sb)
+	assert.Equal(t, si.Port, 5432)
+	assert.Equal(t, len(si.Ports), 2)
+}
+
+func TestRequiredBridgesHostTarget(t *testing.T) {
+	sb := newServiceBindings("", "http", "db", []PortBindings{{publicPort: 8080, ingressPort: 1024}}, nil, "", false, "")
+	sb.addHostTarget("db.internal", "db.internal", 0, map[string]int{"": 80}, nil)
+	services := map[string]*ServiceBindings{"db": sb}
+
+	bridges := requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.HttpConnectors), 1)
+	connector, ok := bridges.HttpConnectors["db.internal@db.internal"]
+	assert.Assert(t, ok)
+	assert.Equal(t, connector.Host, "db.internal")
+	assert.Equal(t, connector.Port, "80")
+	assert.Equal(t, connector.Address, "db")
+
+	resolved := []string{"10.0.0.2", "10.0.0.1"}
+	resolver := newHostResolver("db.internal", time.Minute, nil)
+	resolver.lookup = func(host string) ([]string, error) {
+		return resolved, nil
+	}
+	sb.targets["db.internal"].resolver = resolver
+	assert.Assert(t, !sb.targets["db.internal"].available())
+	assert.Assert(t, resolver.resolve())
+	assert.Assert(t, !resolver.resolve())
+
+	bridges = requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.HttpConnectors), 2)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		connector, ok := bridges.HttpConnectors["db.internal@"+ip]
+		assert.Assert(t, ok, ip)
+		assert.Equal(t, connector.Host, ip)
+		assert.Equal(t, connector.HostOverride, "db.internal")
+	}
+
+	resolved = []string{"10.0.0.3"}
+	assert.Assert(t, resolver.resolve())
+	resolver.lookup = func(host string) ([]string, error) {
+		return nil, fmt.Errorf("lookup failed")
+	}
+	assert.Assert(t, !resolver.resolve())
+	bridges = requiredBridges(services, "site-a", nil)
+	assert.Equal(t, len(bridges.HttpConnectors), 1)
+	_, ok = bridges.HttpConnectors["db.internal@10.0.0.3"]
+	assert.Assert(t, ok)
+}
+
+func TestMergeBridges(t *testing.T) {
+	services := map[string]*ServiceBindings{}
+	for i, protocol := range []string{"tcp", "http", "http2"} {
+		address := fmt.Sprintf("svc%d", i)
+		sb := newServiceBindings("", protocol, address, []PortBindings{{publicPort: 8080, ingressPort: 1024 + i}}, nil, "", false, "")
+		sb.addServiceTarget(address, address+"-backend", map[string]int{"": 8080}, nil)
+		sb.tls = &types.ServiceTls{Originate: true}
+		services[address] = sb
+	}
+	fragments := newRouterFragments()
+	for address, sb := range services {
+		assert.Assert(t, fragments.update(address, requiredFragmentFor(sb, "site-a", nil)))
+	}
+	assert.DeepEqual(t, &fragments.bridges, requiredBridges(services, "site-a", nil))
+	assert.DeepEqual(t, fragments.profiles, requiredSslProfiles(services))
+
+	// only the entries of the changed address are replaced, and a
+	// profile shared with others is kept until none use it
+	config := qdr.InitialConfig("test-router", "{}", false)
+	changed, profilesChanged := fragments.apply(&config, true)
+	assert.Assert(t, changed && profilesChanged)
+	services["svc1"].targets["svc1-backend"].egressPorts[""] = 9090
+	assert.Assert(t, fragments.update("svc1", requiredFragmentFor(services["svc1"], "site-a", nil)))
+	assert.Assert(t, !fragments.update("svc1", requiredFragmentFor(services["svc1"], "site-a", nil)))
+	assert.Assert(t, fragments.update("svc2", nil))
+	delete(services, "svc2")
+	changed, profilesChanged = fragments.apply(&config, false)
+	assert.Assert(t, changed && !profilesChanged)
+	assert.DeepEqual(t, config.Bridges, *requiredBridges(services, "site-a", nil))
+	assert.DeepEqual(t, config.SslProfiles, requiredSslProfiles(services))
+	for address := range services {
+		assert.Assert(t, fragments.update(address, nil))
+	}
+	changed, profilesChanged = fragments.apply(&config, false)
+	assert.Assert(t, changed && profilesChanged)
+	assert.Equal(t, len(config.SslProfiles), 0)
+	changed, _ = fragments.apply(&config, false)
+	assert.Assert(t, !changed)
+}
@@ -5,36 +5,102 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/informers/internalinterfaces"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/health"
+	"github.com/skupperproject/skupper/pkg/kube"
 	"github.com/skupperproject/skupper/pkg/qdr"
 )
 
 // Syncs the live router config with the configmap (currently only
-// bridge configuration needs to be synced in this way)
+// bridge and service address configuration needs to be synced in
+// this way). Each router pod is synced over its own connection, as
+// the skupper-messaging service reaches only one of them when the
+// router has multiple replicas. Each router pod is also labelled with
+// the index of the replica it serves as, and the replicas of an
+// interior site are linked to each other.
 type ConfigSync struct {
-	informer  cache.SharedIndexInformer
-	events    workqueue.RateLimitingInterface
-	agentPool *qdr.AgentPool
+	vanClient     *client.VanClient
+	informer      cache.SharedIndexInformer
+	podInformer   cache.SharedIndexInformer
+	events        workqueue.RateLimitingInterface
+	connect       func(url string) *ConnectionManager
+	routers       map[string]*routerConnection
+	eventRecorder record.EventRecorder
+	progress      health.Progress
+
+	lock         sync.Mutex
+	lastSynced   time.Time
+	failingSince time.Time
+	lastError    string
+}
+
+// routerConnection is the connection to a single router pod
+type routerConnection struct {
+	ip          string
+	replica     int
+	connections *ConnectionManager
 }
 
-func newConfigSync(configInformer cache.SharedIndexInformer, config *tls.Config) *ConfigSync {
+// getRouterUrl returns the url for the controller's connection to a
+// router pod, which presents the certificate of the skupper-messaging
+// service
+func getRouterUrl(ip string) string {
+	return "amqps://" + net.JoinHostPort(ip, strconv.Itoa(int(types.AmqpsDefaultPort)))
+}
+
+func newConfigSync(cli *client.VanClient, configInformer cache.SharedIndexInformer, tlsConfig *tls.Config, recorder record.EventRecorder) *ConfigSync {
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = "skupper-messaging"
+	}
 	configSync := &ConfigSync{
+		vanClient: cli,
 		informer:  configInformer,
-		agentPool: qdr.NewAgentPool("amqps://skupper-messaging:5671", config),
+		podInformer: corev1informer.NewFilteredPodInformer(
+			cli.KubeClient,
+			cli.Namespace,
+			time.Second*30,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+			internalinterfaces.TweakListOptionsFunc(func(options *metav1.ListOptions) {
+				options.LabelSelector = "skupper.io/component=" + types.TransportComponentName
+			})),
+		connect: func(url string) *ConnectionManager {
+			return newConnectionManager(url, tlsConfig)
+		},
+		routers:       map[string]*routerConnection{},
+		eventRecorder: recorder,
 	}
 	configSync.events = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "skupper-config-sync")
 	configSync.informer.AddEventHandler(newEventHandlerFor(configSync.events, "", SimpleKey, ConfigMapResourceVersionTest))
+	// a router pod may start before the configmap it mounts has been
+	// updated, so each is synced as it becomes ready
+	configSync.podInformer.AddEventHandler(newEventHandlerFor(configSync.events, cli.Namespace+"/skupper-internal", FixedKey, PodResourceVersionTest))
 	return configSync
 }
 
 func (c *ConfigSync) start(stopCh <-chan struct{}) error {
+	go c.podInformer.Run(stopCh)
+	if ok := cache.WaitForCacheSync(stopCh, c.podInformer.HasSynced); !ok {
+		return fmt.Errorf("Failed to wait for router pod cache to sync")
+	}
 	go wait.Until(c.runConfigSync, time.Second, stopCh)
 
 	return nil
@@ -47,6 +113,11 @@ func (c *ConfigSync) stop() {
 func (c *ConfigSync) runConfigSync() {
 	for c.processNextEvent() {
 	}
+	// the router connections are only used from this goroutine
+	for name, router := range c.routers {
+		router.connections.Close()
+		delete(c.routers, name)
+	}
 }
 
 func (c *ConfigSync) processNextEvent() bool {
@@ -59,6 +130,8 @@ func (c *ConfigSync) processNextEvent() bool {
 
 	err := func(obj interface{}) error {
 		defer c.events.Done(obj)
+		c.progress.Start()
+		defer c.progress.Finish()
 
 		var ok bool
 		var key string
@@ -77,18 +150,26 @@ func (c *ConfigSync) processNextEvent() bool {
 				if !ok {
 					return fmt.Errorf("Expected ConfigMap for %s but got %#v", key, obj)
 				}
-				bridges, err := qdr.GetBridgeConfigFromConfigMap(configmap)
+				config, err := qdr.GetRouterConfigFromConfigMap(configmap)
 				if err != nil {
-					return fmt.Errorf("Error parsing bridge configuration from %s: %s", key, err)
+					return fmt.Errorf("Error parsing router configuration from %s: %s", key, err)
+				} else if config == nil {
+					return fmt.Errorf("Router configuration not defined in %s", key)
+				}
+				err = c.assignRouterReplicas()
+				if err == nil {
+					err = c.syncConfig(&config.Bridges, serviceAddresses(config), config.IsEdge())
 				}
-				err = c.syncConfig(bridges)
 				if err != nil {
 					log.Printf("[config_sync] Sync failed")
+					c.syncFailed(err)
+					c.recordSyncFailure(err)
 					return err
 				}
 			}
 		}
 		log.Printf("[config_sync] Sync suceeded")
+		c.synced()
 		c.events.Forget(obj)
 		return nil
 	}(obj)
@@ -107,6 +188,62 @@ func (c *ConfigSync) processNextEvent() bool {
 	return true
 }
 
+func (c *ConfigSync) synced() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastSynced = time.Now()
+	c.failingSince = time.Time{}
+	c.lastError = ""
+}
+
+func (c *ConfigSync) syncFailed(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.failingSince.IsZero() {
+		c.failingSince = time.Now()
+	}
+	c.lastError = err.Error()
+}
+
+// syncCheck fails if the router's bridge configuration has not been
+// synced for longer than limit, due to repeated failures
+func (c *ConfigSync) syncCheck(limit time.Duration) health.Check {
+	return func() error {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if !c.failingSince.IsZero() && time.Since(c.failingSince) > limit {
+			last := "never"
+			if !c.lastSynced.IsZero() {
+				last = time.Since(c.lastSynced).Round(time.Second).String() + " ago"
+			}
+			return fmt.Errorf("failing since %s (last synced %s): %s", c.failingSince.Format(time.RFC3339), last, c.lastError)
+		}
+		return nil
+	}
+}
+
+// recordSyncFailure records an event against the router deployment, as
+// the router is left with a configuration that differs from what it
+// was asked for
+func (c *ConfigSync) recordSyncFailure(err error) {
+	router, getErr := kube.GetDeployment(types.TransportDeploymentName, c.vanClient.Namespace, c.vanClient.KubeClient)
+	if getErr != nil {
+		log.Printf("[config_sync] Could not retrieve router deployment: %s", getErr)
+		return
+	}
+	c.eventRecorder.Event(router, corev1.EventTypeWarning, EventBridgeSyncFailed, err.Error())
+}
+
+func serviceAddresses(config *qdr.RouterConfig) qdr.AddressMap {
+	addresses := qdr.AddressMap{}
+	for prefix, address := range config.Addresses {
+		if !qdr.IsReservedPrefix(prefix) {
+			addresses[prefix] = address
+		}
+	}
+	return addresses
+}
+
 func syncConfig(agent *qdr.Agent, desired *qdr.BridgeConfig) (bool, error) {
 	actual, err := agent.GetLocalBridgeConfig()
 	if err != nil {
@@ -124,8 +261,184 @@ func syncConfig(agent *qdr.Agent, desired *qdr.BridgeConfig) (bool, error) {
 	}
 }
 
-func (c *ConfigSync) syncConfig(desired *qdr.BridgeConfig) error {
-	agent, err := c.agentPool.Get()
+func syncAddresses(agent *qdr.Agent, desired qdr.AddressMap) (bool, error) {
+	actual, err := agent.GetLocalAddressConfig()
+	if err != nil {
+		return false, fmt.Errorf("Error retrieving addresses: %s", err)
+	}
+	differences := actual.Difference(desired)
+	if differences.Empty() {
+		return true, nil
+	} else {
+		log.Printf("Address config changes: %d deleted, %d added", len(differences.Deleted), len(differences.Added))
+		if err = agent.UpdateLocalAddressConfig(&differences); err != nil {
+			return false, fmt.Errorf("Error syncing addresses: %s", err)
+		}
+		return false, nil
+	}
+}
+
+// getReplicaIndex returns the index of the replica the router pod has
+// been labelled as serving
+func getReplicaIndex(pod *corev1.Pod) (int, bool) {
+	value, ok := pod.ObjectMeta.Labels[types.RouterReplicaQualifier]
+	if !ok {
+		return 0, false
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// assignReplicaIndexes returns the router pods whose replica index
+// needs to change, with the index each should take. A pod keeps its
+// index if that is unique and below the number of replicas; any other
+// takes the lowest index free, which is only at or above the number of
+// replicas while a rolling update has surplus pods.
+func assignReplicaIndexes(pods []*corev1.Pod, replicas int) map[string]int {
+	sort.Slice(pods, func(i, j int) bool {
+		if !pods[i].ObjectMeta.CreationTimestamp.Equal(&pods[j].ObjectMeta.CreationTimestamp) {
+			return pods[i].ObjectMeta.CreationTimestamp.Before(&pods[j].ObjectMeta.CreationTimestamp)
+		}
+		return pods[i].ObjectMeta.Name < pods[j].ObjectMeta.Name
+	})
+	taken := map[int]bool{}
+	pending := []*corev1.Pod{}
+	for _, pod := range pods {
+		if index, ok := getReplicaIndex(pod); ok && index < replicas && !taken[index] {
+			taken[index] = true
+		} else {
+			pending = append(pending, pod)
+		}
+	}
+	changes := map[string]int{}
+	next := 0
+	for _, pod := range pending {
+		for taken[next] {
+			next++
+		}
+		taken[next] = true
+		if index, ok := getReplicaIndex(pod); !ok || index != next {
+			changes[pod.ObjectMeta.Name] = next
+		}
+	}
+	return changes
+}
+
+// assignRouterReplicas labels each router pod with the index of the
+// replica it serves as, which selects it for the services through
+// which that replica is reached
+func (c *ConfigSync) assignRouterReplicas() error {
+	pods := []*corev1.Pod{}
+	for _, obj := range c.podInformer.GetStore().List() {
+		if pod, ok := obj.(*corev1.Pod); ok && pod.ObjectMeta.DeletionTimestamp == nil {
+			pods = append(pods, pod)
+		}
+	}
+	replicas := len(pods)
+	router, err := kube.GetDeployment(types.TransportDeploymentName, c.vanClient.Namespace, c.vanClient.KubeClient)
+	if err == nil && router.Spec.Replicas != nil {
+		replicas = int(*router.Spec.Replicas)
+	}
+	changes := assignReplicaIndexes(pods, replicas)
+	for _, pod := range pods {
+		index, ok := changes[pod.ObjectMeta.Name]
+		if !ok {
+			continue
+		}
+		updated := pod.DeepCopy()
+		if updated.ObjectMeta.Labels == nil {
+			updated.ObjectMeta.Labels = map[string]string{}
+		}
+		updated.ObjectMeta.Labels[types.RouterReplicaQualifier] = strconv.Itoa(index)
+		if _, err := c.vanClient.KubeClient.CoreV1().Pods(c.vanClient.Namespace).Update(updated); err != nil {
+			return fmt.Errorf("Failed to label router pod %s as replica %d: %s", pod.ObjectMeta.Name, index, err)
+		}
+		log.Printf("Router pod %s labelled as replica %d", pod.ObjectMeta.Name, index)
+	}
+	return nil
+}
+
+// updateRouterConnections returns the connections to each ready router
+// pod, closing those to pods no longer ready or whose address changed
+func (c *ConfigSync) updateRouterConnections() map[string]*routerConnection {
+	ready := map[string]*corev1.Pod{}
+	for _, obj := range c.podInformer.GetStore().List() {
+		pod, ok := obj.(*corev1.Pod)
+		if ok && pod.Status.PodIP != "" && pod.ObjectMeta.DeletionTimestamp == nil && kube.IsPodReady(pod) {
+			ready[pod.ObjectMeta.Name] = pod
+		}
+	}
+	for name, router := range c.routers {
+		if pod, ok := ready[name]; !ok || pod.Status.PodIP != router.ip {
+			router.connections.Close()
+			delete(c.routers, name)
+		}
+	}
+	for name, pod := range ready {
+		router, ok := c.routers[name]
+		if !ok {
+			router = &routerConnection{
+				ip:          pod.Status.PodIP,
+				connections: c.connect(getRouterUrl(pod.Status.PodIP)),
+			}
+			c.routers[name] = router
+		}
+		// the label may be added, or changed, after the connection
+		// was made
+		if index, ok := getReplicaIndex(pod); ok {
+			router.replica = index
+		} else {
+			router.replica = -1
+		}
+	}
+	return c.routers
+}
+
+// getReplicaConnectors returns the connectors through which the router
+// replica links to the other replicas of its site, or nil if it is not
+// yet known which replica the router is
+func getReplicaConnectors(replica int, namespace string, edge bool) map[string]qdr.Connector {
+	if replica < 0 {
+		return nil
+	}
+	if edge {
+		// edge routers only link to interior routers
+		return map[string]qdr.Connector{}
+	}
+	return qdr.GetReplicaConnectors(replica, namespace)
+}
+
+func (c *ConfigSync) syncConfig(desired *qdr.BridgeConfig, addresses qdr.AddressMap, edge bool) error {
+	routers := c.updateRouterConnections()
+	if len(routers) == 0 {
+		// each router pod is synced as it becomes ready
+		log.Println("No router pod ready to sync bridge config to")
+		return nil
+	}
+	names := []string{}
+	for name := range routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := []string{}
+	for _, name := range names {
+		replicaConnectors := getReplicaConnectors(routers[name].replica, c.vanClient.Namespace, edge)
+		if err := syncRouter(routers[name].connections, desired, addresses, replicaConnectors); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Error while syncing bridge config to router pods: %s", strings.Join(failures, "; "))
+	}
+	log.Printf("Bridge config synced to %d router pods", len(names))
+	return nil
+}
+
+func syncRouter(connections *ConnectionManager, desired *qdr.BridgeConfig, addresses qdr.AddressMap, replicaConnectors map[string]qdr.Connector) error {
+	agent, err := connections.GetAgent()
 	if err != nil {
 		return fmt.Errorf("Could not get management agent : %s", err)
 	}
@@ -133,13 +446,24 @@ func (c *ConfigSync) syncConfig(desired *qdr.BridgeConfig) error {
 	for i := 0; i < 3 && err == nil && !synced; i++ {
 		synced, err = syncConfig(agent, desired)
 	}
-	c.agentPool.Put(agent)
+	if err == nil && synced {
+		synced = false
+		for i := 0; i < 3 && err == nil && !synced; i++ {
+			synced, err = syncAddresses(agent, addresses)
+		}
+	}
+	if err == nil && synced && replicaConnectors != nil {
+		synced = false
+		for i := 0; i < 3 && err == nil && !synced; i++ {
+			synced, err = agent.UpdateLocalReplicaConnectors(replicaConnectors)
+		}
+	}
+	connections.PutAgent(agent, err)
 	if err != nil {
-		return fmt.Errorf("Error while syncing bridge config : %s", err)
+		return err
 	}
 	if !synced {
 		return fmt.Errorf("Failed to sync bridge config")
 	}
-	log.Println("Bridge config synced")
 	return nil
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+)
+
+func TestConfigSyncCheck(t *testing.T) {
+	c := &ConfigSync{}
+	check := c.syncCheck(time.Minute)
+	assert.Assert(t, check())
+
+	c.syncFailed(fmt.Errorf("connection refused"))
+	// failures are tolerated for a while
+	assert.Assert(t, check())
+	c.failingSince = time.Now().Add(-2 * time.Minute)
+	err := check()
+	assert.Assert(t, err != nil)
+	assert.Assert(t, strings.Contains(err.Error(), "last synced never"), err.Error())
+	assert.Assert(t, strings.Contains(err.Error(), "connection refused"), err.Error())
+
+	c.synced()
+	assert.Assert(t, check())
+	c.syncFailed(fmt.Errorf("timed out"))
+	c.failingSince = time.Now().Add(-2 * time.Minute)
+	err = check()
+	assert.Assert(t, err != nil)
+	assert.Assert(t, !strings.Contains(err.Error(), "never"), err.Error())
+}
+
+func routerPod(name string, ip string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{"skupper.io/component": "router"},
+		},
+		Status: corev1.PodStatus{
+			PodIP: ip,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: status},
+			},
+		},
+	}
+}
+
+func TestRouterConnections(t *testing.T) {
+	cli := &client.VanClient{
+		Namespace:  testNamespace,
+		KubeClient: fake.NewSimpleClientset(),
+	}
+	configInformer := corev1informer.NewConfigMapInformer(cli.KubeClient, testNamespace, time.Second*30, cache.Indexers{})
+	c := newConfigSync(cli, configInformer, nil, nil)
+	connected := []string{}
+	c.connect = func(url string) *ConnectionManager {
+		connected = append(connected, url)
+		return newConnectionManager(url, nil)
+	}
+	store := c.podInformer.GetStore()
+
+	assert.Equal(t, len(c.updateRouterConnections()), 0)
+
+	assert.Assert(t, store.Add(routerPod("router-a", "10.0.0.1", true)))
+	assert.Assert(t, store.Add(routerPod("router-b", "10.0.0.2", true)))
+	assert.Assert(t, store.Add(routerPod("router-c", "10.0.0.3", false)))
+	routers := c.updateRouterConnections()
+	assert.Equal(t, len(routers), 2)
+	assert.Equal(t, routers["router-a"].ip, "10.0.0.1")
+	assert.Equal(t, routers["router-b"].ip, "10.0.0.2")
+	assert.Equal(t, len(connected), 2)
+
+	// existing connections are reused
+	c.updateRouterConnections()
+	assert.Equal(t, len(connected), 2)
+
+	// a restarted pod is reconnected at its new address, and a pod
+	// that is no longer ready is dropped
+	assert.Assert(t, store.Update(routerPod("router-a", "10.0.0.4", true)))
+	assert.Assert(t, store.Update(routerPod("router-b", "10.0.0.2", false)))
+	assert.Assert(t, store.Update(routerPod("router-c", "10.0.0.3", true)))
+	routers = c.updateRouterConnections()
+	assert.Equal(t, len(routers), 2)
+	assert.Equal(t, routers["router-a"].ip, "10.0.0.4")
+	assert.Equal(t, routers["router-c"].ip, "10.0.0.3")
+	sort.Strings(connected)
+	assert.DeepEqual(t, connected, []string{"amqps://10.0.0.1:5671", "amqps://10.0.0.2:5671", "amqps://10.0.0.3:5671", "amqps://10.0.0.4:5671"})
+}
+
+func replicaPod(name string, created int64, replica string) *corev1.Pod {
+	pod := routerPod(name, "", true)
+	pod.ObjectMeta.CreationTimestamp = metav1.Unix(created, 0)
+	if replica != "" {
+		pod.ObjectMeta.Labels[types.RouterReplicaQualifier] = replica
+	}
+	return pod
+}
+
+func TestAssignReplicaIndexes(t *testing.T) {
+	tests := []struct {
+		name     string
+		pods     []*corev1.Pod
+		replicas int
+		expected map[string]int
+	}{
+		{
+			name:     "unlabelled",
+			pods:     []*corev1.Pod{replicaPod("router-b", 2, ""), replicaPod("router-a", 1, ""), replicaPod("router-c", 2, "")},
+			replicas: 3,
+			expected: map[string]int{"router-a": 0, "router-b": 1, "router-c": 2},
+		},
+		{
+			name:     "replacement",
+			pods:     []*corev1.Pod{replicaPod("router-a", 1, "0"), replicaPod("router-d", 4, ""), replicaPod("router-c", 3, "2")},
+			replicas: 3,
+			expected: map[string]int{"router-d": 1},
+		},
+		{
+			name:     "duplicate",
+			pods:     []*corev1.Pod{replicaPod("router-a", 1, "1"), replicaPod("router-b", 2, "1")},
+			replicas: 2,
+			expected: map[string]int{"router-b": 0},
+		},
+		{
+			name:     "surplus",
+			pods:     []*corev1.Pod{replicaPod("router-a", 1, "0"), replicaPod("router-b", 2, "1"), replicaPod("router-c", 3, "")},
+			replicas: 2,
+			expected: map[string]int{"router-c": 2},
+		},
+		{
+			name:     "scaled-down",
+			pods:     []*corev1.Pod{replicaPod("router-a", 1, "0"), replicaPod("router-c", 3, "2")},
+			replicas: 2,
+			expected: map[string]int{"router-c": 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.DeepEqual(t, assignReplicaIndexes(test.pods, test.replicas), test.expected)
+		})
+	}
+}
+
+func TestAssignRouterReplicas(t *testing.T) {
+	pods := []*corev1.Pod{replicaPod("router-a", 1, ""), replicaPod("router-b", 2, "")}
+	cli := &client.VanClient{
+		Namespace:  testNamespace,
+		KubeClient: fake.NewSimpleClientset(pods[0], pods[1]),
+	}
+	configInformer := corev1informer.NewConfigMapInformer(cli.KubeClient, testNamespace, time.Second*30, cache.Indexers{})
+	c := newConfigSync(cli, configInformer, nil, nil)
+	for _, pod := range pods {
+		assert.Assert(t, c.podInformer.GetStore().Add(pod))
+	}
+	assert.Assert(t, c.assignRouterReplicas())
+	for i, name := range []string{"router-a", "router-b"} {
+		pod, err := cli.KubeClient.CoreV1().Pods(testNamespace).Get(name, metav1.GetOptions{})
+		assert.Assert(t, err)
+		assert.Equal(t, pod.ObjectMeta.Labels[types.RouterReplicaQualifier], fmt.Sprint(i))
+	}
+}
+
+func TestGetReplicaConnectors(t *testing.T) {
+	assert.Assert(t, getReplicaConnectors(-1, testNamespace, false) == nil)
+	assert.Equal(t, len(getReplicaConnectors(1, testNamespace, true)), 0)
+	connectors := getReplicaConnectors(1, testNamespace, false)
+	assert.Equal(t, len(connectors), 1)
+	assert.Equal(t, connectors[types.GetReplicaConnectorName(0)].Host, types.GetRouterReplicaServiceName(0)+"."+testNamespace)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	amqp "github.com/interconnectedcloud/go-amqp"
+
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+const (
+	minReconnectDelay time.Duration = time.Second
+	maxReconnectDelay time.Duration = time.Minute
+)
+
+// backoff doubles the delay between successive attempts, up to a
+// maximum
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	} else if b.current < b.max {
+		b.current *= 2
+		if b.current > b.max {
+			b.current = b.max
+		}
+	}
+	return b.current
+}
+
+func (b *backoff) reset() {
+	b.current = 0
+}
+
+// ConnectionStatus describes the state of the controller's connection
+// to the router and how often it, or the links using it, have failed
+type ConnectionStatus struct {
+	Connected     bool
+	Since         time.Time
+	Reconnects    int
+	DialFailures  int
+	AgentFailures int
+	LinkFailures  map[string]int
+	LastError     string
+}
+
+// the number of idle management agents kept for reuse
+const maxIdleAgents int = 10
+
+// ConnectionManager maintains the connection to the router shared by
+// the controller's long lived links, re-establishing it and the links
+// with exponential backoff whenever they fail. It also hands out the
+// management agents used for request-response interactions, each on
+// its own session of the same connection.
+type ConnectionManager struct {
+	dial     func() (*amqp.Client, error)
+	minDelay time.Duration
+	maxDelay time.Duration
+
+	lock       sync.Mutex
+	connection *amqp.Client
+	dialled    bool
+	status     ConnectionStatus
+	// the connection on which each agent was created, so that idle
+	// agents are discarded once it has been lost
+	agents map[*qdr.Agent]*amqp.Client
+	idle   []*qdr.Agent
+}
+
+func newConnectionManager(url string, config *tls.Config) *ConnectionManager {
+	return &ConnectionManager{
+		dial: func() (*amqp.Client, error) {
+			return amqp.Dial(url, amqp.ConnSASLExternal(), amqp.ConnMaxFrameSize(4294967295), amqp.ConnTLSConfig(config))
+		},
+		minDelay: minReconnectDelay,
+		maxDelay: maxReconnectDelay,
+		status: ConnectionStatus{
+			LinkFailures: map[string]int{},
+		},
+		agents: map[*qdr.Agent]*amqp.Client{},
+	}
+}
+
+func (m *ConnectionManager) connect() (*amqp.Client, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.connection != nil {
+		return m.connection, nil
+	}
+	connection, err := m.dial()
+	if err != nil {
+		m.status.DialFailures++
+		m.status.LastError = err.Error()
+		return nil, fmt.Errorf("Failed to create amqp connection: %s", err)
+	}
+	if m.dialled {
+		m.status.Reconnects++
+		log.Printf("Connection to skupper-messaging service re-established (%d reconnects)", m.status.Reconnects)
+	} else {
+		log.Println("Connection to skupper-messaging service established")
+	}
+	m.dialled = true
+	m.connection = connection
+	m.status.Connected = true
+	m.status.Since = time.Now()
+	return connection, nil
+}
+
+// disconnect discards the connection if it is still the current one,
+// so that the next user to need it will dial again
+func (m *ConnectionManager) disconnect(connection *amqp.Client, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.connection != connection {
+		return
+	}
+	log.Printf("Connection to skupper-messaging service lost: %s", err)
+	connection.Close()
+	m.forgetIdleAgents()
+	m.connection = nil
+	m.status.Connected = false
+	m.status.Since = time.Now()
+	m.status.LastError = err.Error()
+}
+
+func (m *ConnectionManager) session() (*amqp.Session, error) {
+	connection, err := m.connect()
+	if err != nil {
+		return nil, err
+	}
+	session, err := connection.NewSession()
+	if err != nil {
+		m.disconnect(connection, err)
+		return nil, fmt.Errorf("Failed to create amqp session: %s", err)
+	}
+	return session, nil
+}
+
+func (m *ConnectionManager) linkFailed(name string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.status.LinkFailures[name]++
+	m.status.LastError = err.Error()
+}
+
+// Run calls the handler with a new session on the shared connection,
+// and again whenever the handler returns, until stopCh is closed. The
+// handler should return an error if any of its links fail, and return
+// when its context is cancelled.
+func (m *ConnectionManager) Run(name string, handler func(ctx context.Context, session *amqp.Session) error, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	delay := backoff{min: m.minDelay, max: m.maxDelay}
+	for {
+		session, err := m.session()
+		if err == nil {
+			started := time.Now()
+			err = handler(ctx, session)
+			closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second)
+			session.Close(closeCtx)
+			closeCancel()
+			if time.Since(started) > m.maxDelay {
+				// the links were working for a while, so
+				// treat this as a fresh failure
+				delay.reset()
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("%s stopped", name)
+		}
+		m.linkFailed(name, err)
+		wait := delay.next()
+		log.Printf("[%s] %s; retrying in %s", name, err, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// GetAgent returns a management agent on the shared connection,
+// dialling it if necessary
+func (m *ConnectionManager) GetAgent() (*qdr.Agent, error) {
+	if agent := m.idleAgent(); agent != nil {
+		return agent, nil
+	}
+	connection, err := m.connect()
+	if err != nil {
+		m.agentFailed(err)
+		return nil, err
+	}
+	session, err := connection.NewSession()
+	if err != nil {
+		m.disconnect(connection, err)
+		m.agentFailed(err)
+		return nil, fmt.Errorf("Failed to create amqp session: %s", err)
+	}
+	agent, err := qdr.NewAgent(session)
+	if err != nil {
+		if agent != nil {
+			agent.Close()
+		} else {
+			closeSession(session)
+		}
+		m.agentFailed(err)
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.agents[agent] = connection
+	return agent, nil
+}
+
+// idleAgent returns an agent put back for reuse, if there is one on
+// the current connection
+func (m *ConnectionManager) idleAgent() *qdr.Agent {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for len(m.idle) > 0 {
+		agent := m.idle[len(m.idle)-1]
+		m.idle = m.idle[:len(m.idle)-1]
+		if m.connection != nil && m.agents[agent] == m.connection {
+			return agent
+		}
+		delete(m.agents, agent)
+	}
+	return nil
+}
+
+// PutAgent returns an agent obtained from GetAgent for reuse, unless
+// it failed, in which case its session is closed
+func (m *ConnectionManager) PutAgent(agent *qdr.Agent, err error) {
+	if agent == nil {
+		return
+	}
+	m.lock.Lock()
+	current := m.connection != nil && m.agents[agent] == m.connection
+	if err == nil && current && len(m.idle) < maxIdleAgents {
+		m.idle = append(m.idle, agent)
+		m.lock.Unlock()
+		return
+	}
+	delete(m.agents, agent)
+	m.lock.Unlock()
+	if current {
+		// otherwise the session was closed with its connection
+		agent.Close()
+	}
+	if err != nil {
+		m.agentFailed(err)
+	}
+}
+
+// forgetIdleAgents drops the idle agents, whose sessions are closed
+// with the connection they are on. The lock must be held.
+func (m *ConnectionManager) forgetIdleAgents() {
+	for _, agent := range m.idle {
+		delete(m.agents, agent)
+	}
+	m.idle = nil
+}
+
+func closeSession(session *amqp.Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session.Close(ctx)
+}
+
+// Close closes the shared connection, and with it any agents and
+// links still using it
+func (m *ConnectionManager) Close() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.forgetIdleAgents()
+	if m.connection != nil {
+		m.connection.Close()
+		m.connection = nil
+		m.status.Connected = false
+		m.status.Since = time.Now()
+	}
+}
+
+func (m *ConnectionManager) agentFailed(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.status.AgentFailures++
+	m.status.LastError = err.Error()
+}
+
+// Status returns a copy of the current connection status
+func (m *ConnectionManager) Status() ConnectionStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	status := m.status
+	status.LinkFailures = map[string]int{}
+	for name, count := range m.status.LinkFailures {
+		status.LinkFailures[name] = count
+	}
+	return status
+}
+
+// writeMetrics writes the connection status in the prometheus text
+// exposition format
+func (m *ConnectionManager) writeMetrics(w io.Writer) {
+	status := m.Status()
+	connected := 0
+	if status.Connected {
+		connected = 1
+	}
+	fmt.Fprintln(w, "# HELP skupper_amqp_connected Whether the controller is connected to the router")
+	fmt.Fprintln(w, "# TYPE skupper_amqp_connected gauge")
+	fmt.Fprintf(w, "skupper_amqp_connected %d\n", connected)
+	fmt.Fprintln(w, "# HELP skupper_amqp_reconnects_total Connections to the router re-established after being lost")
+	fmt.Fprintln(w, "# TYPE skupper_amqp_reconnects_total counter")
+	fmt.Fprintf(w, "skupper_amqp_reconnects_total %d\n", status.Reconnects)
+	fmt.Fprintln(w, "# HELP skupper_amqp_dial_failures_total Failed attempts to connect to the router")
+	fmt.Fprintln(w, "# TYPE skupper_amqp_dial_failures_total counter")
+	fmt.Fprintf(w, "skupper_amqp_dial_failures_total %d\n", status.DialFailures)
+	fmt.Fprintln(w, "# HELP skupper_amqp_agent_failures_total Failed management requests to the router")
+	fmt.Fprintln(w, "# TYPE skupper_amqp_agent_failures_total counter")
+	fmt.Fprintf(w, "skupper_amqp_agent_failures_total %d\n", status.AgentFailures)
+	fmt.Fprintln(w, "# HELP skupper_amqp_link_failures_total Failures of the links used by each part of the controller")
+	fmt.Fprintln(w, "# TYPE skupper_amqp_link_failures_total counter")
+	names := []string{}
+	for name := range status.LinkFailures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "skupper_amqp_link_failures_total{link=%q} %d\n", name, status.LinkFailures[name])
+	}
+}
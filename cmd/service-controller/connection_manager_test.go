@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/interconnectedcloud/go-amqp"
+	"gotest.tools/assert"
+
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+func TestBackoff(t *testing.T) {
+	b := backoff{min: time.Second, max: 5 * time.Second}
+	delays := []time.Duration{}
+	for i := 0; i < 5; i++ {
+		delays = append(delays, b.next())
+	}
+	assert.DeepEqual(t, delays, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second})
+	b.reset()
+	assert.Equal(t, b.next(), time.Second)
+}
+
+func TestConnectionManagerRetries(t *testing.T) {
+	attempts := make(chan struct{}, 10)
+	m := &ConnectionManager{
+		dial: func() (*amqp.Client, error) {
+			attempts <- struct{}{}
+			return nil, fmt.Errorf("connection refused")
+		},
+		minDelay: time.Millisecond,
+		maxDelay: 4 * time.Millisecond,
+		status:   ConnectionStatus{LinkFailures: map[string]int{}},
+	}
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		m.Run("test", func(ctx context.Context, session *amqp.Session) error {
+			t.Error("handler should not be called without a connection")
+			return nil
+		}, stopCh)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		<-attempts
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return when stopped")
+	}
+
+	status := m.Status()
+	assert.Assert(t, !status.Connected)
+	assert.Assert(t, status.DialFailures >= 3)
+	assert.Assert(t, status.LinkFailures["test"] >= 3)
+	assert.Equal(t, status.Reconnects, 0)
+	assert.Assert(t, strings.Contains(status.LastError, "connection refused"))
+
+	m.agentFailed(fmt.Errorf("no route"))
+	m.PutAgent(nil, nil)
+	var metrics bytes.Buffer
+	m.writeMetrics(&metrics)
+	assert.Assert(t, strings.Contains(metrics.String(), "skupper_amqp_connected 0\n"))
+	assert.Assert(t, strings.Contains(metrics.String(), "skupper_amqp_agent_failures_total 1\n"))
+	assert.Assert(t, strings.Contains(metrics.String(), `skupper_amqp_link_failures_total{link="test"}`))
+}
+
+func TestAgentReuse(t *testing.T) {
+	connection := &amqp.Client{}
+	m := newConnectionManager("amqps://skupper-messaging:5671", nil)
+	m.connection = connection
+	agent := &qdr.Agent{}
+	m.agents[agent] = connection
+
+	m.PutAgent(agent, nil)
+	assert.Equal(t, m.idleAgent(), agent)
+	assert.Assert(t, m.idleAgent() == nil)
+
+	// agents are not reused once the connection they were made on
+	// has been replaced
+	m.PutAgent(agent, nil)
+	m.connection = &amqp.Client{}
+	assert.Assert(t, m.idleAgent() == nil)
+	assert.Equal(t, len(m.agents), 0)
+
+	stale := &qdr.Agent{}
+	m.agents[stale] = connection
+	m.PutAgent(stale, nil)
+	assert.Equal(t, len(m.idle), 0)
+	assert.Equal(t, len(m.agents), 0)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,14 +16,14 @@ import (
 )
 
 type ConsoleServer struct {
-	agentPool *qdr.AgentPool
-	iplookup  *IpLookup
+	connections *ConnectionManager
+	iplookup    *IpLookup
 }
 
-func newConsoleServer(cli *client.VanClient, config *tls.Config) *ConsoleServer {
+func newConsoleServer(cli *client.VanClient, connections *ConnectionManager) *ConsoleServer {
 	return &ConsoleServer{
-		agentPool: qdr.NewAgentPool("amqps://skupper-messaging:5671", config),
-		iplookup:  NewIpLookup(cli),
+		connections: connections,
+		iplookup:    NewIpLookup(cli),
 	}
 }
 
@@ -68,13 +67,14 @@ func authenticated(h http.Handler) http.Handler {
 }
 
 func (server *ConsoleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	agent, err := server.agentPool.Get()
+	agent, err := server.connections.GetAgent()
 	if err != nil {
 		log.Printf("Could not get management agent : %s", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 	data, err := getConsoleData(agent, server.iplookup)
-	server.agentPool.Put(agent)
+	server.connections.PutAgent(agent, err)
 	if err != nil {
 		log.Printf("Error retrieving console data: %s", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -105,6 +105,10 @@ func (server *ConsoleServer) listen() {
 	}
 	log.Printf("Console server listening on %s", addr)
 	http.Handle("/DATA", authenticated(server))
+	http.Handle("/metrics", authenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		server.connections.writeMetrics(w)
+	})))
 	http.Handle("/", authenticated(http.FileServer(http.Dir("/app/console/"))))
 	log.Fatal(http.ListenAndServe(addr, nil))
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/skupperproject/skupper/pkg/utils"
@@ -22,16 +23,20 @@ import (
 	corev1informer "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/informers/internalinterfaces"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
-	amqp "github.com/interconnectedcloud/go-amqp"
-
 	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/health"
 	"github.com/skupperproject/skupper/pkg/kube"
 	"github.com/skupperproject/skupper/pkg/qdr"
 )
 
+// how long an item may take to process, or the router's bridge
+// configuration fail to sync, before the controller is unhealthy
+const stuckLimit time.Duration = 5 * time.Minute
+
 type Controller struct {
 	origin            string
 	vanClient         *client.VanClient
@@ -39,26 +44,43 @@ type Controller struct {
 	svcDefInformer    cache.SharedIndexInformer
 	svcInformer       cache.SharedIndexInformer
 	headlessInformer  cache.SharedIndexInformer
+	eventRecorder     record.EventRecorder
 
 	//control loop state:
-	events   workqueue.RateLimitingInterface
-	bindings map[string]*ServiceBindings
-	ports    *FreePorts
+	events      workqueue.RateLimitingInterface
+	bindings    map[string]*ServiceBindings
+	definitions map[string]string
+	fragments   *routerFragments
+	tlsPending  bool
+	ports       *FreePorts
+	// skupper-internal as last read or written, and the version of
+	// it superseded by that write, which the informer may not yet
+	// have caught up with
+	routerConfig      *qdr.RouterConfig
+	routerConfigMap   *corev1.ConfigMap
+	routerConfigStale string
+	// how long changes to the bridges are held back so that those
+	// made meanwhile are written to skupper-internal together
+	bridgesDelay time.Duration
 
 	//service_sync state:
-	tlsConfig       *tls.Config
-	amqpClient      *amqp.Client
-	amqpSession     *amqp.Session
+	connections     *ConnectionManager
+	syncLock        sync.Mutex // guards localServices, byName and unavailable
 	byOrigin        map[string]map[string]types.ServiceInterface
 	localServices   map[string]types.ServiceInterface
 	byName          map[string]types.ServiceInterface
+	unavailable     map[string]bool
 	desiredServices map[string]types.ServiceInterface
 	heardFrom       map[string]time.Time
+	conflicts       map[string]map[string]bool
+	sitePriorities  *SitePriorities
 
 	definitionMonitor *DefinitionMonitor
 	consoleServer     *ConsoleServer
 	siteQueryServer   *SiteQueryServer
 	configSync        *ConfigSync
+	health            *health.Checker
+	progress          health.Progress
 }
 
 func hasProxyAnnotation(service corev1.Service) bool {
@@ -113,7 +135,7 @@ func NewController(cli *client.VanClient, origin string, tlsConfig *tls.Config)
 		time.Second*30,
 		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
 		internalinterfaces.TweakListOptionsFunc(func(options *metav1.ListOptions) {
-			options.FieldSelector = "metadata.name=skupper-services"
+			options.LabelSelector = types.TypeServiceDefinitionsQualifier
 		}))
 	bridgeDefInformer := corev1informer.NewFilteredConfigMapInformer(
 		cli.KubeClient,
@@ -137,13 +159,15 @@ func NewController(cli *client.VanClient, origin string, tlsConfig *tls.Config)
 	controller := &Controller{
 		vanClient:         cli,
 		origin:            origin,
-		tlsConfig:         tlsConfig,
+		connections:       newConnectionManager("amqps://skupper-messaging:5671", tlsConfig),
 		bridgeDefInformer: bridgeDefInformer,
 		svcDefInformer:    svcDefInformer,
 		svcInformer:       svcInformer,
 		headlessInformer:  headlessInformer,
+		eventRecorder:     kube.NewEventRecorder(types.ControllerDeploymentName, cli.KubeClient),
 		events:            events,
 		ports:             newFreePorts(),
+		bridgesDelay:      time.Second,
 	}
 
 	// Organize service definitions
@@ -152,20 +176,48 @@ func NewController(cli *client.VanClient, origin string, tlsConfig *tls.Config)
 	controller.byName = make(map[string]types.ServiceInterface)
 	controller.desiredServices = make(map[string]types.ServiceInterface)
 	controller.heardFrom = make(map[string]time.Time)
+	controller.conflicts = make(map[string]map[string]bool)
+	controller.sitePriorities = newSitePriorities()
 
 	log.Println("Setting up event handlers")
-	svcDefInformer.AddEventHandler(controller.newEventHandler("servicedefs", AnnotatedKey, ConfigMapResourceVersionTest))
+	// skupper-services and its shards are processed together
+	svcDefInformer.AddEventHandler(controller.newEventHandler("servicedefs@"+controller.namespaced(types.ServiceInterfaceConfigMap), FixedKey, ConfigMapResourceVersionTest))
 	bridgeDefInformer.AddEventHandler(controller.newEventHandler("bridges", AnnotatedKey, ConfigMapResourceVersionTest))
 	svcInformer.AddEventHandler(controller.newEventHandler("actual-services", AnnotatedKey, ServiceResourceVersionTest))
 	headlessInformer.AddEventHandler(controller.newEventHandler("statefulset", AnnotatedKey, StatefulSetResourceVersionTest))
-	controller.consoleServer = newConsoleServer(cli, tlsConfig)
-	controller.siteQueryServer = newSiteQueryServer(tlsConfig)
+	controller.consoleServer = newConsoleServer(cli, controller.connections)
+	controller.siteQueryServer = newSiteQueryServer()
 
-	controller.definitionMonitor = newDefinitionMonitor(controller.origin, controller.vanClient, controller.svcDefInformer, controller.svcInformer)
-	controller.configSync = newConfigSync(controller.bridgeDefInformer, tlsConfig)
+	controller.definitionMonitor = newDefinitionMonitor(controller.origin, controller.vanClient, controller.svcDefInformer, controller.svcInformer, controller.eventRecorder)
+	controller.configSync = newConfigSync(controller.vanClient, controller.bridgeDefInformer, tlsConfig, controller.eventRecorder)
+	controller.health = controller.newHealthChecker()
 	return controller, nil
 }
 
+// newHealthChecker returns the checks for the controller's liveness
+// and readiness probes
+func (c *Controller) newHealthChecker() *health.Checker {
+	checker := health.NewChecker()
+	checker.AddLivenessCheck("service-controller", c.progress.Check(stuckLimit))
+	checker.AddLivenessCheck("definition-monitor", c.definitionMonitor.progress.Check(stuckLimit))
+	checker.AddLivenessCheck("config-sync", c.configSync.progress.Check(stuckLimit))
+	checker.AddReadinessCheck("informers", health.InformersSynced(
+		c.svcDefInformer.HasSynced,
+		c.bridgeDefInformer.HasSynced,
+		c.svcInformer.HasSynced,
+		c.headlessInformer.HasSynced,
+		c.definitionMonitor.statefulSetInformer.HasSynced,
+		c.definitionMonitor.deploymentInformer.HasSynced))
+	checker.AddReadinessCheck("amqp", func() error {
+		if status := c.connections.Status(); !status.Connected {
+			return fmt.Errorf("not connected to the router: %s", status.LastError)
+		}
+		return nil
+	})
+	checker.AddReadinessCheck("bridge-sync", c.configSync.syncCheck(stuckLimit))
+	return checker
+}
+
 type ResourceVersionTest func(a interface{}, b interface{}) bool
 
 func ConfigMapResourceVersionTest(a interface{}, b interface{}) bool {
@@ -255,6 +307,12 @@ func newEventHandlerFor(events workqueue.RateLimitingInterface, category string,
 }
 
 func (c *Controller) Run(stopCh <-chan struct{}) error {
+	// skupper-services, if created by an earlier version, needs to be
+	// labelled to be watched along with any shards
+	if err := kube.MigrateServiceDefinitions(c.vanClient.Namespace, c.vanClient.KubeClient); err != nil {
+		log.Printf("Failed to migrate service definitions: %s", err)
+	}
+
 	// fire up the informers
 	go c.svcDefInformer.Run(stopCh)
 	go c.bridgeDefInformer.Run(stopCh)
@@ -273,8 +331,8 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 
 	log.Println("Starting workers")
 	c.siteQueryServer.getLocalSiteInfo(c.vanClient)
-	go wait.Until(c.siteQueryServer.run, time.Second, stopCh)
-	go wait.Until(c.runServiceSync, time.Second, stopCh)
+	go c.connections.Run("site-query", c.siteQueryServer.run, stopCh)
+	go c.connections.Run("service-sync", c.runServiceSync, stopCh)
 	go wait.Until(c.runServiceCtrl, time.Second, stopCh)
 	c.definitionMonitor.start(stopCh)
 	c.consoleServer.start(stopCh)
@@ -291,20 +349,24 @@ func (c *Controller) Run(stopCh <-chan struct{}) error {
 
 func (c *Controller) createServiceFor(desired *ServiceBindings) error {
 	log.Println("Creating new service for ", desired.address)
-	_, err := kube.NewServiceForAddress(desired.address, desired.publicPort, desired.ingressPort, getOwnerReference(), c.vanClient.Namespace, c.vanClient.KubeClient)
+	svc, err := kube.NewServiceForAddress(desired.address, desired.servicePorts(), desired.ingressPorts(), desired.labels, desired.annotations, kube.GetServiceTypeForExternal(desired.external), getOwnerReference(), c.vanClient.Namespace, c.vanClient.KubeClient)
 	if err != nil {
 		log.Printf("Error while creating service %s: %s", desired.address, err)
+		return err
 	}
-	return err
+	c.eventRecorder.Event(svc, corev1.EventTypeNormal, EventServiceCreated, describeBinding(desired))
+	return nil
 }
 
 func (c *Controller) createHeadlessServiceFor(desired *ServiceBindings) error {
 	log.Println("Creating new headless service for ", desired.address)
-	_, err := kube.NewHeadlessServiceForAddress(desired.address, desired.publicPort, desired.ingressPort, getOwnerReference(), c.vanClient.Namespace, c.vanClient.KubeClient)
+	svc, err := kube.NewHeadlessServiceForAddress(desired.address, desired.publicPort(), desired.ingressPort(), desired.labels, desired.annotations, getOwnerReference(), c.vanClient.Namespace, c.vanClient.KubeClient)
 	if err != nil {
 		log.Printf("Error while creating headless service %s: %s", desired.address, err)
+		return err
 	}
-	return err
+	c.eventRecorder.Event(svc, corev1.EventTypeNormal, EventServiceCreated, describeBinding(desired))
+	return nil
 }
 
 func equivalentSelectors(a map[string]string, b map[string]string) bool {
@@ -327,12 +389,20 @@ func equivalentSelectors(a map[string]string, b map[string]string) bool {
 func (c *Controller) checkServiceFor(desired *ServiceBindings, actual *corev1.Service) error {
 	log.Printf("Checking service changes for %s", actual.ObjectMeta.Name)
 	update := false
-	if len(actual.Spec.Ports) > 0 {
-		if actual.Spec.Ports[0].Port != int32(desired.publicPort) {
+	if desired.hasNamedPorts() {
+		update = checkNamedPortsFor(desired, actual)
+	} else if len(actual.Spec.Ports) > 0 {
+		if len(actual.Spec.Ports) > 1 && isOwned(actual) {
+			// service no longer has named ports
+			update = true
+			actual.Spec.Ports = actual.Spec.Ports[:1]
+			actual.Spec.Ports[0].Name = desired.address
+		}
+		if actual.Spec.Ports[0].Port != int32(desired.publicPort()) {
 			update = true
-			actual.Spec.Ports[0].Port = int32(desired.publicPort)
+			actual.Spec.Ports[0].Port = int32(desired.publicPort())
 		}
-		if actual.Spec.Ports[0].TargetPort.IntValue() != desired.ingressPort {
+		if actual.Spec.Ports[0].TargetPort.IntValue() != desired.ingressPort() {
 			update = true
 			originalAssignedPort, _ := strconv.Atoi(actual.Annotations[types.OriginalAssignedQualifier])
 			actualTargetPort := actual.Spec.Ports[0].TargetPort.IntValue()
@@ -343,8 +413,8 @@ func (c *Controller) checkServiceFor(desired *ServiceBindings, actual *corev1.Se
 			if actualTargetPort != originalAssignedPort {
 				actual.ObjectMeta.Annotations[types.OriginalTargetPortQualifier] = strconv.Itoa(actualTargetPort)
 			}
-			actual.ObjectMeta.Annotations[types.OriginalAssignedQualifier] = strconv.Itoa(desired.ingressPort)
-			actual.Spec.Ports[0].TargetPort = intstr.FromInt(desired.ingressPort)
+			actual.ObjectMeta.Annotations[types.OriginalAssignedQualifier] = strconv.Itoa(desired.ingressPort())
+			actual.Spec.Ports[0].TargetPort = intstr.FromInt(desired.ingressPort())
 		}
 	}
 	if desired.headless == nil && !equivalentSelectors(actual.Spec.Selector, kube.GetLabelsForRouter()) {
@@ -355,6 +425,14 @@ func (c *Controller) checkServiceFor(desired *ServiceBindings, actual *corev1.Se
 		actual.ObjectMeta.Annotations[types.OriginalSelectorQualifier] = utils.StringifySelector(actual.Spec.Selector)
 		actual.Spec.Selector = kube.GetLabelsForRouter()
 	}
+	// custom labels and annotations are only kept reconciled on
+	// services the controller generated
+	if isOwned(actual) && kube.ApplyServiceMetadata(actual, desired.labels, desired.annotations) {
+		update = true
+	}
+	if isOwned(actual) && desired.headless == nil && kube.SetServiceType(actual, kube.GetServiceTypeForExternal(desired.external)) {
+		update = true
+	}
 	if update {
 		_, err := c.vanClient.KubeClient.CoreV1().Services(c.vanClient.Namespace).Update(actual)
 		return err
@@ -362,6 +440,77 @@ func (c *Controller) checkServiceFor(desired *ServiceBindings, actual *corev1.Se
 	return nil
 }
 
+// checkNamedPortsFor matches the ports of the service by name. The
+// original target ports of an annotated service are recorded (as
+// name:port pairs) so that they can be restored. Ports are only added
+// to or removed from services the controller owns.
+func checkNamedPortsFor(desired *ServiceBindings, actual *corev1.Service) bool {
+	update := false
+	originalTargetPorts := kube.ParsePortAnnotation(actual.ObjectMeta.Annotations[types.OriginalTargetPortQualifier])
+	originalAssignedPorts := kube.ParsePortAnnotation(actual.ObjectMeta.Annotations[types.OriginalAssignedQualifier])
+	owned := isOwned(actual)
+	required := map[string]bool{}
+	for _, port := range desired.ports {
+		name := kube.GetServicePortName(desired.address, port.name)
+		required[name] = true
+		i := indexOfServicePort(actual.Spec.Ports, name)
+		if i < 0 {
+			if owned {
+				update = true
+				actual.Spec.Ports = append(actual.Spec.Ports, corev1.ServicePort{
+					Name:       name,
+					Port:       int32(port.publicPort),
+					TargetPort: intstr.FromInt(port.ingressPort),
+				})
+			}
+			continue
+		}
+		if actual.Spec.Ports[i].Port != int32(port.publicPort) {
+			update = true
+			actual.Spec.Ports[i].Port = int32(port.publicPort)
+		}
+		if actualTargetPort := actual.Spec.Ports[i].TargetPort.IntValue(); actualTargetPort != port.ingressPort {
+			update = true
+			// If target port has been modified by user
+			if actualTargetPort != originalAssignedPorts[port.name] {
+				originalTargetPorts[port.name] = actualTargetPort
+			}
+			originalAssignedPorts[port.name] = port.ingressPort
+			actual.Spec.Ports[i].TargetPort = intstr.FromInt(port.ingressPort)
+		}
+	}
+	if owned {
+		ports := []corev1.ServicePort{}
+		for _, port := range actual.Spec.Ports {
+			if required[port.Name] {
+				ports = append(ports, port)
+			} else {
+				update = true
+			}
+		}
+		actual.Spec.Ports = ports
+	}
+	if update {
+		if actual.ObjectMeta.Annotations == nil {
+			actual.ObjectMeta.Annotations = map[string]string{}
+		}
+		if len(originalTargetPorts) > 0 {
+			actual.ObjectMeta.Annotations[types.OriginalTargetPortQualifier] = kube.FormatPortAnnotation(originalTargetPorts)
+		}
+		actual.ObjectMeta.Annotations[types.OriginalAssignedQualifier] = kube.FormatPortAnnotation(originalAssignedPorts)
+	}
+	return update
+}
+
+func indexOfServicePort(ports []corev1.ServicePort, name string) int {
+	for i, port := range ports {
+		if port.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (c *Controller) ensureServiceFor(desired *ServiceBindings) error {
 	log.Println("Checking service for: ", desired.address)
 	obj, exists, err := c.svcInformer.GetStore().GetByKey(c.namespaced(desired.address))
@@ -385,13 +534,19 @@ func (c *Controller) ensureServiceFor(desired *ServiceBindings) error {
 
 func (c *Controller) deleteService(svc *corev1.Service) error {
 	log.Println("Deleting service ", svc.ObjectMeta.Name)
-	return c.vanClient.KubeClient.CoreV1().Services(c.vanClient.Namespace).Delete(svc.ObjectMeta.Name, &metav1.DeleteOptions{})
+	err := c.vanClient.KubeClient.CoreV1().Services(c.vanClient.Namespace).Delete(svc.ObjectMeta.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+	c.eventRecorder.Event(svc, corev1.EventTypeNormal, EventServiceDeleted, "Skupper address "+svc.ObjectMeta.Name+" is no longer defined")
+	return nil
 }
 
 func (c *Controller) updateActualServices() {
 	for _, v := range c.bindings {
 		c.ensureServiceFor(v)
 	}
+	c.updateExternalAccess()
 	services := c.svcInformer.GetStore().List()
 	for _, v := range services {
 		svc := v.(*corev1.Service)
@@ -455,19 +610,52 @@ func (c *Controller) namespaced(name string) string {
 	return c.vanClient.Namespace + "/" + name
 }
 
-func (c *Controller) parseServiceDefinitions(cm *corev1.ConfigMap) map[string]types.ServiceInterface {
-	definitions := make(map[string]types.ServiceInterface)
-	if len(cm.Data) > 0 {
-		for _, v := range cm.Data {
-			si := types.ServiceInterface{}
-			err := jsonencoding.Unmarshal([]byte(v), &si)
-			if err == nil {
-				definitions[si.Address] = si
+// getServiceDefinitionsFrom returns the encoded definitions from
+// skupper-services and its shards in the store, or false if
+// skupper-services is not in it
+func getServiceDefinitionsFrom(store cache.Store) (map[string]string, bool) {
+	configmaps := []*corev1.ConfigMap{}
+	found := false
+	for _, obj := range store.List() {
+		if cm, ok := obj.(*corev1.ConfigMap); ok {
+			configmaps = append(configmaps, cm)
+			if cm.ObjectMeta.Name == types.ServiceInterfaceConfigMap {
+				found = true
 			}
 		}
-		c.desiredServices = definitions
 	}
-	return definitions
+	return kube.MergeServiceDefinitions(configmaps), found
+}
+
+// updateServiceDefinitions records the encoded definitions, returning
+// those that differ from when they were last processed and the
+// addresses of those since removed. Only the changed definitions are
+// parsed.
+func (c *Controller) updateServiceDefinitions(definitions map[string]string) (map[string]types.ServiceInterface, []string) {
+	changed := map[string]types.ServiceInterface{}
+	removed := []string{}
+	for k, v := range definitions {
+		if previous, ok := c.definitions[k]; ok && previous == v {
+			continue
+		}
+		c.definitions[k] = v
+		si := types.ServiceInterface{}
+		err := jsonencoding.Unmarshal([]byte(v), &si)
+		if err != nil {
+			log.Printf("Ignoring malformed service definition for %s: %s", k, err)
+			continue
+		}
+		c.desiredServices[si.Address] = si
+		changed[si.Address] = si
+	}
+	for k := range c.definitions {
+		if _, ok := definitions[k]; !ok {
+			delete(c.definitions, k)
+			delete(c.desiredServices, k)
+			removed = append(removed, k)
+		}
+	}
+	return changed, removed
 }
 
 func (c *Controller) runServiceCtrl() {
@@ -502,24 +690,74 @@ func (c *Controller) updateBridgeConfig(name string) error {
 		if !ok {
 			return fmt.Errorf("Expected ConfigMap for %s but got %#v", name, obj)
 		}
-		desiredBridges := requiredBridges(c.bindings, c.origin)
-		update, err := desiredBridges.UpdateConfigMap(cm)
-		if err != nil {
-			return fmt.Errorf("Error updating %s: %s", cm.ObjectMeta.Name, err)
+		// the config is only parsed and compared in full when it
+		// has been changed by something else, otherwise only the
+		// fragments changed since it was last written are applied
+		full := c.routerConfig == nil || (cm.ObjectMeta.ResourceVersion != c.routerConfigMap.ObjectMeta.ResourceVersion && cm.ObjectMeta.ResourceVersion != c.routerConfigStale)
+		if full {
+			config, err := qdr.GetRouterConfigFromConfigMap(cm)
+			if err != nil {
+				return fmt.Errorf("Error parsing %s: %s", cm.ObjectMeta.Name, err)
+			} else if config == nil {
+				return fmt.Errorf("Router config not defined in %s", cm.ObjectMeta.Name)
+			}
+			c.routerConfig = config
+			c.routerConfigMap = cm
+			c.routerConfigStale = ""
 		}
-		if update {
+		changed, profilesChanged := c.fragments.apply(c.routerConfig, full)
+		if changed {
+			updated := c.routerConfigMap.DeepCopy()
+			err = c.routerConfig.WriteToConfigMap(updated)
+			if err != nil {
+				return fmt.Errorf("Error updating %s: %s", cm.ObjectMeta.Name, err)
+			}
 			log.Printf("Updating %s", cm.ObjectMeta.Name)
-			_, err = c.vanClient.KubeClient.CoreV1().ConfigMaps(c.vanClient.Namespace).Update(cm)
+			updated, err = c.vanClient.KubeClient.CoreV1().ConfigMaps(c.vanClient.Namespace).Update(updated)
 			if err != nil {
+				// the changes are no longer recorded as pending, so
+				// the config must be compared in full on the retry
+				c.routerConfig = nil
+				c.events.AddRateLimited("bridges@" + name)
 				return fmt.Errorf("Failed to update %s: %v", name, err.Error())
 			}
+			c.routerConfigStale = c.routerConfigMap.ObjectMeta.ResourceVersion
+			c.routerConfigMap = updated
+		}
+		if profilesChanged || c.tlsPending {
+			// the router only loads ssl profiles on startup, which
+			// changing the mounted secrets will trigger, restarting
+			// every router pod; until that has succeeded the
+			// credentials are retried with each update, as the
+			// profiles will no longer show as changed
+			c.tlsPending = true
+			err = c.ensureServiceTlsCredentials()
+			if err != nil {
+				c.events.AddRateLimited("bridges@" + name)
+				return err
+			}
+			c.tlsPending = false
 		}
 	}
 	return nil
 }
 
+// queueBridgeConfig schedules the bridge changes made so far to be
+// written to skupper-internal, together with any made for other
+// addresses before that happens; the workqueue holds a single delayed
+// key, so however many changes are made within bridgesDelay of the
+// first, skupper-internal is rewritten once
+func (c *Controller) queueBridgeConfig() {
+	c.events.AddAfter("bridges@"+c.namespaced("skupper-internal"), c.bridgesDelay)
+}
+
 func (c *Controller) initialiseServiceBindingsMap() (map[string]int, error) {
 	c.bindings = map[string]*ServiceBindings{}
+	c.definitions = map[string]string{}
+	c.fragments = newRouterFragments()
+	c.routerConfig = nil
+	// credentials may not have been completed before a restart
+	c.tlsPending = true
 	//on first initiliasing the service bindings map, need to get any
 	//port allocations from bridge config
 	bridges, err := c.getInitialBridgeConfig()
@@ -542,14 +780,11 @@ func (c *Controller) initialiseServiceBindingsMap() (map[string]int, error) {
 func (c *Controller) deleteServiceBindings(k string, v *ServiceBindings) {
 	if v != nil {
 		v.stop()
+		c.releasePorts(v)
 	}
 	delete(c.bindings, k)
 }
 
-func (c *Controller) updateServiceSync(defs *corev1.ConfigMap) {
-	c.serviceSyncDefinitionsUpdated(c.parseServiceDefinitions(defs))
-}
-
 func (c *Controller) deleteHeadlessProxy(statefulset *appsv1.StatefulSet) error {
 	return c.vanClient.KubeClient.AppsV1().StatefulSets(c.vanClient.Namespace).Delete(statefulset.ObjectMeta.Name, &metav1.DeleteOptions{})
 }
@@ -592,6 +827,59 @@ func (c *Controller) updateHeadlessProxies() {
 	}
 }
 
+// updateHeadlessProxyFor does the same as updateHeadlessProxies for a
+// single address
+func (c *Controller) updateHeadlessProxyFor(address string) error {
+	sb := c.bindings[address]
+	if sb != nil && sb.headless != nil {
+		if err := c.ensureHeadlessProxyFor(sb, nil); err != nil {
+			return err
+		}
+	}
+	proxies := c.headlessInformer.GetStore().List()
+	for _, v := range proxies {
+		proxy := v.(*appsv1.StatefulSet)
+		if proxy.Spec.ServiceName == address && (sb == nil || sb.headless == nil) {
+			if err := c.deleteHeadlessProxy(proxy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileAddress brings the bridges, service, external access and
+// headless proxy for a single address in line with its bindings, or
+// removes them if it is no longer defined, without revisiting those of
+// any other address
+func (c *Controller) reconcileAddress(address string) error {
+	if c.updateBridgeFragment(address) {
+		c.queueBridgeConfig()
+	}
+	if sb := c.bindings[address]; sb != nil {
+		if err := c.ensureServiceFor(sb); err != nil {
+			return err
+		}
+	} else {
+		obj, exists, err := c.svcInformer.GetStore().GetByKey(c.namespaced(address))
+		if err != nil {
+			return fmt.Errorf("Error reading service %s from cache: %s", address, err)
+		} else if exists {
+			svc, ok := obj.(*corev1.Service)
+			if !ok {
+				return fmt.Errorf("Expected Service for %s but got %#v", address, obj)
+			}
+			if isOwned(svc) {
+				if err := c.deleteService(svc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	c.updateExternalAccessFor(address)
+	return c.updateHeadlessProxyFor(address)
+}
+
 func (c *Controller) processNextEvent() bool {
 
 	obj, shutdown := c.events.Get()
@@ -602,6 +890,8 @@ func (c *Controller) processNextEvent() bool {
 
 	err := func(obj interface{}) error {
 		defer c.events.Done(obj)
+		c.progress.Start()
+		defer c.progress.Finish()
 
 		var ok bool
 		var key string
@@ -614,48 +904,56 @@ func (c *Controller) processNextEvent() bool {
 			switch category {
 			case "servicedefs":
 				log.Printf("Service definitions have changed")
-				//get the configmap, parse the json, check against the current servicebindings map
-				obj, exists, err := c.svcDefInformer.GetStore().GetByKey(name)
-				if err != nil {
-					return fmt.Errorf("Error reading skupper-services from cache: %s", err)
-				} else if exists {
+				//merge skupper-services and its shards, parse the json, check against the current servicebindings map
+				definitions, exists := getServiceDefinitionsFrom(c.svcDefInformer.GetStore())
+				if exists {
 					var portAllocations map[string]int
-					if c.bindings == nil {
+					initialising := c.bindings == nil
+					if initialising {
+						var err error
 						portAllocations, err = c.initialiseServiceBindingsMap()
 						if err != nil {
 							return err
 						}
 					}
-					cm, ok := obj.(*corev1.ConfigMap)
-					if !ok {
-						return fmt.Errorf("Expected ConfigMap for %s but got %#v", name, obj)
-					}
-					c.updateServiceSync(cm)
-					if cm.Data != nil && len(cm.Data) > 0 {
-						for k, v := range cm.Data {
-							si := types.ServiceInterface{}
-							err := jsonencoding.Unmarshal([]byte(v), &si)
-							if err == nil {
-								c.updateServiceBindings(si, portAllocations)
-							} else {
-								log.Printf("Could not parse service definition for %s: %s", k, err)
-							}
+					changed, removed := c.updateServiceDefinitions(definitions)
+					c.serviceSyncDefinitionsUpdated(changed, removed)
+					for address, si := range changed {
+						err := c.updateServiceBindings(si, portAllocations)
+						if err != nil {
+							log.Printf("Could not update service bindings for %s: %s", address, err)
 						}
-						for k, v := range c.bindings {
-							_, ok := cm.Data[k]
-							if !ok {
-								c.deleteServiceBindings(k, v)
-							}
+					}
+					for _, address := range removed {
+						c.deleteServiceBindings(address, c.bindings[address])
+					}
+					if initialising {
+						// check everything, to remove anything left
+						// for addresses deleted while not running
+						c.updateBridgeFragments()
+						c.updateBridgeConfig(c.namespaced("skupper-internal"))
+						c.updateActualServices()
+						c.updateHeadlessProxies()
+					} else {
+						// only the changed addresses need be reconciled
+						for address := range changed {
+							c.events.Add("address@" + address)
 						}
-					} else if len(c.bindings) > 0 {
-						for k, v := range c.bindings {
-							c.deleteServiceBindings(k, v)
+						for _, address := range removed {
+							c.events.Add("address@" + address)
 						}
 					}
 				}
-				c.updateBridgeConfig(c.namespaced("skupper-internal"))
-				c.updateActualServices()
-				c.updateHeadlessProxies()
+			case "address":
+				if c.bindings == nil {
+					//not yet initialised
+					return nil
+				}
+				log.Printf("Reconciling skupper address %s", name)
+				err := c.reconcileAddress(name)
+				if err != nil {
+					return err
+				}
 			case "bridges":
 				if c.bindings == nil {
 					//not yet initialised
@@ -714,8 +1012,32 @@ func (c *Controller) processNextEvent() bool {
 				}
 			case "targetpods":
 				log.Printf("Got targetpods event %s", name)
+				if c.bindings == nil {
+					//not yet initialised
+					return nil
+				}
 				//name is the address of the skupper service
-				c.updateBridgeConfig(c.namespaced("skupper-internal"))
+				if c.updateBridgeFragment(name) {
+					c.queueBridgeConfig()
+				}
+			case "targethost":
+				log.Printf("Resolved addresses changed for host target of %s", name)
+				if c.bindings == nil {
+					//not yet initialised
+					return nil
+				}
+				if c.updateBridgeFragment(name) {
+					c.queueBridgeConfig()
+				}
+			case "sitepriorities":
+				log.Printf("Service priorities changed for site %s", name)
+				if c.bindings == nil {
+					//not yet initialised
+					return nil
+				}
+				if c.updateBridgeFragments() {
+					c.queueBridgeConfig()
+				}
 			case "statefulset":
 				log.Printf("Got statefulset proxy event %s", name)
 				obj, exists, err := c.headlessInformer.GetStore().GetByKey(name)
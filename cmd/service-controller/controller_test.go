@@ -0,0 +1,339 @@
+package main
+
+import (
+	jsonencoding "encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1informer "k8s.io/client-go/informers/apps/v1"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+const testNamespace = "test"
+
+func setServiceDefinition(tb testing.TB, cm *corev1.ConfigMap, address string, port int, labels map[string]string) {
+	encoded, err := jsonencoding.Marshal(types.ServiceInterface{
+		Address:  address,
+		Protocol: "tcp",
+		Port:     port,
+		Labels:   labels,
+		Targets: []types.ServiceInterfaceTarget{
+			{Name: address, Service: address + "-backend"},
+		},
+	})
+	assert.Assert(tb, err)
+	cm.Data[address] = string(encoded)
+}
+
+// newTestController returns a controller for a site with the given
+// number of services defined, whose informers are not run but have
+// their stores populated by the test
+func newTestController(tb testing.TB, services int) (*Controller, *corev1.ConfigMap) {
+	config := qdr.InitialConfig("test-router", "{}", false)
+	internal := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "skupper-internal", Namespace: testNamespace},
+	}
+	assert.Assert(tb, config.WriteToConfigMap(internal))
+	definitions := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: types.ServiceInterfaceConfigMap, Namespace: testNamespace},
+		Data:       map[string]string{},
+	}
+	for i := 0; i < services; i++ {
+		setServiceDefinition(tb, definitions, fmt.Sprintf("svc%d", i), 8080, nil)
+	}
+	kubeClient := fake.NewSimpleClientset(internal, definitions)
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	c := &Controller{
+		origin: "site-a",
+		vanClient: &client.VanClient{
+			Namespace:  testNamespace,
+			KubeClient: kubeClient,
+		},
+		bridgeDefInformer: corev1informer.NewConfigMapInformer(kubeClient, testNamespace, time.Second*30, indexers),
+		svcDefInformer:    corev1informer.NewConfigMapInformer(kubeClient, testNamespace, time.Second*30, indexers),
+		svcInformer:       corev1informer.NewServiceInformer(kubeClient, testNamespace, time.Second*30, indexers),
+		headlessInformer:  appsv1informer.NewStatefulSetInformer(kubeClient, testNamespace, time.Second*30, indexers),
+		eventRecorder:     &record.FakeRecorder{},
+		events:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test"),
+		ports:             newFreePorts(),
+		byOrigin:          map[string]map[string]types.ServiceInterface{},
+		localServices:     map[string]types.ServiceInterface{},
+		byName:            map[string]types.ServiceInterface{},
+		desiredServices:   map[string]types.ServiceInterface{},
+		heardFrom:         map[string]time.Time{},
+		conflicts:         map[string]map[string]bool{},
+		sitePriorities:    newSitePriorities(),
+	}
+	assert.Assert(tb, c.bridgeDefInformer.GetStore().Add(internal))
+	assert.Assert(tb, c.svcDefInformer.GetStore().Add(definitions))
+	return c, definitions
+}
+
+// changeServiceDefinitions queues the event the informer would for a
+// change to skupper-services
+func changeServiceDefinitions(tb testing.TB, c *Controller, definitions *corev1.ConfigMap) {
+	assert.Assert(tb, c.svcDefInformer.GetStore().Update(definitions))
+	c.events.Add("servicedefs@" + c.namespaced(types.ServiceInterfaceConfigMap))
+}
+
+func processEvents(c *Controller) {
+	for c.events.Len() > 0 {
+		c.processNextEvent()
+	}
+}
+
+// syncServices updates the service informer's store with the services
+// the controller has created, as the informer would if it were run
+func syncServices(tb testing.TB, c *Controller) {
+	list, err := c.vanClient.KubeClient.CoreV1().Services(testNamespace).List(metav1.ListOptions{})
+	assert.Assert(tb, err)
+	items := []interface{}{}
+	for i := range list.Items {
+		items = append(items, &list.Items[i])
+	}
+	assert.Assert(tb, c.svcInformer.GetStore().Replace(items, ""))
+}
+
+func getBridges(t *testing.T, c *Controller) qdr.BridgeConfig {
+	cm, err := c.vanClient.KubeClient.CoreV1().ConfigMaps(testNamespace).Get("skupper-internal", metav1.GetOptions{})
+	assert.Assert(t, err)
+	config, err := qdr.GetRouterConfigFromConfigMap(cm)
+	assert.Assert(t, err)
+	return config.Bridges
+}
+
+func TestIncrementalReconciliation(t *testing.T) {
+	os.Setenv("OWNER_NAME", "skupper-service-controller")
+	os.Setenv("OWNER_UID", "00000000-0000-0000-0000-000000000000")
+	defer os.Unsetenv("OWNER_NAME")
+	defer os.Unsetenv("OWNER_UID")
+	c, definitions := newTestController(t, 3)
+	services := c.vanClient.KubeClient.CoreV1().Services(testNamespace)
+
+	changeServiceDefinitions(t, c, definitions)
+	processEvents(c)
+	syncServices(t, c)
+	for _, address := range []string{"svc0", "svc1", "svc2"} {
+		_, err := services.Get(address, metav1.GetOptions{})
+		assert.Assert(t, err)
+	}
+	bridges := getBridges(t, c)
+	assert.Equal(t, len(bridges.TcpListeners), 3)
+	assert.Equal(t, len(bridges.TcpConnectors), 3)
+
+	// only the changed address is queued for reconciliation
+	setServiceDefinition(t, definitions, "svc1", 9090, nil)
+	changeServiceDefinitions(t, c, definitions)
+	assert.Assert(t, c.processNextEvent())
+	assert.Equal(t, c.events.Len(), 1)
+	key, _ := c.events.Get()
+	assert.Equal(t, key, "address@svc1")
+	c.events.Done(key)
+	c.events.Add(key)
+	processEvents(c)
+	syncServices(t, c)
+	svc, err := services.Get("svc1", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, svc.Spec.Ports[0].Port, int32(9090))
+	bridges = getBridges(t, c)
+	assert.Equal(t, bridges.TcpConnectors["svc1@svc1-backend"].Port, "9090")
+	assert.Equal(t, bridges.TcpConnectors["svc0@svc0-backend"].Port, "8080")
+
+	// a removed address has its service and bridges removed
+	delete(definitions.Data, "svc2")
+	changeServiceDefinitions(t, c, definitions)
+	processEvents(c)
+	_, err = services.Get("svc2", metav1.GetOptions{})
+	assert.Assert(t, errors.IsNotFound(err))
+	_, err = services.Get("svc0", metav1.GetOptions{})
+	assert.Assert(t, err)
+	bridges = getBridges(t, c)
+	assert.Equal(t, len(bridges.TcpListeners), 2)
+	assert.Equal(t, len(bridges.TcpConnectors), 2)
+	_, ok := bridges.TcpConnectors["svc2@svc2-backend"]
+	assert.Assert(t, !ok)
+}
+
+func TestShardedServiceDefinitions(t *testing.T) {
+	c, definitions := newTestController(t, 0)
+	definitions.ObjectMeta.Annotations = map[string]string{types.ServiceDefinitionShardsQualifier: "2"}
+	labels := map[string]string{types.InternalTypeQualifier: types.TypeServiceDefinitions}
+	for i, address := range []string{"svc0", "svc1"} {
+		shard := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%d", types.ServiceInterfaceConfigMap, i), Namespace: testNamespace, Labels: labels},
+			Data:       map[string]string{},
+		}
+		setServiceDefinition(t, shard, address, 8080, nil)
+		assert.Assert(t, c.svcDefInformer.GetStore().Add(shard))
+	}
+	// a definition written directly to skupper-services takes precedence
+	setServiceDefinition(t, definitions, "svc1", 9090, nil)
+	changeServiceDefinitions(t, c, definitions)
+	processEvents(c)
+
+	assert.Equal(t, len(c.bindings), 2)
+	assert.Equal(t, c.bindings["svc0"].publicPort(), 8080)
+	assert.Equal(t, c.bindings["svc1"].publicPort(), 9090)
+	bridges := getBridges(t, c)
+	assert.Equal(t, len(bridges.TcpConnectors), 2)
+}
+
+func TestBridgeConfigCoalesced(t *testing.T) {
+	c, definitions := newTestController(t, 3)
+	changeServiceDefinitions(t, c, definitions)
+	processEvents(c)
+	kubeClient := c.vanClient.KubeClient.(*fake.Clientset)
+	writes := func() int {
+		count := 0
+		for _, action := range kubeClient.Actions() {
+			if action.GetVerb() == "update" && action.GetResource().Resource == "configmaps" {
+				count++
+			}
+		}
+		return count
+	}
+	initial := writes()
+
+	// changes to the targets of several services are held back and
+	// written together
+	c.bridgesDelay = time.Hour
+	for _, address := range []string{"svc0", "svc1"} {
+		c.bindings[address].targets[address+"-backend"].egressPorts[""] = 9090
+		c.events.Add("targethost@" + address)
+	}
+	processEvents(c)
+	assert.Equal(t, writes(), initial)
+	assert.Equal(t, getBridges(t, c).TcpConnectors["svc0@svc0-backend"].Port, "8080")
+	flushBridgeConfig(c)
+	assert.Equal(t, writes(), initial+1)
+	bridges := getBridges(t, c)
+	assert.Equal(t, bridges.TcpConnectors["svc0@svc0-backend"].Port, "9090")
+	assert.Equal(t, bridges.TcpConnectors["svc1@svc1-backend"].Port, "9090")
+	flushBridgeConfig(c)
+	assert.Equal(t, writes(), initial+1)
+
+	// a change made to skupper-internal by anything else is compared
+	// in full, restoring the bridges it removed
+	internal, err := kubeClient.CoreV1().ConfigMaps(testNamespace).Get("skupper-internal", metav1.GetOptions{})
+	assert.Assert(t, err)
+	config, err := qdr.GetRouterConfigFromConfigMap(internal)
+	assert.Assert(t, err)
+	config.Bridges = qdr.NewBridgeConfig()
+	assert.Assert(t, config.WriteToConfigMap(internal))
+	internal.ObjectMeta.ResourceVersion = "2"
+	internal, err = kubeClient.CoreV1().ConfigMaps(testNamespace).Update(internal)
+	assert.Assert(t, err)
+	assert.Assert(t, c.bridgeDefInformer.GetStore().Update(internal))
+	flushBridgeConfig(c)
+	assert.Equal(t, len(getBridges(t, c).TcpConnectors), 3)
+}
+
+// flushBridgeConfig writes the bridge changes held back by
+// bridgesDelay to skupper-internal, as the delayed event would
+func flushBridgeConfig(c *Controller) {
+	c.updateBridgeConfig(c.namespaced("skupper-internal"))
+}
+
+// BenchmarkServiceDefinitionChange measures the cost of reconciling a
+// change to a single service definition. Finding which definition
+// changed compares each in skupper-services with that last seen, but
+// nothing else should grow with the number of other services: only the
+// changed service's bridges, address and profiles are merged into the
+// router's configuration. Rewriting skupper-internal, which holds
+// those of every service, is shared by all changes made within
+// bridgesDelay, so is measured separately by BenchmarkBridgeConfigWrite.
+func BenchmarkServiceDefinitionChange(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	changes := map[string]func(i int) (int, map[string]string){
+		"labels": func(i int) (int, map[string]string) {
+			return 8080, map[string]string{"version": fmt.Sprint(i % 2)}
+		},
+		"port": func(i int) (int, map[string]string) {
+			return 8081 + i%2, nil
+		},
+	}
+	for _, change := range []string{"labels", "port"} {
+		for _, services := range []int{10, 100, 1000, 2000} {
+			b.Run(fmt.Sprintf("%s/services=%d", change, services), func(b *testing.B) {
+				c, definitions := newTestController(b, services)
+				changeServiceDefinitions(b, c, definitions)
+				processEvents(c)
+				syncServices(b, c)
+				c.bridgesDelay = time.Hour
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					port, labels := changes[change](i)
+					setServiceDefinition(b, definitions, "svc0", port, labels)
+					changeServiceDefinitions(b, c, definitions)
+					processEvents(c)
+				}
+				b.StopTimer()
+				flushBridgeConfig(c)
+			})
+		}
+	}
+}
+
+// BenchmarkTargetChange measures the cost of reconciling a change in
+// the targets of a single service, which likewise should not grow with
+// the number of services, the write of skupper-internal again being
+// shared by all the changes made within bridgesDelay
+func BenchmarkTargetChange(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, services := range []int{10, 100, 1000, 2000} {
+		b.Run(fmt.Sprintf("services=%d", services), func(b *testing.B) {
+			c, definitions := newTestController(b, services)
+			changeServiceDefinitions(b, c, definitions)
+			processEvents(c)
+			c.bridgesDelay = time.Hour
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.bindings["svc0"].targets["svc0-backend"].egressPorts[""] = 8081 + i%2
+				c.events.Add("targethost@svc0")
+				processEvents(c)
+			}
+			b.StopTimer()
+			flushBridgeConfig(c)
+		})
+	}
+}
+
+// BenchmarkBridgeConfigWrite measures the cost of writing the bridges
+// to skupper-internal after a change, which grows with the number of
+// services but is incurred at most once per bridgesDelay
+func BenchmarkBridgeConfigWrite(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, services := range []int{10, 100, 1000, 2000} {
+		b.Run(fmt.Sprintf("services=%d", services), func(b *testing.B) {
+			c, definitions := newTestController(b, services)
+			changeServiceDefinitions(b, c, definitions)
+			processEvents(c)
+			c.tlsPending = false
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.bindings["svc0"].targets["svc0-backend"].egressPorts[""] = 8081 + i%2
+				c.updateBridgeFragment("svc0")
+				flushBridgeConfig(c)
+			}
+		})
+	}
+}
@@ -4,20 +4,23 @@ import (
 	jsonencoding "encoding/json"
 	"fmt"
 	"log"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	appsv1informer "k8s.io/client-go/informers/apps/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/health"
 	"github.com/skupperproject/skupper/pkg/kube"
 	"github.com/skupperproject/skupper/pkg/utils"
 )
@@ -37,9 +40,11 @@ type DefinitionMonitor struct {
 	annotated            map[string]types.ServiceInterface
 	annotatedDeployments map[string]string
 	annotatedServices    map[string]string
+	eventRecorder        record.EventRecorder
+	progress             health.Progress
 }
 
-func newDefinitionMonitor(origin string, client *client.VanClient, svcDefInformer cache.SharedIndexInformer, svcInformer cache.SharedIndexInformer) *DefinitionMonitor {
+func newDefinitionMonitor(origin string, client *client.VanClient, svcDefInformer cache.SharedIndexInformer, svcInformer cache.SharedIndexInformer, recorder record.EventRecorder) *DefinitionMonitor {
 	monitor := &DefinitionMonitor{
 		origin:               origin,
 		vanClient:            client,
@@ -49,6 +54,7 @@ func newDefinitionMonitor(origin string, client *client.VanClient, svcDefInforme
 		annotated:            make(map[string]types.ServiceInterface),
 		annotatedDeployments: make(map[string]string),
 		annotatedServices:    make(map[string]string),
+		eventRecorder:        recorder,
 	}
 	monitor.statefulSetInformer = appsv1informer.NewStatefulSetInformer(
 		client.KubeClient,
@@ -64,7 +70,7 @@ func newDefinitionMonitor(origin string, client *client.VanClient, svcDefInforme
 
 	monitor.statefulSetInformer.AddEventHandler(newEventHandlerFor(monitor.events, "statefulsets", AnnotatedKey, StatefulSetResourceVersionTest))
 	monitor.deploymentInformer.AddEventHandler(newEventHandlerFor(monitor.events, "deployments", AnnotatedKey, DeploymentResourceVersionTest))
-	monitor.svcDefInformer.AddEventHandler(newEventHandlerFor(monitor.events, "servicedefs", AnnotatedKey, ConfigMapResourceVersionTest))
+	monitor.svcDefInformer.AddEventHandler(newEventHandlerFor(monitor.events, "servicedefs@"+client.Namespace+"/"+types.ServiceInterfaceConfigMap, FixedKey, ConfigMapResourceVersionTest))
 	monitor.svcInformer.AddEventHandler(newEventHandlerFor(monitor.events, "services", AnnotatedKey, ServiceResourceVersionTest))
 
 	return monitor
@@ -108,6 +114,49 @@ func deducePort(deployment *appsv1.Deployment) int {
 	}
 }
 
+// deduceNamedPorts reads named ports from the port annotation on a
+// deployment, given in the form name:port,name:port
+func deduceNamedPorts(deployment *appsv1.Deployment) []types.ServicePort {
+	if value, ok := deployment.ObjectMeta.Annotations[types.PortQualifier]; ok && strings.Contains(value, ":") {
+		ports, err := client.ParseServicePorts([]string{value})
+		if err != nil {
+			log.Printf("Invalid port annotation on deployment %s: %s", deployment.ObjectMeta.Name, err)
+			return nil
+		}
+		return ports
+	}
+	return nil
+}
+
+func deducePortsFromService(service *corev1.Service) []types.ServicePort {
+	ports := []types.ServicePort{}
+	if len(service.Spec.Ports) > 1 {
+		for _, port := range service.Spec.Ports {
+			ports = append(ports, types.ServicePort{
+				Name: port.Name,
+				Port: int(port.Port),
+			})
+		}
+	}
+	return ports
+}
+
+func deduceTargetPortsFromService(service *corev1.Service) map[string]int {
+	targetPorts := map[string]int{}
+	if hasOriginalTargetPort(*service) {
+		targetPorts = kube.ParsePortAnnotation(service.Annotations[types.OriginalTargetPortQualifier])
+	}
+	for _, port := range service.Spec.Ports {
+		if _, ok := targetPorts[port.Name]; !ok && port.TargetPort.IntValue() != 0 && port.TargetPort.IntValue() != int(port.Port) {
+			targetPorts[port.Name] = port.TargetPort.IntValue()
+		}
+	}
+	if len(targetPorts) == 0 {
+		return nil
+	}
+	return targetPorts
+}
+
 func deducePortFromService(service *corev1.Service) int {
 	if len(service.Spec.Ports) > 0 {
 		return int(service.Spec.Ports[0].Port)
@@ -126,7 +175,10 @@ func updateAnnotatedServiceDefinition(actual *types.ServiceInterface, desired *t
 	if actual.Origin != "annotation" {
 		return false
 	}
-	if actual.Protocol != desired.Protocol || actual.Port != desired.Port {
+	if actual.Protocol != desired.Protocol || actual.Port != desired.Port || actual.Distribution != desired.Distribution {
+		return true
+	}
+	if !reflect.DeepEqual(actual.Ports, desired.Ports) {
 		return true
 	}
 	if len(actual.Targets) != len(desired.Targets) {
@@ -136,22 +188,37 @@ func updateAnnotatedServiceDefinition(actual *types.ServiceInterface, desired *t
 		nameChanged := actual.Targets[0].Name != desired.Targets[0].Name
 		selectorChanged := actual.Targets[0].Selector != desired.Targets[0].Selector
 		targetPortChanged := actual.Targets[0].TargetPort != desired.Targets[0].TargetPort
-		if nameChanged || selectorChanged || targetPortChanged {
+		targetPortsChanged := !reflect.DeepEqual(actual.Targets[0].TargetPorts, desired.Targets[0].TargetPorts)
+		if nameChanged || selectorChanged || targetPortChanged || targetPortsChanged {
 			return true
 		}
 	}
 	return false
 }
 
+// isValidDistribution returns true for the distributions a service may
+// be given, as checked when one is created through the client
+func isValidDistribution(distribution string) bool {
+	return distribution == "" || distribution == types.DistributionBalanced || distribution == types.DistributionClosest
+}
+
+func invalidDistributionMessage(distribution string) string {
+	return fmt.Sprintf("Not exposed; %q is not a valid value for the %s annotation, choose '%s' or '%s'", distribution, types.DistributionQualifier, types.DistributionBalanced, types.DistributionClosest)
+}
+
 func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedDeployment(deployment *appsv1.Deployment) (types.ServiceInterface, bool) {
 	var svc types.ServiceInterface
 	if protocol, ok := deployment.ObjectMeta.Annotations[types.ProxyQualifier]; ok {
-		if port := deducePort(deployment); port != 0 {
+		if ports := deduceNamedPorts(deployment); len(ports) > 0 {
+			svc.Ports = ports
+			svc.Port = ports[0].Port
+		} else if port := deducePort(deployment); port != 0 {
 			svc.Port = int(port)
 		} else if protocol == "http" {
 			svc.Port = 80
 		} else {
 			log.Printf("Ignoring annotated deployment %s; cannot deduce port", deployment.ObjectMeta.Name)
+			m.eventRecorder.Event(deployment, corev1.EventTypeWarning, EventServiceIgnored, "Not exposed; cannot deduce port, specify one with the "+types.PortQualifier+" annotation")
 			return svc, false
 		}
 		svc.Protocol = protocol
@@ -160,6 +227,14 @@ func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedDeployment(deployme
 		} else {
 			svc.Address = deployment.ObjectMeta.Name
 		}
+		if distribution, ok := deployment.ObjectMeta.Annotations[types.DistributionQualifier]; ok {
+			if !isValidDistribution(distribution) {
+				log.Printf("Ignoring annotated deployment %s; invalid distribution %q", deployment.ObjectMeta.Name, distribution)
+				m.eventRecorder.Event(deployment, corev1.EventTypeWarning, EventServiceIgnored, invalidDistributionMessage(distribution))
+				return types.ServiceInterface{}, false
+			}
+			svc.Distribution = distribution
+		}
 
 		selector := ""
 		if deployment.Spec.Selector != nil {
@@ -184,12 +259,23 @@ func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedService(service *co
 		if port := deducePortFromService(service); port != 0 {
 			svc.Port = int(port)
 		}
+		if ports := deducePortsFromService(service); len(ports) > 0 {
+			svc.Ports = ports
+		}
 		svc.Protocol = protocol
 		if address, ok := service.ObjectMeta.Annotations[types.AddressQualifier]; ok {
 			svc.Address = address
 		} else {
 			svc.Address = service.ObjectMeta.Name
 		}
+		if distribution, ok := service.ObjectMeta.Annotations[types.DistributionQualifier]; ok {
+			if !isValidDistribution(distribution) {
+				log.Printf("Ignoring annotated service %s; invalid distribution %q", service.ObjectMeta.Name, distribution)
+				m.eventRecorder.Event(service, corev1.EventTypeWarning, EventServiceIgnored, invalidDistributionMessage(distribution))
+				return types.ServiceInterface{}, false
+			}
+			svc.Distribution = distribution
+		}
 		if target, ok := service.ObjectMeta.Annotations[types.TargetServiceQualifier]; ok {
 			port, err := kube.GetPortForServiceTarget(target, m.vanClient.Namespace, m.vanClient.KubeClient)
 			if err != nil {
@@ -202,6 +288,7 @@ func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedService(service *co
 					svc.Port = 80
 				} else {
 					log.Printf("Ignoring annotated service %s; cannot deduce port", service.ObjectMeta.Name)
+					m.eventRecorder.Event(service, corev1.EventTypeWarning, EventServiceIgnored, "Not exposed; cannot deduce port, the service defines none")
 					return svc, false
 				}
 			}
@@ -221,6 +308,7 @@ func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedService(service *co
 					svc.Port = 80
 				} else {
 					log.Printf("Ignoring annotated service %s; cannot deduce port", service.ObjectMeta.Name)
+					m.eventRecorder.Event(service, corev1.EventTypeWarning, EventServiceIgnored, "Not exposed; cannot deduce port, the service defines none")
 					return svc, false
 				}
 			}
@@ -237,7 +325,9 @@ func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedService(service *co
 				Name:     service.ObjectMeta.Name,
 				Selector: svcSelector,
 			}
-			if !hasOriginalTargetPort(*service) {
+			if len(svc.Ports) > 0 {
+				target.TargetPorts = deduceTargetPortsFromService(service)
+			} else if !hasOriginalTargetPort(*service) {
 				// If getting target port from new annotated service, deduce target port from service
 				if targetPort := deduceTargetPortFromService(service); targetPort != 0 {
 					target.TargetPort = targetPort
@@ -253,6 +343,7 @@ func (m *DefinitionMonitor) getServiceDefinitionFromAnnotatedService(service *co
 			}
 		} else {
 			log.Printf("Ignoring annotated service %s; no selector defined", service.ObjectMeta.Name)
+			m.eventRecorder.Event(service, corev1.EventTypeWarning, EventServiceIgnored, "Not exposed; no selector defined and no "+types.TargetServiceQualifier+" annotation")
 			return svc, false
 		}
 		svc.Origin = "annotation"
@@ -297,30 +388,25 @@ func (m *DefinitionMonitor) deleteServiceDefinitionForAnnotatedObject(name strin
 }
 
 func (m *DefinitionMonitor) restoreServiceDefinitions(service *corev1.Service) error {
-	updated := false
-	if hasOriginalSelector(*service) {
-		updated = true
-		originalSelector := service.ObjectMeta.Annotations[types.OriginalSelectorQualifier]
-		delete(service.ObjectMeta.Annotations, types.OriginalSelectorQualifier)
-		service.Spec.Selector = utils.LabelToMap(originalSelector)
-	}
-	if hasOriginalTargetPort(*service) {
-		updated = true
-		originalTargetPort, _ := strconv.Atoi(service.ObjectMeta.Annotations[types.OriginalTargetPortQualifier])
-		delete(service.ObjectMeta.Annotations, types.OriginalTargetPortQualifier)
-		service.Spec.Ports[0].TargetPort = intstr.FromInt(originalTargetPort)
-	}
-	if hasOriginalAssigned(*service) {
-		updated = true
-		delete(service.ObjectMeta.Annotations, types.OriginalAssignedQualifier)
-	}
-	if updated {
+	if kube.RestoreServiceDefinition(service) {
 		_, err := m.vanClient.KubeClient.CoreV1().Services(m.vanClient.Namespace).Update(service)
 		return err
 	}
 	return nil
 }
 
+// recordServiceDefinitionsEvent records an event against the
+// skupper-services configmap, if it has been read
+func (m *DefinitionMonitor) recordServiceDefinitionsEvent(eventtype string, reason string, message string) {
+	obj, exists, err := m.svcDefInformer.GetStore().GetByKey(m.vanClient.Namespace + "/" + types.ServiceInterfaceConfigMap)
+	if err != nil || !exists {
+		return
+	}
+	if cm, ok := obj.(*corev1.ConfigMap); ok {
+		m.eventRecorder.Event(cm, eventtype, reason, message)
+	}
+}
+
 func (m *DefinitionMonitor) processNextEvent() bool {
 
 	obj, shutdown := m.events.Get()
@@ -331,6 +417,8 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 
 	err := func(obj interface{}) error {
 		defer m.events.Done(obj)
+		m.progress.Start()
+		defer m.progress.Finish()
 
 		var ok bool
 		var key string
@@ -343,17 +431,11 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 			switch category {
 			case "servicedefs":
 				log.Print("[DefMon] Service definitions have changed")
-				//get the configmap, parse the json, check against the current servicebindings map
-				obj, exists, err := m.svcDefInformer.GetStore().GetByKey(name)
-				if err != nil {
-					return fmt.Errorf("Error reading skupper-services from cache: %s", err)
-				} else if exists {
-					cm, ok := obj.(*corev1.ConfigMap)
-					if !ok {
-						return fmt.Errorf("Expected ConfigMap for %s but got %#v", name, obj)
-					}
-					if cm.Data != nil && len(cm.Data) > 0 {
-						for k, v := range cm.Data {
+				//merge skupper-services and its shards, parse the json, check against the current servicebindings map
+				definitions, exists := getServiceDefinitionsFrom(m.svcDefInformer.GetStore())
+				if exists {
+					if len(definitions) > 0 {
+						for k, v := range definitions {
 							svc := types.ServiceInterface{}
 							err := jsonencoding.Unmarshal([]byte(v), &svc)
 							if err == nil {
@@ -367,13 +449,13 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 							}
 						}
 						for k, v := range m.headless {
-							_, ok := cm.Data[v.Address]
+							_, ok := definitions[v.Address]
 							if !ok {
 								delete(m.headless, k)
 							}
 						}
 						for k, v := range m.annotated {
-							_, ok := cm.Data[v.Address]
+							_, ok := definitions[v.Address]
 							if !ok {
 								delete(m.annotated, k)
 							}
@@ -401,7 +483,11 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 								svc,
 							}
 							deleted := []string{}
-							kube.UpdateSkupperServices(changed, deleted, m.origin, m.vanClient.Namespace, m.vanClient.KubeClient)
+							if err := kube.UpdateSkupperServices(changed, deleted, m.origin, m.vanClient.Namespace, m.vanClient.KubeClient); err != nil {
+								m.eventRecorder.Event(statefulset, corev1.EventTypeWarning, EventServiceExposeFailed, err.Error())
+								m.events.AddRateLimited(key)
+								return fmt.Errorf("Failed to update service definition for statefulset %s: %s", name, err)
+							}
 						}
 					}
 				} else {
@@ -415,7 +501,11 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 						deleted := []string{
 							svc.Address,
 						}
-						kube.UpdateSkupperServices(changed, deleted, m.origin, m.vanClient.Namespace, m.vanClient.KubeClient)
+						if err := kube.UpdateSkupperServices(changed, deleted, m.origin, m.vanClient.Namespace, m.vanClient.KubeClient); err != nil {
+							m.recordServiceDefinitionsEvent(corev1.EventTypeWarning, EventServiceSyncFailed, fmt.Sprintf("Failed to remove service definition for deleted statefulset %s: %s", unqualified, err))
+							m.events.AddRateLimited(key)
+							return fmt.Errorf("Failed to remove service definition for deleted statefulset %s: %s", name, err)
+						}
 					}
 				}
 			case "deployments":
@@ -441,8 +531,10 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 							deleted := []string{}
 							err = kube.UpdateSkupperServices(changed, deleted, "annotation", m.vanClient.Namespace, m.vanClient.KubeClient)
 							if err != nil {
+								m.eventRecorder.Event(deployment, corev1.EventTypeWarning, EventServiceExposeFailed, err.Error())
 								return fmt.Errorf("failed to update service definition for annotated deployment %s: %s", name, err)
 							}
+							m.eventRecorder.Event(deployment, corev1.EventTypeNormal, EventServiceExposed, describeExposed(desired))
 						}
 						address, ok := m.annotatedDeployments[name]
 						if ok {
@@ -492,8 +584,10 @@ func (m *DefinitionMonitor) processNextEvent() bool {
 							deleted := []string{}
 							err = kube.UpdateSkupperServices(changed, deleted, "annotation", m.vanClient.Namespace, m.vanClient.KubeClient)
 							if err != nil {
+								m.eventRecorder.Event(service, corev1.EventTypeWarning, EventServiceExposeFailed, err.Error())
 								return fmt.Errorf("failed to update service definition for annotated service %s: %s", name, err)
 							}
+							m.eventRecorder.Event(service, corev1.EventTypeNormal, EventServiceExposed, describeExposed(desired))
 						}
 						address, ok := m.annotatedServices[name]
 						if ok {
@@ -12,6 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	"testing"
 )
 
@@ -37,7 +38,8 @@ func TestGetServiceDefinitionFromAnnotatedDeployment(t *testing.T) {
 	}
 
 	dm := &DefinitionMonitor{
-		vanClient: vanClient,
+		vanClient:     vanClient,
+		eventRecorder: record.NewFakeRecorder(100),
 	}
 
 	// Help preparing sample deployments to compose test table
@@ -203,7 +205,8 @@ func TestGetServiceDefinitionFromAnnotatedService(t *testing.T) {
 	}
 
 	dm := &DefinitionMonitor{
-		vanClient: vanClient,
+		vanClient:     vanClient,
+		eventRecorder: record.NewFakeRecorder(100),
 	}
 
 	// Helper used to prepare test table
@@ -563,3 +566,57 @@ func TestDeduceTargetPortFromService(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnotatedDistribution(t *testing.T) {
+	dm := &DefinitionMonitor{
+		vanClient: &client.VanClient{
+			Namespace:  "test",
+			KubeClient: fake.NewSimpleClientset(),
+		},
+		eventRecorder: record.NewFakeRecorder(100),
+	}
+	newDeployment := func(distribution string) *v1.Deployment {
+		return &v1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dep1",
+				Namespace: "test",
+				Annotations: map[string]string{
+					types.ProxyQualifier:        "tcp",
+					types.PortQualifier:         "8080",
+					types.DistributionQualifier: distribution,
+				},
+			},
+			Spec: v1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "dep1"}},
+			},
+		}
+	}
+	newService := func(distribution string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "svc1",
+				Namespace: "test",
+				Annotations: map[string]string{
+					types.ProxyQualifier:        "tcp",
+					types.DistributionQualifier: distribution,
+				},
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: map[string]string{"app": "svc1"},
+				Ports:    []corev1.ServicePort{{Port: 8080}},
+			},
+		}
+	}
+	for _, distribution := range []string{types.DistributionBalanced, types.DistributionClosest} {
+		service, ok := dm.getServiceDefinitionFromAnnotatedDeployment(newDeployment(distribution))
+		assert.Assert(t, ok)
+		assert.Equal(t, service.Distribution, distribution)
+		service, ok = dm.getServiceDefinitionFromAnnotatedService(newService(distribution))
+		assert.Assert(t, ok)
+		assert.Equal(t, service.Distribution, distribution)
+	}
+	_, ok := dm.getServiceDefinitionFromAnnotatedDeployment(newDeployment("nearest"))
+	assert.Assert(t, !ok)
+	_, ok = dm.getServiceDefinitionFromAnnotatedService(newService("nearest"))
+	assert.Assert(t, !ok)
+}
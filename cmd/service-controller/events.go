@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+// Reasons for the events recorded against annotated workloads, the
+// services created for skupper addresses, the skupper-services
+// configmap and the router deployment
+const (
+	EventServiceExposed      string = "Exposed"
+	EventServiceExposeFailed string = "ExposeFailed"
+	EventServiceIgnored      string = "Ignored"
+	EventServiceCreated      string = "BindingCreated"
+	EventServiceDeleted      string = "BindingDeleted"
+	EventServiceSyncConflict string = "SyncConflict"
+	EventServiceSyncAgedOut  string = "OriginAgedOut"
+	EventServiceSyncFailed   string = "SyncFailed"
+	EventBridgeSyncFailed    string = "BridgeSyncFailed"
+)
+
+// serviceDefinitions returns the skupper-services configmap from the
+// cache, or nil if it is not known
+func (c *Controller) serviceDefinitions() *corev1.ConfigMap {
+	if c.svcDefInformer == nil {
+		return nil
+	}
+	obj, exists, err := c.svcDefInformer.GetStore().GetByKey(c.namespaced(types.ServiceInterfaceConfigMap))
+	if err != nil || !exists {
+		return nil
+	}
+	cm, _ := obj.(*corev1.ConfigMap)
+	return cm
+}
+
+// recordServiceSyncEvent records an event against the skupper-services
+// configmap, if it has been read
+func (c *Controller) recordServiceSyncEvent(eventtype string, reason string, message string) {
+	if cm := c.serviceDefinitions(); cm != nil {
+		c.eventRecorder.Event(cm, eventtype, reason, message)
+	}
+}
+
+func describeBinding(sb *ServiceBindings) string {
+	message := fmt.Sprintf("Created for skupper address %s (%s port %d)", sb.address, sb.protocol, sb.publicPort())
+	if sb.origin != "" && sb.origin != "annotation" {
+		message += " exposed by site " + sb.origin
+	}
+	return message
+}
+
+func describeExposed(service types.ServiceInterface) string {
+	return fmt.Sprintf("Exposed as skupper address %s (%s port %d)", service.Address, service.Protocol, service.Port)
+}
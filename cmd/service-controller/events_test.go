@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+)
+
+func recordedEvents(recorder record.EventRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-recorder.(*record.FakeRecorder).Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestAnnotatedDeploymentEvents(t *testing.T) {
+	const NS = "test"
+	kubeClient := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: types.ServiceInterfaceConfigMap, Namespace: NS},
+	})
+	vanClient := &client.VanClient{
+		Namespace:  NS,
+		KubeClient: kubeClient,
+	}
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	svcDefInformer := corev1informer.NewConfigMapInformer(kubeClient, NS, time.Second*30, indexers)
+	svcInformer := corev1informer.NewServiceInformer(kubeClient, NS, time.Second*30, indexers)
+	recorder := record.NewFakeRecorder(10)
+	dm := newDefinitionMonitor("", vanClient, svcDefInformer, svcInformer, recorder)
+
+	deployments := []*appsv1.Deployment{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: NS,
+				Annotations: map[string]string{
+					types.ProxyQualifier: "http",
+					types.PortQualifier:  "8080",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "db",
+				Namespace:   NS,
+				Annotations: map[string]string{types.ProxyQualifier: "tcp"},
+			},
+		},
+	}
+	for _, deployment := range deployments {
+		assert.Assert(t, dm.deploymentInformer.GetStore().Add(deployment))
+		dm.events.Add("deployments@" + NS + "/" + deployment.ObjectMeta.Name)
+		assert.Assert(t, dm.processNextEvent())
+	}
+
+	assert.DeepEqual(t, recordedEvents(recorder), []string{
+		"Normal Exposed Exposed as skupper address web (http port 8080)",
+		"Warning Ignored Not exposed; cannot deduce port, specify one with the skupper.io/port annotation",
+	})
+}
+
+func TestServiceBindingEvents(t *testing.T) {
+	const NS = "test"
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		vanClient: &client.VanClient{
+			Namespace:  NS,
+			KubeClient: fake.NewSimpleClientset(),
+		},
+		eventRecorder: recorder,
+	}
+	sb := newServiceBindings("east", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+
+	assert.Assert(t, c.createServiceFor(sb))
+	svc, err := c.vanClient.KubeClient.CoreV1().Services(NS).Get("db", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Assert(t, c.deleteService(svc))
+
+	assert.DeepEqual(t, recordedEvents(recorder), []string{
+		"Normal BindingCreated Created for skupper address db (tcp port 5432) exposed by site east",
+		"Normal BindingDeleted Skupper address db is no longer defined",
+	})
+}
+
+func TestServiceSyncFailedEvents(t *testing.T) {
+	c, _ := newTestController(t, 0)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	failing := true
+	c.vanClient.KubeClient.(*fake.Clientset).Fake.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failing {
+			return true, nil, fmt.Errorf("conflict")
+		}
+		return false, nil, nil
+	})
+	c.byOrigin["site-b"] = map[string]types.ServiceInterface{
+		"db": {Address: "db", Protocol: "tcp", Port: 5432, Origin: "site-b"},
+	}
+
+	// the definition is kept until its removal succeeds
+	c.ensureServiceInterfaceDefinitions("site-b", map[string]types.ServiceInterface{})
+	_, ok := c.byOrigin["site-b"]["db"]
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, recordedEvents(recorder), []string{
+		"Warning SyncFailed Failed to update service definitions from site site-b: Failed to update skupper-services config map: conflict",
+	})
+
+	failing = false
+	c.ensureServiceInterfaceDefinitions("site-b", map[string]types.ServiceInterface{})
+	_, ok = c.byOrigin["site-b"]["db"]
+	assert.Assert(t, !ok)
+	assert.Equal(t, len(recordedEvents(recorder)), 0)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+
+	routev1 "github.com/openshift/api/route/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+func (sb *ServiceBindings) requiresExternal(externalType string) bool {
+	return sb.external != nil && sb.external.Type == externalType && sb.headless == nil
+}
+
+// getExternalPortName returns the name of the service port an ingress
+// or route for the address targets, i.e. its first port
+func (sb *ServiceBindings) getExternalPortName() string {
+	name := ""
+	if len(sb.ports) > 0 {
+		name = sb.ports[0].name
+	}
+	return kube.GetServicePortName(sb.address, name)
+}
+
+func getExternalLabels(address string) map[string]string {
+	return map[string]string{
+		types.ExternalForQualifier: address,
+	}
+}
+
+func isExternalFor(meta metav1.ObjectMeta, address string) bool {
+	return meta.Labels[types.ExternalForQualifier] == address
+}
+
+func makeIngressFor(sb *ServiceBindings) *networkingv1beta1.Ingress {
+	ingress := &networkingv1beta1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1beta1",
+			Kind:       "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sb.address,
+			Labels: getExternalLabels(sb.address),
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: sb.external.Host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Path: "/",
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: sb.address,
+										ServicePort: intstr.FromString(sb.getExternalPortName()),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if owner := getOwnerReference(); owner != nil {
+		ingress.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return ingress
+}
+
+// equivalentIngressRules compares only the fields set by the
+// controller, ignoring any defaulted by the cluster
+func equivalentIngressRules(actual []networkingv1beta1.IngressRule, desired []networkingv1beta1.IngressRule) bool {
+	if len(actual) != len(desired) {
+		return false
+	}
+	for i := range desired {
+		if actual[i].Host != desired[i].Host || actual[i].HTTP == nil || len(actual[i].HTTP.Paths) != len(desired[i].HTTP.Paths) {
+			return false
+		}
+		for j, path := range desired[i].HTTP.Paths {
+			if actual[i].HTTP.Paths[j].Path != path.Path || actual[i].HTTP.Paths[j].Backend != path.Backend {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func makeRouteFor(sb *ServiceBindings) *routev1.Route {
+	// http is terminated at the route, whereas services that
+	// terminate TLS themselves are passed through
+	termination := routev1.TLSTerminationEdge
+	if sb.terminatesTls() {
+		termination = routev1.TLSTerminationPassthrough
+	}
+	route := &routev1.Route{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Route",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sb.address,
+			Labels: getExternalLabels(sb.address),
+		},
+		Spec: routev1.RouteSpec{
+			Host: sb.external.Host,
+			Port: &routev1.RoutePort{
+				TargetPort: intstr.FromString(sb.getExternalPortName()),
+			},
+			To: routev1.RouteTargetReference{
+				Kind: "Service",
+				Name: sb.address,
+			},
+			TLS: &routev1.TLSConfig{
+				Termination:                   termination,
+				InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyRedirect,
+			},
+		},
+	}
+	if owner := getOwnerReference(); owner != nil {
+		route.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return route
+}
+
+// updateExternalAccess ensures that there is an ingress or route for
+// each address that requires one, and that those previously created
+// for other addresses are removed
+func (c *Controller) updateExternalAccess() {
+	for _, sb := range c.bindings {
+		c.ensureExternalAccessFor(sb)
+	}
+	if err := c.removeStaleIngresses(); err != nil {
+		log.Printf("Failed to remove stale ingresses: %s", err)
+	}
+	if err := c.removeStaleRoutes(); err != nil {
+		log.Printf("Failed to remove stale routes: %s", err)
+	}
+}
+
+func (c *Controller) ensureExternalAccessFor(sb *ServiceBindings) {
+	if sb.requiresExternal(types.ExternalTypeIngress) {
+		if err := c.ensureIngressFor(sb); err != nil {
+			log.Printf("Failed to expose %s through ingress: %s", sb.address, err)
+		}
+	} else if sb.requiresExternal(types.ExternalTypeRoute) {
+		if err := c.ensureRouteFor(sb); err != nil {
+			log.Printf("Failed to expose %s through route: %s", sb.address, err)
+		}
+	}
+}
+
+// updateExternalAccessFor does the same as updateExternalAccess for a
+// single address, without listing the ingresses and routes for others
+func (c *Controller) updateExternalAccessFor(address string) {
+	sb := c.bindings[address]
+	if sb != nil {
+		c.ensureExternalAccessFor(sb)
+	}
+	if sb == nil || !sb.requiresExternal(types.ExternalTypeIngress) {
+		if err := c.removeIngressFor(address); err != nil {
+			log.Printf("Failed to remove stale ingress for %s: %s", address, err)
+		}
+	}
+	if sb == nil || !sb.requiresExternal(types.ExternalTypeRoute) {
+		if err := c.removeRouteFor(address); err != nil {
+			log.Printf("Failed to remove stale route for %s: %s", address, err)
+		}
+	}
+}
+
+func (c *Controller) removeIngressFor(address string) error {
+	ingresses := c.vanClient.KubeClient.NetworkingV1beta1().Ingresses(c.vanClient.Namespace)
+	ingress, err := ingresses.Get(address, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !isExternalFor(ingress.ObjectMeta, address) {
+		return nil
+	}
+	log.Printf("Deleting ingress for %s", address)
+	if err := ingresses.Delete(ingress.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) removeRouteFor(address string) error {
+	if c.vanClient.RouteClient == nil {
+		return nil
+	}
+	routes := c.vanClient.RouteClient.Routes(c.vanClient.Namespace)
+	route, err := routes.Get(address, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !isExternalFor(route.ObjectMeta, address) {
+		return nil
+	}
+	log.Printf("Deleting route for %s", address)
+	if err := routes.Delete(route.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) ensureIngressFor(sb *ServiceBindings) error {
+	ingresses := c.vanClient.KubeClient.NetworkingV1beta1().Ingresses(c.vanClient.Namespace)
+	desired := makeIngressFor(sb)
+	actual, err := ingresses.Get(sb.address, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		log.Printf("Creating ingress for %s", sb.address)
+		_, err = ingresses.Create(desired)
+		return err
+	} else if err != nil {
+		return err
+	} else if !isExternalFor(actual.ObjectMeta, sb.address) {
+		return fmt.Errorf("Ingress %s exists and was not created by skupper", sb.address)
+	} else if !equivalentIngressRules(actual.Spec.Rules, desired.Spec.Rules) {
+		log.Printf("Updating ingress for %s", sb.address)
+		actual.Spec = desired.Spec
+		_, err = ingresses.Update(actual)
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) ensureRouteFor(sb *ServiceBindings) error {
+	if c.vanClient.RouteClient == nil {
+		return fmt.Errorf("Routes are not supported in this cluster")
+	}
+	routes := c.vanClient.RouteClient.Routes(c.vanClient.Namespace)
+	desired := makeRouteFor(sb)
+	actual, err := routes.Get(sb.address, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		log.Printf("Creating route for %s", sb.address)
+		_, err = kube.CreateRoute(desired, c.vanClient.Namespace, c.vanClient.RouteClient)
+		return err
+	} else if err != nil {
+		return err
+	} else if !isExternalFor(actual.ObjectMeta, sb.address) {
+		return fmt.Errorf("Route %s exists and was not created by skupper", sb.address)
+	}
+	// the host is assigned by the cluster if not specified
+	if desired.Spec.Host == "" {
+		desired.Spec.Host = actual.Spec.Host
+	}
+	if !reflect.DeepEqual(actual.Spec.Port, desired.Spec.Port) || !reflect.DeepEqual(actual.Spec.TLS, desired.Spec.TLS) || actual.Spec.Host != desired.Spec.Host {
+		log.Printf("Updating route for %s", sb.address)
+		actual.Spec.Host = desired.Spec.Host
+		actual.Spec.Port = desired.Spec.Port
+		actual.Spec.TLS = desired.Spec.TLS
+		_, err = routes.Update(actual)
+		return err
+	}
+	return nil
+}
+
+func externalListOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: types.ExternalForQualifier}
+}
+
+func (c *Controller) removeStaleIngresses() error {
+	ingresses := c.vanClient.KubeClient.NetworkingV1beta1().Ingresses(c.vanClient.Namespace)
+	list, err := ingresses.List(externalListOptions())
+	if err != nil {
+		return err
+	}
+	for _, ingress := range list.Items {
+		address := ingress.ObjectMeta.Labels[types.ExternalForQualifier]
+		if sb, ok := c.bindings[address]; !ok || !sb.requiresExternal(types.ExternalTypeIngress) {
+			log.Printf("Deleting ingress for %s", address)
+			if err := ingresses.Delete(ingress.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Controller) removeStaleRoutes() error {
+	if c.vanClient.RouteClient == nil {
+		return nil
+	}
+	routes := c.vanClient.RouteClient.Routes(c.vanClient.Namespace)
+	list, err := routes.List(externalListOptions())
+	if err != nil {
+		return err
+	}
+	for _, route := range list.Items {
+		address := route.ObjectMeta.Labels[types.ExternalForQualifier]
+		if sb, ok := c.bindings[address]; !ok || !sb.requiresExternal(types.ExternalTypeRoute) {
+			log.Printf("Deleting route for %s", address)
+			if err := routes.Delete(route.ObjectMeta.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+)
+
+func TestUpdateExternalAccessIngress(t *testing.T) {
+	const NS = "test"
+	unrelated := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: NS,
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(unrelated)
+	c := &Controller{
+		vanClient: &client.VanClient{
+			Namespace:  NS,
+			KubeClient: kubeClient,
+		},
+	}
+	ingresses := kubeClient.NetworkingV1beta1().Ingresses(NS)
+	sb := newServiceBindings("", "http", "web", []PortBindings{{publicPort: 8080, ingressPort: 1024}}, nil, "", false, "")
+	sb.external = &types.ServiceExternal{Type: types.ExternalTypeIngress, Host: "web.example.com"}
+	c.bindings = map[string]*ServiceBindings{"web": sb}
+
+	c.updateExternalAccess()
+	ingress, err := ingresses.Get("web", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, ingress.ObjectMeta.Labels[types.ExternalForQualifier], "web")
+	assert.Equal(t, ingress.Spec.Rules[0].Host, "web.example.com")
+	assert.Equal(t, ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName, "web")
+	assert.Equal(t, ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort.StrVal, "web")
+
+	sb.external.Host = "www.example.com"
+	c.updateExternalAccess()
+	ingress, err = ingresses.Get("web", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, ingress.Spec.Rules[0].Host, "www.example.com")
+
+	sb.external = &types.ServiceExternal{Type: types.ExternalTypeLoadBalancer}
+	c.updateExternalAccess()
+	list, err := ingresses.List(metav1.ListOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(list.Items), 1)
+	assert.Equal(t, list.Items[0].ObjectMeta.Name, "other")
+}
+
+func TestEnsureIngressForExisting(t *testing.T) {
+	const NS = "test"
+	existing := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: NS,
+		},
+	}
+	c := &Controller{
+		vanClient: &client.VanClient{
+			Namespace:  NS,
+			KubeClient: fake.NewSimpleClientset(existing),
+		},
+	}
+	sb := newServiceBindings("", "http", "web", []PortBindings{{publicPort: 8080, ingressPort: 1024}}, nil, "", false, "")
+	sb.external = &types.ServiceExternal{Type: types.ExternalTypeIngress}
+	assert.ErrorContains(t, c.ensureIngressFor(sb), "was not created by skupper")
+}
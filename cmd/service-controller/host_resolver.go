@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type LookupFunc func(host string) ([]string, error)
+
+// HostResolver periodically re-resolves the name of a host target,
+// invoking a callback whenever the set of addresses it resolves to
+// changes
+type HostResolver struct {
+	host      string
+	interval  time.Duration
+	lookup    LookupFunc
+	onChange  func()
+	addresses []string
+	lock      sync.RWMutex
+}
+
+func newHostResolver(host string, interval time.Duration, onChange func()) *HostResolver {
+	return &HostResolver{
+		host:     host,
+		interval: interval,
+		lookup:   net.LookupHost,
+		onChange: onChange,
+	}
+}
+
+func (r *HostResolver) start(stopper <-chan struct{}) {
+	r.resolve()
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if r.resolve() && r.onChange != nil {
+					r.onChange()
+				}
+			case <-stopper:
+				return
+			}
+		}
+	}()
+}
+
+// resolve looks up the host, returning true if the resolved addresses
+// differ from those previously known. On failure the previous
+// addresses are retained.
+func (r *HostResolver) resolve() bool {
+	addresses, err := r.lookup(r.host)
+	if err != nil {
+		log.Printf("Failed to resolve %s: %s", r.host, err)
+		return false
+	}
+	sort.Strings(addresses)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if reflect.DeepEqual(addresses, r.addresses) {
+		return false
+	}
+	log.Printf("Host %s resolved to %v", r.host, addresses)
+	r.addresses = addresses
+	return true
+}
+
+func (r *HostResolver) getAddresses() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.addresses
+}
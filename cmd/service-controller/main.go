@@ -92,6 +92,7 @@ func main() {
 	if err != nil {
 		log.Fatal("Error getting new controller", err.Error())
 	}
+	go controller.health.Serve(int(types.ControllerHealthPort), stopCh)
 
 	log.Println("Waiting for Skupper router component to start")
 	pods, err := kube.GetDeploymentPods(types.TransportDeploymentName, "skupper.io/component=router", namespace, cli.KubeClient)
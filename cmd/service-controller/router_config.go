@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+// routerFragment is the part of the router's configuration required
+// for a single service
+type routerFragment struct {
+	bridges  *qdr.BridgeConfig
+	address  *qdr.Address
+	profiles []qdr.SslProfile
+}
+
+func requiredFragmentFor(service *ServiceBindings, siteId string, priorities *SitePriorities) *routerFragment {
+	return &routerFragment{
+		bridges:  requiredBridgesFor(service, siteId, priorities),
+		address:  requiredAddressFor(service),
+		profiles: requiredSslProfilesFor(service),
+	}
+}
+
+// routerFragments merges the fragments for every service into the
+// bridges, addresses and ssl profiles the router requires, applying
+// only the entries of a fragment that changes rather than merging
+// them all again, and records which of them have yet to be applied
+// to the router's configuration
+type routerFragments struct {
+	byAddress   map[string]*routerFragment
+	bridges     qdr.BridgeConfig
+	addresses   qdr.AddressMap
+	profiles    map[string]qdr.SslProfile
+	profileRefs map[string]int // trust profiles can be shared
+
+	bridgesChanged   bool
+	changedAddresses map[string]bool
+	changedProfiles  map[string]bool
+}
+
+func newRouterFragments() *routerFragments {
+	return &routerFragments{
+		byAddress:        map[string]*routerFragment{},
+		bridges:          qdr.NewBridgeConfig(),
+		addresses:        qdr.AddressMap{},
+		profiles:         map[string]qdr.SslProfile{},
+		profileRefs:      map[string]int{},
+		changedAddresses: map[string]bool{},
+		changedProfiles:  map[string]bool{},
+	}
+}
+
+// update replaces the fragment for an address, or removes it if nil,
+// returning true if anything changed
+func (f *routerFragments) update(address string, fragment *routerFragment) bool {
+	current, ok := f.byAddress[address]
+	if fragment == nil && !ok {
+		return false
+	}
+	if ok && fragment != nil && reflect.DeepEqual(current, fragment) {
+		return false
+	}
+	if ok {
+		f.remove(current)
+		delete(f.byAddress, address)
+	}
+	if fragment != nil {
+		f.add(fragment)
+		f.byAddress[address] = fragment
+	}
+	return true
+}
+
+func (f *routerFragments) add(fragment *routerFragment) {
+	for name, e := range fragment.bridges.TcpListeners {
+		f.bridges.TcpListeners[name] = e
+	}
+	for name, e := range fragment.bridges.TcpConnectors {
+		f.bridges.TcpConnectors[name] = e
+	}
+	for name, e := range fragment.bridges.HttpListeners {
+		f.bridges.HttpListeners[name] = e
+	}
+	for name, e := range fragment.bridges.HttpConnectors {
+		f.bridges.HttpConnectors[name] = e
+	}
+	f.bridgesChanged = true
+	if fragment.address != nil {
+		f.addresses[fragment.address.Prefix] = *fragment.address
+		f.changedAddresses[fragment.address.Prefix] = true
+	}
+	for _, profile := range fragment.profiles {
+		if f.profileRefs[profile.Name] == 0 {
+			f.profiles[profile.Name] = profile
+			f.changedProfiles[profile.Name] = true
+		}
+		f.profileRefs[profile.Name]++
+	}
+}
+
+func (f *routerFragments) remove(fragment *routerFragment) {
+	for name := range fragment.bridges.TcpListeners {
+		delete(f.bridges.TcpListeners, name)
+	}
+	for name := range fragment.bridges.TcpConnectors {
+		delete(f.bridges.TcpConnectors, name)
+	}
+	for name := range fragment.bridges.HttpListeners {
+		delete(f.bridges.HttpListeners, name)
+	}
+	for name := range fragment.bridges.HttpConnectors {
+		delete(f.bridges.HttpConnectors, name)
+	}
+	f.bridgesChanged = true
+	if fragment.address != nil {
+		delete(f.addresses, fragment.address.Prefix)
+		f.changedAddresses[fragment.address.Prefix] = true
+	}
+	for _, profile := range fragment.profiles {
+		f.profileRefs[profile.Name]--
+		if f.profileRefs[profile.Name] == 0 {
+			delete(f.profileRefs, profile.Name)
+			delete(f.profiles, profile.Name)
+			f.changedProfiles[profile.Name] = true
+		}
+	}
+}
+
+// apply brings the router's configuration in line with the merged
+// fragments, returning whether it changed and whether the ssl profiles
+// in particular did. A config that has not been applied to before must
+// be compared in full; after that the bridges are shared with it, so
+// only the addresses and profiles changed since need be applied.
+func (f *routerFragments) apply(config *qdr.RouterConfig, full bool) (bool, bool) {
+	var bridgesChanged, addressesChanged, profilesChanged bool
+	if full {
+		bridgesChanged = config.UpdateBridgeConfig(f.bridges)
+		config.Bridges = f.bridges
+		addressesChanged = config.UpdateServiceAddresses(f.addresses)
+		profilesChanged = config.UpdateServiceSslProfiles(f.profiles)
+	} else {
+		bridgesChanged = f.bridgesChanged
+		for prefix := range f.changedAddresses {
+			if address, ok := f.addresses[prefix]; ok {
+				config.Addresses[prefix] = address
+			} else {
+				delete(config.Addresses, prefix)
+			}
+			addressesChanged = true
+		}
+		for name := range f.changedProfiles {
+			if profile, ok := f.profiles[name]; ok {
+				config.SslProfiles[name] = profile
+			} else {
+				delete(config.SslProfiles, name)
+			}
+			profilesChanged = true
+		}
+	}
+	f.bridgesChanged = false
+	f.changedAddresses = map[string]bool{}
+	f.changedProfiles = map[string]bool{}
+	return bridgesChanged || addressesChanged || profilesChanged, profilesChanged
+}
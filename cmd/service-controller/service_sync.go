@@ -6,15 +6,66 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sync"
 	"time"
 
 	amqp "github.com/interconnectedcloud/go-amqp"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/pkg/kube"
 )
 
+// SitePriorities tracks the priorities with which other sites
+// provide services, to determine whether the local site should hold
+// its own targets in reserve
+type SitePriorities struct {
+	lock     sync.Mutex
+	byOrigin map[string]map[string]int
+}
+
+func newSitePriorities() *SitePriorities {
+	return &SitePriorities{
+		byOrigin: map[string]map[string]int{},
+	}
+}
+
+func (p *SitePriorities) update(origin string, priorities map[string]int) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if reflect.DeepEqual(p.byOrigin[origin], priorities) || (len(p.byOrigin[origin]) == 0 && len(priorities) == 0) {
+		return false
+	}
+	if len(priorities) == 0 {
+		delete(p.byOrigin, origin)
+	} else {
+		p.byOrigin[origin] = priorities
+	}
+	return true
+}
+
+func (p *SitePriorities) remove(origin string) bool {
+	return p.update(origin, nil)
+}
+
+// isStandby returns true if another site provides the address with a
+// lower (i.e. preferred) non-zero priority. Sites only advertise a
+// priority while they have a target available, so when the preferred
+// site loses its targets the next one takes over.
+func (p *SitePriorities) isStandby(address string, priority int) bool {
+	if p == nil || priority <= 0 {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, priorities := range p.byOrigin {
+		if other, ok := priorities[address]; ok && other > 0 && other < priority {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Controller) pareByOrigin(service string) {
 	for _, origin := range c.byOrigin {
 		if _, ok := origin[service]; ok {
@@ -24,46 +75,67 @@ func (c *Controller) pareByOrigin(service string) {
 	}
 }
 
-func (c *Controller) serviceSyncDefinitionsUpdated(definitions map[string]types.ServiceInterface) {
-	latest := make(map[string]types.ServiceInterface) // becomes c.localServices
-	byName := make(map[string]types.ServiceInterface)
+// serviceSyncDefinitionsUpdated applies changes to the service
+// definitions in skupper-services to those advertised to other sites
+func (c *Controller) serviceSyncDefinitionsUpdated(changed map[string]types.ServiceInterface, deleted []string) {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+	latest := c.localServices
+	byName := c.byName
 	var added []types.ServiceInterface
 	var modified []types.ServiceInterface
 	var removed []types.ServiceInterface
 
-	for name, original := range definitions {
+	for name, original := range changed {
 		service := types.ServiceInterface{
-			Address:  original.Address,
-			Protocol: original.Protocol,
-			Port:     original.Port,
-			Origin:   original.Origin,
-			Headless: original.Headless,
-			Targets:  []types.ServiceInterfaceTarget{},
+			Address:      original.Address,
+			Protocol:     original.Protocol,
+			Port:         original.Port,
+			Ports:        original.Ports,
+			Distribution: original.Distribution,
+			Origin:       original.Origin,
+			Headless:     original.Headless,
+			Labels:       original.Labels,
+			Annotations:  original.Annotations,
+			Targets:      []types.ServiceInterfaceTarget{},
+		}
+		if len(original.Targets) > 0 {
+			// only advertise a priority for services this site
+			// can actually provide
+			service.Priority = original.Priority
 		}
+		if original.Tls != nil && original.Tls.Terminate {
+			// origination of TLS only concerns the local targets
+			service.Tls = &types.ServiceTls{Terminate: true}
+		}
+		previous, existed := latest[service.Address]
 		if service.Origin != "" && service.Origin != "annotation" {
 			if _, ok := c.byOrigin[service.Origin]; !ok {
 				c.byOrigin[service.Origin] = make(map[string]types.ServiceInterface)
 			}
 			c.byOrigin[service.Origin][name] = service
+			if existed {
+				removed = append(removed, previous)
+				delete(latest, service.Address)
+			}
 		} else {
+			if !existed {
+				added = append(added, service)
+			} else if !reflect.DeepEqual(previous, service) {
+				modified = append(modified, previous)
+			}
 			latest[service.Address] = service
 			// may have previously been tracked by origin
 			c.pareByOrigin(service.Address)
 		}
 		byName[service.Address] = service
 	}
-
-	for _, def := range c.localServices {
-		if _, ok := latest[def.Address]; !ok {
-			removed = append(removed, def)
-		} else if !reflect.DeepEqual(def, latest[def.Address]) {
-			modified = append(modified, def)
-		}
-	}
-	for _, def := range latest {
-		if _, ok := c.localServices[def.Address]; !ok {
-			added = append(added, def)
+	for _, name := range deleted {
+		if previous, ok := latest[name]; ok {
+			removed = append(removed, previous)
+			delete(latest, name)
 		}
+		delete(byName, name)
 	}
 
 	// TODO: detect and describe changes
@@ -76,13 +148,56 @@ func (c *Controller) serviceSyncDefinitionsUpdated(definitions map[string]types.
 	if len(modified) > 0 {
 		log.Println("Service interface(s) modified", modified)
 	}
+}
+
+// definedService returns the definition for the address from
+// skupper-services, if there is one
+func (c *Controller) definedService(address string) (types.ServiceInterface, bool) {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+	service, ok := c.byName[address]
+	return service, ok
+}
 
-	c.localServices = latest
-	c.byName = byName
+// localServiceDefinitions returns the definitions this site advertises
+func (c *Controller) localServiceDefinitions() []types.ServiceInterface {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+	local := make([]types.ServiceInterface, 0, len(c.localServices))
+	for _, si := range c.localServices {
+		if c.unavailable[si.Address] {
+			// no longer able to provide the service, so other
+			// sites should not hold theirs in reserve
+			si.Priority = 0
+		}
+		local = append(local, si)
+	}
+	return local
+}
+
+// setTargetsAvailable records whether any local target of the address
+// is available, which determines whether its priority is advertised
+func (c *Controller) setTargetsAvailable(address string, available bool) {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+	if available {
+		delete(c.unavailable, address)
+	} else {
+		if c.unavailable == nil {
+			c.unavailable = map[string]bool{}
+		}
+		c.unavailable[address] = true
+	}
 }
 
 func equivalentServiceDefinition(a *types.ServiceInterface, b *types.ServiceInterface) bool {
-	if a.Protocol != b.Protocol || a.Port != b.Port || a.EventChannel != b.EventChannel || a.Aggregate != b.Aggregate {
+	if a.Protocol != b.Protocol || a.Port != b.Port || a.EventChannel != b.EventChannel || a.Aggregate != b.Aggregate || a.Distribution != b.Distribution || a.Priority != b.Priority {
+		return false
+	}
+	if !reflect.DeepEqual(a.Ports, b.Ports) || !reflect.DeepEqual(a.Tls, b.Tls) {
+		return false
+	}
+	if !reflect.DeepEqual(a.Labels, b.Labels) || !reflect.DeepEqual(a.Annotations, b.Annotations) {
 		return false
 	}
 	if a.Headless == nil && b.Headless == nil {
@@ -104,12 +219,37 @@ func (c *Controller) ensureServiceInterfaceDefinitions(origin string, serviceInt
 
 	c.heardFrom[origin] = time.Now()
 
+	priorities := map[string]int{}
+	for _, def := range serviceInterfaceDefs {
+		if def.Priority > 0 {
+			priorities[def.Address] = def.Priority
+		}
+	}
+	if c.sitePriorities.update(origin, priorities) {
+		c.events.Add("sitepriorities@" + origin)
+	}
+
+	conflicts := map[string]bool{}
 	for _, def := range serviceInterfaceDefs {
-		existing, ok := c.byName[def.Address]
+		existing, ok := c.definedService(def.Address)
 		if !ok || (existing.Origin == origin && !equivalentServiceDefinition(&def, &existing)) {
 			changed = append(changed, def)
+		} else if existing.Origin != origin && !equivalentServiceDefinition(&def, &existing) {
+			conflicts[def.Address] = true
+			// only reported when the conflict first arises, as
+			// updates are received every few seconds
+			if !c.conflicts[origin][def.Address] {
+				definedBy := existing.Origin
+				if definedBy == "" || definedBy == "annotation" {
+					definedBy = "this site"
+				} else {
+					definedBy = "site " + definedBy
+				}
+				c.recordServiceSyncEvent(corev1.EventTypeWarning, EventServiceSyncConflict, fmt.Sprintf("Ignoring definition of %s from site %s; it differs from that of %s", def.Address, origin, definedBy))
+			}
 		}
 	}
+	c.conflicts[origin] = conflicts
 
 	if _, ok := c.byOrigin[origin]; !ok {
 		c.byOrigin[origin] = make(map[string]types.ServiceInterface)
@@ -122,29 +262,28 @@ func (c *Controller) ensureServiceInterfaceDefinitions(origin string, serviceInt
 		}
 	}
 
-	kube.UpdateSkupperServices(changed, deleted, origin, c.vanClient.Namespace, c.vanClient.KubeClient)
+	if err := kube.UpdateSkupperServices(changed, deleted, origin, c.vanClient.Namespace, c.vanClient.KubeClient); err != nil {
+		// the definitions are compared afresh with the next update
+		// from the origin, so leaving the cached state as it is
+		// retries the change
+		log.Printf("Service sync failed to update service definitions from origin %s: %s", origin, err)
+		c.recordServiceSyncEvent(corev1.EventTypeWarning, EventServiceSyncFailed, fmt.Sprintf("Failed to update service definitions from site %s: %s", origin, err))
+		return
+	}
 
 	for _, name := range deleted {
 		delete(c.byOrigin[origin], name)
 	}
 }
 
-func (c *Controller) syncSender(sendLocal chan bool) {
+func (c *Controller) syncSender(ctx context.Context, sender *amqp.Sender) error {
 	var request amqp.Message
 	var properties amqp.MessageProperties
 
-	ctx := context.Background()
-	sender, err := c.amqpSession.NewSender(amqp.LinkTargetAddress(types.ServiceSyncAddress))
-	if err != nil {
-		log.Fatal("Failed to create sender: ", err.Error())
-	}
-
-	defer func() {
-		sender.Close(ctx)
-	}()
-
 	tickerSend := time.NewTicker(5 * time.Second)
 	tickerAge := time.NewTicker(30 * time.Second)
+	defer tickerSend.Stop()
+	defer tickerAge.Stop()
 
 	properties.Subject = "service-sync-update"
 	request.Properties = &properties
@@ -153,20 +292,18 @@ func (c *Controller) syncSender(sendLocal chan bool) {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
 		case <-tickerSend.C:
-			local := make([]types.ServiceInterface, 0)
-
-			for _, si := range c.localServices {
-				local = append(local, si)
-			}
-
-			encoded, err := jsonencoding.Marshal(local)
+			encoded, err := jsonencoding.Marshal(c.localServiceDefinitions())
 			if err != nil {
-				log.Println("Failed to create json for service definition sync: ", err.Error())
-				return
+				return fmt.Errorf("Failed to create json for service definition sync: %s", err)
 			}
 			request.Value = string(encoded)
 			err = sender.Send(ctx, &request)
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("Failed to send service definitions: %s", err)
+			}
 
 		case <-tickerAge.C:
 			var agedOrigins []string
@@ -178,71 +315,96 @@ func (c *Controller) syncSender(sendLocal chan bool) {
 
 				if lastHeard, ok := c.heardFrom[origin]; ok {
 					if now.Sub(lastHeard) >= 60*time.Second {
-						agedOrigins = append(agedOrigins, origin)
 						agedDefinitions := c.byOrigin[origin]
 						for name, _ := range agedDefinitions {
 							deleted = append(deleted, name)
 						}
 						if len(deleted) > 0 {
-							kube.UpdateSkupperServices([]types.ServiceInterface{}, deleted, origin, c.vanClient.Namespace, c.vanClient.KubeClient)
+							if err := kube.UpdateSkupperServices([]types.ServiceInterface{}, deleted, origin, c.vanClient.Namespace, c.vanClient.KubeClient); err != nil {
+								// the origin is aged out again on
+								// the next tick
+								log.Printf("Service sync failed to age out service definitions from origin %s: %s", origin, err)
+								c.recordServiceSyncEvent(corev1.EventTypeWarning, EventServiceSyncFailed, fmt.Sprintf("Failed to remove service definitions from site %s: %s", origin, err))
+								continue
+							}
 						}
+						agedOrigins = append(agedOrigins, origin)
 					}
 				}
 			}
 
 			for _, originName := range agedOrigins {
 				log.Println("Service sync aged out service definitions from origin ", originName)
+				c.recordServiceSyncEvent(corev1.EventTypeWarning, EventServiceSyncAgedOut, fmt.Sprintf("Removed service definitions from site %s, which has not been heard from for 60 seconds", originName))
+				delete(c.conflicts, originName)
 				delete(c.heardFrom, originName)
 				delete(c.byOrigin, originName)
+				if c.sitePriorities.remove(originName) {
+					c.events.Add("sitepriorities@" + originName)
+				}
 			}
 		}
 	}
 }
 
-func (c *Controller) runServiceSync() {
-	ctx := context.Background()
-
-	log.Println("Establishing connection to skupper-messaging service for service sync")
-
-	client, err := amqp.Dial("amqps://skupper-messaging:5671", amqp.ConnSASLExternal(), amqp.ConnMaxFrameSize(4294967295), amqp.ConnTLSConfig(c.tlsConfig))
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create amqp connection %s", err.Error()))
-		return
-	}
-	log.Println("Service sync connection to skupper-messaging service established")
-	c.amqpClient = client
-	defer c.amqpClient.Close()
-
-	c.amqpSession, err = c.amqpClient.NewSession()
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create amqp session %s", err.Error()))
-		return
-	}
-
-	receiver, err := c.amqpSession.NewReceiver(
+// runServiceSync exchanges service definitions with other sites over
+// a session on the router connection, until a link fails or ctx is
+// cancelled
+func (c *Controller) runServiceSync(ctx context.Context, session *amqp.Session) error {
+	receiver, err := session.NewReceiver(
 		amqp.LinkSourceAddress(types.ServiceSyncAddress),
 		amqp.LinkCredit(10),
 	)
 	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create amqp receiver %s", err.Error()))
-		return
+		return fmt.Errorf("Failed to create amqp receiver: %s", err)
 	}
 	defer func() {
-		ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		receiver.Close(ctx)
 		cancel()
 	}()
-
-	sendLocal := make(chan bool)
-	go c.syncSender(sendLocal)
+	sender, err := session.NewSender(amqp.LinkTargetAddress(types.ServiceSyncAddress))
+	if err != nil {
+		return fmt.Errorf("Failed to create sender: %s", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		sender.Close(ctx)
+		cancel()
+	}()
+	log.Println("Service sync links to skupper-messaging service established")
+
+	// a failure to send also stops the receiver, and the sender is
+	// stopped before the links are closed
+	ctx, cancel := context.WithCancel(ctx)
+	senderErr := make(chan error, 1)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		senderErr <- c.syncSender(ctx, sender)
+		cancel()
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
 
 	for {
 		var ok bool
 		var origin string
 		msg, err := receiver.Receive(ctx)
 		if err != nil {
-			utilruntime.HandleError(fmt.Errorf("Failed reading message from service sync %s", err.Error()))
-			return
+			select {
+			case err := <-senderErr:
+				if err != nil {
+					return err
+				}
+			default:
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("Failed reading message from service sync: %s", err)
 		}
 		// Decode message as it is either a request to send update
 		// or it is a receipt that needs to be reconciled
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
+)
+
+const certsDir string = "/etc/qpid-dispatch-certs/"
+
+func getProfileDir(profile string) string {
+	return certsDir + profile + "/"
+}
+
+func isServiceTlsMount(path string) bool {
+	return strings.HasPrefix(path, certsDir+types.ServiceTlsProfilePrefix) || strings.HasPrefix(path, certsDir+types.ServiceTrustProfilePrefix)
+}
+
+func getTrustSecret(tls *types.ServiceTls) string {
+	if tls.CaSecret == "" {
+		return types.ServiceCaSecret
+	}
+	return tls.CaSecret
+}
+
+func getTrustProfile(tls *types.ServiceTls) qdr.SslProfile {
+	secret := getTrustSecret(tls)
+	name := types.GetServiceTrustProfile(secret)
+	// the service CA secret holds the CA certificate itself, whereas
+	// other secrets are expected to hold the CA that issued them
+	file := "ca.crt"
+	if secret == types.ServiceCaSecret {
+		file = "tls.crt"
+	}
+	return qdr.SslProfile{
+		Name:       name,
+		CaCertFile: getProfileDir(name) + file,
+	}
+}
+
+func getTlsProfile(address string) qdr.SslProfile {
+	name := types.GetServiceTlsProfile(address)
+	return qdr.SslProfile{
+		Name:           name,
+		CertFile:       getProfileDir(name) + "tls.crt",
+		PrivateKeyFile: getProfileDir(name) + "tls.key",
+		CaCertFile:     getProfileDir(name) + "ca.crt",
+	}
+}
+
+func (sb *ServiceBindings) terminatesTls() bool {
+	return sb.tls != nil && sb.tls.Terminate && sb.headless == nil
+}
+
+func (sb *ServiceBindings) originatesTls() bool {
+	return sb.tls != nil && sb.tls.Originate && sb.headless == nil
+}
+
+// requiredSslProfiles returns the ssl profiles referenced by the
+// bridges for the given services
+func requiredSslProfiles(services map[string]*ServiceBindings) map[string]qdr.SslProfile {
+	profiles := map[string]qdr.SslProfile{}
+	for _, sb := range services {
+		for _, profile := range requiredSslProfilesFor(sb) {
+			profiles[profile.Name] = profile
+		}
+	}
+	return profiles
+}
+
+// requiredSslProfilesFor returns the ssl profiles referenced by the
+// bridges for a single service
+func requiredSslProfilesFor(sb *ServiceBindings) []qdr.SslProfile {
+	var profiles []qdr.SslProfile
+	if sb.terminatesTls() {
+		profiles = append(profiles, getTlsProfile(sb.address))
+	}
+	if sb.originatesTls() {
+		profiles = append(profiles, getTrustProfile(sb.tls))
+	}
+	return profiles
+}
+
+// requiredTlsMounts returns the directory in which each secret needed
+// for the ssl profiles of the given services must be mounted, keyed by
+// secret name
+func requiredTlsMounts(services map[string]*ServiceBindings) map[string]string {
+	mounts := map[string]string{}
+	for _, sb := range services {
+		if sb.terminatesTls() {
+			name := types.GetServiceTlsProfile(sb.address)
+			mounts[name] = getProfileDir(name)
+		}
+		if sb.originatesTls() {
+			secret := getTrustSecret(sb.tls)
+			mounts[secret] = getProfileDir(types.GetServiceTrustProfile(secret))
+		}
+	}
+	return mounts
+}
+
+func requiresServiceCa(services map[string]*ServiceBindings) bool {
+	for _, sb := range services {
+		if sb.terminatesTls() || (sb.originatesTls() && sb.tls.CaSecret == "") {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureServiceTlsCredentials issues certificates for services that
+// terminate TLS and mounts all secrets needed by the ssl profiles of
+// service bridges into the router. Secrets issued for services that no
+// longer use TLS are retained so that clients trusting them continue
+// to do so if TLS is enabled again.
+func (c *Controller) ensureServiceTlsCredentials() error {
+	namespace := c.vanClient.Namespace
+	cli := c.vanClient.KubeClient
+	if requiresServiceCa(c.bindings) {
+		_, err := kube.NewCertAuthority(types.CertAuthority{Name: types.ServiceCaSecret}, getOwnerReference(), namespace, cli)
+		if err != nil {
+			return err
+		}
+	}
+	for _, sb := range c.bindings {
+		if !sb.terminatesTls() {
+			continue
+		}
+		name := types.GetServiceTlsProfile(sb.address)
+		_, err := cli.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			log.Printf("Issuing certificate for service %s", sb.address)
+			_, err = kube.NewSecret(types.Credential{
+				CA:      types.ServiceCaSecret,
+				Name:    name,
+				Subject: sb.address,
+				Hosts:   []string{sb.address, sb.address + "." + namespace, sb.address + "." + namespace + ".svc.cluster.local"},
+			}, getOwnerReference(), namespace, cli)
+			if err != nil {
+				return fmt.Errorf("Failed to issue certificate for %s: %s", sb.address, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("Failed to retrieve certificate for %s: %s", sb.address, err)
+		}
+	}
+
+	required := requiredTlsMounts(c.bindings)
+	deployment, err := kube.GetDeployment(types.TransportDeploymentName, namespace, cli)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve router deployment: %s", err)
+	}
+	actual := map[string]string{}
+	for _, mount := range deployment.Spec.Template.Spec.Containers[0].VolumeMounts {
+		if isServiceTlsMount(mount.MountPath) {
+			actual[mount.Name] = mount.MountPath
+		}
+	}
+	changed := false
+	for name, path := range actual {
+		if required[name] != path {
+			kube.RemoveSecretVolumeForDeployment(name, deployment, 0)
+			delete(actual, name)
+			changed = true
+		}
+	}
+	for name, path := range required {
+		if _, ok := actual[name]; !ok {
+			kube.AppendSecretVolume(&deployment.Spec.Template.Spec.Volumes, &deployment.Spec.Template.Spec.Containers[0].VolumeMounts, name, path)
+			changed = true
+		}
+	}
+	if changed {
+		log.Printf("Updating secrets mounted for service TLS")
+		_, err = cli.AppsV1().Deployments(namespace).Update(deployment)
+		if err != nil {
+			return fmt.Errorf("Failed to update router deployment: %s", err)
+		}
+	}
+	return nil
+}
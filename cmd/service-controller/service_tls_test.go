@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+)
+
+func TestRequiredBridgesTls(t *testing.T) {
+	sb := newServiceBindings("", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+	sb.tls = &types.ServiceTls{Terminate: true, Originate: true, CaSecret: "db-ca", VerifyHostname: true}
+	sb.addServiceTarget("db", "db-backend", map[string]int{"": 5432}, nil)
+	services := map[string]*ServiceBindings{"db": sb}
+
+	bridges := requiredBridges(services, "site-a", nil)
+	assert.Equal(t, bridges.TcpListeners["db"].SslProfile, "skupper-tls-db")
+	connector := bridges.TcpConnectors["db@db-backend"]
+	assert.Equal(t, connector.SslProfile, "skupper-trust-db-ca")
+	assert.Assert(t, connector.VerifyHostname != nil && *connector.VerifyHostname)
+
+	profiles := requiredSslProfiles(services)
+	assert.Equal(t, len(profiles), 2)
+	assert.Equal(t, profiles["skupper-tls-db"].CertFile, "/etc/qpid-dispatch-certs/skupper-tls-db/tls.crt")
+	assert.Equal(t, profiles["skupper-trust-db-ca"].CaCertFile, "/etc/qpid-dispatch-certs/skupper-trust-db-ca/ca.crt")
+
+	sb.tls = &types.ServiceTls{Originate: true}
+	bridges = requiredBridges(services, "site-a", nil)
+	assert.Equal(t, bridges.TcpListeners["db"].SslProfile, "")
+	connector = bridges.TcpConnectors["db@db-backend"]
+	assert.Equal(t, connector.SslProfile, "skupper-trust-skupper-service-ca")
+	assert.Assert(t, connector.VerifyHostname != nil && !*connector.VerifyHostname)
+	profiles = requiredSslProfiles(services)
+	assert.Equal(t, profiles["skupper-trust-skupper-service-ca"].CaCertFile, "/etc/qpid-dispatch-certs/skupper-trust-skupper-service-ca/tls.crt")
+
+	sb.tls = nil
+	bridges = requiredBridges(services, "site-a", nil)
+	assert.Equal(t, bridges.TcpConnectors["db@db-backend"].SslProfile, "")
+	assert.Assert(t, bridges.TcpConnectors["db@db-backend"].VerifyHostname == nil)
+	assert.Equal(t, len(requiredSslProfiles(services)), 0)
+}
+
+func TestEnsureServiceTlsCredentials(t *testing.T) {
+	const NS = "test"
+	router := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.TransportDeploymentName,
+			Namespace: NS,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "router"}},
+				},
+			},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(router)
+	c := &Controller{
+		vanClient: &client.VanClient{
+			Namespace:  NS,
+			KubeClient: kubeClient,
+		},
+	}
+	sb := newServiceBindings("", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+	sb.tls = &types.ServiceTls{Terminate: true, Originate: true}
+	c.bindings = map[string]*ServiceBindings{"db": sb}
+
+	assert.Assert(t, c.ensureServiceTlsCredentials())
+	_, err := kubeClient.CoreV1().Secrets(NS).Get(types.ServiceCaSecret, metav1.GetOptions{})
+	assert.Assert(t, err)
+	secret, err := kubeClient.CoreV1().Secrets(NS).Get("skupper-tls-db", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Assert(t, len(secret.Data["tls.crt"]) > 0)
+
+	mounts := func() map[string]string {
+		deployment, err := kubeClient.AppsV1().Deployments(NS).Get(types.TransportDeploymentName, metav1.GetOptions{})
+		assert.Assert(t, err)
+		result := map[string]string{}
+		for _, m := range deployment.Spec.Template.Spec.Containers[0].VolumeMounts {
+			result[m.Name] = m.MountPath
+		}
+		assert.Equal(t, len(deployment.Spec.Template.Spec.Volumes), len(result))
+		return result
+	}
+	assert.DeepEqual(t, mounts(), map[string]string{
+		"skupper-tls-db":     "/etc/qpid-dispatch-certs/skupper-tls-db/",
+		"skupper-service-ca": "/etc/qpid-dispatch-certs/skupper-trust-skupper-service-ca/",
+	})
+
+	sb.tls = nil
+	assert.Assert(t, c.ensureServiceTlsCredentials())
+	assert.Equal(t, len(mounts()), 0)
+}
+
+func TestServiceTlsCredentialsRetried(t *testing.T) {
+	c, _ := newTestController(t, 0)
+	kubeClient := c.vanClient.KubeClient
+	sb := newServiceBindings("", "tcp", "db", []PortBindings{{publicPort: 5432, ingressPort: 1024}}, nil, "", false, "")
+	sb.tls = &types.ServiceTls{Terminate: true}
+	c.bindings = map[string]*ServiceBindings{"db": sb}
+	c.fragments = newRouterFragments()
+	c.updateBridgeFragment("db")
+
+	// the router deployment is not yet there to mount the secret into
+	name := c.namespaced("skupper-internal")
+	assert.Assert(t, c.updateBridgeConfig(name) != nil)
+	assert.Assert(t, c.tlsPending)
+
+	_, err := kubeClient.AppsV1().Deployments(testNamespace).Create(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: types.TransportDeploymentName, Namespace: testNamespace},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "router"}},
+				},
+			},
+		},
+	})
+	assert.Assert(t, err)
+	internal, err := kubeClient.CoreV1().ConfigMaps(testNamespace).Get("skupper-internal", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Assert(t, c.bridgeDefInformer.GetStore().Update(internal))
+
+	// though the profiles are already in the router config, the
+	// credentials are completed on the next update
+	assert.Assert(t, c.updateBridgeConfig(name))
+	assert.Assert(t, !c.tlsPending)
+	deployment, err := kubeClient.AppsV1().Deployments(testNamespace).Get(types.TransportDeploymentName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(deployment.Spec.Template.Spec.Containers[0].VolumeMounts), 1)
+}
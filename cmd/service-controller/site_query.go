@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -13,7 +12,6 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 
 	"github.com/skupperproject/skupper/client"
 	"github.com/skupperproject/skupper/pkg/kube"
@@ -28,14 +26,11 @@ type SiteInfo struct {
 }
 
 type SiteQueryServer struct {
-	tlsConfig *tls.Config
-	siteInfo  SiteInfo
+	siteInfo SiteInfo
 }
 
-func newSiteQueryServer(tlsConfig *tls.Config) *SiteQueryServer {
-	return &SiteQueryServer{
-		tlsConfig: tlsConfig,
-	}
+func newSiteQueryServer() *SiteQueryServer {
+	return &SiteQueryServer{}
 }
 
 func (s *SiteQueryServer) getLocalSiteInfo(vanClient *client.VanClient) {
@@ -77,50 +72,34 @@ func getSiteQueryAddress(siteId string) string {
 	return siteId + "/skupper-site-query"
 }
 
-func (s *SiteQueryServer) run() {
-	ctx := context.Background()
-
-	log.Println("Establishing connection to skupper-messaging service for site query server")
-
-	client, err := amqp.Dial("amqps://skupper-messaging:5671", amqp.ConnSASLExternal(), amqp.ConnMaxFrameSize(4294967295), amqp.ConnTLSConfig(s.tlsConfig))
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create amqp connection for site query server: %s", err.Error()))
-		return
-	}
-	log.Println("Site query server connection to skupper-messaging service established")
-	defer client.Close()
-
-	session, err := client.NewSession()
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create amqp session %s", err.Error()))
-		return
-	}
-
+// run answers queries for the site's details, until a link fails or
+// ctx is cancelled
+func (s *SiteQueryServer) run(ctx context.Context, session *amqp.Session) error {
 	receiver, err := session.NewReceiver(
 		amqp.LinkSourceAddress(getSiteQueryAddress(s.siteInfo.SiteId)),
 		amqp.LinkCredit(10),
 	)
 	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create amqp receiver %s", err.Error()))
-		return
+		return fmt.Errorf("Failed to create amqp receiver: %s", err)
 	}
 	sender, err := session.NewSender()
 	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Failed to create sender: %s", err))
-		return
+		return fmt.Errorf("Failed to create sender: %s", err)
 	}
+	log.Println("Site query server links to skupper-messaging service established")
 	for {
 		msg, err := receiver.Receive(ctx)
 		if err != nil {
-			utilruntime.HandleError(fmt.Errorf("Failed reading message from service sync %s", err.Error()))
-			return
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("Failed reading message for site query: %s", err)
 		}
 		msg.Accept()
 
 		bytes, err := json.Marshal(s.siteInfo)
 		if err != nil {
-			utilruntime.HandleError(fmt.Errorf("Could not encode response: %s", err))
-			return
+			return fmt.Errorf("Could not encode response: %s", err)
 		}
 
 		correlationId, ok := qdr.AsUint64(msg.Properties.CorrelationID)
@@ -137,8 +116,10 @@ func (s *SiteQueryServer) run() {
 
 		err = sender.Send(ctx, &response)
 		if err != nil {
-			utilruntime.HandleError(fmt.Errorf("Could not send response: %s", err))
-			return
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("Could not send response: %s", err)
 		}
 	}
 }
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -16,18 +18,33 @@ import (
 	corev1informer "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/informers/internalinterfaces"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/generated/client/clientset/versioned"
+	"github.com/skupperproject/skupper/pkg/health"
+	"github.com/skupperproject/skupper/pkg/kube"
 )
 
 type SiteController struct {
 	vanClient            *client.VanClient
+	siteClients          map[string]*client.VanClient
+	siteClientsLock      sync.Mutex
 	siteInformer         cache.SharedIndexInformer
 	tokenInformer        cache.SharedIndexInformer
 	tokenRequestInformer cache.SharedIndexInformer
 	workqueue            workqueue.RateLimitingInterface
+	eventRecorder        record.EventRecorder
+	progress             health.Progress
+	// the following are only set if the skupper custom resources
+	// are installed
+	skupperClient                versioned.Interface
+	siteResourceInformer         cache.SharedIndexInformer
+	linkInformer                 cache.SharedIndexInformer
+	tokenRequestResourceInformer cache.SharedIndexInformer
+	serviceExportInformer        cache.SharedIndexInformer
 }
 
 func NewSiteController(cli *client.VanClient) (*SiteController, error) {
@@ -70,30 +87,79 @@ func NewSiteController(cli *client.VanClient) (*SiteController, error) {
 
 	controller := &SiteController{
 		vanClient:            cli,
+		siteClients:          map[string]*client.VanClient{},
 		siteInformer:         siteInformer,
 		tokenInformer:        tokenInformer,
 		tokenRequestInformer: tokenRequestInformer,
 		workqueue:            workqueue,
+		eventRecorder:        kube.NewEventRecorder("skupper-site-controller", cli.KubeClient),
 	}
 
 	siteInformer.AddEventHandler(controller.getHandlerFuncs(SiteConfig, configmapResourceVersionTest))
 	tokenInformer.AddEventHandler(controller.getHandlerFuncs(Token, secretResourceVersionTest))
 	tokenRequestInformer.AddEventHandler(controller.getHandlerFuncs(TokenRequest, secretResourceVersionTest))
+
+	if cli.RestConfig != nil && hasCustomResources(cli) {
+		skupperClient, err := versioned.NewForConfig(cli.RestConfig)
+		if err != nil {
+			return nil, err
+		}
+		controller.watchCustomResources(skupperClient, watchNamespace)
+		log.Println("Skupper site controller watching skupper custom resources")
+	} else {
+		log.Println("Skupper custom resources not installed")
+	}
 	return controller, nil
 }
 
+// getVanClient returns a client for the site in the given namespace.
+// The controller's own client is for the namespace the controller runs
+// in, which is not that of the site when watching all namespaces.
+func (c *SiteController) getVanClient(namespace string) *client.VanClient {
+	c.siteClientsLock.Lock()
+	defer c.siteClientsLock.Unlock()
+	if cli, ok := c.siteClients[namespace]; ok {
+		return cli
+	}
+	cli := &client.VanClient{
+		Namespace:   namespace,
+		KubeClient:  c.vanClient.KubeClient,
+		RouteClient: c.vanClient.RouteClient,
+		RestConfig:  c.vanClient.RestConfig,
+	}
+	if c.siteClients == nil {
+		c.siteClients = map[string]*client.VanClient{}
+	}
+	c.siteClients[namespace] = cli
+	return cli
+}
+
+// forgetVanClient discards the client for a namespace once the site in
+// it is removed
+func (c *SiteController) forgetVanClient(namespace string) {
+	c.siteClientsLock.Lock()
+	defer c.siteClientsLock.Unlock()
+	delete(c.siteClients, namespace)
+}
+
 type resourceVersionTest func(a interface{}, b interface{}) bool
 
 func configmapResourceVersionTest(a interface{}, b interface{}) bool {
 	aa := a.(*corev1.ConfigMap)
 	bb := b.(*corev1.ConfigMap)
-	return aa.ResourceVersion == bb.ResourceVersion
+	if aa.ResourceVersion == bb.ResourceVersion {
+		return true
+	}
+	return reflect.DeepEqual(aa.Data, bb.Data) && onlyStatusChanged(&aa.ObjectMeta, &bb.ObjectMeta)
 }
 
 func secretResourceVersionTest(a interface{}, b interface{}) bool {
 	aa := a.(*corev1.Secret)
 	bb := b.(*corev1.Secret)
-	return aa.ResourceVersion == bb.ResourceVersion
+	if aa.ResourceVersion == bb.ResourceVersion {
+		return true
+	}
+	return reflect.DeepEqual(aa.Data, bb.Data) && onlyStatusChanged(&aa.ObjectMeta, &bb.ObjectMeta)
 }
 
 func (c *SiteController) getHandlerFuncs(category triggerType, test resourceVersionTest) *cache.ResourceEventHandlerFuncs {
@@ -120,9 +186,14 @@ func (c *SiteController) Run(stopCh <-chan struct{}) error {
 	go c.siteInformer.Run(stopCh)
 	go c.tokenInformer.Run(stopCh)
 	go c.tokenRequestInformer.Run(stopCh)
+	synced := []cache.InformerSynced{c.siteInformer.HasSynced, c.tokenInformer.HasSynced}
+	for _, informer := range c.customResourceInformers() {
+		go informer.Run(stopCh)
+		synced = append(synced, informer.HasSynced)
+	}
 
 	log.Println("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, c.siteInformer.HasSynced, c.tokenInformer.HasSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, synced...); !ok {
 		return fmt.Errorf("Failed to wait for caches to sync")
 	}
 
@@ -132,15 +203,43 @@ func (c *SiteController) Run(stopCh <-chan struct{}) error {
 
 	<-stopCh
 	log.Println("Shutting down workers")
+	c.workqueue.ShutDown()
+	c.removeSiteFinalizers()
 	return nil
 }
 
+// HealthPort is where the liveness and readiness probes are served
+const HealthPort int = 8081
+
+// how long a trigger may take to process before the controller is
+// considered stuck
+const stuckLimit time.Duration = 5 * time.Minute
+
+// newHealthChecker returns the checks for the controller's liveness
+// and readiness probes
+func (c *SiteController) newHealthChecker() *health.Checker {
+	synced := []cache.InformerSynced{c.siteInformer.HasSynced, c.tokenInformer.HasSynced, c.tokenRequestInformer.HasSynced}
+	for _, informer := range c.customResourceInformers() {
+		synced = append(synced, informer.HasSynced)
+	}
+	checker := health.NewChecker()
+	checker.AddLivenessCheck("workqueue", c.progress.Check(stuckLimit))
+	checker.AddReadinessCheck("informers", health.InformersSynced(synced...))
+	return checker
+}
+
 type triggerType int
 
 const (
 	SiteConfig triggerType = iota
 	Token
 	TokenRequest
+	SiteResource
+	LinkResource
+	TokenRequestResource
+	ServiceExportResource
+	SiteStatus
+	TokenStatus
 )
 
 type trigger struct {
@@ -161,6 +260,8 @@ func (c *SiteController) processNextTrigger() bool {
 	}
 
 	defer c.workqueue.Done(obj)
+	c.progress.Start()
+	defer c.progress.Finish()
 	var t trigger
 	var ok bool
 	if t, ok = obj.(trigger); !ok {
@@ -187,6 +288,18 @@ func (c *SiteController) dispatchTrigger(trigger trigger) error {
 		return c.checkToken(trigger.key)
 	case TokenRequest:
 		return c.checkTokenRequest(trigger.key)
+	case SiteResource:
+		return c.checkSiteResource(trigger.key)
+	case LinkResource:
+		return c.checkLink(trigger.key)
+	case TokenRequestResource:
+		return c.checkTokenRequestResource(trigger.key)
+	case ServiceExportResource:
+		return c.checkServiceExport(trigger.key)
+	case SiteStatus:
+		return c.checkSiteStatus(trigger.key)
+	case TokenStatus:
+		return c.checkTokenStatus(trigger.key)
 	default:
 		return fmt.Errorf("invalid trigger %d", trigger.category)
 	}
@@ -206,12 +319,12 @@ func (c *SiteController) enqueueTrigger(obj interface{}, category triggerType) {
 	})
 }
 
-func (c *SiteController) checkAllForSite() {
+func (c *SiteController) checkAllForSite(namespace string) {
 	// Now need to check whether there are any token requests already in place
-	log.Println("Checking tokens...")
-	c.checkAllTokens()
-	log.Println("Checking token requests...")
-	c.checkAllTokenRequests()
+	log.Printf("Checking tokens in %s...", namespace)
+	c.checkAllTokens(namespace)
+	log.Printf("Checking token requests in %s...", namespace)
+	c.checkAllTokenRequests(namespace)
 	log.Println("Done.")
 }
 
@@ -229,7 +342,18 @@ func (c *SiteController) checkSite(key string) error {
 		return err
 	} else if exists {
 		configmap := obj.(*corev1.ConfigMap)
-		routerInspectResponse, err := c.vanClient.RouterInspect(context.Background())
+		if configmap.ObjectMeta.DeletionTimestamp != nil {
+			return c.teardownSite(configmap)
+		}
+		if err := c.updateSiteFinalizer(siteNamespace, true); err != nil {
+			log.Println("Error adding finalizer to skupper-site config map: ", err)
+			return err
+		}
+		if err := c.labelSiteNamespace(siteNamespace, true); err != nil {
+			log.Printf("Error labelling namespace %s: %s", siteNamespace, err)
+		}
+		cli := c.getVanClient(siteNamespace)
+		routerInspectResponse, err := cli.RouterInspect(context.Background())
 		if err == nil {
 			log.Println("Skupper site exists ", key)
 			wantEdgeMode := configmap.Data["edge"] == "true"
@@ -238,23 +362,51 @@ func (c *SiteController) checkSite(key string) error {
 			if wantEdgeMode != haveEdgeMode {
 				//TODO: enable van router update
 			}
-			c.checkAllForSite()
+			siteConfig, err := cli.SiteConfigInspect(context.Background(), configmap)
+			if err != nil {
+				log.Println("Error reading skupper-site config map: ", err)
+				return c.siteFailed(configmap, err)
+			}
+			updated, err := cli.RouterUpdatePodSettings(context.Background(), siteConfig.Spec)
+			if err != nil {
+				log.Println("Error updating pod settings: ", err)
+				return c.siteFailed(configmap, err)
+			} else if updated {
+				log.Println("Updated pod settings for site ", key)
+				if err := c.siteAccepted(configmap, EventSiteUpdated, "Updated pod settings"); err != nil {
+					return err
+				}
+			} else if getStatus(&configmap.ObjectMeta) != types.StatusReady {
+				if err := c.siteAccepted(configmap, EventSiteUpdated, "Site configuration accepted"); err != nil {
+					return err
+				}
+			}
+			c.checkAllForSite(siteNamespace)
 		} else if errors.IsNotFound(err) {
-			log.Println("Initialising skupper site ...")
-			siteConfig, _ := c.vanClient.SiteConfigInspect(context.Background(), configmap)
+			log.Printf("Initialising skupper site in %s ...", siteNamespace)
+			siteConfig, err := cli.SiteConfigInspect(context.Background(), configmap)
+			if err != nil {
+				log.Println("Error reading skupper-site config map: ", err)
+				return c.siteFailed(configmap, err)
+			}
 			siteConfig.Spec.SkupperNamespace = siteNamespace
-			err = c.vanClient.RouterCreate(context.Background(), *siteConfig)
+			err = cli.RouterCreate(context.Background(), *siteConfig)
 			if err != nil {
 				log.Println("Error initialising skupper: ", err)
-				return err
+				return c.siteFailed(configmap, err)
 			} else {
-				log.Println("Skupper site initialised")
-				c.checkAllForSite()
+				log.Println("Skupper site initialised in ", siteNamespace)
+				if err := c.siteAccepted(configmap, EventSiteInitialised, "Skupper site initialised"); err != nil {
+					return err
+				}
+				c.checkAllForSite(siteNamespace)
 			}
 		} else {
 			log.Println("Error inspecting VAN router: ", err)
 			return err
 		}
+	} else {
+		c.forgetVanClient(siteNamespace)
 	}
 	return nil
 }
@@ -282,7 +434,7 @@ func (c *SiteController) connect(token *corev1.Secret, namespace string) error {
 	if cost, ok := getTokenCost(token); ok {
 		options.Cost = cost
 	}
-	return c.vanClient.ConnectorCreate(context.Background(), token, options)
+	return c.getVanClient(namespace).ConnectorCreate(context.Background(), token, options)
 }
 
 func (c *SiteController) disconnect(name string, namespace string) error {
@@ -292,12 +444,12 @@ func (c *SiteController) disconnect(name string, namespace string) error {
 	options.SkupperNamespace = namespace
 	// Secret has already been deleted so force update to current active secrets
 	options.ForceCurrent = true
-	return c.vanClient.ConnectorRemove(context.Background(), options)
+	return c.getVanClient(namespace).ConnectorRemove(context.Background(), options)
 }
 
 func (c *SiteController) generate(token *corev1.Secret) error {
 	log.Printf("Generating token for request %s...", token.ObjectMeta.Name)
-	generated, _, err := c.vanClient.ConnectorTokenCreate(context.Background(), token.ObjectMeta.Name, token.ObjectMeta.Namespace)
+	generated, _, err := c.getVanClient(token.ObjectMeta.Namespace).ConnectorTokenCreate(context.Background(), token.ObjectMeta.Name, token.ObjectMeta.Namespace)
 	if err == nil {
 		token.Data = generated.Data
 		if token.ObjectMeta.Annotations == nil {
@@ -311,26 +463,43 @@ func (c *SiteController) generate(token *corev1.Secret) error {
 		if siteId != "" {
 			token.ObjectMeta.Annotations[types.TokenGeneratedBy] = siteId
 		}
+		setStatus(&token.ObjectMeta, types.StatusGenerated, "", "")
 		_, err = c.vanClient.KubeClient.CoreV1().Secrets(token.ObjectMeta.Namespace).Update(token)
+		if err == nil {
+			c.eventRecorder.Event(token, corev1.EventTypeNormal, EventTokenGenerated, "Token generated")
+		}
 		return err
 	} else {
 		log.Printf("Failed to generate token for request %s: %s", token.ObjectMeta.Name, err)
+		if changed, statusErr := c.setSecretStatus(token.ObjectMeta.Namespace, token.ObjectMeta.Name, types.StatusFailed, err.Error(), ""); statusErr != nil {
+			log.Printf("Failed to update status of token request %s: %s", token.ObjectMeta.Name, statusErr)
+		} else if changed {
+			c.eventRecorder.Event(token, corev1.EventTypeWarning, EventTokenRequestFailed, err.Error())
+		}
 		return err
 	}
 }
 
-func (c *SiteController) checkAllTokens() {
+func (c *SiteController) checkAllTokens(namespace string) {
 	//can we rely on the cache here?
-	tokens := c.tokenInformer.GetStore().List()
+	tokens, err := c.tokenInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		log.Printf("Error listing tokens in %s: %s", namespace, err)
+		return
+	}
 	for _, t := range tokens {
 		// service from the workqueue
 		c.enqueueTrigger(t, Token)
 	}
 }
 
-func (c *SiteController) checkAllTokenRequests() {
+func (c *SiteController) checkAllTokenRequests(namespace string) {
 	//can we rely on the cache here?
-	tokens := c.tokenRequestInformer.GetStore().List()
+	tokens, err := c.tokenRequestInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		log.Printf("Error listing token requests in %s: %s", namespace, err)
+		return
+	}
 	for _, t := range tokens {
 		// service from workqueue
 		c.enqueueTrigger(t, TokenRequest)
@@ -347,7 +516,10 @@ func (c *SiteController) checkToken(key string) error {
 		if err == nil {
 			token := obj.(*corev1.Secret)
 			if c.isTokenValidInSite(token) {
-				return c.connect(token, siteNamespace)
+				if err := c.connect(token, siteNamespace); err != nil {
+					return c.tokenFailed(token, err)
+				}
+				return c.tokenAccepted(token)
 			} else {
 				return nil
 			}
@@ -375,7 +547,11 @@ func (c *SiteController) checkTokenRequest(key string) error {
 		token := obj.(*corev1.Secret)
 		if !c.isTokenRequestValidInSite(token) {
 			log.Println("Cannot handle token request, as site not yet initialised")
-			return nil
+			changed, err := c.setSecretStatus(token.ObjectMeta.Namespace, token.ObjectMeta.Name, types.StatusAccepted, "Waiting for site to be initialised", "")
+			if changed {
+				c.eventRecorder.Event(token, corev1.EventTypeNormal, EventTokenRequestDeferred, "Waiting for site to be initialised")
+			}
+			return err
 		}
 		return c.generate(token)
 	}
@@ -392,6 +568,9 @@ func (c *SiteController) getSiteIdForNamespace(namespace string) string {
 		}
 		return ""
 	}
+	if siteId, ok := cm.ObjectMeta.Annotations[types.SiteIdQualifier]; ok && siteId != "" {
+		return siteId
+	}
 	return string(cm.ObjectMeta.UID)
 }
 
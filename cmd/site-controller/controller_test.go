@@ -3,7 +3,20 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 	"testing"
+
+	"gotest.tools/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+	"github.com/skupperproject/skupper/pkg/qdr"
 )
 
 var clusterRun = flag.Bool("use-cluster", false, "run tests against a configured cluster")
@@ -12,3 +25,109 @@ func TestMain(m *testing.M) {
 	flag.Parse()
 	os.Exit(m.Run())
 }
+
+func newSiteConfigMap(namespace string, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.DefaultSiteName,
+			Namespace: namespace,
+			UID:       k8stypes.UID(namespace + "-site-id"),
+		},
+		Data: map[string]string{
+			"name":               name,
+			"edge":               "false",
+			"cluster-local":      "true",
+			"service-controller": "false",
+		},
+	}
+}
+
+func newTokenRequest(namespace string, name string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				types.SkupperTypeQualifier: "connection-token-request",
+			},
+		},
+	}
+}
+
+func getRouterConfig(t *testing.T, controller *SiteController, namespace string) *qdr.RouterConfig {
+	configmap, err := kube.GetConfigMap("skupper-internal", namespace, controller.vanClient.KubeClient)
+	assert.Assert(t, err)
+	config, err := qdr.GetRouterConfigFromConfigMap(configmap)
+	assert.Assert(t, err)
+	return config
+}
+
+func TestSitesInMultipleNamespaces(t *testing.T) {
+	sites := []runtime.Object{
+		newSiteConfigMap("east", "east-site"),
+		newSiteConfigMap("west", "west-site"),
+		newTokenRequest("east", "east-token"),
+		newTokenRequest("west", "west-token"),
+	}
+	controller := newFakeSiteController(sites, nil)
+	controller.vanClient.Namespace = "skupper-site-controller"
+	for _, obj := range sites {
+		if configmap, ok := obj.(*corev1.ConfigMap); ok {
+			controller.siteInformer.GetStore().Add(configmap)
+		} else {
+			controller.tokenRequestInformer.GetStore().Add(obj)
+		}
+	}
+
+	for _, namespace := range []string{"east", "west"} {
+		assert.Assert(t, controller.checkSite(namespace+"/skupper-site"))
+		_, err := kube.GetDeployment(types.TransportDeploymentName, namespace, controller.vanClient.KubeClient)
+		assert.Assert(t, err)
+		assert.Equal(t, getRouterConfig(t, controller, namespace).Metadata.Metadata, namespace+"-site-id")
+		assert.Equal(t, controller.getVanClient(namespace).Namespace, namespace)
+	}
+	_, err := kube.GetDeployment(types.TransportDeploymentName, "skupper-site-controller", controller.vanClient.KubeClient)
+	assert.Assert(t, errors.IsNotFound(err))
+
+	// each site generates tokens for itself
+	for _, namespace := range []string{"east", "west"} {
+		assert.Assert(t, controller.checkTokenRequest(namespace+"/"+namespace+"-token"))
+		token, err := controller.vanClient.KubeClient.CoreV1().Secrets(namespace).Get(namespace+"-token", metav1.GetOptions{})
+		assert.Assert(t, err)
+		assert.Equal(t, token.ObjectMeta.Labels[types.SkupperTypeQualifier], types.TypeToken)
+		assert.Equal(t, token.ObjectMeta.Annotations[types.TokenGeneratedBy], namespace+"-site-id")
+		assert.Equal(t, token.ObjectMeta.Annotations[types.TokenSiteName], namespace+"-site")
+		assert.Assert(t, strings.Contains(token.ObjectMeta.Annotations["inter-router-host"], "."+namespace))
+	}
+
+	// a token from east applied in west links west to east only
+	eastToken, err := controller.vanClient.KubeClient.CoreV1().Secrets("east").Get("east-token", metav1.GetOptions{})
+	assert.Assert(t, err)
+	link := eastToken.DeepCopy()
+	link.ObjectMeta = metav1.ObjectMeta{
+		Name:        "link-to-east",
+		Namespace:   "west",
+		Labels:      eastToken.ObjectMeta.Labels,
+		Annotations: eastToken.ObjectMeta.Annotations,
+	}
+	link, err = controller.vanClient.KubeClient.CoreV1().Secrets("west").Create(link)
+	assert.Assert(t, err)
+	controller.tokenInformer.GetStore().Add(link)
+	assert.Assert(t, controller.checkToken("west/link-to-east"))
+	_, ok := getRouterConfig(t, controller, "west").Connectors["link-to-east"]
+	assert.Assert(t, ok)
+	assert.Equal(t, len(getRouterConfig(t, controller, "east").Connectors), 0)
+
+	// the east token is not applied in east itself
+	controller.tokenInformer.GetStore().Add(eastToken)
+	assert.Assert(t, controller.checkToken("east/east-token"))
+	assert.Equal(t, len(getRouterConfig(t, controller, "east").Connectors), 0)
+
+	// removing a site discards its client
+	controller.siteInformer.GetStore().Delete(sites[0])
+	assert.Assert(t, controller.checkSite("east/skupper-site"))
+	_, ok = controller.siteClients["east"]
+	assert.Assert(t, !ok)
+	_, ok = controller.siteClients["west"]
+	assert.Assert(t, ok)
+}
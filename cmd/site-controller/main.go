@@ -52,6 +52,9 @@ func main() {
 		log.Fatal("Error getting new site controller ", err.Error())
 	}
 
+	go runWebhook(getWebhookCertDir(), stopCh)
+	go controller.newHealthChecker().Serve(HealthPort, stopCh)
+
 	if err = controller.Run(stopCh); err != nil {
 		log.Fatal("Error running site controller: ", err.Error())
 	}
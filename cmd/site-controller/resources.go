@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	jsonencoding "encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/apis/skupper/v1alpha1"
+	"github.com/skupperproject/skupper/pkg/generated/client/clientset/versioned"
+	v1alpha1client "github.com/skupperproject/skupper/pkg/generated/client/clientset/versioned/typed/skupper/v1alpha1"
+	skupperinformers "github.com/skupperproject/skupper/pkg/generated/client/informers/externalversions/skupper/v1alpha1"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+// Reasons given in the Ready condition of the custom resources
+const (
+	ReasonReady         string = "Ready"
+	ReasonPending       string = "Pending"
+	ReasonConflict      string = "Conflict"
+	ReasonInvalid       string = "Invalid"
+	ReasonError         string = "Error"
+	ReasonSiteNotReady  string = "SiteNotReady"
+	ReasonTokenNotFound string = "TokenNotFound"
+	ReasonConnecting    string = "Connecting"
+	ReasonConnected     string = "Connected"
+	ReasonGenerated     string = "Generated"
+	ReasonExported      string = "Exported"
+)
+
+// ServiceExportFinalizer ensures the service definition for an export
+// is removed before the export itself
+const ServiceExportFinalizer string = "skupper.io/service-export"
+
+const recheckInterval = time.Second * 5
+
+func metaResourceVersionTest(a interface{}, b interface{}) bool {
+	aa, err := meta.Accessor(a)
+	if err != nil {
+		return false
+	}
+	bb, err := meta.Accessor(b)
+	if err != nil {
+		return false
+	}
+	return aa.GetResourceVersion() == bb.GetResourceVersion()
+}
+
+// hasCustomResources returns true if the skupper custom resource
+// definitions are installed in the cluster
+func hasCustomResources(cli *client.VanClient) bool {
+	_, err := cli.KubeClient.Discovery().ServerResourcesForGroupVersion(v1alpha1.SchemeGroupVersion.String())
+	return err == nil
+}
+
+// watchCustomResources sets up informers for the skupper custom
+// resources, which are reconciled onto the same operations as the
+// skupper-site config map and token secrets
+func (c *SiteController) watchCustomResources(skupperClient versioned.Interface, watchNamespace string) {
+	c.skupperClient = skupperClient
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	c.siteResourceInformer = skupperinformers.NewSiteInformer(skupperClient, watchNamespace, time.Second*30, indexers)
+	c.linkInformer = skupperinformers.NewLinkInformer(skupperClient, watchNamespace, time.Second*30, indexers)
+	c.tokenRequestResourceInformer = skupperinformers.NewTokenRequestInformer(skupperClient, watchNamespace, time.Second*30, indexers)
+	c.serviceExportInformer = skupperinformers.NewServiceExportInformer(skupperClient, watchNamespace, time.Second*30, indexers)
+	c.siteResourceInformer.AddEventHandler(c.getHandlerFuncs(SiteResource, metaResourceVersionTest))
+	c.linkInformer.AddEventHandler(c.getHandlerFuncs(LinkResource, metaResourceVersionTest))
+	c.tokenRequestResourceInformer.AddEventHandler(c.getHandlerFuncs(TokenRequestResource, metaResourceVersionTest))
+	c.serviceExportInformer.AddEventHandler(c.getHandlerFuncs(ServiceExportResource, metaResourceVersionTest))
+}
+
+func (c *SiteController) customResourceInformers() []cache.SharedIndexInformer {
+	if c.skupperClient == nil {
+		return nil
+	}
+	return []cache.SharedIndexInformer{
+		c.siteResourceInformer,
+		c.linkInformer,
+		c.tokenRequestResourceInformer,
+		c.serviceExportInformer,
+	}
+}
+
+func boolOrDefault(value *bool, defaultValue bool) bool {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
+
+func getSiteConfigSpec(site *v1alpha1.Site) types.SiteConfigSpec {
+	return types.SiteConfigSpec{
+		SkupperName:                site.Spec.SiteName,
+		IsEdge:                     site.Spec.Edge,
+		EnableController:           boolOrDefault(site.Spec.ServiceController, true),
+		EnableServiceSync:          boolOrDefault(site.Spec.ServiceSync, true),
+		EnableConsole:              site.Spec.Console,
+		EnableRouterConsole:        site.Spec.RouterConsole,
+		AuthMode:                   site.Spec.ConsoleAuthentication,
+		ClusterLocal:               site.Spec.ClusterLocal,
+		Ingress:                    site.Spec.Ingress,
+		IngressHost:                site.Spec.IngressHost,
+		Replicas:                   site.Spec.Routers,
+		KeepCertificateAuthorities: site.Spec.KeepCertificateAuthorities,
+		SiteControlled:             true,
+	}
+}
+
+func getOwnerReference(obj metav1.Object, kind string) metav1.OwnerReference {
+	return *metav1.NewControllerRef(obj, v1alpha1.SchemeGroupVersion.WithKind(kind))
+}
+
+func isOwnedBy(obj metav1.Object, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *SiteController) checkSiteResource(key string) error {
+	obj, exists, err := c.siteResourceInformer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		// the skupper-site config map is owned by the site and
+		// will be garbage collected
+		return nil
+	}
+	site := obj.(*v1alpha1.Site).DeepCopy()
+	cli := c.getVanClient(site.ObjectMeta.Namespace)
+	reason, err := c.reconcileSite(cli, site)
+	if err == nil && reason == ReasonReady {
+		site.Status.SetReady(reason, nil, site.ObjectMeta.Generation)
+	} else if err == nil {
+		site.Status.SetCondition(v1alpha1.ConditionReady, corev1.ConditionFalse, reason, "Waiting for the router to be ready", site.ObjectMeta.Generation)
+	} else {
+		site.Status.SetReady(reason, err, site.ObjectMeta.Generation)
+	}
+	if reason == ReasonPending {
+		c.recheckLater(key, SiteResource)
+	}
+	return c.updateSiteStatus(site, obj.(*v1alpha1.Site))
+}
+
+// recheckLater requeues a resource whose state depends on something
+// that is not itself watched, e.g. the readiness of the router
+func (c *SiteController) recheckLater(key string, category triggerType) {
+	c.workqueue.AddAfter(trigger{
+		key:      key,
+		category: category,
+	}, recheckInterval)
+}
+
+func (c *SiteController) reconcileSite(cli *client.VanClient, site *v1alpha1.Site) (string, error) {
+	desired, err := cli.NewSiteConfigMap(getSiteConfigSpec(site))
+	if err != nil {
+		return ReasonInvalid, err
+	}
+	for key, value := range site.Spec.Settings {
+		desired.Data[key] = value
+	}
+	if _, err := cli.SiteConfigInspect(context.Background(), desired); err != nil {
+		return ReasonInvalid, err
+	}
+	desired.ObjectMeta.OwnerReferences = []metav1.OwnerReference{getOwnerReference(site, "Site")}
+	configmaps := cli.KubeClient.CoreV1().ConfigMaps(cli.Namespace)
+	actual, err := configmaps.Get(types.DefaultSiteName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		log.Printf("Creating skupper-site for site %s/%s", site.ObjectMeta.Namespace, site.ObjectMeta.Name)
+		if _, err := configmaps.Create(desired); err != nil {
+			return ReasonError, err
+		}
+		return ReasonPending, nil
+	} else if err != nil {
+		return ReasonError, err
+	} else if !isOwnedBy(actual, site) {
+		return ReasonConflict, fmt.Errorf("%s already exists and is not managed by this site", types.DefaultSiteName)
+	} else if !reflect.DeepEqual(actual.Data, desired.Data) {
+		log.Printf("Updating skupper-site for site %s/%s", site.ObjectMeta.Namespace, site.ObjectMeta.Name)
+		actual.Data = desired.Data
+		if _, err := configmaps.Update(actual); err != nil {
+			return ReasonError, err
+		}
+	}
+	router, err := kube.GetDeployment(types.TransportDeploymentName, cli.Namespace, cli.KubeClient)
+	if errors.IsNotFound(err) {
+		return ReasonPending, nil
+	} else if err != nil {
+		return ReasonError, err
+	} else if router.Status.ReadyReplicas == 0 {
+		return ReasonPending, nil
+	}
+	return ReasonReady, nil
+}
+
+func (c *SiteController) updateSiteStatus(site *v1alpha1.Site, original *v1alpha1.Site) error {
+	if reflect.DeepEqual(site.Status, original.Status) {
+		return nil
+	}
+	_, err := c.skupperClient.SkupperV1alpha1().Sites(site.ObjectMeta.Namespace).UpdateStatus(site)
+	return err
+}
+
+// getLinkTokenName returns the name of the connection-token secret
+// through which the link is made
+func getLinkTokenName(link *v1alpha1.Link) string {
+	return link.ObjectMeta.Name
+}
+
+func (c *SiteController) checkLink(key string) error {
+	obj, exists, err := c.linkInformer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		// the token secret is owned by the link, and when it
+		// is garbage collected the link will be removed
+		return nil
+	}
+	link := obj.(*v1alpha1.Link).DeepCopy()
+	cli := c.getVanClient(link.ObjectMeta.Namespace)
+	reason, err := c.reconcileLink(cli, link)
+	link.Status.SetReady(reason, err, link.ObjectMeta.Generation)
+	if reason == ReasonConnecting {
+		c.recheckLater(key, LinkResource)
+	}
+	if reflect.DeepEqual(link.Status, obj.(*v1alpha1.Link).Status) {
+		return nil
+	}
+	_, err = c.skupperClient.SkupperV1alpha1().Links(link.ObjectMeta.Namespace).UpdateStatus(link)
+	return err
+}
+
+func (c *SiteController) reconcileLink(cli *client.VanClient, link *v1alpha1.Link) (string, error) {
+	secrets := cli.KubeClient.CoreV1().Secrets(cli.Namespace)
+	source, err := secrets.Get(link.Spec.TokenSecret, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return ReasonTokenNotFound, fmt.Errorf("Token secret %s not found", link.Spec.TokenSecret)
+	} else if err != nil {
+		return ReasonError, err
+	}
+	if author, ok := source.ObjectMeta.Annotations[types.TokenGeneratedBy]; ok && author == c.getSiteIdForNamespace(cli.Namespace) {
+		return ReasonInvalid, fmt.Errorf("Cannot link site to itself")
+	}
+	name := getLinkTokenName(link)
+	var existing *corev1.Secret
+	desired := source.DeepCopy()
+	if source.ObjectMeta.Name == name {
+		existing = source
+	} else {
+		existing, err = secrets.Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			existing = nil
+			desired.ObjectMeta = metav1.ObjectMeta{
+				Name:        name,
+				Labels:      desired.ObjectMeta.Labels,
+				Annotations: withoutStatus(desired.ObjectMeta.Annotations),
+			}
+		} else if err != nil {
+			return ReasonError, err
+		} else if !isOwnedBy(existing, link) {
+			return ReasonConflict, fmt.Errorf("Secret %s already exists and is not managed by this link", name)
+		} else {
+			// the annotations follow the source token, but the
+			// status reported on the link's token is kept
+			copied := desired
+			desired = existing.DeepCopy()
+			desired.Data = copied.Data
+			desired.ObjectMeta.Annotations = withoutStatus(copied.ObjectMeta.Annotations)
+			for _, key := range statusAnnotations {
+				if value, ok := existing.ObjectMeta.Annotations[key]; ok {
+					desired.ObjectMeta.Annotations[key] = value
+				}
+			}
+		}
+	}
+	if desired.ObjectMeta.Labels == nil {
+		desired.ObjectMeta.Labels = map[string]string{}
+	}
+	desired.ObjectMeta.Labels[types.SkupperTypeQualifier] = types.TypeToken
+	if desired.ObjectMeta.Annotations == nil {
+		desired.ObjectMeta.Annotations = map[string]string{}
+	}
+	if link.Spec.Cost != 0 {
+		desired.ObjectMeta.Annotations[types.TokenCost] = strconv.Itoa(int(link.Spec.Cost))
+	}
+	if !isOwnedBy(desired, link) {
+		desired.ObjectMeta.OwnerReferences = append(desired.ObjectMeta.OwnerReferences, getOwnerReference(link, "Link"))
+	}
+	if existing == nil {
+		log.Printf("Creating token %s for link %s/%s", name, link.ObjectMeta.Namespace, link.ObjectMeta.Name)
+		if _, err := secrets.Create(desired); err != nil {
+			return ReasonError, err
+		}
+		return ReasonConnecting, fmt.Errorf("Link is being established")
+	} else if !reflect.DeepEqual(desired, existing) {
+		if _, err := secrets.Update(desired); err != nil {
+			return ReasonError, err
+		}
+	}
+	connector, err := cli.ConnectorInspect(context.Background(), name)
+	if err != nil {
+		return ReasonConnecting, err
+	}
+	if connector.Connector != nil {
+		link.Status.Url = connector.Connector.Host + ":" + connector.Connector.Port
+	}
+	if !connector.Connected {
+		return ReasonConnecting, fmt.Errorf("Link is not yet connected")
+	}
+	return ReasonConnected, nil
+}
+
+// getTokenRequestSecretName returns the name of the secret a token
+// request is fulfilled with
+func getTokenRequestSecretName(request *v1alpha1.TokenRequest) string {
+	if request.Spec.SecretName != "" {
+		return request.Spec.SecretName
+	}
+	return request.ObjectMeta.Name
+}
+
+func (c *SiteController) checkTokenRequestResource(key string) error {
+	obj, exists, err := c.tokenRequestResourceInformer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		return nil
+	}
+	request := obj.(*v1alpha1.TokenRequest).DeepCopy()
+	cli := c.getVanClient(request.ObjectMeta.Namespace)
+	reason, err := c.reconcileTokenRequest(cli, request)
+	request.Status.SetReady(reason, err, request.ObjectMeta.Generation)
+	if reflect.DeepEqual(request.Status, obj.(*v1alpha1.TokenRequest).Status) {
+		return nil
+	}
+	_, err = c.skupperClient.SkupperV1alpha1().TokenRequests(request.ObjectMeta.Namespace).UpdateStatus(request)
+	return err
+}
+
+func (c *SiteController) reconcileTokenRequest(cli *client.VanClient, request *v1alpha1.TokenRequest) (string, error) {
+	name := getTokenRequestSecretName(request)
+	secrets := cli.KubeClient.CoreV1().Secrets(cli.Namespace)
+	existing, err := secrets.Get(name, metav1.GetOptions{})
+	if err == nil {
+		if !isOwnedBy(existing, request) {
+			return ReasonConflict, fmt.Errorf("Secret %s already exists and is not managed by this request", name)
+		}
+		request.Status.SecretName = name
+		return ReasonGenerated, nil
+	} else if !errors.IsNotFound(err) {
+		return ReasonError, err
+	}
+	siteId := c.getSiteIdForNamespace(cli.Namespace)
+	if siteId == "" {
+		return ReasonSiteNotReady, fmt.Errorf("Site not yet initialised")
+	}
+	log.Printf("Generating token %s for request %s/%s", name, request.ObjectMeta.Namespace, request.ObjectMeta.Name)
+	token, _, err := cli.ConnectorTokenCreate(context.Background(), name, cli.Namespace)
+	if err != nil {
+		return ReasonSiteNotReady, err
+	}
+	token.ObjectMeta.Name = name
+	token.ObjectMeta.Annotations[types.TokenGeneratedBy] = siteId
+	token.ObjectMeta.OwnerReferences = []metav1.OwnerReference{getOwnerReference(request, "TokenRequest")}
+	if _, err := secrets.Create(token); err != nil {
+		return ReasonError, err
+	}
+	request.Status.SecretName = name
+	return ReasonGenerated, nil
+}
+
+// getServiceExportAddress returns the address at which an export is
+// made available
+func getServiceExportAddress(export *v1alpha1.ServiceExport) string {
+	if export.Spec.Address != "" {
+		return export.Spec.Address
+	}
+	return export.ObjectMeta.Name
+}
+
+func hasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(obj metav1.Object, finalizer string) {
+	finalizers := []string{}
+	for _, f := range obj.GetFinalizers() {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	obj.SetFinalizers(finalizers)
+}
+
+func (c *SiteController) checkServiceExport(key string) error {
+	obj, exists, err := c.serviceExportInformer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	} else if !exists {
+		return nil
+	}
+	export := obj.(*v1alpha1.ServiceExport).DeepCopy()
+	exports := c.skupperClient.SkupperV1alpha1().ServiceExports(export.ObjectMeta.Namespace)
+	cli := c.getVanClient(export.ObjectMeta.Namespace)
+	address := getServiceExportAddress(export)
+	if export.ObjectMeta.DeletionTimestamp != nil {
+		if !hasFinalizer(export, ServiceExportFinalizer) {
+			return nil
+		}
+		// only a definition the export created is removed
+		if owned := export.Status.Address; owned != "" {
+			log.Printf("Removing service %s for export %s", owned, key)
+			if err := removeServiceDefinition(cli, owned); err != nil {
+				return err
+			}
+		}
+		removeFinalizer(export, ServiceExportFinalizer)
+		_, err = exports.Update(export)
+		return err
+	}
+	if !hasFinalizer(export, ServiceExportFinalizer) {
+		export.ObjectMeta.Finalizers = append(export.ObjectMeta.Finalizers, ServiceExportFinalizer)
+		updated, err := exports.Update(export)
+		if err != nil {
+			return err
+		}
+		export = updated
+	}
+	original := export.Status.DeepCopy()
+	reason, err := claimServiceExportAddress(cli, exports, export, address)
+	if err == nil {
+		reason, err = c.reconcileServiceExport(cli, export)
+	}
+	export.Status.SetReady(reason, err, export.ObjectMeta.Generation)
+	if reflect.DeepEqual(&export.Status, original) {
+		return nil
+	}
+	_, err = exports.UpdateStatus(export)
+	return err
+}
+
+func removeServiceDefinition(cli *client.VanClient, address string) error {
+	current, err := cli.ServiceInterfaceInspect(context.Background(), address)
+	if err != nil {
+		return err
+	} else if current != nil {
+		return cli.ServiceInterfaceRemove(context.Background(), address)
+	}
+	return nil
+}
+
+// claimServiceExportAddress ensures the export owns the definition at
+// its address, removing that at any address it previously owned. The
+// claim is recorded in the status of the export before the definition
+// is created, so that ownership is never in doubt; an address already
+// defined otherwise is a conflict.
+func claimServiceExportAddress(cli *client.VanClient, exports v1alpha1client.ServiceExportInterface, export *v1alpha1.ServiceExport, address string) (string, error) {
+	if export.Status.Address == address {
+		return "", nil
+	}
+	if previous := export.Status.Address; previous != "" {
+		log.Printf("Removing service %s, no longer the address of export %s/%s", previous, export.ObjectMeta.Namespace, export.ObjectMeta.Name)
+		if err := removeServiceDefinition(cli, previous); err != nil {
+			return ReasonError, err
+		}
+		export.Status.Address = ""
+	}
+	current, err := cli.ServiceInterfaceInspect(context.Background(), address)
+	if err != nil {
+		return ReasonError, err
+	} else if current != nil {
+		return ReasonConflict, fmt.Errorf("Service %s is already defined and is not managed by this export", address)
+	}
+	export.Status.Address = address
+	updated, err := exports.UpdateStatus(export)
+	if err != nil {
+		return ReasonError, err
+	}
+	*export = *updated
+	return "", nil
+}
+
+func (c *SiteController) reconcileServiceExport(cli *client.VanClient, export *v1alpha1.ServiceExport) (string, error) {
+	desired := &types.ServiceInterface{
+		Address:  getServiceExportAddress(export),
+		Protocol: export.Spec.Protocol,
+		Port:     export.Spec.Port,
+		Targets:  []types.ServiceInterfaceTarget{},
+	}
+	if desired.Protocol == "" {
+		desired.Protocol = "tcp"
+	}
+	for _, t := range export.Spec.Targets {
+		target, err := cli.GetServiceInterfaceTarget(t.Type, t.Name, false)
+		if err != nil {
+			return ReasonInvalid, err
+		}
+		target.TargetPort = t.TargetPort
+		if target.TargetPort == desired.Port {
+			target.TargetPort = 0
+		}
+		desired.Targets = append(desired.Targets, *target)
+	}
+	current, err := cli.ServiceInterfaceInspect(context.Background(), desired.Address)
+	if err != nil {
+		return ReasonError, err
+	}
+	if current == nil {
+		err = cli.ServiceInterfaceCreate(context.Background(), desired)
+	} else if !equivalentServiceInterfaces(current, desired) {
+		err = cli.ServiceInterfaceUpdate(context.Background(), desired)
+	}
+	if err != nil {
+		return ReasonInvalid, err
+	}
+	return ReasonExported, nil
+}
+
+func equivalentServiceInterfaces(a *types.ServiceInterface, b *types.ServiceInterface) bool {
+	encodedA, errA := jsonencoding.Marshal(a)
+	encodedB, errB := jsonencoding.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+	"github.com/skupperproject/skupper/pkg/apis/skupper/v1alpha1"
+	skupperfake "github.com/skupperproject/skupper/pkg/generated/client/clientset/versioned/fake"
+)
+
+func newFakeSiteController(kubeObjects []runtime.Object, skupperObjects []runtime.Object) *SiteController {
+	cli := &client.VanClient{
+		KubeClient: fake.NewSimpleClientset(kubeObjects...),
+	}
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	controller := &SiteController{
+		vanClient:            cli,
+		siteInformer:         corev1informer.NewConfigMapInformer(cli.KubeClient, metav1.NamespaceAll, 0, indexers),
+		tokenInformer:        corev1informer.NewSecretInformer(cli.KubeClient, metav1.NamespaceAll, 0, indexers),
+		tokenRequestInformer: corev1informer.NewSecretInformer(cli.KubeClient, metav1.NamespaceAll, 0, indexers),
+		workqueue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test"),
+		eventRecorder:        record.NewFakeRecorder(100),
+	}
+	controller.watchCustomResources(skupperfake.NewSimpleClientset(skupperObjects...), metav1.NamespaceAll)
+	for _, obj := range skupperObjects {
+		switch o := obj.(type) {
+		case *v1alpha1.Site:
+			controller.siteResourceInformer.GetStore().Add(o)
+		case *v1alpha1.Link:
+			controller.linkInformer.GetStore().Add(o)
+		case *v1alpha1.TokenRequest:
+			controller.tokenRequestResourceInformer.GetStore().Add(o)
+		case *v1alpha1.ServiceExport:
+			controller.serviceExportInformer.GetStore().Add(o)
+		}
+	}
+	return controller
+}
+
+func getReadyCondition(t *testing.T, status v1alpha1.Status) *v1alpha1.Condition {
+	condition := status.GetCondition(v1alpha1.ConditionReady)
+	assert.Assert(t, condition != nil)
+	return condition
+}
+
+func newRouterDeployment(namespace string, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.TransportDeploymentName,
+			Namespace: namespace,
+		},
+		Status: appsv1.DeploymentStatus{
+			ReadyReplicas: ready,
+		},
+	}
+}
+
+func TestSiteResource(t *testing.T) {
+	site := &v1alpha1.Site{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "west",
+			Namespace: "west",
+			UID:       "site-uid",
+		},
+		Spec: v1alpha1.SiteSpec{
+			SiteName: "west-site",
+			Routers:  2,
+			Settings: map[string]string{
+				"router-cpu": "500m",
+			},
+		},
+	}
+	controller := newFakeSiteController(nil, []runtime.Object{site})
+	assert.Assert(t, controller.checkSiteResource("west/west"))
+
+	cm, err := controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, cm.Data["name"], "west-site")
+	assert.Equal(t, cm.Data["routers"], "2")
+	assert.Equal(t, cm.Data["router-cpu"], "500m")
+	assert.Equal(t, cm.Data["service-controller"], "true")
+	assert.Equal(t, len(cm.ObjectMeta.OwnerReferences), 1)
+	assert.Equal(t, cm.ObjectMeta.OwnerReferences[0].Kind, "Site")
+	assert.Equal(t, string(cm.ObjectMeta.OwnerReferences[0].UID), "site-uid")
+
+	updated, err := controller.skupperClient.SkupperV1alpha1().Sites("west").Get("west", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition := getReadyCondition(t, updated.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonPending)
+
+	// once the router is ready, so is the site
+	_, err = controller.vanClient.KubeClient.AppsV1().Deployments("west").Create(newRouterDeployment("west", 1))
+	assert.Assert(t, err)
+	controller.siteResourceInformer.GetStore().Update(updated)
+	assert.Assert(t, controller.checkSiteResource("west/west"))
+	updated, err = controller.skupperClient.SkupperV1alpha1().Sites("west").Get("west", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Assert(t, updated.Status.IsReady())
+
+	// changes to the spec are applied to the config map
+	updated.Spec.Edge = true
+	updated.ObjectMeta.Generation = 2
+	controller.siteResourceInformer.GetStore().Update(updated)
+	assert.Assert(t, controller.checkSiteResource("west/west"))
+	cm, err = controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, cm.Data["edge"], "true")
+}
+
+func TestSiteResourceConflict(t *testing.T) {
+	site := &v1alpha1.Site{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "east",
+			Namespace: "east",
+			UID:       "site-uid",
+		},
+	}
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.DefaultSiteName,
+			Namespace: "east",
+		},
+		Data: map[string]string{
+			"name": "manual",
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{existing}, []runtime.Object{site})
+	assert.Assert(t, controller.checkSiteResource("east/east"))
+
+	cm, err := controller.vanClient.KubeClient.CoreV1().ConfigMaps("east").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, cm.Data["name"], "manual")
+	updated, err := controller.skupperClient.SkupperV1alpha1().Sites("east").Get("east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition := getReadyCondition(t, updated.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonConflict)
+}
+
+func TestLinkResource(t *testing.T) {
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "token-from-east",
+			Namespace: "west",
+			Annotations: map[string]string{
+				types.TokenGeneratedBy: "east-site-id",
+			},
+		},
+		Data: map[string][]byte{
+			"ca.crt": []byte("ca"),
+		},
+	}
+	link := &v1alpha1.Link{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "to-east",
+			Namespace: "west",
+			UID:       "link-uid",
+		},
+		Spec: v1alpha1.LinkSpec{
+			TokenSecret: "token-from-east",
+			Cost:        5,
+		},
+	}
+	missing := &v1alpha1.Link{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "to-nowhere",
+			Namespace: "west",
+		},
+		Spec: v1alpha1.LinkSpec{
+			TokenSecret: "no-such-token",
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{token}, []runtime.Object{link, missing})
+	assert.Assert(t, controller.checkLink("west/to-east"))
+
+	secret, err := controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("to-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, secret.ObjectMeta.Labels[types.SkupperTypeQualifier], types.TypeToken)
+	assert.Equal(t, secret.ObjectMeta.Annotations[types.TokenCost], "5")
+	assert.Equal(t, secret.ObjectMeta.Annotations[types.TokenGeneratedBy], "east-site-id")
+	assert.DeepEqual(t, secret.Data, token.Data)
+	assert.Equal(t, len(secret.ObjectMeta.OwnerReferences), 1)
+	assert.Equal(t, string(secret.ObjectMeta.OwnerReferences[0].UID), "link-uid")
+	// the source token is left untouched
+	source, err := controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("token-from-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(source.ObjectMeta.OwnerReferences), 0)
+
+	updated, err := controller.skupperClient.SkupperV1alpha1().Links("west").Get("to-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition := getReadyCondition(t, updated.Status.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonConnecting)
+
+	// the status reported on the link's token survives reconciliation
+	_, err = controller.setSecretStatus("west", "to-east", types.StatusConnecting, "", "")
+	assert.Assert(t, err)
+	assert.Assert(t, controller.checkLink("west/to-east"))
+	secret, err = controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("to-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, secret.ObjectMeta.Annotations[types.StatusQualifier], types.StatusConnecting)
+	assert.Equal(t, secret.ObjectMeta.Annotations[types.TokenGeneratedBy], "east-site-id")
+
+	assert.Assert(t, controller.checkLink("west/to-nowhere"))
+	updated, err = controller.skupperClient.SkupperV1alpha1().Links("west").Get("to-nowhere", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition = getReadyCondition(t, updated.Status.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonTokenNotFound)
+}
+
+func TestTokenRequestResourceSiteNotReady(t *testing.T) {
+	request := &v1alpha1.TokenRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "for-east",
+			Namespace: "west",
+		},
+	}
+	controller := newFakeSiteController(nil, []runtime.Object{request})
+	assert.Assert(t, controller.checkTokenRequestResource("west/for-east"))
+
+	_, err := controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("for-east", metav1.GetOptions{})
+	assert.Assert(t, err != nil)
+	updated, err := controller.skupperClient.SkupperV1alpha1().TokenRequests("west").Get("for-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition := getReadyCondition(t, updated.Status.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonSiteNotReady)
+	assert.Equal(t, updated.Status.SecretName, "")
+}
+
+func TestServiceExportResource(t *testing.T) {
+	namespace := "west"
+	backend := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "backend",
+				},
+			},
+		},
+	}
+	services := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.ServiceInterfaceConfigMap,
+			Namespace: namespace,
+		},
+	}
+	export := &v1alpha1.ServiceExport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: namespace,
+		},
+		Spec: v1alpha1.ServiceExportSpec{
+			Protocol: "http",
+			Port:     8080,
+			Targets: []v1alpha1.ServiceExportTarget{
+				{
+					Type:       "deployment",
+					Name:       "backend",
+					TargetPort: 9090,
+				},
+			},
+		},
+	}
+	invalid := &v1alpha1.ServiceExport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "invalid",
+			Namespace: namespace,
+		},
+		Spec: v1alpha1.ServiceExportSpec{
+			Port: 8080,
+			Targets: []v1alpha1.ServiceExportTarget{
+				{
+					Type: "deployment",
+					Name: "no-such-deployment",
+				},
+			},
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{newRouterDeployment(namespace, 1), backend, services}, []runtime.Object{export, invalid})
+	assert.Assert(t, controller.checkServiceExport("west/backend"))
+
+	cli := controller.getVanClient(namespace)
+	service, err := cli.ServiceInterfaceInspect(context.Background(), "backend")
+	assert.Assert(t, err)
+	assert.Assert(t, service != nil)
+	assert.Equal(t, service.Protocol, "http")
+	assert.Equal(t, service.Port, 8080)
+	assert.Equal(t, len(service.Targets), 1)
+	assert.Equal(t, service.Targets[0].Selector, "app=backend")
+	assert.Equal(t, service.Targets[0].TargetPort, 9090)
+
+	updated, err := controller.skupperClient.SkupperV1alpha1().ServiceExports(namespace).Get("backend", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.DeepEqual(t, updated.ObjectMeta.Finalizers, []string{ServiceExportFinalizer})
+	assert.Assert(t, updated.Status.IsReady())
+	assert.Equal(t, updated.Status.Address, "backend")
+
+	assert.Assert(t, controller.checkServiceExport("west/invalid"))
+	updated, err = controller.skupperClient.SkupperV1alpha1().ServiceExports(namespace).Get("invalid", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition := getReadyCondition(t, updated.Status.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonInvalid)
+
+	// deleting the export removes the service and then the finalizer
+	deleted, err := controller.skupperClient.SkupperV1alpha1().ServiceExports(namespace).Get("backend", metav1.GetOptions{})
+	assert.Assert(t, err)
+	now := metav1.Now()
+	deleted.ObjectMeta.DeletionTimestamp = &now
+	controller.serviceExportInformer.GetStore().Update(deleted)
+	assert.Assert(t, controller.checkServiceExport("west/backend"))
+	service, err = cli.ServiceInterfaceInspect(context.Background(), "backend")
+	assert.Assert(t, err)
+	assert.Assert(t, service == nil)
+	updated, err = controller.skupperClient.SkupperV1alpha1().ServiceExports(namespace).Get("backend", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(updated.ObjectMeta.Finalizers), 0)
+}
+
+func TestServiceExportOwnership(t *testing.T) {
+	namespace := "west"
+	backend := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "backend",
+				},
+			},
+		},
+	}
+	services := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.ServiceInterfaceConfigMap,
+			Namespace: namespace,
+		},
+	}
+	export := &v1alpha1.ServiceExport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: namespace,
+		},
+		Spec: v1alpha1.ServiceExportSpec{
+			Port: 8080,
+			Targets: []v1alpha1.ServiceExportTarget{
+				{
+					Type: "deployment",
+					Name: "backend",
+				},
+			},
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{newRouterDeployment(namespace, 1), backend, services}, []runtime.Object{export})
+	cli := controller.getVanClient(namespace)
+	existing := &types.ServiceInterface{
+		Address:  "backend",
+		Protocol: "tcp",
+		Port:     9090,
+		Targets:  []types.ServiceInterfaceTarget{},
+	}
+	assert.Assert(t, cli.ServiceInterfaceCreate(context.Background(), existing))
+
+	// a definition the export did not create is left alone
+	assert.Assert(t, controller.checkServiceExport("west/backend"))
+	updated, err := controller.skupperClient.SkupperV1alpha1().ServiceExports(namespace).Get("backend", metav1.GetOptions{})
+	assert.Assert(t, err)
+	condition := getReadyCondition(t, updated.Status.Status)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, ReasonConflict)
+	assert.Equal(t, updated.Status.Address, "")
+	service, err := cli.ServiceInterfaceInspect(context.Background(), "backend")
+	assert.Assert(t, err)
+	assert.Equal(t, service.Port, 9090)
+
+	// moving to a free address claims that instead
+	updated.Spec.Address = "backend-export"
+	controller.serviceExportInformer.GetStore().Update(updated)
+	assert.Assert(t, controller.checkServiceExport("west/backend"))
+	updated, err = controller.skupperClient.SkupperV1alpha1().ServiceExports(namespace).Get("backend", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Assert(t, updated.Status.IsReady())
+	assert.Equal(t, updated.Status.Address, "backend-export")
+
+	// deleting the export removes only the definition it created
+	now := metav1.Now()
+	updated.ObjectMeta.DeletionTimestamp = &now
+	controller.serviceExportInformer.GetStore().Update(updated)
+	assert.Assert(t, controller.checkServiceExport("west/backend"))
+	service, err = cli.ServiceInterfaceInspect(context.Background(), "backend-export")
+	assert.Assert(t, err)
+	assert.Assert(t, service == nil)
+	service, err = cli.ServiceInterfaceInspect(context.Background(), "backend")
+	assert.Assert(t, err)
+	assert.Assert(t, service != nil)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+// Reasons for the events recorded against tokens and sites
+const (
+	EventSiteInitialised      string = "SiteInitialised"
+	EventSiteReady            string = "SiteReady"
+	EventSiteUpdated          string = "SiteUpdated"
+	EventSiteFailed           string = "SiteFailed"
+	EventTokenAccepted        string = "TokenAccepted"
+	EventTokenConnected       string = "Connected"
+	EventTokenDisconnected    string = "Disconnected"
+	EventTokenFailed          string = "ConnectFailed"
+	EventTokenGenerated       string = "TokenGenerated"
+	EventTokenRequestFailed   string = "TokenRequestFailed"
+	EventTokenRequestDeferred string = "TokenRequestDeferred"
+)
+
+// How often the state of the router is checked while waiting for it
+// to become ready or to connect, and once it has done so
+var (
+	pendingStatusInterval   = time.Second * 5
+	establishStatusInterval = time.Minute
+)
+
+var statusAnnotations = []string{
+	types.StatusQualifier,
+	types.StatusMessageQualifier,
+	types.StatusTimeQualifier,
+	types.RemoteSiteQualifier,
+}
+
+// withoutStatus returns a copy of the annotations without those set by
+// the controller to report status
+func withoutStatus(annotations map[string]string) map[string]string {
+	result := map[string]string{}
+	for key, value := range annotations {
+		result[key] = value
+	}
+	for _, key := range statusAnnotations {
+		delete(result, key)
+	}
+	return result
+}
+
+// onlyStatusChanged returns true if two versions of an object differ
+// in no more than the status annotations or finalizers, so that the
+// controller does not act on its own changes
+func onlyStatusChanged(a *metav1.ObjectMeta, b *metav1.ObjectMeta) bool {
+	if (a.DeletionTimestamp == nil) != (b.DeletionTimestamp == nil) {
+		return false
+	}
+	return reflect.DeepEqual(a.Labels, b.Labels) && reflect.DeepEqual(withoutStatus(a.Annotations), withoutStatus(b.Annotations))
+}
+
+func getStatus(obj *metav1.ObjectMeta) string {
+	return obj.Annotations[types.StatusQualifier]
+}
+
+// setStatus records the status in the annotations of the object,
+// returning false if it was already recorded
+func setStatus(obj *metav1.ObjectMeta, status string, message string, remoteSite string) bool {
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	if obj.Annotations[types.StatusQualifier] == status && obj.Annotations[types.StatusMessageQualifier] == message && obj.Annotations[types.RemoteSiteQualifier] == remoteSite {
+		return false
+	}
+	obj.Annotations[types.StatusQualifier] = status
+	obj.Annotations[types.StatusTimeQualifier] = time.Now().UTC().Format(time.RFC3339)
+	if message == "" {
+		delete(obj.Annotations, types.StatusMessageQualifier)
+	} else {
+		obj.Annotations[types.StatusMessageQualifier] = message
+	}
+	if remoteSite == "" {
+		delete(obj.Annotations, types.RemoteSiteQualifier)
+	} else {
+		obj.Annotations[types.RemoteSiteQualifier] = remoteSite
+	}
+	return true
+}
+
+// setSecretStatus records the status on the named secret, returning
+// true if it changed
+func (c *SiteController) setSecretStatus(namespace string, name string, status string, message string, remoteSite string) (bool, error) {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := c.vanClient.KubeClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if changed = setStatus(&secret.ObjectMeta, status, message, remoteSite); !changed {
+			return nil
+		}
+		_, err = c.vanClient.KubeClient.CoreV1().Secrets(namespace).Update(secret)
+		return err
+	})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return changed, err
+}
+
+// setSiteStatus records the status on the skupper-site config map,
+// returning true if it changed
+func (c *SiteController) setSiteStatus(namespace string, status string, message string) (bool, error) {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configmap, err := c.vanClient.KubeClient.CoreV1().ConfigMaps(namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if changed = setStatus(&configmap.ObjectMeta, status, message, ""); !changed {
+			return nil
+		}
+		_, err = c.vanClient.KubeClient.CoreV1().ConfigMaps(namespace).Update(configmap)
+		return err
+	})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return changed, err
+}
+
+// siteFailed reports an error in processing the skupper-site config
+// map, returning the original error
+func (c *SiteController) siteFailed(configmap *corev1.ConfigMap, err error) error {
+	if changed, statusErr := c.setSiteStatus(configmap.ObjectMeta.Namespace, types.StatusFailed, err.Error()); statusErr != nil {
+		log.Printf("Failed to update status of skupper-site in %s: %s", configmap.ObjectMeta.Namespace, statusErr)
+	} else if changed {
+		c.eventRecorder.Event(configmap, corev1.EventTypeWarning, EventSiteFailed, err.Error())
+	}
+	return err
+}
+
+// siteAccepted reports that the skupper-site config map has been
+// applied, and arranges for the site to be checked until it is ready
+func (c *SiteController) siteAccepted(configmap *corev1.ConfigMap, reason string, message string) error {
+	if getStatus(&configmap.ObjectMeta) == types.StatusReady && reason == EventSiteUpdated {
+		c.eventRecorder.Event(configmap, corev1.EventTypeNormal, reason, message)
+		return nil
+	}
+	changed, err := c.setSiteStatus(configmap.ObjectMeta.Namespace, types.StatusAccepted, "")
+	if err != nil {
+		return err
+	}
+	if changed {
+		c.eventRecorder.Event(configmap, corev1.EventTypeNormal, reason, message)
+	}
+	c.checkStatusLater(configmap, SiteStatus, pendingStatusInterval)
+	return nil
+}
+
+func (c *SiteController) checkStatusLater(obj interface{}, category triggerType, interval time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	c.workqueue.AddAfter(trigger{
+		key:      key,
+		category: category,
+	}, interval)
+}
+
+// checkSiteStatus reports the site ready once the router is
+func (c *SiteController) checkSiteStatus(key string) error {
+	obj, exists, err := c.siteInformer.GetStore().GetByKey(key)
+	if err != nil || !exists {
+		return err
+	}
+	configmap := obj.(*corev1.ConfigMap)
+	if getStatus(&configmap.ObjectMeta) == types.StatusFailed || configmap.ObjectMeta.DeletionTimestamp != nil {
+		return nil
+	}
+	router, err := kube.GetDeployment(types.TransportDeploymentName, configmap.ObjectMeta.Namespace, c.vanClient.KubeClient)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err != nil || router.Status.ReadyReplicas == 0 {
+		c.checkStatusLater(configmap, SiteStatus, pendingStatusInterval)
+		return nil
+	}
+	changed, err := c.setSiteStatus(configmap.ObjectMeta.Namespace, types.StatusReady, "")
+	if err != nil {
+		return err
+	}
+	if changed {
+		c.eventRecorder.Event(configmap, corev1.EventTypeNormal, EventSiteReady, "Site router is ready")
+	}
+	return nil
+}
+
+// tokenFailed reports an error in connecting with a token, returning
+// the original error
+func (c *SiteController) tokenFailed(token *corev1.Secret, err error) error {
+	if changed, statusErr := c.setSecretStatus(token.ObjectMeta.Namespace, token.ObjectMeta.Name, types.StatusFailed, err.Error(), ""); statusErr != nil {
+		log.Printf("Failed to update status of token %s/%s: %s", token.ObjectMeta.Namespace, token.ObjectMeta.Name, statusErr)
+	} else if changed {
+		c.eventRecorder.Event(token, corev1.EventTypeWarning, EventTokenFailed, err.Error())
+	}
+	return err
+}
+
+// tokenAccepted reports that a connector has been configured for the
+// token, and arranges for the link to be checked until it is up
+func (c *SiteController) tokenAccepted(token *corev1.Secret) error {
+	status := getStatus(&token.ObjectMeta)
+	if status != types.StatusConnecting && status != types.StatusConnected {
+		changed, err := c.setSecretStatus(token.ObjectMeta.Namespace, token.ObjectMeta.Name, types.StatusConnecting, "", "")
+		if err != nil {
+			return err
+		}
+		if changed {
+			c.eventRecorder.Event(token, corev1.EventTypeNormal, EventTokenAccepted, "Connecting to "+getTokenHost(token))
+		}
+	}
+	c.checkStatusLater(token, TokenStatus, pendingStatusInterval)
+	return nil
+}
+
+func getTokenHost(token *corev1.Secret) string {
+	if host, ok := token.ObjectMeta.Annotations["inter-router-host"]; ok {
+		return host
+	}
+	return token.ObjectMeta.Annotations["edge-host"]
+}
+
+func getTokenRemoteSite(token *corev1.Secret) string {
+	if name, ok := token.ObjectMeta.Annotations[types.TokenSiteName]; ok {
+		return name
+	}
+	return getTokenHost(token)
+}
+
+// checkTokenStatus reports whether the link made with a token is
+// connected; it is checked frequently until it is, and periodically
+// thereafter
+func (c *SiteController) checkTokenStatus(key string) error {
+	obj, exists, err := c.tokenInformer.GetStore().GetByKey(key)
+	if err != nil || !exists {
+		return err
+	}
+	token := obj.(*corev1.Secret)
+	status := getStatus(&token.ObjectMeta)
+	if status != types.StatusConnecting && status != types.StatusConnected {
+		return nil
+	}
+	cli := c.getVanClient(token.ObjectMeta.Namespace)
+	connector, err := cli.ConnectorInspect(context.Background(), token.ObjectMeta.Name)
+	if err != nil {
+		// the check is repeated later rather than requeued, as
+		// returning the error would queue it a second time
+		log.Printf("Could not check link status for token %s: %s", key, err)
+		c.checkStatusLater(token, TokenStatus, pendingStatusInterval)
+		return nil
+	}
+	if connector.Connected {
+		remoteSite := getTokenRemoteSite(token)
+		changed, err := c.setSecretStatus(token.ObjectMeta.Namespace, token.ObjectMeta.Name, types.StatusConnected, "", remoteSite)
+		if err != nil {
+			return err
+		}
+		if changed {
+			c.eventRecorder.Event(token, corev1.EventTypeNormal, EventTokenConnected, "Connected to "+remoteSite)
+		}
+		c.checkStatusLater(token, TokenStatus, establishStatusInterval)
+	} else {
+		changed, err := c.setSecretStatus(token.ObjectMeta.Namespace, token.ObjectMeta.Name, types.StatusConnecting, "", "")
+		if err != nil {
+			return err
+		}
+		if changed && status == types.StatusConnected {
+			c.eventRecorder.Event(token, corev1.EventTypeWarning, EventTokenDisconnected, "Link to "+token.ObjectMeta.Annotations[types.RemoteSiteQualifier]+" is down")
+		}
+		c.checkStatusLater(token, TokenStatus, pendingStatusInterval)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+func getEvents(controller *SiteController) []string {
+	events := []string{}
+	recorder := controller.eventRecorder.(*record.FakeRecorder)
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	obj := &metav1.ObjectMeta{}
+	assert.Assert(t, setStatus(obj, types.StatusFailed, "bad token", ""))
+	assert.Equal(t, obj.Annotations[types.StatusQualifier], types.StatusFailed)
+	assert.Equal(t, obj.Annotations[types.StatusMessageQualifier], "bad token")
+	assert.Assert(t, obj.Annotations[types.StatusTimeQualifier] != "")
+	assert.Assert(t, !setStatus(obj, types.StatusFailed, "bad token", ""))
+
+	assert.Assert(t, setStatus(obj, types.StatusConnected, "", "east"))
+	assert.Equal(t, obj.Annotations[types.StatusQualifier], types.StatusConnected)
+	assert.Equal(t, obj.Annotations[types.RemoteSiteQualifier], "east")
+	_, ok := obj.Annotations[types.StatusMessageQualifier]
+	assert.Assert(t, !ok)
+}
+
+func TestResourceVersionTestIgnoresStatus(t *testing.T) {
+	a := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				"inter-router-host": "east.example.com",
+			},
+		},
+		Data: map[string][]byte{
+			"tls.crt": []byte("cert"),
+		},
+	}
+	b := a.DeepCopy()
+	b.ObjectMeta.ResourceVersion = "2"
+	setStatus(&b.ObjectMeta, types.StatusConnecting, "", "")
+	assert.Assert(t, secretResourceVersionTest(a, b))
+
+	c := b.DeepCopy()
+	c.ObjectMeta.ResourceVersion = "3"
+	c.Data["tls.crt"] = []byte("renewed")
+	assert.Assert(t, !secretResourceVersionTest(b, c))
+
+	site := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			"edge": "false",
+		},
+	}
+	annotated := site.DeepCopy()
+	annotated.ObjectMeta.ResourceVersion = "2"
+	setStatus(&annotated.ObjectMeta, types.StatusReady, "", "")
+	assert.Assert(t, configmapResourceVersionTest(site, annotated))
+	changed := annotated.DeepCopy()
+	changed.ObjectMeta.ResourceVersion = "3"
+	changed.Data["edge"] = "true"
+	assert.Assert(t, !configmapResourceVersionTest(annotated, changed))
+}
+
+func TestTokenConnectFailed(t *testing.T) {
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "to-east",
+			Namespace: "west",
+			Labels: map[string]string{
+				types.SkupperTypeQualifier: types.TypeToken,
+			},
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{token}, nil)
+	controller.tokenInformer.GetStore().Add(token)
+
+	// the site has not been initialised, so the connector cannot be added
+	assert.Assert(t, controller.checkToken("west/to-east") != nil)
+	updated, err := controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("to-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, updated.ObjectMeta.Annotations[types.StatusQualifier], types.StatusFailed)
+	assert.Assert(t, updated.ObjectMeta.Annotations[types.StatusMessageQualifier] != "")
+	events := getEvents(controller)
+	assert.Equal(t, len(events), 1)
+	assert.Assert(t, strings.HasPrefix(events[0], "Warning "+EventTokenFailed))
+
+	// a repeated failure is not reported again
+	assert.Assert(t, controller.checkToken("west/to-east") != nil)
+	assert.Equal(t, len(getEvents(controller)), 0)
+}
+
+func TestTokenRequestStatus(t *testing.T) {
+	request := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "for-east",
+			Namespace: "west",
+			Labels: map[string]string{
+				types.SkupperTypeQualifier: "connection-token-request",
+			},
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{request}, nil)
+	controller.tokenRequestInformer.GetStore().Add(request)
+
+	assert.Assert(t, controller.checkTokenRequest("west/for-east"))
+	updated, err := controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("for-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, updated.ObjectMeta.Annotations[types.StatusQualifier], types.StatusAccepted)
+	events := getEvents(controller)
+	assert.Equal(t, len(events), 1)
+	assert.Assert(t, strings.HasPrefix(events[0], "Normal "+EventTokenRequestDeferred))
+
+	// once there is a site, generating the token fails as the router
+	// has not been configured
+	site := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.DefaultSiteName,
+			Namespace: "west",
+			UID:       "west-site-id",
+		},
+	}
+	_, err = controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Create(site)
+	assert.Assert(t, err)
+	assert.Assert(t, controller.checkTokenRequest("west/for-east") != nil)
+	updated, err = controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("for-east", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, updated.ObjectMeta.Annotations[types.StatusQualifier], types.StatusFailed)
+	events = getEvents(controller)
+	assert.Equal(t, len(events), 1)
+	assert.Assert(t, strings.HasPrefix(events[0], "Warning "+EventTokenRequestFailed))
+}
+
+func TestSiteStatus(t *testing.T) {
+	site := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.DefaultSiteName,
+			Namespace: "west",
+			Annotations: map[string]string{
+				types.StatusQualifier: types.StatusAccepted,
+			},
+		},
+	}
+	controller := newFakeSiteController([]runtime.Object{site, newRouterDeployment("west", 0)}, nil)
+	controller.siteInformer.GetStore().Add(site)
+
+	// not ready until the router is
+	assert.Assert(t, controller.checkSiteStatus("west/skupper-site"))
+	updated, err := controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, updated.ObjectMeta.Annotations[types.StatusQualifier], types.StatusAccepted)
+	assert.Equal(t, len(getEvents(controller)), 0)
+
+	_, err = controller.vanClient.KubeClient.AppsV1().Deployments("west").Update(newRouterDeployment("west", 1))
+	assert.Assert(t, err)
+	assert.Assert(t, controller.checkSiteStatus("west/skupper-site"))
+	updated, err = controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, updated.ObjectMeta.Annotations[types.StatusQualifier], types.StatusReady)
+	events := getEvents(controller)
+	assert.Equal(t, len(events), 1)
+	assert.Assert(t, strings.HasPrefix(events[0], "Normal "+EventSiteReady))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+// SiteTeardownFinalizer holds back deletion of the skupper-site config
+// map until the site it describes has been removed. It is only present
+// while the site controller runs, so that the deletion does not hang
+// once the controller has been uninstalled.
+const SiteTeardownFinalizer string = "skupper.io/site-teardown"
+
+const (
+	EventSiteTeardown       string = "SiteTeardown"
+	EventSiteTeardownFailed string = "SiteTeardownFailed"
+)
+
+// updateSiteFinalizer adds or removes the teardown finalizer on the
+// skupper-site config map
+func (c *SiteController) updateSiteFinalizer(namespace string, add bool) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configmap, err := c.vanClient.KubeClient.CoreV1().ConfigMaps(namespace).Get(types.DefaultSiteName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if hasFinalizer(configmap, SiteTeardownFinalizer) == add {
+			return nil
+		}
+		if add {
+			configmap.ObjectMeta.Finalizers = append(configmap.ObjectMeta.Finalizers, SiteTeardownFinalizer)
+		} else {
+			removeFinalizer(configmap, SiteTeardownFinalizer)
+		}
+		_, err = c.vanClient.KubeClient.CoreV1().ConfigMaps(namespace).Update(configmap)
+		return err
+	})
+	return ignoreNotFound(err)
+}
+
+// removeSiteFinalizers removes the teardown finalizer from every site
+// as the controller shuts down; it is added back when the controller
+// next starts
+func (c *SiteController) removeSiteFinalizers() {
+	for _, obj := range c.siteInformer.GetStore().List() {
+		configmap, ok := obj.(*corev1.ConfigMap)
+		if !ok || !hasFinalizer(configmap, SiteTeardownFinalizer) {
+			continue
+		}
+		if err := c.updateSiteFinalizer(configmap.ObjectMeta.Namespace, false); err != nil {
+			log.Printf("Error removing finalizer from skupper-site config map in %s: %s", configmap.ObjectMeta.Namespace, err)
+		}
+	}
+}
+
+func ignoreNotFound(err error) error {
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// teardownSite removes the site once its skupper-site config map has
+// been deleted, then allows the deletion to complete
+func (c *SiteController) teardownSite(configmap *corev1.ConfigMap) error {
+	namespace := configmap.ObjectMeta.Namespace
+	if !hasFinalizer(configmap, SiteTeardownFinalizer) {
+		return nil
+	}
+	keepCAs := configmap.Data["keep-ca-on-delete"] == "true"
+	log.Printf("Tearing down skupper site in %s", namespace)
+	if err := c.getVanClient(namespace).SiteTeardown(context.Background(), keepCAs); err != nil {
+		log.Printf("Error tearing down skupper site in %s: %s", namespace, err)
+		c.eventRecorder.Event(configmap, corev1.EventTypeWarning, EventSiteTeardownFailed, err.Error())
+		return err
+	}
+	c.eventRecorder.Event(configmap, corev1.EventTypeNormal, EventSiteTeardown, "Skupper site removed")
+	if err := c.labelSiteNamespace(namespace, false); err != nil {
+		log.Printf("Error removing label from namespace %s: %s", namespace, err)
+	}
+	if err := c.updateSiteFinalizer(namespace, false); err != nil {
+		return err
+	}
+	c.forgetVanClient(namespace)
+	log.Printf("Skupper site in %s removed", namespace)
+	return nil
+}
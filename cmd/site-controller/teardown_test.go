@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/kube"
+)
+
+func TestSiteTeardownOnDelete(t *testing.T) {
+	site := newSiteConfigMap("west", "west-site")
+	site.Data["keep-ca-on-delete"] = "true"
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "west"}}
+	controller := newFakeSiteController([]runtime.Object{site, namespace}, nil)
+	controller.siteInformer.GetStore().Add(site)
+
+	assert.Assert(t, controller.checkSite("west/skupper-site"))
+	configmap, err := controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.DeepEqual(t, configmap.ObjectMeta.Finalizers, []string{SiteTeardownFinalizer})
+	namespace, err = controller.vanClient.KubeClient.CoreV1().Namespaces().Get("west", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, namespace.ObjectMeta.Labels[SiteNamespaceLabel], "true")
+	_, err = kube.GetDeployment(types.TransportDeploymentName, "west", controller.vanClient.KubeClient)
+	assert.Assert(t, err)
+	getEvents(controller)
+
+	// deletion is held back by the finalizer until the site is removed
+	now := metav1.Now()
+	configmap.ObjectMeta.DeletionTimestamp = &now
+	configmap, err = controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Update(configmap)
+	assert.Assert(t, err)
+	controller.siteInformer.GetStore().Update(configmap)
+	assert.Assert(t, controller.checkSite("west/skupper-site"))
+
+	_, err = kube.GetDeployment(types.TransportDeploymentName, "west", controller.vanClient.KubeClient)
+	assert.Assert(t, errors.IsNotFound(err))
+	_, err = controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get("skupper-internal", metav1.GetOptions{})
+	assert.Assert(t, errors.IsNotFound(err))
+	ca, err := controller.vanClient.KubeClient.CoreV1().Secrets("west").Get("skupper-internal-ca", metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(ca.ObjectMeta.OwnerReferences), 0)
+	configmap, err = controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(configmap.ObjectMeta.Finalizers), 0)
+	namespace, err = controller.vanClient.KubeClient.CoreV1().Namespaces().Get("west", metav1.GetOptions{})
+	assert.Assert(t, err)
+	_, ok := namespace.ObjectMeta.Labels[SiteNamespaceLabel]
+	assert.Assert(t, !ok)
+	events := getEvents(controller)
+	assert.Equal(t, len(events), 1)
+	assert.Assert(t, strings.HasPrefix(events[0], corev1.EventTypeNormal+" "+EventSiteTeardown))
+	_, ok = controller.siteClients["west"]
+	assert.Assert(t, !ok)
+}
+
+func TestSiteFinalizerRemovedOnShutdown(t *testing.T) {
+	site := newSiteConfigMap("west", "west-site")
+	site.ObjectMeta.Finalizers = []string{SiteTeardownFinalizer}
+	controller := newFakeSiteController([]runtime.Object{site}, nil)
+	controller.siteInformer.GetStore().Add(site)
+
+	controller.removeSiteFinalizers()
+	configmap, err := controller.vanClient.KubeClient.CoreV1().ConfigMaps("west").Get(types.DefaultSiteName, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(configmap.ObjectMeta.Finalizers), 0)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/client"
+)
+
+// Where the validating admission webhook is served, and where it finds
+// its serving certificate; the webhook is only enabled if the
+// certificate is present
+const (
+	WebhookPath           string = "/validate"
+	WebhookPort           int    = 8443
+	DefaultWebhookCertDir string = "/etc/skupper-site-controller/webhook"
+)
+
+// SiteNamespaceLabel marks the namespaces in which the controller
+// manages a site, to which the webhook is scoped
+const SiteNamespaceLabel string = "skupper.io/site"
+
+func getWebhookCertDir() string {
+	if dir := os.Getenv("WEBHOOK_CERT_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultWebhookCertDir
+}
+
+// runWebhook serves the admission webhook until stopped, if a serving
+// certificate has been provided
+func runWebhook(certDir string, stopCh <-chan struct{}) {
+	certFile := filepath.Join(certDir, "tls.crt")
+	keyFile := filepath.Join(certDir, "tls.key")
+	if _, err := os.Stat(certFile); err != nil {
+		log.Printf("Admission webhook not enabled; no certificate found in %s", certDir)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(WebhookPath, serveValidate)
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", WebhookPort),
+		Handler: mux,
+	}
+	go func() {
+		<-stopCh
+		server.Close()
+	}()
+	log.Printf("Serving admission webhook on %s", server.Addr)
+	if err := server.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+		log.Printf("Admission webhook failed: %s", err)
+	}
+}
+
+func serveValidate(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "Expected an AdmissionReview", http.StatusBadRequest)
+		return
+	}
+	response := &admissionv1.AdmissionResponse{
+		UID:     review.Request.UID,
+		Allowed: true,
+	}
+	if err := validateAdmission(review.Request); err != nil {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	review.Request = nil
+	review.Response = response
+	encoded, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(encoded)
+}
+
+// validateAdmission checks the skupper-site config map, the
+// skupper-services config map and its shards, and the annotations
+// through which services, deployments and statefulsets are exposed.
+// Updates are only checked for the content they change, so that an
+// object already in an invalid state can still be deleted or have its
+// status recorded.
+func validateAdmission(request *admissionv1.AdmissionRequest) error {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return nil
+	}
+	switch request.Kind.Kind {
+	case "ConfigMap":
+		configmap := corev1.ConfigMap{}
+		if err := json.Unmarshal(request.Object.Raw, &configmap); err != nil {
+			return err
+		}
+		old := corev1.ConfigMap{}
+		if request.Operation == admissionv1.Update {
+			if err := json.Unmarshal(request.OldObject.Raw, &old); err == nil && reflect.DeepEqual(old.Data, configmap.Data) {
+				return nil
+			}
+		}
+		switch {
+		case configmap.ObjectMeta.Name == types.DefaultSiteName:
+			return client.ValidateSiteConfig(configmap.Data)
+		case configmap.ObjectMeta.Name == types.ServiceInterfaceConfigMap, configmap.ObjectMeta.Labels[types.InternalTypeQualifier] == types.TypeServiceDefinitions:
+			return client.ValidateServiceDefinitionChanges(old.Data, configmap.Data)
+		default:
+			return nil
+		}
+	case "Service", "Deployment", "StatefulSet":
+		obj := metav1.PartialObjectMetadata{}
+		if err := json.Unmarshal(request.Object.Raw, &obj); err != nil {
+			return err
+		}
+		if request.Operation == admissionv1.Update {
+			old := metav1.PartialObjectMetadata{}
+			if err := json.Unmarshal(request.OldObject.Raw, &old); err == nil && sameExposure(old.ObjectMeta.Annotations, obj.ObjectMeta.Annotations) {
+				return nil
+			}
+		}
+		return client.ValidateServiceAnnotations(obj.ObjectMeta.Annotations)
+	default:
+		return nil
+	}
+}
+
+// labelSiteNamespace adds or removes the label by which the webhook
+// selects the namespaces it validates. A controller watching only its
+// own namespace may not be permitted to do so, in which case the
+// webhook is not deployed either.
+func (c *SiteController) labelSiteNamespace(namespace string, add bool) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ns, err := c.vanClient.KubeClient.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := ns.ObjectMeta.Labels[SiteNamespaceLabel]; ok == add {
+			return nil
+		}
+		if add {
+			if ns.ObjectMeta.Labels == nil {
+				ns.ObjectMeta.Labels = map[string]string{}
+			}
+			ns.ObjectMeta.Labels[SiteNamespaceLabel] = "true"
+		} else {
+			delete(ns.ObjectMeta.Labels, SiteNamespaceLabel)
+		}
+		_, err = c.vanClient.KubeClient.CoreV1().Namespaces().Update(ns)
+		return err
+	})
+	if errors.IsForbidden(err) {
+		return nil
+	}
+	return ignoreNotFound(err)
+}
+
+var exposureAnnotations = []string{
+	types.ProxyQualifier,
+	types.AddressQualifier,
+	types.PortQualifier,
+	types.DistributionQualifier,
+}
+
+func sameExposure(a map[string]string, b map[string]string) bool {
+	for _, key := range exposureAnnotations {
+		va, oka := a[key]
+		vb, okb := b[key]
+		if oka != okb || va != vb {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+func review(t *testing.T, operation admissionv1.Operation, kind string, obj interface{}, old interface{}) *admissionv1.AdmissionResponse {
+	request := &admissionv1.AdmissionRequest{
+		UID:       "request-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: kind},
+		Operation: operation,
+	}
+	encoded, err := json.Marshal(obj)
+	assert.Assert(t, err)
+	request.Object = runtime.RawExtension{Raw: encoded}
+	if old != nil {
+		encoded, err = json.Marshal(old)
+		assert.Assert(t, err)
+		request.OldObject = runtime.RawExtension{Raw: encoded}
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{Request: request})
+	assert.Assert(t, err)
+
+	recorder := httptest.NewRecorder()
+	serveValidate(recorder, httptest.NewRequest(http.MethodPost, WebhookPath, bytes.NewReader(body)))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	result := admissionv1.AdmissionReview{}
+	assert.Assert(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+	assert.Assert(t, result.Response != nil)
+	assert.Equal(t, string(result.Response.UID), "request-1")
+	return result.Response
+}
+
+func TestWebhookSiteConfig(t *testing.T) {
+	site := newSiteConfigMap("west", "west-site")
+	assert.Assert(t, review(t, admissionv1.Create, "ConfigMap", site, nil).Allowed)
+
+	bad := site.DeepCopy()
+	bad.Data["service-sync"] = "maybe"
+	response := review(t, admissionv1.Create, "ConfigMap", bad, nil)
+	assert.Assert(t, !response.Allowed)
+	assert.Assert(t, strings.Contains(response.Result.Message, "service-sync"), response.Result.Message)
+	assert.Assert(t, !review(t, admissionv1.Update, "ConfigMap", bad, site).Allowed)
+
+	// changes to other fields of a site already invalid are allowed
+	annotated := bad.DeepCopy()
+	annotated.ObjectMeta.Finalizers = []string{SiteTeardownFinalizer}
+	assert.Assert(t, review(t, admissionv1.Update, "ConfigMap", annotated, bad).Allowed)
+
+	// other config maps are not checked
+	other := bad.DeepCopy()
+	other.ObjectMeta.Name = "my-config"
+	assert.Assert(t, review(t, admissionv1.Create, "ConfigMap", other, nil).Allowed)
+}
+
+func TestWebhookServiceDefinitions(t *testing.T) {
+	definitions := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.ServiceInterfaceConfigMap,
+			Namespace: "west",
+		},
+		Data: map[string]string{
+			"backend": `{"address": "backend", "protocol": "tcp", "port": 8080}`,
+		},
+	}
+	assert.Assert(t, review(t, admissionv1.Create, "ConfigMap", definitions, nil).Allowed)
+	definitions.Data["frontend"] = `{"address": "frontend", "protocol": "udp", "port": 8080}`
+	assert.Assert(t, !review(t, admissionv1.Create, "ConfigMap", definitions, nil).Allowed)
+
+	// only the definitions added or changed by an update are checked
+	valid := definitions.DeepCopy()
+	delete(valid.Data, "frontend")
+	legacy := valid.DeepCopy()
+	legacy.Data["Legacy_Name"] = `{"address": "Legacy_Name", "protocol": "tcp", "port": 8080}`
+	added := legacy.DeepCopy()
+	added.Data["other"] = `{"address": "other", "protocol": "http", "port": 8080}`
+	assert.Assert(t, review(t, admissionv1.Update, "ConfigMap", added, legacy).Allowed)
+	added.Data["Legacy_Name"] = `{"address": "Legacy_Name", "protocol": "tcp", "port": 9090}`
+	assert.Assert(t, !review(t, admissionv1.Update, "ConfigMap", added, legacy).Allowed)
+
+	// as are the shards amongst which they may be spread
+	shard := definitions.DeepCopy()
+	shard.ObjectMeta.Name = types.ServiceInterfaceConfigMap + "-3"
+	shard.ObjectMeta.Labels = map[string]string{types.InternalTypeQualifier: types.TypeServiceDefinitions}
+	assert.Assert(t, !review(t, admissionv1.Create, "ConfigMap", shard, nil).Allowed)
+	legacyShard := legacy.DeepCopy()
+	legacyShard.ObjectMeta = shard.ObjectMeta
+	addedShard := legacyShard.DeepCopy()
+	addedShard.Data["other"] = `{"address": "other", "protocol": "tcp", "port": 8080}`
+	assert.Assert(t, review(t, admissionv1.Update, "ConfigMap", addedShard, legacyShard).Allowed)
+}
+
+func TestWebhookAnnotations(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: "west",
+			Annotations: map[string]string{
+				types.ProxyQualifier: "tcp",
+			},
+		},
+	}
+	assert.Assert(t, review(t, admissionv1.Create, "Service", service, nil).Allowed)
+
+	bad := service.DeepCopy()
+	bad.ObjectMeta.Annotations[types.PortQualifier] = "eighty"
+	response := review(t, admissionv1.Update, "Service", bad, service)
+	assert.Assert(t, !response.Allowed)
+	assert.Equal(t, response.Result.Reason, metav1.StatusReasonInvalid)
+	assert.Assert(t, !review(t, admissionv1.Create, "Deployment", bad, nil).Allowed)
+	assert.Assert(t, review(t, admissionv1.Delete, "Deployment", bad, nil).Allowed)
+}
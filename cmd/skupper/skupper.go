@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -23,11 +27,22 @@ import (
 var version = "undefined"
 
 type ExposeOptions struct {
-	Protocol   string
-	Address    string
-	Port       int
-	TargetPort int
-	Headless   bool
+	Protocol     string
+	Address      string
+	Port         int
+	Ports        []types.ServicePort
+	TargetPort   int
+	TargetPorts  map[string]int
+	Headless     bool
+	Distribution string
+	Weight       int
+	Priority     int
+	// ResolveInterval applies only to host targets
+	ResolveInterval time.Duration
+	Tls             types.ServiceTls
+	External        types.ServiceExternal
+	Labels          map[string]string
+	Annotations     map[string]string
 }
 
 func SkupperNotInstalledError(namespace string) error {
@@ -63,16 +78,27 @@ func expose(cli types.VanClientInterface, ctx context.Context, targetType string
 			if targetType != "statefulset" {
 				return "", fmt.Errorf("The headless option is only supported for statefulsets")
 			}
+			if len(options.Ports) > 0 {
+				return "", fmt.Errorf("Named ports are not supported for headless services")
+			}
 			service, err = cli.GetHeadlessServiceConfiguration(targetName, options.Protocol, options.Address, options.Port)
 			if err != nil {
 				return "", err
 			}
+			service.Distribution = options.Distribution
+			service.Labels = mergeMetadata(nil, options.Labels)
+			service.Annotations = mergeMetadata(nil, options.Annotations)
 			return service.Address, cli.ServiceInterfaceUpdate(ctx, service)
 		} else {
 			service = &types.ServiceInterface{
-				Address:  serviceName,
-				Port:     options.Port,
-				Protocol: options.Protocol,
+				Address:      serviceName,
+				Port:         options.Port,
+				Ports:        options.Ports,
+				Protocol:     options.Protocol,
+				Distribution: options.Distribution,
+			}
+			if len(options.Ports) > 0 {
+				service.Port = options.Ports[0].Port
 			}
 		}
 	} else if service.Headless != nil {
@@ -81,10 +107,38 @@ func expose(cli types.VanClientInterface, ctx context.Context, targetType string
 		return "", fmt.Errorf("Service already exposed, cannot reconfigure as headless")
 	} else if options.Protocol != "" && service.Protocol != options.Protocol {
 		return "", fmt.Errorf("Invalid protocol %s for service with mapping %s", options.Protocol, service.Protocol)
+	} else if len(options.Ports) > 0 && !reflect.DeepEqual(options.Ports, service.Ports) {
+		return "", fmt.Errorf("Service %s is already exposed with different ports", serviceName)
+	}
+	if options.Distribution != "" {
+		service.Distribution = options.Distribution
+	}
+	if options.Tls != (types.ServiceTls{}) {
+		tls := options.Tls
+		service.Tls = &tls
 	}
+	if options.External.Type != "" {
+		external := options.External
+		service.External = &external
+	} else if options.External.Host != "" {
+		return "", fmt.Errorf("The external-host option requires an external-type")
+	}
+	service.Labels = mergeMetadata(service.Labels, options.Labels)
+	service.Annotations = mergeMetadata(service.Annotations, options.Annotations)
 
 	// service may exist from remote origin
+	if service.Origin != "" {
+		// site preferences of the remote origin do not apply here
+		service.Weight = 0
+		service.Priority = 0
+	}
 	service.Origin = ""
+	if options.ResolveInterval != 0 && targetType != "host" {
+		return "", fmt.Errorf("The resolve-interval option is only valid for host targets")
+	}
+	if options.Weight != 0 || options.Priority != 0 || len(options.TargetPorts) > 0 || options.ResolveInterval != 0 {
+		setTargetOptions(service, targetName, options)
+	}
 	err = cli.ServiceInterfaceBind(ctx, service, targetType, targetName, options.Protocol, options.TargetPort)
 	if errors.IsNotFound(err) {
 		return "", SkupperNotInstalledError(cli.GetNamespace())
@@ -95,6 +149,93 @@ func expose(cli types.VanClientInterface, ctx context.Context, targetType string
 	return options.Address, nil
 }
 
+// mergeMetadata adds the specified labels or annotations to those
+// already defined for a service
+func mergeMetadata(current map[string]string, specified map[string]string) map[string]string {
+	if len(specified) == 0 {
+		return current
+	}
+	merged := map[string]string{}
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range specified {
+		merged[k] = v
+	}
+	return merged
+}
+
+// setTargetOptions records the weight, priority, named target ports
+// and resolve interval for the named target; these are retained when
+// the target is (re)bound
+func setTargetOptions(service *types.ServiceInterface, targetName string, options ExposeOptions) {
+	for i, t := range service.Targets {
+		if t.Name == targetName {
+			if options.Weight != 0 {
+				service.Targets[i].Weight = options.Weight
+			}
+			if options.Priority != 0 {
+				service.Targets[i].Priority = options.Priority
+			}
+			if len(options.TargetPorts) > 0 {
+				service.Targets[i].TargetPorts = options.TargetPorts
+			}
+			if options.ResolveInterval != 0 {
+				service.Targets[i].ResolveInterval = options.ResolveInterval.String()
+			}
+			return
+		}
+	}
+	target := types.ServiceInterfaceTarget{
+		Name:        targetName,
+		Weight:      options.Weight,
+		Priority:    options.Priority,
+		TargetPorts: options.TargetPorts,
+	}
+	if options.ResolveInterval != 0 {
+		target.ResolveInterval = options.ResolveInterval.String()
+	}
+	service.Targets = append(service.Targets, target)
+}
+
+// parseExposePorts sets the port options from the --port and
+// --target-port flags, each of which is either a single number or a
+// list of name:port pairs
+func parseExposePorts(options *ExposeOptions, ports []string, targetPorts []string) error {
+	options.Port = 0
+	options.Ports = nil
+	options.TargetPort = 0
+	options.TargetPorts = nil
+	parsed, err := client.ParseServicePorts(ports)
+	if err != nil {
+		return err
+	}
+	if len(parsed) == 1 && parsed[0].Name == "" {
+		options.Port = parsed[0].Port
+	} else if len(parsed) > 0 {
+		options.Ports = parsed
+	}
+	parsed, err = client.ParseServicePorts(targetPorts)
+	if err != nil {
+		return err
+	}
+	if len(parsed) == 1 && parsed[0].Name == "" {
+		if len(options.Ports) > 0 {
+			return fmt.Errorf("Target ports must be named when the service has named ports")
+		}
+		options.TargetPort = parsed[0].Port
+	} else if len(parsed) > 0 {
+		if len(options.Ports) == 0 {
+			return fmt.Errorf("Named target ports require named ports (e.g. --port data:5432)")
+		}
+		options.TargetPorts = map[string]int{}
+		for _, p := range parsed {
+			options.TargetPorts[p.Name] = p.Port
+		}
+	}
+	return nil
+}
+
 func stringSliceContains(s []string, e string) bool {
 	for _, a := range s {
 		if a == e {
@@ -104,7 +245,7 @@ func stringSliceContains(s []string, e string) bool {
 	return false
 }
 
-var validExposeTargets = []string{"deployment", "statefulset", "pods", "service"}
+var validExposeTargets = []string{"deployment", "statefulset", "pods", "service", "host"}
 
 func verifyTargetTypeFromArgs(args []string) error {
 	targetType, _ := parseTargetTypeAndName(args)
@@ -167,6 +308,44 @@ func NewClient(namespace string, context string, kubeConfigPath string) *client.
 }
 
 var routerCreateOpts types.SiteConfigSpec
+var podSettingsFlags = map[string]*string{}
+
+func addPodSettingsFlags(cmd *cobra.Command, prefix string, component string) {
+	descriptions := map[string]string{
+		client.PodSettingsCpu:                      "The CPU requested for the " + component + " pod (e.g. 100m)",
+		client.PodSettingsCpuLimit:                 "The CPU limit for the " + component + " pod",
+		client.PodSettingsMemory:                   "The memory requested for the " + component + " pod (e.g. 128Mi)",
+		client.PodSettingsMemoryLimit:              "The memory limit for the " + component + " pod",
+		client.PodSettingsNodeSelector:             "Node selector for the " + component + " pod, as a comma separated list of key=value pairs",
+		client.PodSettingsTolerations:              "Tolerations for the " + component + " pod, as a comma separated list of key[=value][:effect]",
+		client.PodSettingsAffinity:                 "Affinity for the " + component + " pod, as JSON",
+		client.PodSettingsPriorityClass:            "The priority class for the " + component + " pod",
+		client.PodSettingsPodSecurityContext:       "The pod security context for the " + component + " pod, as JSON",
+		client.PodSettingsContainerSecurityContext: "The security context for the " + component + " containers, as JSON",
+		client.PodSettingsSidecarCpu:               "The CPU requested for each sidecar of the " + component + " pod",
+		client.PodSettingsSidecarCpuLimit:          "The CPU limit for each sidecar of the " + component + " pod",
+		client.PodSettingsSidecarMemory:            "The memory requested for each sidecar of the " + component + " pod",
+		client.PodSettingsSidecarMemoryLimit:       "The memory limit for each sidecar of the " + component + " pod",
+	}
+	for _, key := range client.PodSettingsKeys(prefix) {
+		value := new(string)
+		podSettingsFlags[key] = value
+		cmd.Flags().StringVar(value, key, "", descriptions[strings.TrimPrefix(key, prefix)])
+	}
+}
+
+func parsePodSettingsFlags(options *types.SiteConfigSpec) error {
+	data := map[string]string{}
+	for key, value := range podSettingsFlags {
+		data[key] = *value
+	}
+	var err error
+	if options.Router, err = client.ParsePodSettings(client.RouterPodSettingsPrefix, data); err != nil {
+		return err
+	}
+	options.Controller, err = client.ParsePodSettings(client.ControllerPodSettingsPrefix, data)
+	return err
+}
 
 func NewCmdInit(newClient cobraFunc) *cobra.Command {
 	cmd := &cobra.Command{
@@ -179,6 +358,15 @@ installation that can then be connected to other skupper installations`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			//TODO: should cli allow init to diff ns?
 			silenceCobra(cmd)
+			if routerCreateOpts.Replicas < 1 {
+				return fmt.Errorf("Invalid value for --routers: %d", routerCreateOpts.Replicas)
+			}
+			if routerCreateOpts.Ingress != "" && !types.IsValidIngress(routerCreateOpts.Ingress) {
+				return fmt.Errorf("Invalid value for --ingress: %s", routerCreateOpts.Ingress)
+			}
+			if err := parsePodSettingsFlags(&routerCreateOpts); err != nil {
+				return err
+			}
 			ns := cli.GetNamespace()
 			routerCreateOpts.SkupperNamespace = ns
 			siteConfig, err := cli.SiteConfigInspect(context.Background(), nil)
@@ -211,6 +399,14 @@ installation that can then be connected to other skupper installations`,
 	cmd.Flags().StringVarP(&routerCreateOpts.User, "console-user", "", "", "Skupper console user. Valid only when --console-auth=internal")
 	cmd.Flags().StringVarP(&routerCreateOpts.Password, "console-password", "", "", "Skupper console user. Valid only when --console-auth=internal")
 	cmd.Flags().BoolVarP(&routerCreateOpts.ClusterLocal, "cluster-local", "", false, "Set up skupper to only accept connections from within the local cluster.")
+	cmd.Flags().StringVarP(&routerCreateOpts.Ingress, "ingress", "", "", "How to accept links from other sites. One of: '"+strings.Join(types.ValidIngressOptions(), "', '")+"'. Defaults to 'route' where supported, otherwise 'loadbalancer'.")
+	cmd.Flags().StringVarP(&routerCreateOpts.IngressHost, "ingress-host", "", "", "The host advertised in connection tokens; for 'nginx-ingress' the domain under which ingress hosts are created")
+	cmd.Flags().IntVarP(&routerCreateOpts.IngressInterRouterPort, "ingress-inter-router-port", "", 0, "The port advertised in connection tokens for inter-router links")
+	cmd.Flags().IntVarP(&routerCreateOpts.IngressEdgePort, "ingress-edge-port", "", 0, "The port advertised in connection tokens for edge links")
+	cmd.Flags().Int32VarP(&routerCreateOpts.Replicas, "routers", "", 1, "The number of router replicas; replicas are spread across nodes and linked to each other, which requires the service controller, and sites linking to this one make a link to each replica, unless --ingress-host (other than for nginx-ingress or nodeport) or the ports to advertise are given")
+	addPodSettingsFlags(cmd, client.RouterPodSettingsPrefix, "router")
+	addPodSettingsFlags(cmd, client.ControllerPodSettingsPrefix, "controller")
+	cmd.Flags().StringSliceVarP(&routerCreateOpts.ImagePullSecrets, client.ImagePullSecretsKey, "", []string{}, "Secrets used to pull the router and controller images")
 
 	return cmd
 }
@@ -467,6 +663,9 @@ func NewCmdStatus(newClient cobraFunc) *cobra.Command {
 				if vir.Status.TransportReadyReplicas == 0 {
 					fmt.Printf(" Status pending...")
 				} else {
+					if vir.Status.TransportReplicas > 1 {
+						fmt.Printf(" %d of %d router replicas ready.", vir.Status.TransportReadyReplicas, vir.Status.TransportReplicas)
+					}
 					if len(vir.Status.ConnectedSites.Warnings) > 0 {
 						for _, w := range vir.Status.ConnectedSites.Warnings {
 							fmt.Printf("Warning: %s", w)
@@ -511,10 +710,12 @@ func NewCmdStatus(newClient cobraFunc) *cobra.Command {
 }
 
 var exposeOpts ExposeOptions
+var exposePorts []string
+var exposeTargetPorts []string
 
 func NewCmdExpose(newClient cobraFunc) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:    "expose [deployment <name>|pods <selector>|statefulset <statefulsetname>|service <name>]",
+		Use:    "expose [deployment <name>|pods <selector>|statefulset <statefulsetname>|service <name>|host <hostname-or-ip>]",
 		Short:  "Expose a set of pods through a Skupper address",
 		Args:   exposeTargetArgs,
 		PreRun: newClient,
@@ -523,13 +724,22 @@ func NewCmdExpose(newClient cobraFunc) *cobra.Command {
 
 			targetType, targetName := parseTargetTypeAndName(args)
 
+			if err := parseExposePorts(&exposeOpts, exposePorts, exposeTargetPorts); err != nil {
+				return err
+			}
+
 			//silence cobra may be moved below the "if" we want to print
 			//the usage message along with this error
 			if exposeOpts.Address == "" {
 				if targetType == "service" {
 					return fmt.Errorf("--address option is required for target type 'service'")
 				}
-				if !exposeOpts.Headless {
+				if targetType == "host" {
+					exposeOpts.Address = client.DefaultAddressForHost(targetName)
+					if exposeOpts.Address == "" {
+						return fmt.Errorf("--address option is required for an IP address host target")
+					}
+				} else if !exposeOpts.Headless {
 					exposeOpts.Address = targetName
 				}
 			}
@@ -543,9 +753,17 @@ func NewCmdExpose(newClient cobraFunc) *cobra.Command {
 	}
 	cmd.Flags().StringVar(&(exposeOpts.Protocol), "protocol", "tcp", "The protocol to proxy (tcp, http, or http2)")
 	cmd.Flags().StringVar(&(exposeOpts.Address), "address", "", "The Skupper address to expose")
-	cmd.Flags().IntVar(&(exposeOpts.Port), "port", 0, "The port to expose on")
-	cmd.Flags().IntVar(&(exposeOpts.TargetPort), "target-port", 0, "The port to target on pods")
+	cmd.Flags().StringSliceVar(&exposePorts, "port", []string{}, "The port to expose on; repeat as <name>:<port> to expose multiple named ports")
+	cmd.Flags().StringSliceVar(&exposeTargetPorts, "target-port", []string{}, "The port to target on pods; given as <name>:<port> for named ports")
 	cmd.Flags().BoolVar(&(exposeOpts.Headless), "headless", false, "Expose through a headless service (valid only for a statefulset target)")
+	cmd.Flags().StringVar(&(exposeOpts.Distribution), "distribution", "", "How requests are distributed across the targets for the address (balanced or closest)")
+	cmd.Flags().IntVar(&(exposeOpts.Weight), "weight", 0, "The relative share of traffic for this target")
+	cmd.Flags().IntVar(&(exposeOpts.Priority), "priority", 0, "The priority of this target; lower values are preferred, higher ones only receive traffic on failure")
+	cmd.Flags().DurationVar(&(exposeOpts.ResolveInterval), "resolve-interval", 0, "If set for a host target, the interval at which the host name is re-resolved (e.g. 30s)")
+	addServiceTlsFlags(cmd, &exposeOpts.Tls)
+	addServiceExternalFlags(cmd, &exposeOpts.External)
+	cmd.Flags().StringToStringVar(&exposeOpts.Labels, "label", map[string]string{}, "Labels to add to the Kubernetes service for the address (e.g. --label app=db); skupper.io/ keys are reserved")
+	cmd.Flags().StringToStringVar(&exposeOpts.Annotations, "annotation", map[string]string{}, "Annotations to add to the Kubernetes service for the address (e.g. --annotation prometheus.io/scrape=true); skupper.io/ keys are reserved")
 
 	return cmd
 }
@@ -554,7 +772,7 @@ var unexposeAddress string
 
 func NewCmdUnexpose(newClient cobraFunc) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:    "unexpose [deployment <name>|pods <selector>|statefulset <statefulsetname>|service <name>]",
+		Use:    "unexpose [deployment <name>|pods <selector>|statefulset <statefulsetname>|service <name>|host <hostname-or-ip>]",
 		Short:  "Unexpose a set of pods previously exposed through a Skupper address",
 		Args:   exposeTargetArgs,
 		PreRun: newClient,
@@ -577,6 +795,17 @@ func NewCmdUnexpose(newClient cobraFunc) *cobra.Command {
 	return cmd
 }
 
+func describePorts(si *types.ServiceInterface) string {
+	if len(si.Ports) == 0 {
+		return fmt.Sprintf("port %d", si.Port)
+	}
+	ports := []string{}
+	for _, p := range si.Ports {
+		ports = append(ports, fmt.Sprintf("%s:%d", p.Name, p.Port))
+	}
+	return "ports " + strings.Join(ports, ", ")
+}
+
 func NewCmdListExposed(newClient cobraFunc) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:    "list-exposed",
@@ -593,11 +822,24 @@ func NewCmdListExposed(newClient cobraFunc) *cobra.Command {
 					fmt.Println("Services exposed through Skupper:")
 					for _, si := range vsis {
 						if len(si.Targets) == 0 {
-							fmt.Printf("    %s (%s port %d)", si.Address, si.Protocol, si.Port)
+							fmt.Printf("    %s (%s %s)", si.Address, si.Protocol, describePorts(si))
 							fmt.Println()
 						} else {
-							fmt.Printf("    %s (%s port %d) with targets", si.Address, si.Protocol, si.Port)
+							fmt.Printf("    %s (%s %s) with targets", si.Address, si.Protocol, describePorts(si))
 							fmt.Println()
+						}
+						if si.External != nil {
+							url, err := cli.ServiceInterfaceExternalUrl(context.Background(), si)
+							if err != nil {
+								fmt.Printf("      external (%s): %s", si.External.Type, err)
+							} else if url == "" {
+								fmt.Printf("      external (%s): <pending>", si.External.Type)
+							} else {
+								fmt.Printf("      external (%s): %s", si.External.Type, url)
+							}
+							fmt.Println()
+						}
+						if len(si.Targets) > 0 {
 							for _, t := range si.Targets {
 								var name string
 								if t.Name != "" {
@@ -607,6 +849,8 @@ func NewCmdListExposed(newClient cobraFunc) *cobra.Command {
 									fmt.Printf("      => %s %s", t.Selector, name)
 								} else if t.Service != "" {
 									fmt.Printf("      => %s %s", t.Service, name)
+								} else if t.Host != "" {
+									fmt.Printf("      => %s %s", t.Host, name)
 								} else {
 									fmt.Printf("      => %s (no selector)", name)
 								}
@@ -634,6 +878,20 @@ func NewCmdService() *cobra.Command {
 }
 
 var serviceToCreate types.ServiceInterface
+var serviceToCreateTls types.ServiceTls
+var serviceToCreateExternal types.ServiceExternal
+
+func addServiceTlsFlags(cmd *cobra.Command, tls *types.ServiceTls) {
+	cmd.Flags().BoolVar(&tls.Terminate, "tls-terminate", false, "If specified, the service accepts TLS connections, using a certificate for the address issued by the site's service CA. The site's router pods are restarted to load the certificate.")
+	cmd.Flags().BoolVar(&tls.Originate, "tls-originate", false, "If specified, connections to the targets of the service use TLS")
+	cmd.Flags().StringVar(&tls.CaSecret, "tls-ca-secret", "", "The secret holding the CA (as ca.crt) used to verify targets when originating TLS; defaults to the site's service CA. The site's router pods are restarted to load a secret not already in use.")
+	cmd.Flags().BoolVar(&tls.VerifyHostname, "tls-verify-hostname", false, "If specified, the certificates of targets must match their host names when originating TLS")
+}
+
+func addServiceExternalFlags(cmd *cobra.Command, external *types.ServiceExternal) {
+	cmd.Flags().StringVar(&external.Type, "external-type", "", "If specified, the service is also made accessible from outside the cluster in this site. One of 'loadbalancer', 'nodeport', 'ingress' or 'route'.")
+	cmd.Flags().StringVar(&external.Host, "external-host", "", "The host name for external access through an ingress or route")
+}
 
 func NewCmdCreateService(newClient cobraFunc) *cobra.Command {
 	cmd := &cobra.Command{
@@ -657,6 +915,14 @@ func NewCmdCreateService(newClient cobraFunc) *cobra.Command {
 				return fmt.Errorf("%s is not a valid port", sPort)
 			} else {
 				serviceToCreate.Port = servicePort
+				if serviceToCreateTls != (types.ServiceTls{}) {
+					serviceToCreate.Tls = &serviceToCreateTls
+				}
+				if serviceToCreateExternal.Type != "" {
+					serviceToCreate.External = &serviceToCreateExternal
+				} else if serviceToCreateExternal.Host != "" {
+					return fmt.Errorf("The external-host option requires an external-type")
+				}
 				err = cli.ServiceInterfaceCreate(context.Background(), &serviceToCreate)
 				if err != nil {
 					return fmt.Errorf("%w", err)
@@ -668,6 +934,13 @@ func NewCmdCreateService(newClient cobraFunc) *cobra.Command {
 	cmd.Flags().StringVar(&serviceToCreate.Protocol, "mapping", "tcp", "The mapping in use for this service address (currently one of tcp or http)")
 	cmd.Flags().StringVar(&serviceToCreate.Aggregate, "aggregate", "", "The aggregation strategy to use. One of 'json' or 'multipart'. If specified requests to this service will be sent to all registered implementations and the responses aggregated.")
 	cmd.Flags().BoolVar(&serviceToCreate.EventChannel, "event-channel", false, "If specified, this service will be a channel for multicast events.")
+	cmd.Flags().StringVar(&serviceToCreate.Distribution, "distribution", "", "How requests are distributed across the targets for the address. One of 'balanced' or 'closest'.")
+	cmd.Flags().IntVar(&serviceToCreate.Weight, "weight", 0, "The relative share of traffic for the targets of this service in this site.")
+	cmd.Flags().IntVar(&serviceToCreate.Priority, "priority", 0, "The priority of this site for this service. If specified, lower values are preferred and sites with higher values only receive traffic when no preferred site is available.")
+	addServiceTlsFlags(cmd, &serviceToCreateTls)
+	addServiceExternalFlags(cmd, &serviceToCreateExternal)
+	cmd.Flags().StringToStringVar(&serviceToCreate.Labels, "label", map[string]string{}, "Labels to add to the Kubernetes service for the address (e.g. --label app=db); skupper.io/ keys are reserved")
+	cmd.Flags().StringToStringVar(&serviceToCreate.Annotations, "annotation", map[string]string{}, "Annotations to add to the Kubernetes service for the address (e.g. --annotation prometheus.io/scrape=true); skupper.io/ keys are reserved")
 
 	return cmd
 }
@@ -774,27 +1047,313 @@ func NewCmdVersion(newClient cobraFunc) *cobra.Command {
 
 func NewCmdDebug() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "debug dump <file> or debug action <tbd>",
+		Use:   "debug dump <file>, debug analyze <file> or debug check",
 		Short: "Debug skupper installation",
 	}
 	return cmd
 }
 
+var redactDump bool
+
 func NewCmdDebugDump(newClient cobraFunc) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:    "dump <filename>",
-		Short:  "Collect and save skupper logs, config, etc.",
+		Use:   "dump <filename>",
+		Short: "Collect and save skupper logs, config, etc.",
+		Long: `Collect and save skupper logs, config, etc.
+
+The configuration, status, events and logs of the site are saved in a compressed
+archive, indexed by manifest.json. With --redact, hostnames, IP addresses
+and credentials are replaced throughout by placeholders, so that the archive can be
+shared outside the organisation.`,
 		Args:   cobra.ExactArgs(1),
 		PreRun: newClient,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			silenceCobra(cmd)
-			err := cli.SkupperDump(context.Background(), args[0], version, kubeConfigPath, kubeContext)
+			err := cli.SkupperDump(context.Background(), args[0], version, redactDump)
 			if err != nil {
 				return fmt.Errorf("Unable to save skupper details: %w", err)
 			}
 			return nil
 		},
 	}
+	cmd.Flags().BoolVarP(&redactDump, "redact", "", false, "Replace hostnames, IP addresses and credentials with placeholders")
+	return cmd
+}
+
+func NewCmdDebugAnalyze() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analyze <filename>",
+		Short: "Report common problems found in a file saved by 'skupper debug dump'",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			silenceCobra(cmd)
+			manifest, findings, err := client.AnalyzeDump(args[0])
+			if err != nil {
+				return fmt.Errorf("Unable to analyze dump: %w", err)
+			}
+			fmt.Printf("Dump of namespace '%s' taken %s\n", manifest.Namespace, manifest.Time.Format(time.RFC3339))
+			if len(findings) == 0 {
+				fmt.Println("No problems found.")
+				return nil
+			}
+			for _, finding := range findings {
+				fmt.Println(finding)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+var checkOutput string
+
+func NewCmdDebugCheck(newClient cobraFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the site for common problems",
+		Long: `Check the permissions of the current user, the router and controller deployments,
+the ingress through which other sites link to this one, the certificates in skupper's
+secrets, services exposed through the router and the bridges in the router, suggesting
+how to put right any problem found.`,
+		Args:   cobra.NoArgs,
+		PreRun: newClient,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			silenceCobra(cmd)
+			results, err := cli.SkupperCheck(context.Background())
+			if err != nil {
+				return fmt.Errorf("Unable to check site: %w", err)
+			}
+			switch checkOutput {
+			case "json":
+				encoded, err := json.MarshalIndent(results, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(encoded))
+			case "", "text":
+				for _, result := range results {
+					fmt.Println(describeCheckResult(result))
+				}
+			default:
+				return fmt.Errorf("Unsupported output format %q", checkOutput)
+			}
+			failures := 0
+			for _, result := range results {
+				if result.Status == types.CheckFail {
+					failures++
+				}
+			}
+			if failures > 0 {
+				return fmt.Errorf("%d of %d checks failed", failures, len(results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&checkOutput, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+func describeCheckResult(result types.CheckResult) string {
+	description := fmt.Sprintf("[%s] %s: %s", result.Status, result.Name, result.Message)
+	if result.Remediation != "" && result.Status != types.CheckPass {
+		description += "\n       " + result.Remediation
+	}
+	return description
+}
+
+var exportFile string
+
+func NewCmdExport(newClient cobraFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Describe this site in a manifest that can be applied with 'skupper apply'",
+		Long: `Describe the site configuration, links and service definitions of this site
+in a manifest. Links refer to a token file named after the link, which must be
+provided alongside the manifest for any link that is to be created by 'skupper apply'.
+The console password is not included.`,
+		Args:   cobra.NoArgs,
+		PreRun: newClient,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			silenceCobra(cmd)
+			manifest, err := cli.SiteManifestExport(context.Background())
+			if err != nil {
+				return fmt.Errorf("Unable to export site: %w", err)
+			}
+			encoded, err := client.WriteSiteManifest(manifest)
+			if err != nil {
+				return err
+			}
+			if exportFile == "" {
+				fmt.Print(string(encoded))
+				return nil
+			}
+			return ioutil.WriteFile(exportFile, encoded, 0644)
+		},
+	}
+	cmd.Flags().StringVarP(&exportFile, "output", "o", "", "The file to write the manifest to; written to standard output if not specified")
+	return cmd
+}
+
+type ApplyOptions struct {
+	File   string
+	Prune  bool
+	DryRun bool
+}
+
+var applyOpts ApplyOptions
+
+// readManifestFile reads a site manifest, resolving the token files of
+// its links relative to the directory of the manifest
+func readManifestFile(file string) (*types.SiteManifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := client.ReadSiteManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	for i, link := range manifest.Links {
+		if link.Token != "" && !filepath.IsAbs(link.Token) {
+			manifest.Links[i].Token = filepath.Join(filepath.Dir(file), link.Token)
+		}
+	}
+	return manifest, nil
+}
+
+func describeChange(change types.SiteManifestChange) string {
+	symbol := "~"
+	switch change.Action {
+	case types.ManifestCreate:
+		symbol = "+"
+	case types.ManifestDelete:
+		symbol = "-"
+	}
+	description := fmt.Sprintf("%s %s %s", symbol, change.Kind, change.Name)
+	if len(change.Details) > 0 {
+		description += " (" + strings.Join(change.Details, ", ") + ")"
+	}
+	return description
+}
+
+func NewCmdApply(newClient cobraFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply -f <manifest-file>",
+		Short: "Bring this site into line with a manifest produced by 'skupper export'",
+		Long: `Create or update the site, its links and its service definitions as described
+in the manifest. Applying the same manifest again makes no further changes. Links and
+services not in the manifest are left in place unless --prune is specified.`,
+		Args:   cobra.NoArgs,
+		PreRun: newClient,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			silenceCobra(cmd)
+			if applyOpts.File == "" {
+				return fmt.Errorf("A manifest must be specified with -f")
+			}
+			manifest, err := readManifestFile(applyOpts.File)
+			if err != nil {
+				return err
+			}
+			var changes []types.SiteManifestChange
+			if applyOpts.DryRun {
+				changes, err = cli.SiteManifestDiff(context.Background(), manifest, applyOpts.Prune)
+			} else {
+				changes, err = cli.SiteManifestApply(context.Background(), manifest, applyOpts.Prune)
+			}
+			if err != nil {
+				return fmt.Errorf("Unable to apply manifest: %w", err)
+			}
+			if len(changes) == 0 {
+				fmt.Println("Site in namespace '" + cli.GetNamespace() + "' is up to date.")
+				return nil
+			}
+			for _, change := range changes {
+				fmt.Println(describeChange(change))
+			}
+			if applyOpts.DryRun {
+				fmt.Println("(dry run; no changes made)")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&applyOpts.File, "filename", "f", "", "The manifest to apply")
+	cmd.Flags().BoolVarP(&applyOpts.Prune, "prune", "", false, "Remove links and service definitions that are not in the manifest")
+	cmd.Flags().BoolVarP(&applyOpts.DryRun, "dry-run", "", false, "Only show the changes that would be made")
+	return cmd
+}
+
+type BackupOptions struct {
+	PassphraseFile string
+}
+
+var backupOpts BackupOptions
+
+// readPassphrase takes the passphrase for a backup from a file if one
+// is given, otherwise from SKUPPER_BACKUP_PASSPHRASE, so that it never
+// appears on the command line
+func readPassphrase(file string) (string, error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase := os.Getenv("SKUPPER_BACKUP_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("A passphrase must be given with --passphrase-file or SKUPPER_BACKUP_PASSPHRASE")
+}
+
+func NewCmdBackup(newClient cobraFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup <output-file>",
+		Short: "Save the identity of this site to an encrypted file",
+		Long: `Save the site id, configuration, certificate authorities, link tokens and service
+definitions of this site, encrypted with a passphrase. The file can be used with
+'skupper restore' to recreate the site without issuing new tokens or links.`,
+		Args:   cobra.ExactArgs(1),
+		PreRun: newClient,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			silenceCobra(cmd)
+			passphrase, err := readPassphrase(backupOpts.PassphraseFile)
+			if err != nil {
+				return err
+			}
+			if err := cli.SiteBackup(context.Background(), args[0], passphrase); err != nil {
+				return fmt.Errorf("Unable to back up site: %w", err)
+			}
+			fmt.Println("Site in namespace '" + cli.GetNamespace() + "' backed up to " + args[0])
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&backupOpts.PassphraseFile, "passphrase-file", "", "", "A file containing the passphrase with which to encrypt the backup")
+	return cmd
+}
+
+func NewCmdRestore(newClient cobraFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <input-file>",
+		Short: "Recreate a site from a file produced by 'skupper backup'",
+		Long: `Recreate a site with the identity saved by 'skupper backup'. The namespace must
+not already have a site. Tokens issued by the original site remain valid and its links
+are made again.`,
+		Args:   cobra.ExactArgs(1),
+		PreRun: newClient,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			silenceCobra(cmd)
+			passphrase, err := readPassphrase(backupOpts.PassphraseFile)
+			if err != nil {
+				return err
+			}
+			if err := cli.SiteRestore(context.Background(), args[0], passphrase); err != nil {
+				return fmt.Errorf("Unable to restore site: %w", err)
+			}
+			fmt.Println("Site restored in namespace '" + cli.GetNamespace() + "'")
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&backupOpts.PassphraseFile, "passphrase-file", "", "", "A file containing the passphrase with which the backup was encrypted")
 	return cmd
 }
 
@@ -851,6 +1410,10 @@ func init() {
 	cmdUnbind := NewCmdUnbind(newClient)
 	cmdVersion := NewCmdVersion(newClient)
 	cmdDebugDump := NewCmdDebugDump(newClient)
+	cmdExport := NewCmdExport(newClient)
+	cmdApply := NewCmdApply(newClient)
+	cmdBackup := NewCmdBackup(newClient)
+	cmdRestore := NewCmdRestore(newClient)
 
 	// setup subcommands
 	cmdService := NewCmdService()
@@ -859,13 +1422,15 @@ func init() {
 
 	cmdDebug := NewCmdDebug()
 	cmdDebug.AddCommand(cmdDebugDump)
+	cmdDebug.AddCommand(NewCmdDebugAnalyze())
+	cmdDebug.AddCommand(NewCmdDebugCheck(newClient))
 
 	cmdCompletion := NewCmdCompletion()
 
 	rootCmd = &cobra.Command{Use: "skupper"}
 	rootCmd.Version = version
 	rootCmd.AddCommand(cmdInit, cmdDelete, cmdConnectionToken, cmdConnect, cmdDisconnect, cmdCheckConnection, cmdStatus, cmdListConnectors, cmdExpose, cmdUnexpose, cmdListExposed,
-		cmdService, cmdBind, cmdUnbind, cmdVersion, cmdDebug, cmdCompletion)
+		cmdService, cmdBind, cmdUnbind, cmdExport, cmdApply, cmdBackup, cmdRestore, cmdVersion, cmdDebug, cmdCompletion)
 	rootCmd.PersistentFlags().StringVarP(&kubeConfigPath, "kubeconfig", "", "", "Path to the kubeconfig file to use")
 	rootCmd.PersistentFlags().StringVarP(&kubeContext, "context", "c", "", "The kubeconfig context to use")
 	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "The Kubernetes namespace to use")
@@ -794,7 +794,7 @@ func TestExposeWithCluster(t *testing.T) {
 			args:            []string{"deployent", "tcp-not-deployed"},
 			expectedCapture: "",
 			expectedOutput:  "",
-			expectedError:   "target type must be one of: [deployment, statefulset, pods, service]",
+			expectedError:   "target type must be one of: [deployment, statefulset, pods, service, host]",
 			realCluster:     false,
 		},
 		{
@@ -964,7 +964,7 @@ func TestUnexposeWithCluster(t *testing.T) {
 			args:            []string{"deployent", "tcp-not-deployed"},
 			expectedCapture: "",
 			expectedOutput:  "",
-			expectedError:   "target type must be one of: [deployment, statefulset, pods, service]",
+			expectedError:   "target type must be one of: [deployment, statefulset, pods, service, host]",
 			realCluster:     false,
 		},
 		{
@@ -149,10 +149,34 @@ func (v *vanClientMock) SiteConfigRemove(ctx context.Context) error {
 	return nil
 }
 
-func (v *vanClientMock) SkupperDump(ctx context.Context, tarName string, version string, kubeConfigPath string, kubeConfigContext string) error {
+func (v *vanClientMock) SkupperDump(ctx context.Context, tarName string, version string, redact bool) error {
 	return nil
 }
 
+func (v *vanClientMock) SiteManifestExport(ctx context.Context) (*types.SiteManifest, error) {
+	return nil, nil
+}
+
+func (v *vanClientMock) SiteManifestDiff(ctx context.Context, manifest *types.SiteManifest, prune bool) ([]types.SiteManifestChange, error) {
+	return nil, nil
+}
+
+func (v *vanClientMock) SiteManifestApply(ctx context.Context, manifest *types.SiteManifest, prune bool) ([]types.SiteManifestChange, error) {
+	return nil, nil
+}
+
+func (v *vanClientMock) SiteBackup(ctx context.Context, file string, passphrase string) error {
+	return nil
+}
+
+func (v *vanClientMock) SiteRestore(ctx context.Context, file string, passphrase string) error {
+	return nil
+}
+
+func (v *vanClientMock) SkupperCheck(ctx context.Context) ([]types.CheckResult, error) {
+	return nil, nil
+}
+
 func (v *vanClientMock) ServiceInterfaceBind(ctx context.Context, service *types.ServiceInterface, targetType string, targetName string, protocol string, targetPort int) error {
 	var calledWith = serviceInterfaceBindCallArgs{
 		service:    service,
@@ -184,6 +208,9 @@ func (v *vanClientMock) ServiceInterfaceUpdate(ctx context.Context, service *typ
 	v.serviceInterfaceUpdateCalledWith = append(v.serviceInterfaceUpdateCalledWith, service)
 	return v.injectedReturns.serviceInterfaceUpdate
 }
+func (v *vanClientMock) ServiceInterfaceExternalUrl(ctx context.Context, service *types.ServiceInterface) (string, error) {
+	return "", nil
+}
 
 func (v *vanClientMock) GetHeadlessServiceConfiguration(targetName string, protocol string, address string, port int) (*types.ServiceInterface, error) {
 	var calledWith = getHeadlessServiceConfigurationCallArgs{
@@ -283,7 +310,7 @@ func TestCmdInit(t *testing.T) {
 			lcli.injectedReturns.siteConfigCreate.err = fmt.Errorf("some error")
 			err := cmd.RunE(&cobra.Command{}, args)
 			assert.Error(t, err, "some error")
-			assert.Assert(t, lcli.siteConfigCreateCalledWith[0] == routerCreateOpts)
+			assert.DeepEqual(t, lcli.siteConfigCreateCalledWith[0], routerCreateOpts)
 		})
 
 	t.Run("routerCreateFails",
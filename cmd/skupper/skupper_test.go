@@ -2,10 +2,14 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"gotest.tools/assert"
+
+	"github.com/skupperproject/skupper/api/types"
 )
 
 func Test_parseTargetTypeAndName(t *testing.T) {
@@ -32,7 +36,7 @@ func Test_bindArgs(t *testing.T) {
 	//must this fail?
 	//assert.Error(t, b([]string{"one/two", "resource/name"}), genericError)
 
-	assert.Error(t, b([]string{"one", "resource/name"}), "target type must be one of: [deployment, statefulset, pods, service]")
+	assert.Error(t, b([]string{"one", "resource/name"}), "target type must be one of: [deployment, statefulset, pods, service, host]")
 
 	assert.Assert(t, b([]string{"one", "pods/name"}))
 	assert.Assert(t, b([]string{"one", "pods", "name"}))
@@ -66,7 +70,7 @@ func Test_createServiceArgs(t *testing.T) {
 
 func Test_exposeTargetArgs(t *testing.T) {
 	genericError := "expose target and name must be specified (e.g. 'skupper expose deployment <name>'"
-	targetError := "target type must be one of: [deployment, statefulset, pods, service]"
+	targetError := "target type must be one of: [deployment, statefulset, pods, service, host]"
 
 	e := func(args []string) error {
 		return exposeTargetArgs(nil, args)
@@ -107,6 +111,60 @@ func Test_cmdExposeParseArgs(t *testing.T) {
 
 var clusterRun = flag.Bool("use-cluster", false, "run tests against a configured cluster")
 
+func Test_readManifestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "site.yaml")
+	manifest := `apiVersion: skupper.io/v1alpha1
+kind: SiteManifest
+site:
+  name: west
+links:
+- name: east
+  token: east.yaml
+- name: north
+  token: /tokens/north.yaml
+`
+	assert.Assert(t, ioutil.WriteFile(file, []byte(manifest), 0644))
+	result, err := readManifestFile(file)
+	assert.Assert(t, err)
+	assert.Equal(t, result.Links[0].Token, filepath.Join(dir, "east.yaml"))
+	assert.Equal(t, result.Links[1].Token, "/tokens/north.yaml")
+}
+
+func Test_describeChange(t *testing.T) {
+	assert.Equal(t, describeChange(types.SiteManifestChange{Action: types.ManifestCreate, Kind: types.ManifestLink, Name: "east"}), "+ link east")
+	assert.Equal(t, describeChange(types.SiteManifestChange{Action: types.ManifestDelete, Kind: types.ManifestService, Name: "backend"}), "- service backend")
+	assert.Equal(t, describeChange(types.SiteManifestChange{Action: types.ManifestUpdate, Kind: types.ManifestService, Name: "backend", Details: []string{"port", "targets"}}), "~ service backend (port, targets)")
+}
+
+func Test_readPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passphrase")
+	assert.Assert(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "passphrase")
+	assert.Assert(t, ioutil.WriteFile(file, []byte("from file\n"), 0600))
+
+	os.Setenv("SKUPPER_BACKUP_PASSPHRASE", "from env")
+	defer os.Unsetenv("SKUPPER_BACKUP_PASSPHRASE")
+	passphrase, err := readPassphrase(file)
+	assert.Assert(t, err)
+	assert.Equal(t, passphrase, "from file")
+	passphrase, err = readPassphrase("")
+	assert.Assert(t, err)
+	assert.Equal(t, passphrase, "from env")
+
+	os.Unsetenv("SKUPPER_BACKUP_PASSPHRASE")
+	_, err = readPassphrase("")
+	assert.ErrorContains(t, err, "A passphrase must be given")
+}
+
+func Test_describeCheckResult(t *testing.T) {
+	assert.Equal(t, describeCheckResult(types.CheckResult{Name: "ingress", Status: types.CheckPass, Message: "Reachable", Remediation: "ignored"}), "[pass] ingress: Reachable")
+	assert.Equal(t, describeCheckResult(types.CheckResult{Name: "service/lost", Status: types.CheckFail, Message: "Selector lost", Remediation: "Restore it"}), "[fail] service/lost: Selector lost\n       Restore it")
+}
+
 func TestMain(m *testing.M) {
 	flag.Parse()
 	os.Exit(m.Run())
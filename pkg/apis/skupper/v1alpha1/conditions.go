@@ -0,0 +1,64 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetCondition returns the condition of the given type, or nil if
+// there is none
+func (s *Status) GetCondition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition records the condition for the given generation, only
+// updating the transition time if its status changed. It returns true
+// if anything changed.
+func (s *Status) SetCondition(conditionType string, status corev1.ConditionStatus, reason string, message string, generation int64) bool {
+	changed := s.ObservedGeneration != generation
+	s.ObservedGeneration = generation
+	current := s.GetCondition(conditionType)
+	if current == nil {
+		s.Conditions = append(s.Conditions, Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: generation,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return true
+	}
+	if current.Status != status {
+		current.Status = status
+		current.LastTransitionTime = metav1.Now()
+		changed = true
+	}
+	if current.Reason != reason || current.Message != message || current.ObservedGeneration != generation {
+		current.Reason = reason
+		current.Message = message
+		current.ObservedGeneration = generation
+		changed = true
+	}
+	return changed
+}
+
+// SetReady records whether the resource is ready, with the error that
+// prevents it being so if it is not
+func (s *Status) SetReady(reason string, err error, generation int64) bool {
+	if err != nil {
+		return s.SetCondition(ConditionReady, corev1.ConditionFalse, reason, err.Error(), generation)
+	}
+	return s.SetCondition(ConditionReady, corev1.ConditionTrue, reason, "", generation)
+}
+
+// IsReady returns true if the Ready condition is true
+func (s *Status) IsReady() bool {
+	c := s.GetCondition(ConditionReady)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
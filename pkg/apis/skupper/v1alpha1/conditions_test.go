@@ -0,0 +1,34 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSetReady(t *testing.T) {
+	status := Status{}
+	assert.Assert(t, !status.IsReady())
+
+	assert.Assert(t, status.SetReady("Pending", fmt.Errorf("not yet"), 1))
+	condition := status.GetCondition(ConditionReady)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Message, "not yet")
+	assert.Assert(t, !status.IsReady())
+	transition := condition.LastTransitionTime
+
+	// no change is reported when nothing changes
+	assert.Assert(t, !status.SetReady("Pending", fmt.Errorf("not yet"), 1))
+
+	// a new message does not change the transition time
+	assert.Assert(t, status.SetReady("Pending", fmt.Errorf("still not"), 1))
+	assert.Equal(t, status.GetCondition(ConditionReady).LastTransitionTime, transition)
+
+	assert.Assert(t, status.SetReady("Ready", nil, 2))
+	assert.Assert(t, status.IsReady())
+	assert.Equal(t, status.ObservedGeneration, int64(2))
+	assert.Equal(t, status.GetCondition(ConditionReady).Message, "")
+	assert.Equal(t, len(status.Conditions), 1)
+}
@@ -0,0 +1,7 @@
+// +k8s:deepcopy-gen=package
+// +groupName=skupper.io
+
+// Package v1alpha1 contains the custom resources through which a skupper
+// site, its links, token requests and exported services can be managed
+// declaratively.
+package v1alpha1
@@ -0,0 +1,187 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types reported in the status of each resource
+const (
+	// ConditionReady is true once the resource has been reconciled
+	// and the state it describes is in effect
+	ConditionReady string = "Ready"
+)
+
+// Condition describes the state of an aspect of a resource at a point
+// in time
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	ObservedGeneration int64                  `json:"observedGeneration,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+}
+
+// Status is common to all the resources in this group
+type Status struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Site initialises skupper in its namespace; it is the declarative
+// equivalent of the skupper-site config map, which it manages
+type Site struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SiteSpec `json:"spec,omitempty"`
+	Status Status   `json:"status,omitempty"`
+}
+
+type SiteSpec struct {
+	// SiteName defaults to the namespace
+	SiteName string `json:"siteName,omitempty"`
+	Edge     bool   `json:"edge,omitempty"`
+	// ServiceController and ServiceSync default to true
+	ServiceController     *bool  `json:"serviceController,omitempty"`
+	ServiceSync           *bool  `json:"serviceSync,omitempty"`
+	Console               bool   `json:"console,omitempty"`
+	RouterConsole         bool   `json:"routerConsole,omitempty"`
+	ConsoleAuthentication string `json:"consoleAuthentication,omitempty"`
+	ClusterLocal          bool   `json:"clusterLocal,omitempty"`
+	Ingress               string `json:"ingress,omitempty"`
+	IngressHost           string `json:"ingressHost,omitempty"`
+	Routers               int32  `json:"routers,omitempty"`
+	// KeepCertificateAuthorities retains the site's CAs when it is
+	// deleted, so that links to a reinstalled site remain valid
+	KeepCertificateAuthorities bool `json:"keepCertificateAuthorities,omitempty"`
+	// Settings holds any further skupper-site keys, e.g. router-cpu
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type SiteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Site `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Link connects the site in its namespace to the site that issued the
+// token held in the referenced secret
+type Link struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LinkSpec   `json:"spec,omitempty"`
+	Status LinkStatus `json:"status,omitempty"`
+}
+
+type LinkSpec struct {
+	// TokenSecret is the name of the secret holding the token
+	TokenSecret string `json:"tokenSecret"`
+	Cost        int32  `json:"cost,omitempty"`
+}
+
+type LinkStatus struct {
+	Status `json:",inline"`
+	// Url is the host and port of the remote site
+	Url string `json:"url,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type LinkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Link `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TokenRequest has a token generated by the site in its namespace,
+// that other sites can use to link to it
+type TokenRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TokenRequestSpec   `json:"spec,omitempty"`
+	Status TokenRequestStatus `json:"status,omitempty"`
+}
+
+type TokenRequestSpec struct {
+	// SecretName is the name of the secret the token is written
+	// to; it defaults to the name of the request
+	SecretName string `json:"secretName,omitempty"`
+}
+
+type TokenRequestStatus struct {
+	Status     `json:",inline"`
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type TokenRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []TokenRequest `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceExport makes the targets it lists available at an address
+// across all linked sites
+type ServiceExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceExportSpec   `json:"spec,omitempty"`
+	Status ServiceExportStatus `json:"status,omitempty"`
+}
+
+type ServiceExportSpec struct {
+	// Address defaults to the name of the export
+	Address  string                `json:"address,omitempty"`
+	Protocol string                `json:"protocol,omitempty"`
+	Port     int                   `json:"port"`
+	Targets  []ServiceExportTarget `json:"targets,omitempty"`
+}
+
+type ServiceExportStatus struct {
+	Status `json:",inline"`
+	// Address is that of the service definition created for the
+	// export, which is removed with it; a definition the export did
+	// not create is never modified
+	Address string `json:"address,omitempty"`
+}
+
+// ServiceExportTarget identifies the workload handling requests for the
+// address in the same way as the arguments to skupper expose
+type ServiceExportTarget struct {
+	// Type is one of deployment, statefulset, service or host
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	TargetPort int    `json:"targetPort,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type ServiceExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ServiceExport `json:"items"`
+}
@@ -250,3 +250,38 @@ func GetSecretContent(secretFile string) (map[string][]byte, error) {
 	}
 	return content, nil
 }
+
+func getCertificateFromSecret(secret *corev1.Secret) (*x509.Certificate, error) {
+	block, _ := pem.Decode(secret.Data["tls.crt"])
+	if block == nil {
+		return nil, fmt.Errorf("Failed to decode certificate in secret %s", secret.ObjectMeta.Name)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// GetSecretHosts returns the hosts, by name or IP address, for which
+// the certificate in the secret is valid
+func GetSecretHosts(secret *corev1.Secret) (map[string]bool, error) {
+	cert, err := getCertificateFromSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	hosts := map[string]bool{}
+	for _, name := range cert.DNSNames {
+		hosts[name] = true
+	}
+	for _, ip := range cert.IPAddresses {
+		hosts[ip.String()] = true
+	}
+	return hosts, nil
+}
+
+// GetSecretSubject returns the common name of the certificate in the
+// secret
+func GetSecretSubject(secret *corev1.Secret) (string, error) {
+	cert, err := getCertificateFromSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return cert.Subject.CommonName, nil
+}
@@ -0,0 +1,158 @@
+package health
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	LivenessPath  string = "/healthz"
+	ReadinessPath string = "/readyz"
+)
+
+// Check returns an error describing why the component it concerns is
+// not healthy, or nil if it is
+type Check func() error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker aggregates the checks of a controller's components. All
+// checks must pass for the controller to be ready; only the liveness
+// checks, which detect problems a restart would resolve, need pass for
+// it to be considered alive.
+type Checker struct {
+	lock      sync.Mutex
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddLivenessCheck adds a check for both liveness and readiness
+func (c *Checker) AddLivenessCheck(name string, check Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.liveness = append(c.liveness, namedCheck{name, check})
+}
+
+// AddReadinessCheck adds a check for readiness only
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.readiness = append(c.readiness, namedCheck{name, check})
+}
+
+func (c *Checker) checks(readiness bool) []namedCheck {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	checks := append([]namedCheck{}, c.liveness...)
+	if readiness {
+		checks = append(checks, c.readiness...)
+	}
+	return checks
+}
+
+func (c *Checker) handler(readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ""
+		failed := false
+		for _, check := range c.checks(readiness) {
+			if err := check.check(); err != nil {
+				failed = true
+				report += fmt.Sprintf("[-]%s failed: %s\n", check.name, err)
+			} else {
+				report += fmt.Sprintf("[+]%s ok\n", check.name)
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprint(w, report)
+	}
+}
+
+// Handler serves the liveness and readiness endpoints, which respond
+// with a line per check and a status of 503 if any of them failed
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(LivenessPath, c.handler(false))
+	mux.Handle(ReadinessPath, c.handler(true))
+	return mux
+}
+
+// Serve listens on the given port until stopCh is closed
+func (c *Checker) Serve(port int, stopCh <-chan struct{}) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: c.Handler(),
+	}
+	go func() {
+		<-stopCh
+		server.Close()
+	}()
+	log.Printf("Serving health checks on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Health check server failed: %s", err)
+	}
+}
+
+// InformersSynced checks that the caches of the given informers have
+// been populated
+func InformersSynced(synced ...cache.InformerSynced) Check {
+	return func() error {
+		for _, hasSynced := range synced {
+			if !hasSynced() {
+				return fmt.Errorf("informer caches not yet synced")
+			}
+		}
+		return nil
+	}
+}
+
+// Progress tracks the processing of items taken from a workqueue, so
+// that a worker stuck on an item can be detected
+type Progress struct {
+	lock      sync.Mutex
+	started   time.Time
+	processed int
+}
+
+// Start records that processing of an item has begun
+func (p *Progress) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.started = time.Now()
+}
+
+// Finish records that processing of the current item has ended
+func (p *Progress) Finish() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.started = time.Time{}
+	p.processed++
+}
+
+// Check fails if an item has been processed for longer than limit
+func (p *Progress) Check(limit time.Duration) Check {
+	return func() error {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if !p.started.IsZero() {
+			if elapsed := time.Since(p.started); elapsed > limit {
+				return fmt.Errorf("stuck processing an item for %s after processing %d", elapsed.Round(time.Second), p.processed)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestChecker(t *testing.T) {
+	synced := false
+	connected := fmt.Errorf("not connected")
+	progress := &Progress{}
+
+	checker := NewChecker()
+	checker.AddLivenessCheck("workqueue", progress.Check(time.Minute))
+	checker.AddReadinessCheck("informers", InformersSynced(func() bool { return synced }))
+	checker.AddReadinessCheck("amqp", func() error { return connected })
+	handler := checker.Handler()
+
+	code, body := get(t, handler, LivenessPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "[+]workqueue ok\n")
+	code, body = get(t, handler, ReadinessPath)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, body, "[+]workqueue ok\n[-]informers failed: informer caches not yet synced\n[-]amqp failed: not connected\n")
+
+	synced = true
+	connected = nil
+	code, _ = get(t, handler, ReadinessPath)
+	assert.Equal(t, code, http.StatusOK)
+
+	// an item that has been in progress too long fails both
+	progress.Start()
+	progress.started = time.Now().Add(-2 * time.Minute)
+	code, body = get(t, handler, LivenessPath)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, body, "[-]workqueue failed: stuck processing an item for 2m0s after processing 0\n")
+	code, _ = get(t, handler, ReadinessPath)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	progress.Finish()
+	code, _ = get(t, handler, LivenessPath)
+	assert.Equal(t, code, http.StatusOK)
+}
@@ -70,27 +70,27 @@ func GetConfigMap(name string, namespace string, cli kubernetes.Interface) (*cor
 }
 
 func UpdateSkupperServices(changed []types.ServiceInterface, deleted []string, origin string, namespace string, cli kubernetes.Interface) error {
-	current, err := cli.CoreV1().ConfigMaps(namespace).Get("skupper-services", metav1.GetOptions{})
-	if err == nil {
-		if current.Data == nil {
-			current.Data = make(map[string]string)
-		}
+	err := UpdateServiceDefinitions(namespace, cli, func(definitions map[string]string) error {
 		for _, def := range changed {
+			// external access is configured per site, so is
+			// retained when the definition is updated
+			if existing, ok := definitions[def.Address]; ok && def.External == nil {
+				previous := types.ServiceInterface{}
+				if err := jsonencoding.Unmarshal([]byte(existing), &previous); err == nil {
+					def.External = previous.External
+				}
+			}
 			jsonDef, _ := jsonencoding.Marshal(def)
-			current.Data[def.Address] = string(jsonDef)
+			definitions[def.Address] = string(jsonDef)
 		}
 
 		for _, name := range deleted {
-			delete(current.Data, name)
-		}
-
-		_, err = cli.CoreV1().ConfigMaps(namespace).Update(current)
-		if err != nil {
-			return fmt.Errorf("Failed to update skupper-services config map: %s", err)
+			delete(definitions, name)
 		}
-	} else {
+		return nil
+	})
+	if errors.IsNotFound(err) {
 		return fmt.Errorf("Could not retrive service definitions from configmap 'skupper-services', Error: %v", err)
 	}
-
-	return nil
+	return err
 }
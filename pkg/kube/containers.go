@@ -13,6 +13,26 @@ func ContainerForController(ds types.DeploymentSpec) corev1.Container {
 		Name:  types.ControllerContainerName,
 		Env:   ds.EnvVar,
 	}
+	if ds.LivenessPort != 0 {
+		container.LivenessProbe = &corev1.Probe{
+			InitialDelaySeconds: 60,
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Port: intstr.FromInt(int(ds.LivenessPort)),
+					Path: "/healthz",
+				},
+			},
+		}
+		container.ReadinessProbe = &corev1.Probe{
+			InitialDelaySeconds: 5,
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Port: intstr.FromInt(int(ds.LivenessPort)),
+					Path: "/readyz",
+				},
+			},
+		}
+	}
 	return container
 }
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/skupperproject/skupper/pkg/utils"
 	"os"
+	"reflect"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -250,6 +251,7 @@ func NewControllerDeployment(van *types.RouterSpec, ownerRef *metav1.OwnerRefere
 		for i, _ := range van.Controller.VolumeMounts {
 			dep.Spec.Template.Spec.Containers[i].VolumeMounts = van.Controller.VolumeMounts[i]
 		}
+		UpdatePodSettings(dep, &van.Controller)
 
 		created, err := deployments.Create(dep)
 		if err != nil {
@@ -312,6 +314,7 @@ func NewTransportDeployment(van *types.RouterSpec, ownerRef *metav1.OwnerReferen
 		for i, _ := range van.Transport.VolumeMounts {
 			dep.Spec.Template.Spec.Containers[i].VolumeMounts = van.Transport.VolumeMounts[i]
 		}
+		UpdatePodSettings(dep, &van.Transport)
 
 		created, err := deployments.Create(dep)
 		if err != nil {
@@ -353,3 +356,102 @@ func WaitDeploymentReadyReplicas(name string, namespace string, readyReplicas in
 
 	return dep, err
 }
+
+func equivalentResourceLists(a corev1.ResourceList, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func equivalentResources(a corev1.ResourceRequirements, b corev1.ResourceRequirements) bool {
+	return equivalentResourceLists(a.Requests, b.Requests) && equivalentResourceLists(a.Limits, b.Limits)
+}
+
+func equivalentPodSecurityContexts(a *corev1.PodSecurityContext, b *corev1.PodSecurityContext) bool {
+	// the api server defaults an unset pod security context to an
+	// empty one
+	if a == nil {
+		a = &corev1.PodSecurityContext{}
+	}
+	if b == nil {
+		b = &corev1.PodSecurityContext{}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// UpdatePodSettings applies the resources, scheduling constraints and
+// security settings of the deployment spec to the pod template of the
+// deployment, returning true if anything was changed. The container
+// security context applies to every container; sidecars take the
+// sidecar resources rather than those of the first container.
+func UpdatePodSettings(dep *appsv1.Deployment, ds *types.DeploymentSpec) bool {
+	pod := &dep.Spec.Template.Spec
+	changed := false
+	for i := range pod.Containers {
+		container := &pod.Containers[i]
+		resources := ds.Resources
+		if i > 0 {
+			resources = ds.SidecarResources
+		}
+		if !equivalentResources(container.Resources, resources) {
+			container.Resources = resources
+			changed = true
+		}
+		if !reflect.DeepEqual(container.SecurityContext, ds.ContainerSecurityContext) {
+			container.SecurityContext = ds.ContainerSecurityContext
+			changed = true
+		}
+	}
+	if (len(pod.NodeSelector) > 0 || len(ds.NodeSelector) > 0) && !reflect.DeepEqual(pod.NodeSelector, ds.NodeSelector) {
+		pod.NodeSelector = ds.NodeSelector
+		changed = true
+	}
+	if (len(pod.Tolerations) > 0 || len(ds.Tolerations) > 0) && !reflect.DeepEqual(pod.Tolerations, ds.Tolerations) {
+		pod.Tolerations = ds.Tolerations
+		changed = true
+	}
+	if !reflect.DeepEqual(pod.Affinity, ds.Affinity) {
+		pod.Affinity = ds.Affinity
+		changed = true
+	}
+	if pod.PriorityClassName != ds.PriorityClassName {
+		pod.PriorityClassName = ds.PriorityClassName
+		changed = true
+	}
+	if !equivalentPodSecurityContexts(pod.SecurityContext, ds.PodSecurityContext) {
+		pod.SecurityContext = ds.PodSecurityContext
+		changed = true
+	}
+	if (len(pod.ImagePullSecrets) > 0 || len(ds.ImagePullSecrets) > 0) && !reflect.DeepEqual(pod.ImagePullSecrets, ds.ImagePullSecrets) {
+		pod.ImagePullSecrets = ds.ImagePullSecrets
+		changed = true
+	}
+	return changed
+}
+
+// PreferredPodAntiAffinity asks the scheduler to place pods matching
+// the labels on distinct nodes where possible
+func PreferredPodAntiAffinity(labels map[string]string) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: labels,
+						},
+						TopologyKey: "kubernetes.io/hostname",
+					},
+				},
+			},
+		},
+	}
+}
@@ -2,10 +2,12 @@ package kube_test
 
 import (
 	"fmt"
+	"github.com/skupperproject/skupper/api/types"
 	"github.com/skupperproject/skupper/pkg/kube"
 	"gotest.tools/assert"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"testing"
 )
@@ -73,3 +75,64 @@ func TestGetContainerPort(t *testing.T) {
 	}
 
 }
+
+func TestUpdatePodSettings(t *testing.T) {
+	nonRoot := true
+	dep := &v1.Deployment{
+		Spec: v1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					SecurityContext: &corev1.PodSecurityContext{},
+					Containers:      []corev1.Container{{Name: "router"}, {Name: "sidecar"}},
+				},
+			},
+		},
+	}
+	assert.Assert(t, !kube.UpdatePodSettings(dep, &types.DeploymentSpec{}))
+
+	desired := &types.DeploymentSpec{
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+		NodeSelector:             map[string]string{"zone": "east"},
+		PriorityClassName:        "high",
+		PodSecurityContext:       &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+		ImagePullSecrets:         []corev1.LocalObjectReference{{Name: "registry"}},
+		ContainerSecurityContext: &corev1.SecurityContext{RunAsNonRoot: &nonRoot},
+		SidecarResources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("64Mi")},
+		},
+	}
+	assert.Assert(t, kube.UpdatePodSettings(dep, desired))
+	pod := dep.Spec.Template.Spec
+	assert.Equal(t, pod.NodeSelector["zone"], "east")
+	assert.Equal(t, pod.PriorityClassName, "high")
+	assert.Assert(t, *pod.SecurityContext.RunAsNonRoot)
+	assert.Equal(t, pod.ImagePullSecrets[0].Name, "registry")
+	assert.Equal(t, len(pod.Containers[0].Resources.Limits), 1)
+	assert.Equal(t, len(pod.Containers[1].Resources.Limits), 2)
+	// sidecars are subject to the same admission policies
+	for _, container := range pod.Containers {
+		assert.Assert(t, *container.SecurityContext.RunAsNonRoot, container.Name)
+	}
+
+	// equivalent quantities in a different form are not a change
+	dep.Spec.Template.Spec.Containers[0].Resources.Limits[corev1.ResourceMemory] = resource.MustParse("268435456")
+	assert.Assert(t, !kube.UpdatePodSettings(dep, desired))
+
+	assert.Assert(t, kube.UpdatePodSettings(dep, &types.DeploymentSpec{}))
+	assert.Equal(t, len(dep.Spec.Template.Spec.NodeSelector), 0)
+	assert.Assert(t, dep.Spec.Template.Spec.SecurityContext == nil)
+}
+
+func TestContainerForControllerProbes(t *testing.T) {
+	container := kube.ContainerForController(types.DeploymentSpec{Image: "controller"})
+	assert.Assert(t, container.LivenessProbe == nil)
+	assert.Assert(t, container.ReadinessProbe == nil)
+
+	container = kube.ContainerForController(types.DeploymentSpec{Image: "controller", LivenessPort: types.ControllerHealthPort})
+	assert.Equal(t, container.LivenessProbe.HTTPGet.Path, "/healthz")
+	assert.Equal(t, container.LivenessProbe.HTTPGet.Port.IntValue(), int(types.ControllerHealthPort))
+	assert.Equal(t, container.ReadinessProbe.HTTPGet.Path, "/readyz")
+	assert.Equal(t, container.ReadinessProbe.HTTPGet.Port.IntValue(), int(types.ControllerHealthPort))
+}
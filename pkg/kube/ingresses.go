@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"fmt"
+
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func GetIngress(name string, namespace string, kubeclient kubernetes.Interface) (*networkingv1beta1.Ingress, error) {
+	current, err := kubeclient.NetworkingV1beta1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+	return current, err
+}
+
+func CreateIngress(ingress *networkingv1beta1.Ingress, namespace string, kubeclient kubernetes.Interface) (*networkingv1beta1.Ingress, error) {
+	current, err := kubeclient.NetworkingV1beta1().Ingresses(namespace).Get(ingress.Name, metav1.GetOptions{})
+	if err == nil {
+		return current, fmt.Errorf("Ingress %s already exists", ingress.Name)
+	} else if errors.IsNotFound(err) {
+		created, err := kubeclient.NetworkingV1beta1().Ingresses(namespace).Create(ingress)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create ingress : %w", err)
+		} else {
+			return created, nil
+		}
+	} else {
+		return nil, fmt.Errorf("Failed while checking ingress: %w", err)
+	}
+}
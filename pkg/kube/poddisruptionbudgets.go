@@ -0,0 +1,56 @@
+package kube
+
+import (
+	"fmt"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewPodDisruptionBudget returns a budget allowing at most one of the
+// pods matching the labels to be voluntarily disrupted at a time
+func NewPodDisruptionBudget(name string, labels map[string]string) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "policy/v1beta1",
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+		},
+	}
+}
+
+func CreatePodDisruptionBudget(pdb *policyv1beta1.PodDisruptionBudget, namespace string, kubeclient kubernetes.Interface) (*policyv1beta1.PodDisruptionBudget, error) {
+	current, err := kubeclient.PolicyV1beta1().PodDisruptionBudgets(namespace).Get(pdb.Name, metav1.GetOptions{})
+	if err == nil {
+		return current, fmt.Errorf("Pod disruption budget %s already exists", pdb.Name)
+	} else if errors.IsNotFound(err) {
+		created, err := kubeclient.PolicyV1beta1().PodDisruptionBudgets(namespace).Create(pdb)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create pod disruption budget : %w", err)
+		} else {
+			return created, nil
+		}
+	} else {
+		return nil, fmt.Errorf("Failed while checking pod disruption budget: %w", err)
+	}
+}
+
+func DeletePodDisruptionBudget(name string, namespace string, kubeclient kubernetes.Interface) error {
+	err := kubeclient.PolicyV1beta1().PodDisruptionBudgets(namespace).Delete(name, &metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
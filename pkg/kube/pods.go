@@ -68,6 +68,28 @@ func GetReadyPod(namespace string, clientset kubernetes.Interface, component str
 	}
 }
 
+// GetReadyPods returns all ready pods for the component, e.g. each
+// replica of the router
+func GetReadyPods(namespace string, clientset kubernetes.Interface, component string) ([]corev1.Pod, error) {
+	selector := "skupper.io/component=" + component
+	pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	} else if len(pods.Items) == 0 {
+		return nil, errors.New("Not found")
+	}
+	ready := []corev1.Pod{}
+	for _, p := range pods.Items {
+		if IsPodReady(&p) {
+			ready = append(ready, p)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, errors.New("Not ready")
+	}
+	return ready, nil
+}
+
 func GetImageVersion(pod *corev1.Pod, container string) string {
 	for _, c := range pod.Status.ContainerStatuses {
 		if c.Name == container {
@@ -0,0 +1,337 @@
+package kube
+
+import (
+	goerrors "errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+// Service definitions are held in skupper-services, keyed by address,
+// until it would grow beyond ServiceDefinitionsShardThreshold bytes.
+// They are then moved into ServiceDefinitionShards config maps, to
+// which each address is assigned by hash, and skupper-services is
+// annotated with the number of shards. Definitions written directly to
+// skupper-services after that, e.g. by an older client, take precedence
+// over those in the shards until the next update moves them across.
+const (
+	ServiceDefinitionsShardThreshold int = 512 * 1024
+	ServiceDefinitionShards          int = 16
+)
+
+// serviceDefinitionsRetry allows for more attempts than
+// retry.DefaultRetry, with wider jitter, as the cli, the service
+// controller and its definition monitor may all write at once while
+// service sync is busy
+var serviceDefinitionsRetry = wait.Backoff{
+	Steps:    10,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.5,
+	Jitter:   1.0,
+}
+
+// GetServiceDefinitionShardName returns the name of the config map in
+// which the definition for the address is held once sharded
+func GetServiceDefinitionShardName(address string, shards int) string {
+	h := fnv.New32a()
+	h.Write([]byte(address))
+	return fmt.Sprintf("%s-%d", types.ServiceInterfaceConfigMap, h.Sum32()%uint32(shards))
+}
+
+func getServiceDefinitionShards(configmap *corev1.ConfigMap) int {
+	shards, _ := strconv.Atoi(configmap.ObjectMeta.Annotations[types.ServiceDefinitionShardsQualifier])
+	return shards
+}
+
+func isServiceDefinitionShard(configmap *corev1.ConfigMap) bool {
+	return configmap.ObjectMeta.Name != types.ServiceInterfaceConfigMap && configmap.ObjectMeta.Labels[types.InternalTypeQualifier] == types.TypeServiceDefinitions
+}
+
+// MergeServiceDefinitions returns the encoded service definitions,
+// keyed by address, held in skupper-services and its shards amongst
+// the given config maps
+func MergeServiceDefinitions(configmaps []*corev1.ConfigMap) map[string]string {
+	var legacy *corev1.ConfigMap
+	for _, configmap := range configmaps {
+		if configmap.ObjectMeta.Name == types.ServiceInterfaceConfigMap {
+			legacy = configmap
+		}
+	}
+	definitions := map[string]string{}
+	if legacy == nil || getServiceDefinitionShards(legacy) > 0 {
+		for _, configmap := range configmaps {
+			if isServiceDefinitionShard(configmap) {
+				for address, encoded := range configmap.Data {
+					definitions[address] = encoded
+				}
+			}
+		}
+	}
+	if legacy != nil {
+		for address, encoded := range legacy.Data {
+			definitions[address] = encoded
+		}
+	}
+	return definitions
+}
+
+// getServiceDefinitionConfigMaps returns skupper-services and any
+// shards, keyed by name. Shards are returned even if skupper-services
+// is not yet annotated with their number, as a migration interrupted
+// before that was recorded leaves them in place to be adopted; their
+// contents are only used once it has been.
+func getServiceDefinitionConfigMaps(namespace string, cli kubernetes.Interface) (*corev1.ConfigMap, map[string]*corev1.ConfigMap, error) {
+	legacy, err := cli.CoreV1().ConfigMaps(namespace).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	shards := map[string]*corev1.ConfigMap{}
+	list, err := cli.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{LabelSelector: types.TypeServiceDefinitionsQualifier})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range list.Items {
+		if isServiceDefinitionShard(&list.Items[i]) {
+			shards[list.Items[i].ObjectMeta.Name] = &list.Items[i]
+		}
+	}
+	return legacy, shards, nil
+}
+
+func mergeServiceDefinitionConfigMaps(legacy *corev1.ConfigMap, shards map[string]*corev1.ConfigMap) map[string]string {
+	configmaps := []*corev1.ConfigMap{legacy}
+	for _, shard := range shards {
+		configmaps = append(configmaps, shard)
+	}
+	return MergeServiceDefinitions(configmaps)
+}
+
+// GetServiceDefinitions returns the encoded service definitions, keyed
+// by address. An error satisfying errors.IsNotFound is returned if
+// skupper-services does not exist.
+func GetServiceDefinitions(namespace string, cli kubernetes.Interface) (map[string]string, error) {
+	legacy, shards, err := getServiceDefinitionConfigMaps(namespace, cli)
+	if err != nil {
+		return nil, err
+	}
+	return mergeServiceDefinitionConfigMaps(legacy, shards), nil
+}
+
+// GetServiceDefinition returns the encoded definition for the address,
+// or an empty string if there is none. An error satisfying
+// errors.IsNotFound is returned if skupper-services does not exist.
+func GetServiceDefinition(address string, namespace string, cli kubernetes.Interface) (string, error) {
+	legacy, err := cli.CoreV1().ConfigMaps(namespace).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if encoded, ok := legacy.Data[address]; ok {
+		return encoded, nil
+	}
+	shards := getServiceDefinitionShards(legacy)
+	if shards == 0 {
+		return "", nil
+	}
+	shard, err := cli.CoreV1().ConfigMaps(namespace).Get(GetServiceDefinitionShardName(address, shards), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return shard.Data[address], nil
+}
+
+// NewServiceDefinitionsConfigMap creates skupper-services, labelled so
+// that it is watched along with any shards, if it does not exist
+func NewServiceDefinitionsConfigMap(data map[string]string, owner *metav1.OwnerReference, namespace string, cli kubernetes.Interface) (*corev1.ConfigMap, error) {
+	configmaps := cli.CoreV1().ConfigMaps(namespace)
+	existing, err := configmaps.Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	if err == nil {
+		return existing, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("Failed to check existing config maps: %w", err)
+	}
+	configmap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: types.ServiceInterfaceConfigMap,
+			Labels: map[string]string{
+				types.InternalTypeQualifier: types.TypeServiceDefinitions,
+			},
+		},
+		Data: data,
+	}
+	if owner != nil {
+		configmap.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	created, err := configmaps.Create(configmap)
+	if errors.IsAlreadyExists(err) {
+		// created concurrently since checked
+		return configmaps.Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to create %s config map: %w", types.ServiceInterfaceConfigMap, err)
+	}
+	return created, nil
+}
+
+// isServiceDefinitionsConflict returns true if the error, or any it
+// wraps, shows that the definitions were written by another client
+// since they were read
+func isServiceDefinitionsConflict(err error) bool {
+	for ; err != nil; err = goerrors.Unwrap(err) {
+		if errors.IsConflict(err) || errors.IsAlreadyExists(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateServiceDefinitions reads the encoded service definitions, keyed
+// by address, passes them to update to be modified and writes back any
+// that changed, moving them into shards if skupper-services would
+// otherwise grow too large. If another client writes the definitions
+// first, they are read again and update is retried against them, so
+// update must derive its changes from the definitions it is passed
+// each time. Only the config maps holding changed definitions are
+// written, so concurrent changes to different addresses are all kept.
+// If update returns an error nothing is written and that error is
+// returned. An error satisfying errors.IsNotFound is returned if
+// skupper-services does not exist.
+func UpdateServiceDefinitions(namespace string, cli kubernetes.Interface, update func(definitions map[string]string) error) error {
+	return retry.OnError(serviceDefinitionsRetry, isServiceDefinitionsConflict, func() error {
+		legacy, shards, err := getServiceDefinitionConfigMaps(namespace, cli)
+		if errors.IsNotFound(err) {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("Could not retrieve service definitions: %s", err)
+		}
+		definitions := mergeServiceDefinitionConfigMaps(legacy, shards)
+		if err := update(definitions); err != nil {
+			return err
+		}
+		return writeServiceDefinitions(legacy, shards, definitions, namespace, cli)
+	})
+}
+
+// MigrateServiceDefinitions labels skupper-services so that it is
+// watched along with its shards, and moves the definitions into shards
+// if it has grown too large, as sites created by earlier versions may
+// need
+func MigrateServiceDefinitions(namespace string, cli kubernetes.Interface) error {
+	return UpdateServiceDefinitions(namespace, cli, func(definitions map[string]string) error {
+		return nil
+	})
+}
+
+func definitionsSize(definitions map[string]string) int {
+	size := 0
+	for address, encoded := range definitions {
+		size += len(address) + len(encoded)
+	}
+	return size
+}
+
+func equivalentData(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+func writeServiceDefinitions(legacy *corev1.ConfigMap, shards map[string]*corev1.ConfigMap, definitions map[string]string, namespace string, cli kubernetes.Interface) error {
+	configmaps := cli.CoreV1().ConfigMaps(namespace)
+	labelled := legacy.ObjectMeta.Labels[types.InternalTypeQualifier] == types.TypeServiceDefinitions
+	if !labelled {
+		if legacy.ObjectMeta.Labels == nil {
+			legacy.ObjectMeta.Labels = map[string]string{}
+		}
+		legacy.ObjectMeta.Labels[types.InternalTypeQualifier] = types.TypeServiceDefinitions
+	}
+	count := getServiceDefinitionShards(legacy)
+	if count == 0 {
+		if definitionsSize(definitions) <= ServiceDefinitionsShardThreshold {
+			if labelled && equivalentData(legacy.Data, definitions) {
+				return nil
+			}
+			legacy.Data = definitions
+			if _, err := configmaps.Update(legacy); err != nil {
+				return fmt.Errorf("Failed to update %s config map: %w", types.ServiceInterfaceConfigMap, err)
+			}
+			return nil
+		}
+		count = ServiceDefinitionShards
+		log.Printf("Moving service definitions into %d shards, as %s has grown too large", count, types.ServiceInterfaceConfigMap)
+	}
+
+	byShard := map[string]map[string]string{}
+	for i := 0; i < count; i++ {
+		byShard[fmt.Sprintf("%s-%d", types.ServiceInterfaceConfigMap, i)] = map[string]string{}
+	}
+	for address, encoded := range definitions {
+		byShard[GetServiceDefinitionShardName(address, count)][address] = encoded
+	}
+	for name, data := range byShard {
+		shard, ok := shards[name]
+		if !ok {
+			if len(data) == 0 {
+				continue
+			}
+			shard = &corev1.ConfigMap{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+					Labels: map[string]string{
+						types.InternalTypeQualifier: types.TypeServiceDefinitions,
+					},
+					OwnerReferences: legacy.ObjectMeta.OwnerReferences,
+				},
+				Data: data,
+			}
+			if _, err := configmaps.Create(shard); err != nil {
+				return fmt.Errorf("Failed to create %s config map: %w", name, err)
+			}
+		} else if !equivalentData(shard.Data, data) {
+			// including a shard left by an interrupted migration
+			shard.Data = data
+			if _, err := configmaps.Update(shard); err != nil {
+				return fmt.Errorf("Failed to update %s config map: %w", name, err)
+			}
+		}
+	}
+
+	// only once the shards hold every definition are any left in
+	// skupper-services removed
+	if !labelled || len(legacy.Data) > 0 || getServiceDefinitionShards(legacy) != count {
+		if legacy.ObjectMeta.Annotations == nil {
+			legacy.ObjectMeta.Annotations = map[string]string{}
+		}
+		legacy.ObjectMeta.Annotations[types.ServiceDefinitionShardsQualifier] = strconv.Itoa(count)
+		legacy.Data = nil
+		if _, err := configmaps.Update(legacy); err != nil {
+			return fmt.Errorf("Failed to update %s config map: %w", types.ServiceInterfaceConfigMap, err)
+		}
+	}
+	return nil
+}
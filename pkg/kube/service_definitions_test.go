@@ -0,0 +1,367 @@
+package kube
+
+import (
+	goerrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/skupperproject/skupper/api/types"
+)
+
+// largeDefinitions returns enough definitions, each of the given size,
+// to take skupper-services beyond the shard threshold
+func largeDefinitions(size int) map[string]string {
+	definitions := map[string]string{}
+	for i := 0; definitionsSize(definitions) <= ServiceDefinitionsShardThreshold; i++ {
+		definitions[fmt.Sprintf("svc%d", i)] = strings.Repeat("x", size)
+	}
+	return definitions
+}
+
+func setDefinitions(definitions map[string]string) func(map[string]string) error {
+	return func(current map[string]string) error {
+		for address, encoded := range definitions {
+			current[address] = encoded
+		}
+		return nil
+	}
+}
+
+func TestServiceDefinitionsUnsharded(t *testing.T) {
+	const NS = "test"
+	owner := &metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "skupper-site", UID: "00000000-0000-0000-0000-000000000000"}
+	kubeClient := fake.NewSimpleClientset()
+
+	_, err := GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, errors.IsNotFound(err))
+	err = UpdateServiceDefinitions(NS, kubeClient, setDefinitions(map[string]string{"a": "{}"}))
+	assert.Assert(t, errors.IsNotFound(err))
+
+	_, err = NewServiceDefinitionsConfigMap(nil, owner, NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, setDefinitions(map[string]string{"a": "{\"address\":\"a\"}", "b": "{\"address\":\"b\"}"})))
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, func(definitions map[string]string) error {
+		delete(definitions, "b")
+		return nil
+	}))
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(NS).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.DeepEqual(t, cm.Data, map[string]string{"a": "{\"address\":\"a\"}"})
+	assert.Equal(t, cm.ObjectMeta.Labels[types.InternalTypeQualifier], types.TypeServiceDefinitions)
+	list, err := kubeClient.CoreV1().ConfigMaps(NS).List(metav1.ListOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(list.Items), 1)
+
+	encoded, err := GetServiceDefinition("a", NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, encoded, "{\"address\":\"a\"}")
+	encoded, err = GetServiceDefinition("b", NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, encoded, "")
+
+	// an error from the update prevents anything being written
+	err = UpdateServiceDefinitions(NS, kubeClient, func(definitions map[string]string) error {
+		definitions["c"] = "{}"
+		return fmt.Errorf("rejected")
+	})
+	assert.Error(t, err, "rejected")
+	definitions, err := GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, map[string]string{"a": "{\"address\":\"a\"}"})
+}
+
+func TestServiceDefinitionsSharded(t *testing.T) {
+	const NS = "test"
+	owner := &metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "skupper-site", UID: "00000000-0000-0000-0000-000000000000"}
+	kubeClient := fake.NewSimpleClientset()
+	_, err := NewServiceDefinitionsConfigMap(nil, owner, NS, kubeClient)
+	assert.Assert(t, err)
+
+	expected := largeDefinitions(4096)
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, setDefinitions(expected)))
+
+	// skupper-services is left holding only the number of shards
+	cm, err := kubeClient.CoreV1().ConfigMaps(NS).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(cm.Data), 0)
+	assert.Equal(t, cm.ObjectMeta.Annotations[types.ServiceDefinitionShardsQualifier], fmt.Sprint(ServiceDefinitionShards))
+
+	list, err := kubeClient.CoreV1().ConfigMaps(NS).List(metav1.ListOptions{LabelSelector: types.TypeServiceDefinitionsQualifier})
+	assert.Assert(t, err)
+	assert.Equal(t, len(list.Items), ServiceDefinitionShards+1)
+	configmaps := []*v1.ConfigMap{}
+	for i := range list.Items {
+		shard := &list.Items[i]
+		configmaps = append(configmaps, shard)
+		if shard.ObjectMeta.Name == types.ServiceInterfaceConfigMap {
+			continue
+		}
+		assert.DeepEqual(t, shard.ObjectMeta.OwnerReferences, []metav1.OwnerReference{*owner})
+		assert.Assert(t, definitionsSize(shard.Data) <= ServiceDefinitionsShardThreshold)
+		for address := range shard.Data {
+			assert.Equal(t, GetServiceDefinitionShardName(address, ServiceDefinitionShards), shard.ObjectMeta.Name)
+		}
+	}
+	assert.DeepEqual(t, MergeServiceDefinitions(configmaps), expected)
+
+	definitions, err := GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, expected)
+	encoded, err := GetServiceDefinition("svc0", NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, encoded, expected["svc0"])
+
+	// removing a definition updates only its shard
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, func(definitions map[string]string) error {
+		delete(definitions, "svc0")
+		return nil
+	}))
+	delete(expected, "svc0")
+	definitions, err = GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, expected)
+	encoded, err = GetServiceDefinition("svc0", NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, encoded, "")
+}
+
+func TestServiceDefinitionsLegacyWrite(t *testing.T) {
+	const NS = "test"
+	kubeClient := fake.NewSimpleClientset()
+	_, err := NewServiceDefinitionsConfigMap(nil, nil, NS, kubeClient)
+	assert.Assert(t, err)
+	expected := largeDefinitions(4096)
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, setDefinitions(expected)))
+
+	// a client unaware of the shards writes directly to skupper-services
+	cm, err := kubeClient.CoreV1().ConfigMaps(NS).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	assert.Assert(t, err)
+	cm.Data = map[string]string{"svc0": "{\"address\":\"svc0\"}", "legacy": "{\"address\":\"legacy\"}"}
+	_, err = kubeClient.CoreV1().ConfigMaps(NS).Update(cm)
+	assert.Assert(t, err)
+	expected["svc0"] = "{\"address\":\"svc0\"}"
+	expected["legacy"] = "{\"address\":\"legacy\"}"
+
+	// which takes precedence over the shards
+	definitions, err := GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, expected)
+	encoded, err := GetServiceDefinition("svc0", NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, encoded, "{\"address\":\"svc0\"}")
+
+	// and is moved into them on the next write
+	assert.Assert(t, MigrateServiceDefinitions(NS, kubeClient))
+	cm, err = kubeClient.CoreV1().ConfigMaps(NS).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(cm.Data), 0)
+	shard, err := kubeClient.CoreV1().ConfigMaps(NS).Get(GetServiceDefinitionShardName("legacy", ServiceDefinitionShards), metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, shard.Data["legacy"], "{\"address\":\"legacy\"}")
+	definitions, err = GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, expected)
+}
+
+func TestMigrateServiceDefinitions(t *testing.T) {
+	const NS = "test"
+	// as created by an earlier version
+	kubeClient := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: types.ServiceInterfaceConfigMap, Namespace: NS},
+		Data:       map[string]string{"a": "{\"address\":\"a\"}"},
+	})
+	assert.Assert(t, MigrateServiceDefinitions(NS, kubeClient))
+	cm, err := kubeClient.CoreV1().ConfigMaps(NS).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, cm.ObjectMeta.Labels[types.InternalTypeQualifier], types.TypeServiceDefinitions)
+	assert.DeepEqual(t, cm.Data, map[string]string{"a": "{\"address\":\"a\"}"})
+
+	assert.Assert(t, errors.IsNotFound(MigrateServiceDefinitions("other", kubeClient)))
+}
+
+// newVersionedClientset returns a fake clientset which, like the API
+// server, rejects updates to config maps read before their last write
+func newVersionedClientset(objects ...runtime.Object) *fake.Clientset {
+	kubeClient := fake.NewSimpleClientset(objects...)
+	version := 0
+	// reactions are serialised by the clientset
+	kubeClient.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		configmap := action.(k8stesting.UpdateAction).GetObject().(*v1.ConfigMap).DeepCopy()
+		current, err := kubeClient.Tracker().Get(action.GetResource(), action.GetNamespace(), configmap.ObjectMeta.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		if current.(*v1.ConfigMap).ObjectMeta.ResourceVersion != configmap.ObjectMeta.ResourceVersion {
+			return true, nil, errors.NewConflict(action.GetResource().GroupResource(), configmap.ObjectMeta.Name, fmt.Errorf("the object has been modified"))
+		}
+		version++
+		configmap.ObjectMeta.ResourceVersion = strconv.Itoa(version)
+		return true, configmap, kubeClient.Tracker().Update(action.GetResource(), configmap, action.GetNamespace())
+	})
+	return kubeClient
+}
+
+func TestUpdateServiceDefinitionsConflict(t *testing.T) {
+	const NS = "test"
+	kubeClient := newVersionedClientset()
+	_, err := NewServiceDefinitionsConfigMap(map[string]string{"a": "{}"}, nil, NS, kubeClient)
+	assert.Assert(t, err)
+
+	// another client writes between the first read and write
+	injected := false
+	kubeClient.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if injected {
+			return false, nil, nil
+		}
+		injected = true
+		obj, err := kubeClient.Tracker().Get(action.GetResource(), action.GetNamespace(), types.ServiceInterfaceConfigMap)
+		assert.Assert(t, err)
+		current := obj.(*v1.ConfigMap)
+		current.Data["b"] = "{}"
+		current.ObjectMeta.ResourceVersion = "injected"
+		assert.Assert(t, kubeClient.Tracker().Update(action.GetResource(), current, action.GetNamespace()))
+		return false, nil, nil
+	})
+
+	attempts := 0
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, func(definitions map[string]string) error {
+		attempts++
+		definitions["c"] = "{}"
+		return nil
+	}))
+	assert.Equal(t, attempts, 2)
+	definitions, err := GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, map[string]string{"a": "{}", "b": "{}", "c": "{}"})
+}
+
+func TestInterruptedServiceDefinitionsMigration(t *testing.T) {
+	const NS = "test"
+	kubeClient := newVersionedClientset()
+	_, err := NewServiceDefinitionsConfigMap(nil, nil, NS, kubeClient)
+	assert.Assert(t, err)
+
+	// the shards are written, but another client writes skupper-services
+	// before it records them, every time
+	kubeClient.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		configmap := action.(k8stesting.UpdateAction).GetObject().(*v1.ConfigMap)
+		if configmap.ObjectMeta.Name == types.ServiceInterfaceConfigMap && getServiceDefinitionShards(configmap) > 0 {
+			return true, nil, errors.NewConflict(action.GetResource().GroupResource(), configmap.ObjectMeta.Name, fmt.Errorf("the object has been modified"))
+		}
+		return false, nil, nil
+	})
+	expected := largeDefinitions(4096)
+	err = UpdateServiceDefinitions(NS, kubeClient, setDefinitions(expected))
+	assert.Assert(t, errors.IsConflict(goerrors.Unwrap(err)), err)
+	shard, err := kubeClient.CoreV1().ConfigMaps(NS).Get(GetServiceDefinitionShardName("svc0", ServiceDefinitionShards), metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, shard.Data["svc0"], expected["svc0"])
+
+	// until then the shards are ignored
+	definitions, err := GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, len(definitions), 0)
+
+	// once skupper-services can be written, the shards left behind are
+	// adopted rather than created again
+	kubeClient.ReactionChain = kubeClient.ReactionChain[1:]
+	expected["svc0"] = strings.Repeat("y", 4096)
+	assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, setDefinitions(expected)))
+	definitions, err = GetServiceDefinitions(NS, kubeClient)
+	assert.Assert(t, err)
+	assert.DeepEqual(t, definitions, expected)
+	cm, err := kubeClient.CoreV1().ConfigMaps(NS).Get(types.ServiceInterfaceConfigMap, metav1.GetOptions{})
+	assert.Assert(t, err)
+	assert.Equal(t, len(cm.Data), 0)
+	assert.Equal(t, cm.ObjectMeta.Annotations[types.ServiceDefinitionShardsQualifier], fmt.Sprint(ServiceDefinitionShards))
+}
+
+func TestUpdateServiceDefinitionsConcurrently(t *testing.T) {
+	const NS = "test"
+	const writers = 8
+	const updates = 20
+	for _, sharded := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sharded=%t", sharded), func(t *testing.T) {
+			kubeClient := newVersionedClientset()
+			expected := map[string]string{}
+			if sharded {
+				expected = largeDefinitions(4096)
+			}
+			_, err := NewServiceDefinitionsConfigMap(nil, nil, NS, kubeClient)
+			assert.Assert(t, err)
+			assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, setDefinitions(expected)))
+			expected["counter"] = "0"
+			assert.Assert(t, UpdateServiceDefinitions(NS, kubeClient, setDefinitions(map[string]string{"counter": "0"})))
+
+			// each writer adds its own addresses, which must all be
+			// kept, and increments a shared one, which must not lose
+			// any increment
+			var wg sync.WaitGroup
+			errs := make(chan error, writers*updates)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < updates; i++ {
+						errs <- UpdateServiceDefinitions(NS, kubeClient, func(definitions map[string]string) error {
+							count, err := strconv.Atoi(definitions["counter"])
+							if err != nil {
+								return err
+							}
+							definitions["counter"] = strconv.Itoa(count + 1)
+							definitions[fmt.Sprintf("writer%d-%d", w, i)] = "{}"
+							return nil
+						})
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				assert.Assert(t, err)
+			}
+
+			for w := 0; w < writers; w++ {
+				for i := 0; i < updates; i++ {
+					expected[fmt.Sprintf("writer%d-%d", w, i)] = "{}"
+				}
+			}
+			expected["counter"] = strconv.Itoa(writers * updates)
+			definitions, err := GetServiceDefinitions(NS, kubeClient)
+			assert.Assert(t, err)
+			assert.DeepEqual(t, definitions, expected)
+		})
+	}
+}
+
+func TestNewServiceDefinitionsConfigMapConcurrently(t *testing.T) {
+	const NS = "test"
+	kubeClient := newVersionedClientset()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := NewServiceDefinitionsConfigMap(nil, nil, NS, kubeClient)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Assert(t, err)
+	}
+}
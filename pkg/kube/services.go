@@ -2,6 +2,8 @@ package kube
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -11,6 +13,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/skupperproject/skupper/api/types"
+	"github.com/skupperproject/skupper/pkg/utils"
 )
 
 func GetLabelsForRouter() map[string]string {
@@ -44,22 +47,133 @@ func GetService(name string, namespace string, kubeclient kubernetes.Interface)
 	return current, err
 }
 
-func NewServiceForAddress(address string, port int, targetPort int, owner *metav1.OwnerReference, namespace string, kubeclient kubernetes.Interface) (*corev1.Service, error) {
-	labels := GetLabelsForRouter()
-	service := makeServiceObjectForAddress(address, port, targetPort, labels, owner)
+// NewServiceForAddress creates a service exposing the given ports,
+// with targetPorts giving the target port for each by port name
+func NewServiceForAddress(address string, ports []types.ServicePort, targetPorts map[string]int, labels map[string]string, annotations map[string]string, serviceType corev1.ServiceType, owner *metav1.OwnerReference, namespace string, kubeclient kubernetes.Interface) (*corev1.Service, error) {
+	selector := GetLabelsForRouter()
+	service := makeServiceObjectForAddress(address, ports, targetPorts, selector, owner)
+	service.Spec.Type = serviceType
+	ApplyServiceMetadata(service, labels, annotations)
 	return createServiceFromObject(service, namespace, kubeclient)
 }
 
-func NewHeadlessServiceForAddress(address string, port int, targetPort int, owner *metav1.OwnerReference, namespace string, kubeclient kubernetes.Interface) (*corev1.Service, error) {
-	labels := map[string]string{
+func NewHeadlessServiceForAddress(address string, port int, targetPort int, labels map[string]string, annotations map[string]string, owner *metav1.OwnerReference, namespace string, kubeclient kubernetes.Interface) (*corev1.Service, error) {
+	selector := map[string]string{
 		"internal.skupper.io/service": address,
 	}
-	service := makeServiceObjectForAddress(address, port, targetPort, labels, owner)
+	service := makeServiceObjectForAddress(address, []types.ServicePort{{Port: port}}, map[string]int{"": targetPort}, selector, owner)
 	service.Spec.ClusterIP = "None"
+	ApplyServiceMetadata(service, labels, annotations)
 	return createServiceFromObject(service, namespace, kubeclient)
 }
 
-func makeServiceObjectForAddress(address string, port int, targetPort int, labels map[string]string, owner *metav1.OwnerReference) *corev1.Service {
+// GetServiceTypeForExternal returns the type of service needed to
+// expose an address as specified
+func GetServiceTypeForExternal(external *types.ServiceExternal) corev1.ServiceType {
+	if external != nil {
+		switch external.Type {
+		case types.ExternalTypeLoadBalancer:
+			return corev1.ServiceTypeLoadBalancer
+		case types.ExternalTypeNodePort:
+			return corev1.ServiceTypeNodePort
+		}
+	}
+	return corev1.ServiceTypeClusterIP
+}
+
+// SetServiceType changes the type of the service, clearing any node
+// ports if they are no longer applicable. Returns true if the service
+// was modified.
+func SetServiceType(service *corev1.Service, serviceType corev1.ServiceType) bool {
+	actual := service.Spec.Type
+	if actual == "" {
+		actual = corev1.ServiceTypeClusterIP
+	}
+	if actual == serviceType {
+		return false
+	}
+	service.Spec.Type = serviceType
+	if serviceType == corev1.ServiceTypeClusterIP {
+		for i := range service.Spec.Ports {
+			service.Spec.Ports[i].NodePort = 0
+		}
+	}
+	return true
+}
+
+// IsReservedMetadataKey returns true if the label or annotation key
+// is one skupper itself uses (e.g. skupper.io/proxy) and so cannot be
+// set through a service interface.
+func IsReservedMetadataKey(key string) bool {
+	return strings.HasPrefix(key, types.BaseQualifier+"/") || strings.HasPrefix(key, types.InternalQualifier+"/")
+}
+
+// ApplyServiceMetadata sets the given labels and annotations on the
+// service, removing any previously applied that are no longer
+// specified. The keys applied are recorded on the service so that
+// labels and annotations added by others are left alone. Reserved
+// keys are never applied. Returns true if the service was modified.
+func ApplyServiceMetadata(service *corev1.Service, labels map[string]string, annotations map[string]string) bool {
+	if service.ObjectMeta.Annotations == nil {
+		service.ObjectMeta.Annotations = map[string]string{}
+	}
+	updated := applyMetadata(&service.ObjectMeta.Labels, labels, service.ObjectMeta.Annotations, types.AppliedLabelsQualifier)
+	if applyMetadata(&service.ObjectMeta.Annotations, annotations, service.ObjectMeta.Annotations, types.AppliedAnnotationsQualifier) {
+		updated = true
+	}
+	return updated
+}
+
+func applyMetadata(actual *map[string]string, desired map[string]string, record map[string]string, qualifier string) bool {
+	updated := false
+	if *actual == nil {
+		*actual = map[string]string{}
+	}
+	if previous, ok := record[qualifier]; ok && previous != "" {
+		for _, key := range strings.Split(previous, ",") {
+			if _, ok := desired[key]; ok {
+				continue
+			}
+			if _, ok := (*actual)[key]; ok {
+				delete(*actual, key)
+				updated = true
+			}
+		}
+	}
+	keys := []string{}
+	for key, value := range desired {
+		if IsReservedMetadataKey(key) {
+			continue
+		}
+		keys = append(keys, key)
+		if current, ok := (*actual)[key]; !ok || current != value {
+			(*actual)[key] = value
+			updated = true
+		}
+	}
+	sort.Strings(keys)
+	applied := strings.Join(keys, ",")
+	if applied != record[qualifier] {
+		if applied == "" {
+			delete(record, qualifier)
+		} else {
+			record[qualifier] = applied
+		}
+		updated = true
+	}
+	return updated
+}
+
+// GetServicePortName returns the name of the port on a service for
+// the named port of the service interface with the given address
+func GetServicePortName(address string, name string) string {
+	if name == "" {
+		return address
+	}
+	return name
+}
+
+func makeServiceObjectForAddress(address string, ports []types.ServicePort, targetPorts map[string]int, selector map[string]string, owner *metav1.OwnerReference) *corev1.Service {
 	// TODO: make common service creation and deal with annotation, label differences
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -73,16 +187,16 @@ func makeServiceObjectForAddress(address string, port int, targetPort int, label
 			},
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: labels,
-			Ports: []corev1.ServicePort{
-				corev1.ServicePort{
-					Name:       address,
-					Port:       int32(port),
-					TargetPort: intstr.FromInt(targetPort),
-				},
-			},
+			Selector: selector,
 		},
 	}
+	for _, port := range ports {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       GetServicePortName(address, port.Name),
+			Port:       int32(port.Port),
+			TargetPort: intstr.FromInt(targetPorts[port.Name]),
+		})
+	}
 	if owner != nil {
 		service.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*owner}
 
@@ -138,3 +252,64 @@ func GetPortForServiceTarget(targetName string, defaultNamespace string, kubecli
 		return 0, err
 	}
 }
+
+// ParsePortAnnotation reads the per port values recorded in an
+// annotation as a comma separated list of name:port pairs
+func ParsePortAnnotation(value string) map[string]int {
+	ports := map[string]int{}
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) == 2 {
+			if port, err := strconv.Atoi(parts[1]); err == nil {
+				ports[parts[0]] = port
+			}
+		}
+	}
+	return ports
+}
+
+// FormatPortAnnotation records per port values as name:port pairs
+func FormatPortAnnotation(ports map[string]int) string {
+	names := []string{}
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := []string{}
+	for _, name := range names {
+		items = append(items, name+":"+strconv.Itoa(ports[name]))
+	}
+	return strings.Join(items, ",")
+}
+
+// RestoreServiceDefinition reverts the changes made to expose a service
+// through the router, as recorded in its annotations. It returns true
+// if the service was modified.
+func RestoreServiceDefinition(service *corev1.Service) bool {
+	updated := false
+	if originalSelector, ok := service.ObjectMeta.Annotations[types.OriginalSelectorQualifier]; ok {
+		updated = true
+		delete(service.ObjectMeta.Annotations, types.OriginalSelectorQualifier)
+		service.Spec.Selector = utils.LabelToMap(originalSelector)
+	}
+	if original, ok := service.ObjectMeta.Annotations[types.OriginalTargetPortQualifier]; ok {
+		updated = true
+		delete(service.ObjectMeta.Annotations, types.OriginalTargetPortQualifier)
+		if strings.Contains(original, ":") {
+			originalTargetPorts := ParsePortAnnotation(original)
+			for i, port := range service.Spec.Ports {
+				if targetPort, ok := originalTargetPorts[port.Name]; ok {
+					service.Spec.Ports[i].TargetPort = intstr.FromInt(targetPort)
+				}
+			}
+		} else if len(service.Spec.Ports) > 0 {
+			originalTargetPort, _ := strconv.Atoi(original)
+			service.Spec.Ports[0].TargetPort = intstr.FromInt(originalTargetPort)
+		}
+	}
+	if _, ok := service.ObjectMeta.Annotations[types.OriginalAssignedQualifier]; ok {
+		updated = true
+		delete(service.ObjectMeta.Annotations, types.OriginalAssignedQualifier)
+	}
+	return updated
+}
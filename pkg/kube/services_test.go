@@ -2,6 +2,7 @@ package kube
 
 import (
 	"fmt"
+	"github.com/skupperproject/skupper/api/types"
 	"gotest.tools/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -88,3 +89,70 @@ func TestGetPortForServiceTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestNewServiceForAddressMetadata(t *testing.T) {
+	const NS = "test"
+	kubeClient := fake.NewSimpleClientset()
+	labels := map[string]string{"app": "db"}
+	annotations := map[string]string{"prometheus.io/scrape": "true"}
+	svc, err := NewServiceForAddress("db", []types.ServicePort{{Port: 5432}}, map[string]int{"": 1024}, labels, annotations, corev1.ServiceTypeClusterIP, nil, NS, kubeClient)
+	assert.Assert(t, err)
+	assert.Equal(t, svc.ObjectMeta.Labels["app"], "db")
+	assert.Equal(t, svc.ObjectMeta.Annotations["prometheus.io/scrape"], "true")
+	assert.Equal(t, svc.ObjectMeta.Annotations["internal.skupper.io/controlled"], "true")
+	assert.Equal(t, svc.ObjectMeta.Annotations[types.AppliedLabelsQualifier], "app")
+	assert.Equal(t, svc.ObjectMeta.Annotations[types.AppliedAnnotationsQualifier], "prometheus.io/scrape")
+	assert.DeepEqual(t, svc.Spec.Selector, GetLabelsForRouter())
+}
+
+func TestApplyServiceMetadata(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "db",
+			Labels: map[string]string{"team": "data"},
+		},
+	}
+	assert.Assert(t, ApplyServiceMetadata(svc, map[string]string{"app": "db", "tier": "backend"}, map[string]string{"mesh": "on"}))
+	assert.Assert(t, !ApplyServiceMetadata(svc, map[string]string{"app": "db", "tier": "backend"}, map[string]string{"mesh": "on"}))
+	assert.Equal(t, svc.ObjectMeta.Annotations[types.AppliedLabelsQualifier], "app,tier")
+
+	// drift is corrected
+	svc.ObjectMeta.Labels["app"] = "other"
+	assert.Assert(t, ApplyServiceMetadata(svc, map[string]string{"app": "db", "tier": "backend"}, map[string]string{"mesh": "on"}))
+	assert.Equal(t, svc.ObjectMeta.Labels["app"], "db")
+
+	// labels no longer specified are removed, others left alone
+	assert.Assert(t, ApplyServiceMetadata(svc, map[string]string{"app": "db"}, nil))
+	assert.DeepEqual(t, svc.ObjectMeta.Labels, map[string]string{"team": "data", "app": "db"})
+	_, ok := svc.ObjectMeta.Annotations["mesh"]
+	assert.Assert(t, !ok)
+	_, ok = svc.ObjectMeta.Annotations[types.AppliedAnnotationsQualifier]
+	assert.Assert(t, !ok)
+
+	// reserved keys, e.g. from a definition received from another
+	// site, are never applied
+	assert.Assert(t, !ApplyServiceMetadata(svc, map[string]string{"app": "db", types.AddressQualifier: "other"}, map[string]string{types.ProxyQualifier: "tcp"}))
+	_, ok = svc.ObjectMeta.Labels[types.AddressQualifier]
+	assert.Assert(t, !ok)
+	_, ok = svc.ObjectMeta.Annotations[types.ProxyQualifier]
+	assert.Assert(t, !ok)
+}
+
+func TestSetServiceType(t *testing.T) {
+	svc := &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{{Name: "db", Port: 5432}},
+		},
+	}
+	assert.Equal(t, GetServiceTypeForExternal(nil), corev1.ServiceTypeClusterIP)
+	assert.Equal(t, GetServiceTypeForExternal(&types.ServiceExternal{Type: types.ExternalTypeIngress}), corev1.ServiceTypeClusterIP)
+	assert.Assert(t, !SetServiceType(svc, GetServiceTypeForExternal(nil)))
+	assert.Assert(t, SetServiceType(svc, GetServiceTypeForExternal(&types.ServiceExternal{Type: types.ExternalTypeNodePort})))
+	assert.Equal(t, svc.Spec.Type, corev1.ServiceTypeNodePort)
+
+	// node ports allocated by the cluster are released on reverting
+	svc.Spec.Ports[0].NodePort = 31234
+	assert.Assert(t, SetServiceType(svc, corev1.ServiceTypeClusterIP))
+	assert.Equal(t, svc.Spec.Ports[0].NodePort, int32(0))
+}
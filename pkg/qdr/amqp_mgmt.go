@@ -8,10 +8,13 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"github.com/skupperproject/skupper/api/types"
 )
 
 type Agent struct {
 	connection *amqp.Client
+	session    *amqp.Session
 	sender     *amqp.Sender
 	anonymous  *amqp.Sender
 	receiver   *amqp.Receiver
@@ -63,13 +66,27 @@ func (r Record) AsRecord(field string) Record {
 	}
 }
 
+// asVerifyHostname only reports the hostname verification of
+// connectors using TLS, as the router defaults it for all others
+func asVerifyHostname(record Record) *bool {
+	if record.AsString("sslProfile") == "" {
+		return nil
+	}
+	if value, ok := record["verifyHostname"].(bool); ok {
+		return &value
+	}
+	return nil
+}
+
 func asTcpEndpoint(record Record) TcpEndpoint {
 	return TcpEndpoint{
-		Name:    record.AsString("name"),
-		Host:    record.AsString("host"),
-		Port:    record.AsString("port"),
-		Address: record.AsString("address"),
-		SiteId:  record.AsString("siteId"),
+		Name:           record.AsString("name"),
+		Host:           record.AsString("host"),
+		Port:           record.AsString("port"),
+		Address:        record.AsString("address"),
+		SiteId:         record.AsString("siteId"),
+		SslProfile:     record.AsString("sslProfile"),
+		VerifyHostname: asVerifyHostname(record),
 	}
 }
 
@@ -84,6 +101,8 @@ func asHttpEndpoint(record Record) HttpEndpoint {
 		Aggregation:     record.AsString("aggregation"),
 		EventChannel:    record.AsBool("eventChannel"),
 		HostOverride:    record.AsString("hostOverride"),
+		SslProfile:      record.AsString("sslProfile"),
+		VerifyHostname:  asVerifyHostname(record),
 	}
 }
 
@@ -165,6 +184,18 @@ func (p *AgentPool) Put(a *Agent) {
 	}
 }
 
+// Close closes the connections of any agents waiting to be reused
+func (p *AgentPool) Close() {
+	for {
+		select {
+		case a := <-p.pool:
+			a.Close()
+		default:
+			return
+		}
+	}
+}
+
 func Connect(url string, config *tls.Config) (*Agent, error) {
 	var connection *amqp.Client
 	var err error
@@ -178,9 +209,22 @@ func Connect(url string, config *tls.Config) (*Agent, error) {
 	}
 	session, err := connection.NewSession()
 	if err != nil {
+		connection.Close()
 		return nil, fmt.Errorf("Failed to create session: %s", err)
 	}
+	a, err := NewAgent(session)
+	if a == nil {
+		connection.Close()
+		return nil, err
+	}
+	a.connection = connection
+	return a, err
+}
 
+// NewAgent creates a management agent on a session of a connection
+// owned by the caller, so that several agents and other links can
+// share one connection. Closing the agent closes only the session.
+func NewAgent(session *amqp.Session) (*Agent, error) {
 	receiver, err := session.NewReceiver(
 		amqp.LinkSourceAddress(""),
 		amqp.LinkAddressDynamic(),
@@ -200,10 +244,10 @@ func Connect(url string, config *tls.Config) (*Agent, error) {
 		return nil, fmt.Errorf("Failed to create anonymous sender: %s", err)
 	}
 	a := &Agent{
-		connection: connection,
-		sender:     sender,
-		anonymous:  anonymous,
-		receiver:   receiver,
+		session:   session,
+		sender:    sender,
+		anonymous: anonymous,
+		receiver:  receiver,
 	}
 	a.local, err = a.getLocalRouter()
 	if err != nil {
@@ -214,6 +258,11 @@ func Connect(url string, config *tls.Config) (*Agent, error) {
 
 func (a *Agent) Close() error {
 	a.closed = true
+	if a.connection == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return a.session.Close(ctx)
+	}
 	return a.connection.Close()
 }
 
@@ -690,7 +739,9 @@ func (a *Agent) GetLocalBridgeConfig() (*BridgeConfig, error) {
 		return nil, err
 	}
 	for _, record := range results {
-		config.AddTcpListener(asTcpEndpoint(record))
+		listener := asTcpEndpoint(record)
+		listener.VerifyHostname = nil
+		config.AddTcpListener(listener)
 	}
 
 	results, err = a.Query("org.apache.qpid.dispatch.httpConnector", []string{})
@@ -706,7 +757,9 @@ func (a *Agent) GetLocalBridgeConfig() (*BridgeConfig, error) {
 		return nil, err
 	}
 	for _, record := range results {
-		config.AddHttpListener(asHttpEndpoint(record))
+		listener := asHttpEndpoint(record)
+		listener.VerifyHostname = nil
+		config.AddHttpListener(listener)
 	}
 
 	return &config, nil
@@ -766,6 +819,111 @@ func (a *Agent) UpdateLocalBridgeConfig(changes *BridgeConfigDifference) error {
 	return nil
 }
 
+func asConnector(record Record) Connector {
+	return Connector{
+		Name:       record.AsString("name"),
+		Role:       Role(record.AsString("role")),
+		Host:       record.AsString("host"),
+		Port:       record.AsString("port"),
+		Cost:       int32(record.AsInt("cost")),
+		SslProfile: record.AsString("sslProfile"),
+	}
+}
+
+// GetLocalReplicaConnectors returns the connectors through which the
+// local router links to the other replicas of its site
+func (a *Agent) GetLocalReplicaConnectors() (map[string]Connector, error) {
+	connectors := map[string]Connector{}
+	results, err := a.Query("org.apache.qpid.dispatch.connector", []string{})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range results {
+		connector := asConnector(record)
+		if strings.HasPrefix(connector.Name, types.ReplicaConnectorPrefix) {
+			connectors[connector.Name] = connector
+		}
+	}
+	return connectors, nil
+}
+
+// UpdateLocalReplicaConnectors creates and deletes connectors of the
+// local router to the other replicas of its site, so that they match
+// those desired. Returns true if they already matched.
+func (a *Agent) UpdateLocalReplicaConnectors(desired map[string]Connector) (bool, error) {
+	actual, err := a.GetLocalReplicaConnectors()
+	if err != nil {
+		return false, fmt.Errorf("Error retrieving replica connectors: %s", err)
+	}
+	synced := true
+	for name, connector := range actual {
+		if wanted, ok := desired[name]; !ok || wanted.Host != connector.Host || wanted.Port != connector.Port {
+			if err := a.Delete("org.apache.qpid.dispatch.connector", name); err != nil {
+				return false, fmt.Errorf("Error deleting replica connector: %s", err)
+			}
+			delete(actual, name)
+			synced = false
+		}
+	}
+	for name, connector := range desired {
+		if _, ok := actual[name]; ok {
+			continue
+		}
+		record := map[string]interface{}{}
+		if err := convert(connector, &record); err != nil {
+			return false, fmt.Errorf("Failed to convert record: %s", err)
+		}
+		if err := a.Create("org.apache.qpid.dispatch.connector", name, record); err != nil {
+			return false, fmt.Errorf("Error adding replica connector: %s", err)
+		}
+		synced = false
+	}
+	return synced, nil
+}
+
+func asAddress(record Record) Address {
+	return Address{
+		Name:         record.AsString("name"),
+		Prefix:       record.AsString("prefix"),
+		Distribution: record.AsString("distribution"),
+	}
+}
+
+// GetLocalAddressConfig returns the address configuration of the
+// local router, excluding reserved prefixes
+func (a *Agent) GetLocalAddressConfig() (AddressMap, error) {
+	config := AddressMap{}
+	results, err := a.Query("org.apache.qpid.dispatch.router.config.address", []string{})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range results {
+		address := asAddress(record)
+		if !IsReservedPrefix(address.Prefix) {
+			config[address.Prefix] = address
+		}
+	}
+	return config, nil
+}
+
+func (a *Agent) UpdateLocalAddressConfig(changes *AddressDifference) error {
+	for _, deleted := range changes.Deleted {
+		if err := a.Delete("org.apache.qpid.dispatch.router.config.address", deleted); err != nil {
+			return fmt.Errorf("Error deleting addresses: %s", err)
+		}
+	}
+	for _, added := range changes.Added {
+		record := map[string]interface{}{}
+		if err := convert(added, &record); err != nil {
+			return fmt.Errorf("Failed to convert record: %s", err)
+		}
+		if err := a.Create("org.apache.qpid.dispatch.router.config.address", added.Name, record); err != nil {
+			return fmt.Errorf("Error adding addresses: %s", err)
+		}
+	}
+	return nil
+}
+
 func (a *Agent) GetBridges(routers []Router) ([]BridgeConfig, error) {
 	configs := []BridgeConfig{}
 	agents := getAddressesFor(routers)
@@ -43,13 +43,79 @@ type Connection struct {
 	Dir        string `json:"dir"`
 }
 
-func getConnectedSitesFromNodesEdge(namespace string, clientset kubernetes.Interface, config *restclient.Config) (types.TransportConnectedSites, error) {
-	result := types.TransportConnectedSites{}
-	direct := make(map[string]bool)
-	indirect := make(map[string]bool)
+// connectedSites records the sites seen from one or more routers of
+// the local site, keyed by router id
+type connectedSites struct {
+	direct   map[string]bool
+	indirect map[string]bool
+	warnings []string
+}
+
+func newConnectedSites() *connectedSites {
+	return &connectedSites{
+		direct:   map[string]bool{},
+		indirect: map[string]bool{},
+	}
+}
+
+// merge adds the sites seen by another router of the same site; a site
+// directly connected to any router is counted as direct
+func (s *connectedSites) merge(other *connectedSites) {
+	for key := range other.direct {
+		s.direct[key] = true
+		delete(s.indirect, key)
+	}
+	for key := range other.indirect {
+		if !s.direct[key] {
+			s.indirect[key] = true
+		}
+	}
+	for _, warning := range other.warnings {
+		if !containsString(s.warnings, warning) {
+			s.warnings = append(s.warnings, warning)
+		}
+	}
+}
+
+// toTransportConnectedSites counts the sites, excluding the given
+// router ids which belong to the local site itself
+func (s *connectedSites) toTransportConnectedSites(local map[string]bool) types.TransportConnectedSites {
+	result := types.TransportConnectedSites{
+		Warnings: s.warnings,
+	}
+	isLocal := func(key string) bool {
+		return local[key] || local[strings.TrimPrefix(key, "router.node/")]
+	}
+	for key := range s.direct {
+		if !isLocal(key) {
+			result.Direct++
+		}
+	}
+	for key := range s.indirect {
+		if !isLocal(key) {
+			result.Indirect++
+		}
+	}
+	result.Total = result.Direct + result.Indirect
+	return result
+}
+
+func containsString(list []string, value string) bool {
+	for _, s := range list {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+func getConnectedSitesFromNodesEdge(pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (*connectedSites, error) {
+	result := newConnectedSites()
+	direct := result.direct
+	indirect := result.indirect
 	interiors := make(map[string]RouterNode)
 
-	uplinks, err := getEdgeUplinkConnections(namespace, clientset, config)
+	uplinks, err := getEdgeUplinkConnections(pod, namespace, clientset, config)
 	if err != nil {
 		return result, err
 	}
@@ -66,11 +132,11 @@ func getConnectedSitesFromNodesEdge(namespace string, clientset kubernetes.Inter
 		if interiorsRetrieved {
 			key := fmt.Sprintf("router.node/%s", c.Container)
 			if _, ok := interiors[key]; !ok {
-				result.Warnings = append(result.Warnings, "There are edge uplinks to distinct networks, please verify topology (connected counts may not be accurate).")
+				result.warnings = append(result.warnings, "There are edge uplinks to distinct networks, please verify topology (connected counts may not be accurate).")
 				continue
 			}
 		}
-		interiorNodes, err := getNodesForRouter(c.Container, namespace, clientset, config)
+		interiorNodes, err := getNodesForRouter(c.Container, pod, namespace, clientset, config)
 		if err != nil {
 			return result, err
 		} else {
@@ -87,12 +153,12 @@ func getConnectedSitesFromNodesEdge(namespace string, clientset kubernetes.Inter
 			}
 		}
 	}
-	localId, err := getLocalRouterId(namespace, clientset, config)
+	localId, err := getLocalRouterId(pod, namespace, clientset, config)
 	if err != nil {
 		return result, err
 	}
 	for _, interiorNode := range interiors {
-		edges, err := getEdgeConnectionsForInterior(interiorNode.Id, namespace, clientset, config)
+		edges, err := getEdgeConnectionsForInterior(interiorNode.Id, pod, namespace, clientset, config)
 		if err != nil {
 			return result, err
 		}
@@ -104,19 +170,16 @@ func getConnectedSitesFromNodesEdge(namespace string, clientset kubernetes.Inter
 			}
 		}
 	}
-	result.Direct = len(direct)
-	result.Indirect = len(indirect)
-	result.Total = result.Direct + result.Indirect
 	return result, nil
 }
 
-func getConnectedSitesFromNodesInterior(nodes []RouterNode, namespace string, clientset kubernetes.Interface, config *restclient.Config) (types.TransportConnectedSites, error) {
-	result := types.TransportConnectedSites{}
-	direct := make(map[string]bool)
-	indirect := make(map[string]bool)
+func getConnectedSitesFromNodesInterior(nodes []RouterNode, pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (*connectedSites, error) {
+	result := newConnectedSites()
+	direct := result.direct
+	indirect := result.indirect
 	for _, n := range nodes {
 		if n.NextHop == "(self)" {
-			edges, err := getEdgeConnectionsForInterior(n.Id, namespace, clientset, config)
+			edges, err := getEdgeConnectionsForInterior(n.Id, pod, namespace, clientset, config)
 			if err != nil {
 				return result, fmt.Errorf("Failed to check edge nodes for %s: %w", n.Id, err)
 			}
@@ -131,7 +194,7 @@ func getConnectedSitesFromNodesInterior(nodes []RouterNode, namespace string, cl
 	}
 	for _, n := range nodes {
 		if n.NextHop != "(self)" {
-			edges, err := getEdgeConnectionsForInterior(n.Id, namespace, clientset, config)
+			edges, err := getEdgeConnectionsForInterior(n.Id, pod, namespace, clientset, config)
 			if err != nil {
 				return result, fmt.Errorf("Failed to check edge nodes for %s: %w", n.Id, err)
 			}
@@ -150,28 +213,48 @@ func getConnectedSitesFromNodesInterior(nodes []RouterNode, namespace string, cl
 			}
 		}
 	}
-	result.Direct = len(direct)
-	result.Indirect = len(indirect)
-	result.Total = result.Direct + result.Indirect
 	return result, nil
 }
 
+func getConnectedSitesForPod(edge bool, pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (*connectedSites, error) {
+	if edge {
+		return getConnectedSitesFromNodesEdge(pod, namespace, clientset, config)
+	}
+	nodes, err := getNodesForRouter("", pod, namespace, clientset, config)
+	if err != nil {
+		return nil, err
+	}
+	return getConnectedSitesFromNodesInterior(nodes, pod, namespace, clientset, config)
+}
+
+// GetConnectedSites counts the sites connected to the local site,
+// aggregated across all ready router replicas. The replicas are
+// themselves distinct routers, so they are excluded from the count.
 func GetConnectedSites(edge bool, namespace string, clientset kubernetes.Interface, config *restclient.Config) (types.TransportConnectedSites, error) {
 	result := types.TransportConnectedSites{}
-	if edge {
-		return getConnectedSitesFromNodesEdge(namespace, clientset, config)
-	} else {
-		nodes, err := GetNodes(namespace, clientset, config)
-		if err == nil {
-			return getConnectedSitesFromNodesInterior(nodes, namespace, clientset, config)
-		} else {
+	pods, err := kube.GetReadyPods(namespace, clientset, "router")
+	if err != nil {
+		return result, err
+	}
+	sites := newConnectedSites()
+	local := map[string]bool{}
+	for _, pod := range pods {
+		localId, err := getLocalRouterId(pod.Name, namespace, clientset, config)
+		if err != nil {
+			return result, err
+		}
+		local[localId] = true
+		podSites, err := getConnectedSitesForPod(edge, pod.Name, namespace, clientset, config)
+		if err != nil {
 			return result, err
 		}
+		sites.merge(podSites)
 	}
+	return sites.toTransportConnectedSites(local), nil
 }
 
 func GetEdgeSitesForRouter(routerid string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (int, error) {
-	connections, err := getConnectionsForRouter(routerid, namespace, clientset, config)
+	connections, err := getConnectionsForRouter(routerid, "", namespace, clientset, config)
 
 	if err == nil {
 		count := 0
@@ -204,12 +287,12 @@ func get_query_for_router(typename string, routerid string) []string {
 }
 
 func GetNodes(namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]RouterNode, error) {
-	return getNodesForRouter("", namespace, clientset, config)
+	return getNodesForRouter("", "", namespace, clientset, config)
 }
 
-func getNodesForRouter(routerid, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]RouterNode, error) {
+func getNodesForRouter(routerid, pod, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]RouterNode, error) {
 	command := get_query_for_router("node", routerid)
-	buffer, err := router_exec(command, namespace, clientset, config)
+	buffer, err := router_exec(command, pod, namespace, clientset, config)
 	if err != nil {
 		return nil, err
 	} else {
@@ -234,7 +317,7 @@ func GetInterRouterOrEdgeConnection(host string, connections []Connection) *Conn
 }
 
 func GetConnections(namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
-	return getConnectionsForRouter("", namespace, clientset, config)
+	return getConnectionsForRouter("", "", namespace, clientset, config)
 }
 
 func filterSiteRouters(in []Connection) []Connection {
@@ -247,8 +330,8 @@ func filterSiteRouters(in []Connection) []Connection {
 	return results
 }
 
-func getEdgeUplinkConnections(namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
-	connections, err := GetConnections(namespace, clientset, config)
+func getEdgeUplinkConnections(pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
+	connections, err := getConnectionsForRouter("", pod, namespace, clientset, config)
 	if err != nil {
 		return nil, err
 	}
@@ -256,8 +339,8 @@ func getEdgeUplinkConnections(namespace string, clientset kubernetes.Interface,
 	return getEdgeConnections("out", connections)
 }
 
-func getEdgeConnectionsForInterior(routerid string, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
-	connections, err := getConnectionsForRouter(routerid, namespace, clientset, config)
+func getEdgeConnectionsForInterior(routerid string, pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
+	connections, err := getConnectionsForRouter(routerid, pod, namespace, clientset, config)
 	if err != nil {
 		return nil, err
 	}
@@ -275,9 +358,9 @@ func getEdgeConnections(direction string, connections []Connection) ([]Connectio
 	return result, nil
 }
 
-func getConnectionsForRouter(routerid string, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
+func getConnectionsForRouter(routerid string, pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) ([]Connection, error) {
 	command := get_query_for_router("connection", routerid)
-	buffer, err := router_exec(command, namespace, clientset, config)
+	buffer, err := router_exec(command, pod, namespace, clientset, config)
 	if err != nil {
 		return nil, err
 	} else {
@@ -292,9 +375,9 @@ func getConnectionsForRouter(routerid string, namespace string, clientset kubern
 	}
 }
 
-func getLocalRouterId(namespace string, clientset kubernetes.Interface, config *restclient.Config) (string, error) {
+func getLocalRouterId(pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (string, error) {
 	command := get_query("router")
-	buffer, err := router_exec(command, namespace, clientset, config)
+	buffer, err := router_exec(command, pod, namespace, clientset, config)
 	if err != nil {
 		return "", err
 	} else {
@@ -313,10 +396,44 @@ func getLocalRouterId(namespace string, clientset kubernetes.Interface, config *
 	}
 }
 
-func router_exec(command []string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (*bytes.Buffer, error) {
-	pod, err := kube.GetReadyPod(namespace, clientset, "router")
-	if err != nil {
-		return nil, err
+// router_exec runs the command in the router container of the named
+// pod, or of any ready router pod if no name is given
+func router_exec(command []string, pod string, namespace string, clientset kubernetes.Interface, config *restclient.Config) (*bytes.Buffer, error) {
+	if pod == "" {
+		ready, err := kube.GetReadyPod(namespace, clientset, "router")
+		if err != nil {
+			return nil, err
+		}
+		pod = ready.Name
+	}
+	return kube.ExecCommandInContainer(command, pod, "router", namespace, clientset, config)
+}
+
+// GetBridgeConfig returns the bridges a running router actually has,
+// for comparison with those configured for it
+func GetBridgeConfig(namespace string, clientset kubernetes.Interface, config *restclient.Config) (*BridgeConfig, error) {
+	result := NewBridgeConfig()
+	for _, typename := range []string{"tcpListener", "tcpConnector", "httpListener", "httpConnector"} {
+		buffer, err := router_exec(get_query(typename), "", namespace, clientset, config)
+		if err != nil {
+			return nil, err
+		}
+		records := []Record{}
+		if err := json.Unmarshal(buffer.Bytes(), &records); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s query: %w", typename, err)
+		}
+		for _, record := range records {
+			switch typename {
+			case "tcpListener":
+				result.AddTcpListener(asTcpEndpoint(record))
+			case "tcpConnector":
+				result.AddTcpConnector(asTcpEndpoint(record))
+			case "httpListener":
+				result.AddHttpListener(asHttpEndpoint(record))
+			case "httpConnector":
+				result.AddHttpConnector(asHttpEndpoint(record))
+			}
+		}
 	}
-	return kube.ExecCommandInContainer(command, pod.Name, "router", namespace, clientset, config)
+	return &result, nil
 }
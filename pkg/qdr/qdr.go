@@ -6,6 +6,7 @@ import (
 	"log"
 	"reflect"
 	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -86,6 +87,49 @@ func (r *RouterConfig) RemoveConnector(name string) (bool, Connector) {
 	}
 }
 
+// GetConnectorReplicaName returns the name of the connector with the
+// given index for a link, additional connectors being made to sites
+// whose router has multiple replicas
+func GetConnectorReplicaName(name string, index int) string {
+	if index == 0 {
+		return name
+	}
+	return name + "#" + strconv.Itoa(index)
+}
+
+// RemoveConnectorReplicas removes the additional connectors for the
+// named link, returning the number removed
+func (r *RouterConfig) RemoveConnectorReplicas(name string) int {
+	removed := 0
+	for key := range r.Connectors {
+		if strings.HasPrefix(key, name+"#") {
+			delete(r.Connectors, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// GetReplicaConnectors returns the connectors through which the
+// interior router replica with the given index links to each replica
+// with a lower index, through the headless service for that replica,
+// so that every pair of replicas is linked once
+func GetReplicaConnectors(index int, namespace string) map[string]Connector {
+	connectors := map[string]Connector{}
+	for i := 0; i < index; i++ {
+		connector := Connector{
+			Name:       types.GetReplicaConnectorName(i),
+			Role:       RoleInterRouter,
+			Host:       types.GetRouterReplicaServiceName(i) + "." + namespace,
+			Port:       strconv.Itoa(int(types.InterRouterListenerPort)),
+			Cost:       1,
+			SslProfile: types.InterRouterProfile,
+		}
+		connectors[connector.Name] = connector
+	}
+	return connectors
+}
+
 func (r *RouterConfig) IsEdge() bool {
 	return r.Metadata.Mode == ModeEdge
 }
@@ -113,6 +157,58 @@ func (r *RouterConfig) AddAddress(a Address) {
 	r.Addresses[a.Prefix] = a
 }
 
+// UpdateServiceAddresses replaces all non-reserved addresses with
+// those desired, returning true if anything changed
+func (r *RouterConfig) UpdateServiceAddresses(desired AddressMap) bool {
+	changed := false
+	for prefix, actual := range r.Addresses {
+		if IsReservedPrefix(prefix) {
+			continue
+		}
+		if d, ok := desired[prefix]; !ok || !d.Equivalent(actual) {
+			delete(r.Addresses, prefix)
+			changed = true
+		}
+	}
+	for prefix, d := range desired {
+		if IsReservedPrefix(prefix) {
+			continue
+		}
+		if _, ok := r.Addresses[prefix]; !ok {
+			r.Addresses[prefix] = d
+			changed = true
+		}
+	}
+	return changed
+}
+
+func IsServiceSslProfile(name string) bool {
+	return strings.HasPrefix(name, types.ServiceTlsProfilePrefix) || strings.HasPrefix(name, types.ServiceTrustProfilePrefix)
+}
+
+// UpdateServiceSslProfiles replaces the ssl profiles used by service
+// bridges with those desired, leaving those used by the site itself
+// untouched; returns true if anything changed
+func (r *RouterConfig) UpdateServiceSslProfiles(desired map[string]SslProfile) bool {
+	changed := false
+	for name, actual := range r.SslProfiles {
+		if !IsServiceSslProfile(name) {
+			continue
+		}
+		if d, ok := desired[name]; !ok || d != actual {
+			delete(r.SslProfiles, name)
+			changed = true
+		}
+	}
+	for name, d := range desired {
+		if _, ok := r.SslProfiles[name]; !ok {
+			r.SslProfiles[name] = d
+			changed = true
+		}
+	}
+	return changed
+}
+
 func (r *RouterConfig) AddTcpConnector(e TcpEndpoint) {
 	r.Bridges.AddTcpConnector(e)
 }
@@ -225,16 +321,34 @@ const (
 )
 
 type Address struct {
+	Name         string `json:"name,omitempty"`
 	Prefix       string `json:"prefix,omitempty"`
 	Distribution string `json:"distribution,omitempty"`
 }
 
+type AddressMap map[string]Address
+
+// ReservedPrefixes are configured by the site itself and are never
+// managed on behalf of individual services
+var ReservedPrefixes = []string{"mc"}
+
+func IsReservedPrefix(prefix string) bool {
+	for _, p := range ReservedPrefixes {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
+
 type TcpEndpoint struct {
-	Name    string `json:"name,omitempty"`
-	Host    string `json:"host,omitempty"`
-	Port    string `json:"port,omitempty"`
-	Address string `json:"address,omitempty"`
-	SiteId  string `json:"siteId,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Host           string `json:"host,omitempty"`
+	Port           string `json:"port,omitempty"`
+	Address        string `json:"address,omitempty"`
+	SiteId         string `json:"siteId,omitempty"`
+	SslProfile     string `json:"sslProfile,omitempty"`
+	VerifyHostname *bool  `json:"verifyHostname,omitempty"`
 }
 
 type HttpEndpoint struct {
@@ -247,6 +361,8 @@ type HttpEndpoint struct {
 	Aggregation     string `json:"aggregation,omitempty"`
 	EventChannel    bool   `json:"eventChannel,omitempty"`
 	HostOverride    string `json:"hostOverride,omitempty"`
+	SslProfile      string `json:"sslProfile,omitempty"`
+	VerifyHostname  *bool  `json:"verifyHostname,omitempty"`
 }
 
 func convert(from interface{}, to interface{}) error {
@@ -513,6 +629,49 @@ func GetBridgeConfigFromConfigMap(configmap *corev1.ConfigMap) (*BridgeConfig, e
 	return &routerConfig.Bridges, nil
 }
 
+type AddressDifference struct {
+	Deleted []string
+	Added   []Address
+}
+
+func (a Address) Equivalent(b Address) bool {
+	return a.Prefix == b.Prefix && a.Distribution == b.Distribution
+}
+
+// Difference returns the changes needed to move from a to b; deleted
+// entries are identified by name as that is how the router knows them
+func (a AddressMap) Difference(b AddressMap) AddressDifference {
+	result := AddressDifference{}
+	for key, v1 := range b {
+		v2, ok := a[key]
+		if !ok {
+			result.Added = append(result.Added, v1)
+		} else if !v1.Equivalent(v2) {
+			result.Deleted = append(result.Deleted, v2.Name)
+			result.Added = append(result.Added, v1)
+		}
+	}
+	for key, v1 := range a {
+		_, ok := b[key]
+		if !ok {
+			result.Deleted = append(result.Deleted, v1.Name)
+		}
+	}
+	return result
+}
+
+func (a *AddressDifference) Empty() bool {
+	return len(a.Deleted) == 0 && len(a.Added) == 0
+}
+
+func GetAddressConfigFromConfigMap(configmap *corev1.ConfigMap) (AddressMap, error) {
+	routerConfig, err := GetRouterConfigFromConfigMap(configmap)
+	if err != nil {
+		return nil, err
+	}
+	return AddressMap(routerConfig.Addresses), nil
+}
+
 type TcpEndpointDifference struct {
 	Deleted []string
 	Added   []TcpEndpoint
@@ -553,7 +712,8 @@ func (a TcpEndpointMap) Difference(b TcpEndpointMap) TcpEndpointDifference {
 func (a HttpEndpoint) Equivalent(b HttpEndpoint) bool {
 	if a.Host != b.Host || a.Port != b.Port || a.Address != b.Address ||
 		a.SiteId != b.SiteId || a.Aggregation != b.Aggregation ||
-		a.EventChannel != b.EventChannel || a.HostOverride != b.HostOverride {
+		a.EventChannel != b.EventChannel || a.HostOverride != b.HostOverride ||
+		a.SslProfile != b.SslProfile || !reflect.DeepEqual(a.VerifyHostname, b.VerifyHostname) {
 		return false
 	}
 	if a.ProtocolVersion == HttpVersion2 && b.ProtocolVersion != HttpVersion2 {
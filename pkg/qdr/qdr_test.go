@@ -83,6 +83,139 @@ func TestAddAddress(t *testing.T) {
 	}
 }
 
+func TestUpdateServiceAddresses(t *testing.T) {
+	config := InitialConfig("foo", "bar", true)
+	config.AddAddress(Address{
+		Prefix:       "mc",
+		Distribution: DistributionMulticast,
+	})
+	config.AddAddress(Address{
+		Name:         "stale",
+		Prefix:       "stale",
+		Distribution: "closest",
+	})
+	config.AddAddress(Address{
+		Name:         "db",
+		Prefix:       "db",
+		Distribution: "balanced",
+	})
+	desired := AddressMap{
+		"db": Address{
+			Name:         "db",
+			Prefix:       "db",
+			Distribution: "closest",
+		},
+		"web": Address{
+			Name:         "web",
+			Prefix:       "web",
+			Distribution: "balanced",
+		},
+	}
+	if !config.UpdateServiceAddresses(desired) {
+		t.Errorf("Expected addresses to be changed")
+	}
+	if config.Addresses["mc"].Distribution != "multicast" {
+		t.Errorf("Expected reserved address to be retained")
+	}
+	if _, ok := config.Addresses["stale"]; ok {
+		t.Errorf("Expected address 'stale' to be removed")
+	}
+	if config.Addresses["db"].Distribution != "closest" {
+		t.Errorf("Expected distribution %q but got %q", DistributionClosest, config.Addresses["db"].Distribution)
+	}
+	if config.Addresses["web"].Distribution != "balanced" {
+		t.Errorf("Expected distribution %q but got %q", "balanced", config.Addresses["web"].Distribution)
+	}
+	if config.UpdateServiceAddresses(desired) {
+		t.Errorf("Expected no change on second update")
+	}
+}
+
+func TestUpdateServiceSslProfiles(t *testing.T) {
+	config := InitialConfig("foo", "bar", true)
+	config.AddSslProfile(SslProfile{Name: "skupper-amqps"})
+	config.AddSslProfile(SslProfile{Name: "skupper-tls-stale"})
+	desired := map[string]SslProfile{
+		"skupper-tls-db": SslProfile{
+			Name:     "skupper-tls-db",
+			CertFile: "/etc/qpid-dispatch-certs/skupper-tls-db/tls.crt",
+		},
+		"skupper-trust-my-ca": SslProfile{
+			Name:       "skupper-trust-my-ca",
+			CaCertFile: "/etc/qpid-dispatch-certs/skupper-trust-my-ca/ca.crt",
+		},
+	}
+	if !config.UpdateServiceSslProfiles(desired) {
+		t.Errorf("Expected ssl profiles to be changed")
+	}
+	if _, ok := config.SslProfiles["skupper-amqps"]; !ok {
+		t.Errorf("Expected site ssl profile to be retained")
+	}
+	if _, ok := config.SslProfiles["skupper-tls-stale"]; ok {
+		t.Errorf("Expected ssl profile 'skupper-tls-stale' to be removed")
+	}
+	for name, profile := range desired {
+		if config.SslProfiles[name] != profile {
+			t.Errorf("Expected ssl profile %v but got %v", profile, config.SslProfiles[name])
+		}
+	}
+	if config.UpdateServiceSslProfiles(desired) {
+		t.Errorf("Expected no change on second update")
+	}
+}
+
+func TestHttpEndpointTlsEquivalence(t *testing.T) {
+	verify := true
+	a := HttpEndpoint{Name: "db@db", Host: "db", Port: "8443", Address: "db"}
+	b := a
+	b.SslProfile = "skupper-trust-skupper-service-ca"
+	b.VerifyHostname = &verify
+	if a.Equivalent(b) {
+		t.Errorf("Expected endpoints with different TLS settings to differ")
+	}
+	other := true
+	c := b
+	c.VerifyHostname = &other
+	if !b.Equivalent(c) {
+		t.Errorf("Expected endpoints with the same TLS settings to be equivalent")
+	}
+}
+
+func TestAddressDifference(t *testing.T) {
+	actual := AddressMap{
+		"a": Address{Name: "a", Prefix: "a", Distribution: "closest"},
+		"b": Address{Name: "b", Prefix: "b", Distribution: "closest"},
+		"c": Address{Name: "c", Prefix: "c", Distribution: "balanced"},
+	}
+	desired := AddressMap{
+		"a": Address{Name: "a", Prefix: "a", Distribution: "closest"},
+		"b": Address{Name: "b", Prefix: "b", Distribution: "balanced"},
+		"d": Address{Name: "d", Prefix: "d", Distribution: "balanced"},
+	}
+	diff := actual.Difference(desired)
+	if diff.Empty() {
+		t.Errorf("Expected differences")
+	}
+	deleted := map[string]bool{}
+	for _, name := range diff.Deleted {
+		deleted[name] = true
+	}
+	if !reflect.DeepEqual(deleted, map[string]bool{"b": true, "c": true}) {
+		t.Errorf("Unexpected deletions %v", diff.Deleted)
+	}
+	added := map[string]string{}
+	for _, a := range diff.Added {
+		added[a.Prefix] = a.Distribution
+	}
+	if !reflect.DeepEqual(added, map[string]string{"b": "balanced", "d": "balanced"}) {
+		t.Errorf("Unexpected additions %v", diff.Added)
+	}
+	same := desired.Difference(desired)
+	if !same.Empty() {
+		t.Errorf("Expected no differences but got %v", same)
+	}
+}
+
 func TestMarshalUnmarshalRouterConfig(t *testing.T) {
 	input := RouterConfig{
 		Metadata: RouterMetadata{
@@ -342,3 +475,53 @@ func TestFailedConvert(t *testing.T) {
 		t.Errorf("Expected error for invalid conversion")
 	}
 }
+
+func TestConnectorReplicas(t *testing.T) {
+	config := InitialConfig("foo", "bar", false)
+	for i := 0; i < 3; i++ {
+		config.AddConnector(Connector{Name: GetConnectorReplicaName("conn1", i)})
+	}
+	config.AddConnector(Connector{Name: "conn10"})
+	if _, ok := config.Connectors["conn1#2"]; !ok {
+		t.Errorf("Expected connector conn1#2, got %v", config.Connectors)
+	}
+	if removed := config.RemoveConnectorReplicas("conn1"); removed != 2 {
+		t.Errorf("Expected 2 replicas to be removed, got %d", removed)
+	}
+	if len(config.Connectors) != 2 {
+		t.Errorf("Expected conn1 and conn10 to remain, got %v", config.Connectors)
+	}
+}
+
+func TestGetReplicaConnectors(t *testing.T) {
+	if connectors := GetReplicaConnectors(0, "skupper"); len(connectors) != 0 {
+		t.Errorf("Expected the first replica to make no links, got %v", connectors)
+	}
+	connectors := GetReplicaConnectors(2, "skupper")
+	if len(connectors) != 2 {
+		t.Fatalf("Expected a link to each earlier replica, got %v", connectors)
+	}
+	connector, ok := connectors["skupper-replica-1"]
+	if !ok {
+		t.Fatalf("Expected connector skupper-replica-1, got %v", connectors)
+	}
+	if connector.Host != "skupper-router-1.skupper" || connector.Port != "55671" || connector.Role != RoleInterRouter || connector.SslProfile != "skupper-internal" {
+		t.Errorf("Unexpected connector to replica 1: %v", connector)
+	}
+}
+
+func TestConnectedSitesMerge(t *testing.T) {
+	a := newConnectedSites()
+	a.direct["router.node/remote1"] = true
+	a.indirect["router.node/remote2"] = true
+	a.indirect["router.node/local-b"] = true
+	b := newConnectedSites()
+	b.direct["router.node/remote2"] = true
+	b.direct["router.node/local-a"] = true
+	b.indirect["router.node/remote3"] = true
+	a.merge(b)
+	result := a.toTransportConnectedSites(map[string]bool{"local-a": true, "local-b": true})
+	if result.Direct != 2 || result.Indirect != 1 || result.Total != 3 {
+		t.Errorf("Expected 2 direct and 1 indirect sites, got %#v", result)
+	}
+}